@@ -14,4 +14,66 @@ window.onclick = function (event) {
     if (event.target === modal) {
         closeModal();
     }
-}
\ No newline at end of file
+}
+
+// Live updates: changes made in other tabs or on other machines arrive as Server-Sent Events.
+// A changed task refreshes its row, anything else refreshes the whole table.
+function connectLiveUpdates() {
+    if (!window.EventSource || !document.getElementById('cards-table')) {
+        return;
+    }
+
+    let tableTimer = null;
+    const refreshTable = () => {
+        clearTimeout(tableTimer);
+        tableTimer = setTimeout(() => {
+            htmx.ajax('GET', '/view/tasks-table', { target: '#cards-table', swap: 'outerHTML' });
+        }, 200);
+    };
+
+    const source = new EventSource('/events');
+    source.addEventListener('task-saved', (event) => {
+        const row = document.getElementById('task-row-' + event.data);
+        if (row) {
+            htmx.ajax('GET', '/view/task-row/' + encodeURIComponent(event.data), { target: row, swap: 'outerHTML' });
+        } else {
+            refreshTable();
+        }
+    });
+    source.addEventListener('task-deleted', (event) => {
+        const row = document.getElementById('task-row-' + event.data);
+        if (row) {
+            row.remove();
+        }
+    });
+    source.addEventListener('tasks-changed', refreshTable);
+    source.addEventListener('tags-changed', refreshTable);
+    source.addEventListener('task-assigned', (event) => showAssignedNotification(event.data));
+}
+
+// A toast for a task someone else assigned to the current user; a click opens the task
+function showAssignedNotification(taskId) {
+    const container = document.getElementById('notifications');
+    if (!container) {
+        return;
+    }
+    const note = document.createElement('div');
+    note.className = 'notification';
+    note.textContent = 'A task was assigned to you';
+    note.addEventListener('click', () => {
+        note.remove();
+        htmx.ajax('GET', '/view/task/' + encodeURIComponent(taskId), { target: '#modal-card', swap: 'outerHTML' });
+    });
+    container.appendChild(note);
+    setTimeout(() => note.remove(), 10000);
+}
+
+// htmx does not swap error responses; the import form renders its errors into the modal
+document.addEventListener('htmx:beforeSwap', (event) => {
+    if (event.detail.xhr.status === 400 && event.detail.elt.closest('#import-form')) {
+        event.detail.shouldSwap = true;
+        event.detail.isError = false;
+    }
+});
+
+document.addEventListener('DOMContentLoaded', connectLiveUpdates);
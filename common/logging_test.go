@@ -0,0 +1,61 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func captureLogs(t *testing.T, level, format string) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var buf bytes.Buffer
+	if err := InitLogging(&buf, level, format); err != nil {
+		t.Fatalf("InitLogging failed: %v", err)
+	}
+	return &buf
+}
+
+func TestInitLogging_RequestId(t *testing.T) {
+	buf := captureLogs(t, "info", "json")
+	ctx := ContextWithRequestId(context.Background(), "req-1")
+	slog.InfoContext(ctx, "saved", "task_id", "t1")
+	slog.Info("no request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var first, second map[string]any
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	if first["request_id"] != "req-1" || first["task_id"] != "t1" || first["msg"] != "saved" {
+		t.Errorf("expected the request id on the line, got %v", first)
+	}
+	if _, ok := second["request_id"]; ok {
+		t.Errorf("expected no request id outside of requests, got %v", second)
+	}
+}
+
+func TestInitLogging_Level(t *testing.T) {
+	buf := captureLogs(t, "warn", "text")
+	slog.Info("hidden")
+	slog.Warn("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "level=WARN msg=shown") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestInitLogging_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InitLogging(&buf, "loud", "text"); err == nil {
+		t.Errorf("expected an invalid level to be rejected")
+	}
+	if err := InitLogging(&buf, "info", "xml"); err == nil {
+		t.Errorf("expected an invalid format to be rejected")
+	}
+}
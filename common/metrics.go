@@ -0,0 +1,253 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HTTP_BUCKETS are the upper bounds, in seconds, of the request latency histograms
+var HTTP_BUCKETS = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// QUERY_BUCKETS are the upper bounds, in seconds, of the database query histograms
+var QUERY_BUCKETS = []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// metric is a family of samples written in the Prometheus text format
+type metric interface {
+	name() string
+	writeTo(ctx context.Context, w io.Writer) error
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   []metric
+)
+
+func registerMetric(m metric) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	for _, existing := range metrics {
+		if existing.name() == m.name() {
+			panic(fmt.Sprintf("metric %v is already registered", m.name()))
+		}
+	}
+	metrics = append(metrics, m)
+}
+
+// WriteMetrics writes all the registered metrics in the Prometheus text format, sorted by name;
+// the gauges are collected with ctx
+func WriteMetrics(ctx context.Context, w io.Writer) error {
+	metricsMu.Lock()
+	registered := slices.Clone(metrics)
+	metricsMu.Unlock()
+	sort.Slice(registered, func(i, j int) bool { return registered[i].name() < registered[j].name() })
+
+	for _, m := range registered {
+		if err := m.writeTo(ctx, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterVec counts events by the values of its labels
+type CounterVec struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{metricName: name, help: help, labels: labels, values: map[string]float64{}}
+	registerMetric(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	key := seriesKey(c.labels, labelValues)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) writeTo(ctx context.Context, w io.Writer) error {
+	c.mu.Lock()
+	keys := sortedKeys(c.values)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = c.metricName + key + " " + formatValue(c.values[key])
+	}
+	c.mu.Unlock()
+	return writeFamily(w, c.metricName, c.help, "counter", lines)
+}
+
+// HistogramVec samples observations, e.g. latencies, in buckets by the values of its labels
+type HistogramVec struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{metricName: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogram{}}
+	registerMetric(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	i := sort.SearchFloat64s(h.buckets, value)
+	s.counts[i]++
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) writeTo(ctx context.Context, w io.Writer) error {
+	h.mu.Lock()
+	keys := sortedKeys(h.series)
+	var lines []string
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			lines = append(lines, h.metricName+"_bucket"+withLabel(key, "le", le)+" "+strconv.FormatUint(cumulative, 10))
+		}
+		lines = append(lines,
+			h.metricName+"_sum"+key+" "+formatValue(s.sum),
+			h.metricName+"_count"+key+" "+strconv.FormatUint(s.count, 10),
+		)
+	}
+	h.mu.Unlock()
+	return writeFamily(w, h.metricName, h.help, "histogram", lines)
+}
+
+// Sample is a value of a gauge with the values of its labels
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose samples are collected on every scrape
+type GaugeFunc struct {
+	metricName string
+	help       string
+	labels     []string
+	collect    func(ctx context.Context) ([]Sample, error)
+}
+
+// NewGaugeFunc registers a gauge collected by fn; a failing fn leaves the gauge out of the scrape
+func NewGaugeFunc(name, help string, fn func(ctx context.Context) ([]Sample, error), labels ...string) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, labels: labels, collect: fn}
+	registerMetric(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) writeTo(ctx context.Context, w io.Writer) error {
+	samples, err := g.collect(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to collect metric", "metric", g.metricName, "error", err)
+		return nil
+	}
+	lines := make([]string, len(samples))
+	for i, s := range samples {
+		lines[i] = g.metricName + seriesKey(g.labels, s.LabelValues) + " " + formatValue(s.Value)
+	}
+	return writeFamily(w, g.metricName, g.help, "gauge", lines)
+}
+
+func writeFamily(w io.Writer, name, help, kind string, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesKey formats the labels of a series as written after the metric name, e.g. {method="GET"}
+func seriesKey(labels, values []string) string {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labels), len(values)))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label + "=" + quoteLabelValue(values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel appends a label to the labels of a series
+func withLabel(key, label, value string) string {
+	pair := label + "=" + quoteLabelValue(value)
+	if key == "" {
+		return "{" + pair + "}"
+	}
+	return key[:len(key)-1] + "," + pair + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabelValue(v string) string {
+	return `"` + labelValueEscaper.Replace(v) + `"`
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func writtenMetrics(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	if err := WriteMetrics(context.Background(), &b); err != nil {
+		t.Fatalf("WriteMetrics failed: %v", err)
+	}
+	return b.String()
+}
+
+func TestWriteMetrics(t *testing.T) {
+	counter := NewCounterVec("test_events_total", "Events.", "kind")
+	counter.Inc("a")
+	counter.Inc("a")
+	counter.Inc(`quote"d`)
+	histogram := NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+	histogram.Observe(0.05, "load")
+	histogram.Observe(0.1, "load")
+	histogram.Observe(3, "load")
+	NewGaugeFunc("test_items", "Items.", func(ctx context.Context) ([]Sample, error) {
+		return []Sample{{Value: 7}}, nil
+	})
+	NewGaugeFunc("test_failing", "Fails.", func(ctx context.Context) ([]Sample, error) {
+		return nil, errors.New("no source")
+	})
+
+	out := writtenMetrics(t)
+	for _, expected := range []string{
+		"# HELP test_events_total Events.\n# TYPE test_events_total counter\n" +
+			`test_events_total{kind="a"} 2` + "\n" +
+			`test_events_total{kind="quote\"d"} 1` + "\n",
+		"# TYPE test_duration_seconds histogram\n" +
+			`test_duration_seconds_bucket{op="load",le="0.1"} 2` + "\n" +
+			`test_duration_seconds_bucket{op="load",le="1"} 2` + "\n" +
+			`test_duration_seconds_bucket{op="load",le="+Inf"} 3` + "\n" +
+			`test_duration_seconds_sum{op="load"} 3.15` + "\n" +
+			`test_duration_seconds_count{op="load"} 3` + "\n",
+		"# TYPE test_items gauge\ntest_items 7\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "test_failing") {
+		t.Errorf("expected a failing gauge to be left out, got\n%s", out)
+	}
+	if strings.Index(out, "test_duration_seconds") > strings.Index(out, "test_events_total") {
+		t.Errorf("expected the metrics sorted by name, got\n%s", out)
+	}
+}
+
+func TestRegisterMetric_Duplicate(t *testing.T) {
+	NewCounterVec("test_duplicate_total", "Once.")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a metric twice to panic")
+		}
+	}()
+	NewCounterVec("test_duplicate_total", "Twice.")
+}
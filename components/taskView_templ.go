@@ -12,7 +12,7 @@ import (
 	"github.com/inaryzen/priotasks/models"
 )
 
-func TasksView(cards []models.Task, st models.Settings, allTags []models.TaskTag, totalTime string) templ.Component {
+func TasksView(cards []models.Task, st models.Settings, allTags []models.TaskTag, projects []models.Project, totalTime string) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -37,7 +37,7 @@ func TasksView(cards []models.Task, st models.Settings, allTags []models.TaskTag
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = TasksViewBody(cards, st, allTags, totalTime).Render(ctx, templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = TasksViewBody(cards, st, allTags, projects, totalTime).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
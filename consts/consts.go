@@ -13,6 +13,8 @@ const (
 	FILTER_SEARCH                = "filter-search"
 	FILTER_LIMIT_ENABLE          = "filter-limit-enable"
 	FILTER_LIMIT_COUNT           = "filter-limit-count"
+	FILTER_ASSIGNED_TO_ME        = "filter-assigned-to-me"
+	FILTER_PROJECT               = "filter-project"
 
 	PREPARED_QUERY_RESET                    = "prepared-query-clear"
 	PREPARED_QUERY_COMPLETED_YESTERDAY      = "prepared-query-completed-yesterday"
@@ -21,18 +23,96 @@ const (
 	PREPARED_QUERY_COMPLETED_LAST_TWO_WEEKS = "prepared-query-completed-last-two-weeks"
 	PREPARED_QUERY_COMPLETED_LAST_WEEK      = "prepared-query-completed-last-week"
 
-	COMPLETED_SORT_NAME  = "completed-sort"
-	SORT_COLUMN_NAME     = "sort-column"
-	SORT_DIRECTION_NAME  = "sort-direction"
-	MODAL_TASK_COST_NAME = "modal-task-cost"
+	COMPLETED_SORT_NAME      = "completed-sort"
+	SORT_COLUMN_NAME         = "sort-column"
+	SORT_DIRECTION_NAME      = "sort-direction"
+	MODAL_TASK_COST_NAME     = "modal-task-cost"
+	MODAL_TASK_VERSION_NAME  = "card-version"
+	MODAL_TASK_PROJECT_NAME  = "modal-task-project"
+	MODAL_TASK_ASSIGNEE_NAME = "modal-task-assignee"
 
-	URL_TOGGLE_SORT_TABLE = "/toggle-sort-table"
-	URL_TASKS             = "/tasks"
-	URL_TASKS_ID          = "/tasks/{id}"
-	URL_TASKS_EXPORT_YAML = "/tasks/export/yaml"
+	URL_TOGGLE_SORT_TABLE     = "/toggle-sort-table"
+	URL_TASKS                 = "/tasks"
+	URL_TASKS_ID              = "/tasks/{id}"
+	URL_TASKS_EXPORT_YAML     = "/tasks/export/yaml"
+	URL_TASKS_IMPORT_YAML     = "/tasks/import/yaml"
+	URL_TASKS_EXPORT_CSV      = "/tasks/export/csv"
+	URL_TASKS_IMPORT_CSV      = "/tasks/import/csv"
+	URL_TASKS_EXPORT_TODO_TXT = "/tasks/export/todotxt"
+	URL_TASKS_IMPORT_TODO_TXT = "/tasks/import/todotxt"
+	URL_TASKS_EXPORT_MARKDOWN = "/tasks/export/markdown"
+	URL_TASKS_EXPORT_TW       = "/tasks/export/taskwarrior"
+	URL_TASKS_IMPORT_TW       = "/tasks/import/taskwarrior"
+	URL_REPORT                = "/report"
+	URL_REPORT_MARKDOWN       = "/report/markdown"
+	URL_EXPORT_DUMP           = "/export/dump/{encoding}"
+	URL_CALENDAR_ICS          = "/calendar/tasks.ics"
+	URL_EVENTS                = "/events"
+	URL_CALDAV                = "/caldav/"
+	URL_CALDAV_TASKS          = "/caldav/tasks/"
+	URL_WELL_KNOWN_CALDAV     = "/.well-known/caldav"
+	URL_WEBHOOKS              = "/webhooks"
+	URL_WEBHOOKS_DELETE       = "/webhooks/{id}/delete"
+	URL_LOGIN                 = "/login"
+	URL_LOGOUT                = "/logout"
+	URL_SETTINGS_TOKENS       = "/settings/tokens"
+	URL_SETTINGS_TOKEN_REVOKE = "/settings/tokens/{id}/revoke"
+	URL_SETTINGS_TIME_ZONE    = "/settings/time-zone"
+	URL_ADMIN_USERS           = "/admin/users"
+	URL_ADMIN_USER_PASSWORD   = "/admin/users/{name}/password"
+	URL_ADMIN_USER_ROLE       = "/admin/users/{name}/role"
+	URL_ADMIN_USER_DELETE     = "/admin/users/{name}/delete"
+	URL_PROJECTS              = "/projects"
+	URL_PROJECT_MEMBERS       = "/projects/{id}/members"
+	URL_PROJECT_MEMBER_DELETE = "/projects/{id}/members/{name}/delete"
+	URL_PROJECT_DELETE        = "/projects/{id}/delete"
+	URL_VIEW_TASK_ASSIGNEES   = "/view/task-assignees"
+	URL_METRICS               = "/metrics"
+	URL_HEALTHZ               = "/healthz"
+	URL_READYZ                = "/readyz"
+
+	SESSION_COOKIE_NAME = "priotasks_session"
+	CSRF_COOKIE_NAME    = "priotasks_csrf"
+	CSRF_HEADER_NAME    = "X-CSRF-Token"
+	REQUEST_ID_HEADER   = "X-Request-Id"
+	PARAM_CSRF_TOKEN    = "csrf-token"
 
 	DEFAULT_TIME_FORMAT = "2006-01-02 15:04:05"
 	DEFAULT_DATE_FORMAT = "2006-01-02"
+	// STORAGE_TIME_FORMAT is RFC 3339, the databases store the times in UTC
+	STORAGE_TIME_FORMAT = "2006-01-02T15:04:05Z07:00"
+
+	INPUT_NAME_NEW_TAG        = "input-name-new-tag"
+	INPUT_NAME_IMPORT_FILE    = "input-name-import-file"
+	INPUT_NAME_IMPORT_DRY_RUN = "input-name-import-dry-run"
+
+	MAX_IMPORT_SIZE = 32 << 20
+
+	WEBHOOK_LOG_PAGE_SIZE = 100
+
+	PARAM_PREPARED_QUERY = "prepared-query"
+	PARAM_REPORT_FROM    = "report-from"
+	PARAM_REPORT_TO      = "report-to"
+	PARAM_ICAL_SELECT    = "select"
+	PARAM_ICAL_TAG       = "tag"
+	PARAM_ICAL_EVENTS    = "events"
+	PARAM_ICAL_START     = "start"
+	PARAM_WEBHOOK_URL    = "webhook-url"
+	PARAM_WEBHOOK_EVENT  = "webhook-event"
+	PARAM_WEBHOOK_TAG    = "webhook-tag"
+	PARAM_WEBHOOK_SECRET = "webhook-secret"
+	PARAM_LOGIN_USERNAME = "username"
+	PARAM_LOGIN_PASSWORD = "password"
+	PARAM_LOGIN_NEXT     = "next"
+	PARAM_TOKEN_NAME     = "token-name"
+	PARAM_TOKEN_SCOPE    = "token-scope"
+	PARAM_TIME_ZONE      = "time-zone"
+	PARAM_USER_ADMIN     = "admin"
+	PARAM_PROJECT_NAME   = "project-name"
+	PARAM_PROJECT_MEMBER = "member"
 
-	INPUT_NAME_NEW_TAG = "input-name-new-tag"
+	ICAL_SELECT_OPEN    = "open"
+	ICAL_SELECT_PLANNED = "planned"
+	ICAL_SELECT_WIP     = "wip"
+	ICAL_SELECT_ALL     = "all"
 )
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+const API_TOKENS_COLUMNS = "id, name, token_hash, scope, username, created, last_used"
+
+func (d *DbSQLite) initApiTokens() {
+	slog.Debug("initApiTokens")
+	d.addApiTokensTable()
+}
+
+func (d *DbSQLite) addApiTokensTable() {
+	id := "add_api_tokens"
+	if !d.MigrationExists(context.Background(), id) {
+		apiTokensSql := `
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			token_hash TEXT UNIQUE,
+			scope TEXT,
+			username TEXT,
+			created TEXT,
+			last_used TEXT,
+			FOREIGN KEY (username) REFERENCES users(username) ON DELETE CASCADE
+		)
+		`
+		_, err := d.instance.Exec(apiTokensSql)
+		if err != nil {
+			panic(err)
+		}
+
+		d.RecordMigration(context.Background(), id)
+	}
+}
+
+func scanApiToken(row rowScanner) (models.ApiToken, error) {
+	var t models.ApiToken
+	var scope, created, lastUsed string
+	err := row.Scan(&t.Id, &t.Name, &t.TokenHash, &scope, &t.Username, &created, &lastUsed)
+	if err != nil {
+		return t, err
+	}
+	t.Scope = models.ApiTokenScope(scope)
+	if t.Created, err = parseStorageTime(created); err != nil {
+		return t, fmt.Errorf("invalid created of api token %v: %w", t.Id, err)
+	}
+	if lastUsed != "" {
+		if t.LastUsed, err = parseStorageTime(lastUsed); err != nil {
+			return t, fmt.Errorf("invalid last_used of api token %v: %w", t.Id, err)
+		}
+	}
+	return t, nil
+}
+
+// ApiTokens returns the tokens of the user, the newest first
+func (d *DbSQLite) ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error) {
+	defer observeQuery("ApiTokens", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+API_TOKENS_COLUMNS+" FROM api_tokens WHERE username = ? ORDER BY created DESC, id", username)
+	if err != nil {
+		return nil, fmt.Errorf("ApiTokens: failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.ApiToken
+	for rows.Next() {
+		t, err := scanApiToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ApiTokens: %w", err)
+		}
+		result = append(result, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ApiTokens: error iterating api tokens: %w", err)
+	}
+	return result, nil
+}
+
+func (d *DbSQLite) FindApiTokenByHash(ctx context.Context, tokenHash string) (models.ApiToken, error) {
+	defer observeQuery("FindApiTokenByHash", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+API_TOKENS_COLUMNS+" FROM api_tokens WHERE token_hash = ?", tokenHash)
+	t, err := scanApiToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrNotFound
+	}
+	if err != nil {
+		return t, fmt.Errorf("FindApiTokenByHash: %w", err)
+	}
+	return t, nil
+}
+
+func (d *DbSQLite) SaveApiToken(ctx context.Context, t models.ApiToken) error {
+	defer observeQuery("SaveApiToken", time.Now())
+	sql := "INSERT INTO api_tokens (" + API_TOKENS_COLUMNS + ") VALUES (?, ?, ?, ?, ?, ?, ?)"
+	lastUsed := ""
+	if t.IsUsed() {
+		lastUsed = storageTime(t.LastUsed)
+	}
+	args := []any{
+		t.Id,
+		t.Name,
+		t.TokenHash,
+		string(t.Scope),
+		t.Username,
+		storageTime(t.Created),
+		lastUsed,
+	}
+	logQuery(ctx, "SaveApiToken", sql, []any{t.Id, t.Name, t.Scope, t.Username})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveApiToken: failed to save api token %v: %w", t.Id, err)
+	}
+	return nil
+}
+
+// DeleteApiToken revokes the token of the user
+func (d *DbSQLite) DeleteApiToken(ctx context.Context, username, tokenId string) error {
+	defer observeQuery("DeleteApiToken", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM api_tokens WHERE id = ? AND username = ?", tokenId, username)
+	if err != nil {
+		return fmt.Errorf("DeleteApiToken: failed to delete api token %v: %w", tokenId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteApiToken: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbSQLite) TouchApiToken(ctx context.Context, tokenId string, used time.Time) error {
+	defer observeQuery("TouchApiToken", time.Now())
+	_, err := d.conn(ctx).ExecContext(ctx, "UPDATE api_tokens SET last_used = ? WHERE id = ?", storageTime(used), tokenId)
+	if err != nil {
+		return fmt.Errorf("TouchApiToken: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,899 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+// conformanceTests are the behaviors every implementation of Db must share: the services
+// are tested on MemDB and run on DbSQLite or DbPostgres, so they must not be able to tell
+// them apart
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, d Db)
+}{
+	{"FindTasks_Filters", testFindTasksFilters},
+	{"FindTasks_Sorting", testFindTasksSorting},
+	{"FindTasks_Limit", testFindTasksLimit},
+	{"FindTasks_TimeZones", testFindTasksTimeZones},
+	{"Tasks_RoundTrip", testTasksRoundTrip},
+	{"Tasks_Delete", testTasksDelete},
+	{"TaskCounts", testTaskCounts},
+	{"Tags", testTags},
+	{"TasksTags", testTasksTags},
+	{"ForEach", testForEach},
+	{"Settings", testSettings},
+	{"Migrations", testMigrations},
+	{"Users_Sessions", testUsersSessions},
+	{"ApiTokens", testApiTokens},
+	{"Projects", testProjects},
+	{"TransferOwnership", testTransferOwnership},
+	{"Webhooks", testWebhooks},
+	{"WithTx", testWithTx},
+	{"WithTx_Concurrent", testWithTxConcurrent},
+}
+
+func runConformance(t *testing.T, newStore func(t *testing.T) Db) {
+	for _, tc := range conformanceTests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+func TestConformance_DbSQLite(t *testing.T) {
+	runConformance(t, func(t *testing.T) Db { return setupTestDB(t) })
+}
+
+func TestConformance_MemDB(t *testing.T) {
+	runConformance(t, func(t *testing.T) Db { return NewMemDB() })
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func taskIds(tasks []models.Task) []string {
+	ids := []string{}
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+	return ids
+}
+
+// day is the time as the stores read it back, in UTC
+func day(d, hour int) time.Time {
+	return time.Date(2025, 3, d, hour, 0, 0, 0, time.UTC)
+}
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	must(t, err)
+	return loc
+}
+
+// saveFixtureTasks saves the tasks of alice and bob, some of them shared in the project
+// "p1" both are members of
+func saveFixtureTasks(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveProject(ctx, models.Project{Id: "p1", Name: "Launch", Owner: "bob", Created: day(1, 0), Members: []string{"alice", "bob"}}))
+	tasks := []models.Task{
+		{Id: "a1", Title: "Write the Report", Content: "quarterly numbers", Owner: "alice", Created: day(1, 1), Wip: true, Priority: models.PriorityHigh},
+		{Id: "a2", Title: "Groceries", Content: "milk", Owner: "alice", Created: day(2, 1), Completed: day(10, 12), Planned: true},
+		{Id: "a3", Title: "Deploy", Content: "50% done", Owner: "alice", Created: day(3, 1), Completed: day(20, 12), Project: "p1", Assignee: "alice"},
+		{Id: "b1", Title: "Review", Content: "the report of alice", Owner: "bob", Created: day(4, 1), Project: "p1", Assignee: "alice"},
+		{Id: "b2", Title: "Private", Content: "diary", Owner: "bob", Created: day(5, 1), Wip: true, Planned: true},
+	}
+	for _, task := range tasks {
+		must(t, d.SaveTask(ctx, task))
+	}
+	must(t, d.SaveTag(ctx, "alice", "work"))
+	must(t, d.SaveTag(ctx, "alice", "home"))
+	must(t, d.SaveTag(ctx, "bob", "work"))
+	must(t, d.AddTagToTask(ctx, "a1", "work"))
+	must(t, d.AddTagToTask(ctx, "a2", "home"))
+	must(t, d.AddTagToTask(ctx, "b1", "work"))
+}
+
+func testFindTasksFilters(t *testing.T, d Db) {
+	saveFixtureTasks(t, d)
+	tests := []struct {
+		name  string
+		query models.TasksQuery
+		want  []string
+	}{
+		{"own and shared", models.TasksQuery{Owner: "alice"}, []string{"a1", "a2", "a3", "b1"}},
+		{"other user", models.TasksQuery{Owner: "bob"}, []string{"a3", "b1", "b2"}},
+		{"nobody", models.TasksQuery{Owner: "carol"}, []string{}},
+		{"open", models.TasksQuery{Owner: "alice", FilterCompleted: true}, []string{"a1", "b1"}},
+		{"completed", models.TasksQuery{Owner: "alice", FilterIncompleted: true}, []string{"a2", "a3"}},
+		{"completed from", models.TasksQuery{Owner: "alice", CompletedFrom: day(15, 0)}, []string{"a1", "a3", "b1"}},
+		{"completed to", models.TasksQuery{Owner: "alice", CompletedTo: day(15, 0)}, []string{"a1", "a2", "b1"}},
+		{"completed between", models.TasksQuery{Owner: "alice", FilterIncompleted: true, CompletedFrom: day(10, 12), CompletedTo: day(20, 11)}, []string{"a2"}},
+		{"completed range ignored for open", models.TasksQuery{Owner: "alice", FilterCompleted: true, CompletedFrom: day(15, 0)}, []string{"a1", "b1"}},
+		{"wip", models.TasksQuery{Owner: "alice", FilterWip: true}, []string{"a1"}},
+		{"non wip", models.TasksQuery{Owner: "alice", FilterNonWip: true}, []string{"a2", "a3", "b1"}},
+		{"planned", models.TasksQuery{Owner: "alice", Planned: true}, []string{"a2"}},
+		{"non planned", models.TasksQuery{Owner: "alice", NonPlanned: true}, []string{"a1", "a3", "b1"}},
+		{"assigned to me", models.TasksQuery{Owner: "alice", AssignedToMe: true}, []string{"a3", "b1"}},
+		{"project", models.TasksQuery{Owner: "alice", Project: "p1"}, []string{"a3", "b1"}},
+		{"tag", models.TasksQuery{Owner: "alice", Tags: []models.TaskTag{"work"}}, []string{"a1", "b1"}},
+		{"any of the tags", models.TasksQuery{Owner: "alice", Tags: []models.TaskTag{"work", "home"}}, []string{"a1", "a2", "b1"}},
+		{"unknown tag", models.TasksQuery{Owner: "alice", Tags: []models.TaskTag{"none"}}, []string{}},
+		{"search title ignoring case", models.TasksQuery{Owner: "alice", SearchText: "REPORT"}, []string{"a1", "b1"}},
+		{"search content", models.TasksQuery{Owner: "alice", SearchText: "quarter"}, []string{"a1"}},
+		{"search wildcard", models.TasksQuery{Owner: "alice", SearchText: "r_view"}, []string{"b1"}},
+		{"search percent", models.TasksQuery{Owner: "alice", SearchText: "50%"}, []string{"a3"}},
+		{"combined", models.TasksQuery{Owner: "alice", FilterCompleted: true, Project: "p1", Tags: []models.TaskTag{"work"}, SearchText: "review"}, []string{"b1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := d.FindTasks(context.Background(), tt.query)
+			must(t, err)
+			// without a sort column the order is up to the store
+			got := slices.Sorted(slices.Values(taskIds(tasks)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testFindTasksSorting(t *testing.T, d Db) {
+	ctx := context.Background()
+	tasks := []models.Task{
+		{Id: "x", Created: day(2, 0), Completed: day(9, 0), Priority: models.PriorityLow, Impact: models.ImpactHigh, Cost: models.CostM, Value: 2.5, Fun: models.FunXL},
+		{Id: "y", Created: day(1, 0), Priority: models.PriorityUrgent, Impact: models.ImpactSlight, Cost: models.CostXS, Value: 10, Fun: models.FunM, Wip: true},
+		{Id: "z", Created: day(3, 0), Completed: day(8, 0), Priority: models.PriorityMedium, Impact: models.ImpactModerate, Cost: models.CostXXL, Value: 0.5, Fun: models.FunS, Planned: true},
+	}
+	for _, task := range tasks {
+		must(t, d.SaveTask(ctx, task))
+	}
+	tests := []struct {
+		column models.SortColumn
+		asc    []string
+	}{
+		{models.Created, []string{"y", "x", "z"}},
+		{models.Completed, []string{"y", "z", "x"}},
+		{models.Priority, []string{"x", "z", "y"}},
+		{models.ColumnImpact, []string{"y", "z", "x"}},
+		{models.ColumnCost, []string{"y", "x", "z"}},
+		{models.ColumnValue, []string{"z", "x", "y"}},
+		{models.ColumnFun, []string{"z", "y", "x"}},
+		// the other columns sort by creation time
+		{models.Title, []string{"y", "x", "z"}},
+		{models.ColumnTags, []string{"y", "x", "z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column.ToHumanString(), func(t *testing.T) {
+			asc, err := d.FindTasks(ctx, models.TasksQuery{SortColumn: tt.column, SortDirection: models.Asc})
+			must(t, err)
+			if got := taskIds(asc); !reflect.DeepEqual(got, tt.asc) {
+				t.Errorf("ascending: expected %v, got %v", tt.asc, got)
+			}
+			desc, err := d.FindTasks(ctx, models.TasksQuery{SortColumn: tt.column, SortDirection: models.Desc})
+			must(t, err)
+			want := slices.Clone(tt.asc)
+			slices.Reverse(want)
+			if got := taskIds(desc); !reflect.DeepEqual(got, want) {
+				t.Errorf("descending: expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	for _, column := range []models.SortColumn{models.ColumnWip, models.ColumnPlanned} {
+		desc, err := d.FindTasks(ctx, models.TasksQuery{SortColumn: column, SortDirection: models.Desc})
+		must(t, err)
+		want := map[models.SortColumn]string{models.ColumnWip: "y", models.ColumnPlanned: "z"}[column]
+		if len(desc) != 3 || desc[0].Id != want {
+			t.Errorf("%v: expected %v first, got %v", column.ToHumanString(), want, taskIds(desc))
+		}
+	}
+}
+
+func testFindTasksLimit(t *testing.T, d Db) {
+	ctx := context.Background()
+	for i, priority := range []models.TaskPriority{models.PriorityLow, models.PriorityUrgent, models.PriorityMedium, models.PriorityHigh} {
+		must(t, d.SaveTask(ctx, models.Task{Id: string(rune('a' + i)), Created: day(1+i, 0), Priority: priority}))
+	}
+	query := models.TasksQuery{SortColumn: models.Priority, SortDirection: models.Desc, EnableLimit: true, LimitCount: 2}
+	tasks, err := d.FindTasks(ctx, query)
+	must(t, err)
+	if got := taskIds(tasks); !reflect.DeepEqual(got, []string{"b", "d"}) {
+		t.Errorf("expected the two most urgent tasks, got %v", got)
+	}
+
+	query.EnableLimit = false
+	tasks, err = d.FindTasks(ctx, query)
+	must(t, err)
+	if len(tasks) != 4 {
+		t.Errorf("expected no limit when it is disabled, got %v", taskIds(tasks))
+	}
+	query.EnableLimit, query.LimitCount = true, 0
+	tasks, err = d.FindTasks(ctx, query)
+	must(t, err)
+	if len(tasks) != 4 {
+		t.Errorf("expected no limit when the count is 0, got %v", taskIds(tasks))
+	}
+}
+
+// testFindTasksTimeZones compares the completion times as instants, whatever the time zones
+// the tasks and the query are given in, on the days daylight saving time starts and ends
+func testFindTasksTimeZones(t *testing.T, d Db) {
+	ctx := context.Background()
+	newYork := loadLocation(t, "America/New_York")
+	tokyo := loadLocation(t, "Asia/Tokyo")
+	// 2025-03-09 has 23 hours in New York, 2025-11-02 has 25
+	for id, completed := range map[string]time.Time{
+		"spring-before": time.Date(2025, 3, 8, 23, 59, 59, 0, newYork),
+		"spring-first":  time.Date(2025, 3, 9, 0, 0, 0, 0, newYork).In(tokyo),
+		"spring-last":   time.Date(2025, 3, 9, 23, 30, 0, 0, newYork).UTC(),
+		"spring-after":  time.Date(2025, 3, 10, 0, 0, 1, 0, newYork),
+		"fall-first":    time.Date(2025, 11, 2, 0, 30, 0, 0, newYork).UTC(),
+		// 01:30 comes twice, the second time an hour later in standard time
+		"fall-repeated": time.Date(2025, 11, 2, 6, 30, 0, 0, time.UTC),
+		"fall-last":     time.Date(2025, 11, 2, 23, 30, 0, 0, newYork).In(tokyo),
+		"fall-after":    time.Date(2025, 11, 3, 0, 0, 1, 0, newYork).UTC(),
+	} {
+		must(t, d.SaveTask(ctx, models.Task{Id: id, Owner: "alice", Completed: completed}))
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		want []string
+	}{
+		{"spring", time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), []string{"spring-first", "spring-last"}},
+		{"spring in another zone", time.Date(2025, 3, 9, 0, 0, 0, 0, newYork).In(tokyo), []string{"spring-first", "spring-last"}},
+		{"fall", time.Date(2025, 11, 2, 0, 0, 0, 0, newYork), []string{"fall-first", "fall-repeated", "fall-last"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the day ends at the next midnight in New York, CompletedTo is inclusive
+			to := tt.from.In(newYork).AddDate(0, 0, 1).Add(-time.Second)
+			tasks, err := d.FindTasks(ctx, models.TasksQuery{Owner: "alice", FilterIncompleted: true, CompletedFrom: tt.from, CompletedTo: to, SortColumn: models.Completed, SortDirection: models.Asc})
+			must(t, err)
+			if got := taskIds(tasks); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testTasksRoundTrip(t *testing.T, d Db) {
+	ctx := context.Background()
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	task := models.Task{
+		Id:        "t1",
+		Title:     "Title",
+		Content:   "Content",
+		Created:   time.Date(2025, 1, 2, 3, 4, 5, 999, zone),
+		Updated:   time.Date(2025, 1, 3, 3, 4, 5, 0, time.UTC),
+		Priority:  models.PriorityUrgent,
+		Wip:       true,
+		Impact:    models.ImpactHigh,
+		Cost:      models.CostXL,
+		Fun:       models.FunL,
+		Value:     12.25,
+		Tags:      []models.TaskTag{"ignored"},
+		Owner:     "alice",
+		Project:   "p1",
+		Assignee:  "bob",
+		Completed: models.NOT_COMPLETED,
+	}
+	must(t, d.SaveTask(ctx, task))
+
+	got, err := d.FindTask(ctx, "t1")
+	must(t, err)
+	want := task
+	// the times keep their instant, to the second, and are read back in UTC
+	want.Created = time.Date(2025, 1, 2, 3, 4, 5, 0, zone).UTC()
+	want.Updated = task.Updated.UTC()
+	want.Tags = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got.IsCompleted() {
+		t.Errorf("expected the task to stay open")
+	}
+
+	task.Title = "Changed"
+	task.Completed = day(4, 0)
+	must(t, d.SaveTask(ctx, task))
+	all, err := d.Tasks(ctx)
+	must(t, err)
+	if len(all) != 1 || all[0].Title != "Changed" || !all[0].Completed.Equal(day(4, 0)) {
+		t.Errorf("expected the task to be replaced, got %+v", all)
+	}
+
+	if _, err := d.FindTask(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testTasksDelete(t *testing.T, d Db) {
+	ctx := context.Background()
+	saveFixtureTasks(t, d)
+
+	must(t, d.DeleteTask(ctx, "a1"))
+	if _, err := d.FindTask(ctx, "a1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the task to be deleted, got %v", err)
+	}
+	if tags, _ := d.TaskTags(ctx, "a1"); tags != nil {
+		t.Errorf("expected the tags of the task to be deleted, got %v", tags)
+	}
+	if err := d.DeleteTask(ctx, "a1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := d.DeleteAllTasks(ctx); err == nil {
+		t.Errorf("expected the tagged tasks to keep the tasks from being deleted")
+	}
+	must(t, d.DeleteTagFromTask(ctx, "a2", "home"))
+	must(t, d.DeleteTagFromTask(ctx, "b1", "work"))
+	must(t, d.DeleteAllTasks(ctx))
+	if tasks, _ := d.Tasks(ctx); len(tasks) != 0 {
+		t.Errorf("expected no tasks, got %v", taskIds(tasks))
+	}
+}
+
+func testTaskCounts(t *testing.T, d Db) {
+	saveFixtureTasks(t, d)
+	counts, err := d.TaskCounts(context.Background())
+	must(t, err)
+	// the planned task a2 is completed, it is not counted as planned
+	want := models.TaskCounts{Open: 3, Completed: 2, Wip: 2, Planned: 1}
+	if counts != want {
+		t.Errorf("expected %+v, got %+v", want, counts)
+	}
+}
+
+func testTags(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveTask(ctx, models.Task{Id: "a1", Owner: "alice"}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "b1", Owner: "bob"}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "old", Owner: "alice", Created: day(1, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "new", Owner: "alice", Created: day(2, 0)}))
+	must(t, d.SaveTag(ctx, "bob", "old"))
+
+	if err := d.SaveTag(ctx, "alice", "old"); err == nil {
+		t.Errorf("expected an error for a duplicate tag")
+	}
+	tags, err := d.Tags(ctx, "alice")
+	must(t, err)
+	if !reflect.DeepEqual(tags, []models.TaskTag{"new", "old"}) {
+		t.Errorf("expected the newest tag first, got %v", tags)
+	}
+	if tags, _ := d.Tags(ctx, "carol"); tags != nil {
+		t.Errorf("expected no tags, got %v", tags)
+	}
+
+	if err := d.AddTagToTask(ctx, "b1", "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a tag of another user, got %v", err)
+	}
+	if err := d.AddTagToTask(ctx, "missing", "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing task, got %v", err)
+	}
+	must(t, d.AddTagToTask(ctx, "a1", "old"))
+	must(t, d.AddTagToTask(ctx, "a1", "new"))
+	must(t, d.AddTagToTask(ctx, "b1", "old"))
+	if err := d.AddTagToTask(ctx, "a1", "old"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an error for a duplicate tag of the task, got %v", err)
+	}
+	tags, err = d.TaskTags(ctx, "a1")
+	must(t, err)
+	if !reflect.DeepEqual(tags, []models.TaskTag{"new", "old"}) {
+		t.Errorf("expected the tags of the task, got %v", tags)
+	}
+
+	must(t, d.DeleteTagFromAllTasks(ctx, "alice", "old"))
+	if tags, _ := d.TaskTags(ctx, "a1"); !reflect.DeepEqual(tags, []models.TaskTag{"new"}) {
+		t.Errorf("expected the tag to be removed from the tasks of alice, got %v", tags)
+	}
+	if tags, _ := d.TaskTags(ctx, "b1"); !reflect.DeepEqual(tags, []models.TaskTag{"old"}) {
+		t.Errorf("expected the tasks of bob to keep the tag, got %v", tags)
+	}
+	if err := d.DeleteTagFromTask(ctx, "a1", "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	must(t, d.DeleteTag(ctx, "alice", "old"))
+	if err := d.DeleteTag(ctx, "alice", "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if tags, _ := d.Tags(ctx, "bob"); !reflect.DeepEqual(tags, []models.TaskTag{"old"}) {
+		t.Errorf("expected bob to keep his tag, got %v", tags)
+	}
+}
+
+func testTasksTags(t *testing.T, d Db) {
+	saveFixtureTasks(t, d)
+	ctx := context.Background()
+
+	got, err := d.TasksTags(ctx, "alice", []string{"a1", "a2", "a3", "b1", "b2"})
+	must(t, err)
+	want := map[string][]models.TaskTag{"a1": {"work"}, "a2": {"home"}, "b1": {"work"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	// the tasks the user cannot see are left out
+	got, err = d.TasksTags(ctx, "carol", []string{"a1", "b1"})
+	must(t, err)
+	if len(got) != 0 {
+		t.Errorf("expected no tags, got %v", got)
+	}
+	got, err = d.TasksTags(ctx, "alice", nil)
+	must(t, err)
+	if got == nil || len(got) != 0 {
+		t.Errorf("expected an empty map, got %v", got)
+	}
+}
+
+func testForEach(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveTask(ctx, models.Task{Id: "b", Created: day(1, 0)}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "c", Created: day(2, 0)}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "a", Created: day(2, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "z", Owner: "bob", Created: day(1, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "y", Owner: "", Created: day(3, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "x", Owner: "", Created: day(2, 0)}))
+	must(t, d.AddTagToTask(ctx, "c", "y"))
+	must(t, d.AddTagToTask(ctx, "a", "y"))
+	must(t, d.AddTagToTask(ctx, "a", "x"))
+
+	var tasks []string
+	must(t, d.ForEachTask(ctx, func(task models.Task) error {
+		tasks = append(tasks, task.Id)
+		return nil
+	}))
+	if !reflect.DeepEqual(tasks, []string{"b", "a", "c"}) {
+		t.Errorf("expected the tasks by creation time and id, got %v", tasks)
+	}
+
+	var tags []models.TagRecord
+	must(t, d.ForEachTag(ctx, func(tag models.TagRecord) error {
+		tags = append(tags, tag)
+		return nil
+	}))
+	wantTags := []models.TagRecord{
+		{Id: "x", Owner: "", Created: day(2, 0)},
+		{Id: "y", Owner: "", Created: day(3, 0)},
+		{Id: "z", Owner: "bob", Created: day(1, 0)},
+	}
+	if !reflect.DeepEqual(tags, wantTags) {
+		t.Errorf("expected the tags by owner and creation time, got %v", tags)
+	}
+
+	var taskTags []string
+	must(t, d.ForEachTaskTag(ctx, func(taskId string, tag models.TaskTag) error {
+		taskTags = append(taskTags, taskId+":"+string(tag))
+		return nil
+	}))
+	if !reflect.DeepEqual(taskTags, []string{"a:x", "a:y", "c:y"}) {
+		t.Errorf("expected the tags of the tasks by task and tag, got %v", taskTags)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := d.ForEachTask(ctx, func(models.Task) error {
+		calls++
+		// the callback may use the store
+		if _, err := d.FindTask(ctx, "a"); err != nil {
+			return err
+		}
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the error of the callback to stop the iteration, got %v after %d calls", err, calls)
+	}
+}
+
+func testSettings(t *testing.T, d Db) {
+	ctx := context.Background()
+	s := models.Settings{Id: "b", TasksQuery: models.TasksQuery{
+		FilterCompleted: true,
+		SortColumn:      models.Priority,
+		SortDirection:   models.Desc,
+		CompletedFrom:   time.Date(2025, 3, 4, 15, 16, 17, 0, time.UTC),
+		Tags:            []models.TaskTag{"work"},
+		SearchText:      "report",
+		EnableLimit:     true,
+		LimitCount:      10,
+		AssignedToMe:    true,
+		Project:         "p1",
+		Owner:           "alice",
+	}, TimeZone: "Europe/Berlin"}
+	must(t, d.SaveSettings(ctx, s))
+	must(t, d.SaveSettings(ctx, models.Settings{Id: "a"}))
+
+	got, err := d.FindSettings(ctx, "b")
+	must(t, err)
+	want := s
+	// the completion times are read back in UTC, the owner is not stored
+	want.TasksQuery.CompletedFrom = s.TasksQuery.CompletedFrom.UTC()
+	want.TasksQuery.Owner = ""
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	all, err := d.AllSettings(ctx)
+	must(t, err)
+	if len(all) != 2 || all[0].Id != "a" || all[1].Id != "b" {
+		t.Errorf("expected the settings by id, got %+v", all)
+	}
+
+	s.TasksQuery.Tags = nil
+	must(t, d.SaveSettings(ctx, s))
+	if got, _ := d.FindSettings(ctx, "b"); got.TasksQuery.Tags != nil {
+		t.Errorf("expected the tags to be replaced, got %v", got.TasksQuery.Tags)
+	}
+
+	must(t, d.DeleteSettings(ctx, "b"))
+	if _, err := d.FindSettings(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testMigrations(t *testing.T, d Db) {
+	ctx := context.Background()
+	if d.MigrationExists(ctx, "conformance") {
+		t.Fatalf("expected the migration not to exist yet")
+	}
+	d.RecordMigration(ctx, "conformance")
+	if !d.MigrationExists(ctx, "conformance") {
+		t.Errorf("expected the migration to be recorded")
+	}
+}
+
+func testUsersSessions(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveUser(ctx, models.User{Username: "bob", PasswordHash: "h1", Created: day(2, 0)}))
+	must(t, d.SaveUser(ctx, models.User{Username: "alice", PasswordHash: "h2", Created: day(1, 0), Admin: true}))
+	// saving an existing user only replaces the password
+	must(t, d.SaveUser(ctx, models.User{Username: "alice", PasswordHash: "h3", Created: day(9, 0)}))
+
+	users, err := d.Users(ctx)
+	must(t, err)
+	want := []models.User{
+		{Username: "alice", PasswordHash: "h3", Created: day(1, 0), Admin: true},
+		{Username: "bob", PasswordHash: "h1", Created: day(2, 0)},
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("expected %+v, got %+v", want, users)
+	}
+	if count, _ := d.CountUsers(ctx); count != 2 {
+		t.Errorf("expected 2 users, got %d", count)
+	}
+	must(t, d.SetUserAdmin(ctx, "bob", true))
+	if u, _ := d.FindUser(ctx, "bob"); !u.Admin {
+		t.Errorf("expected bob to be an admin")
+	}
+	if err := d.SetUserAdmin(ctx, "carol", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := d.FindUser(ctx, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	now := time.Now()
+	session := models.Session{TokenHash: "s1", Username: "alice", Created: now, Expires: now.Add(time.Hour)}
+	must(t, d.SaveSession(ctx, session))
+	must(t, d.SaveSession(ctx, models.Session{TokenHash: "s2", Username: "alice", Created: now, Expires: now.Add(-time.Hour)}))
+	if err := d.SaveSession(ctx, models.Session{TokenHash: "s3", Username: "carol", Expires: now}); err == nil {
+		t.Errorf("expected an error for the session of a missing user")
+	}
+	got, err := d.FindSession(ctx, "s1")
+	must(t, err)
+	// the times of the sessions are read back in UTC, to the second
+	if got.Username != "alice" || !got.Expires.Equal(session.Expires.Truncate(time.Second)) || got.Expires.Location() != time.UTC {
+		t.Errorf("expected the session of alice, got %+v", got)
+	}
+
+	must(t, d.DeleteExpiredSessions(ctx, now))
+	if _, err := d.FindSession(ctx, "s2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the expired session to be deleted, got %v", err)
+	}
+	must(t, d.DeleteSession(ctx, "missing"))
+
+	must(t, d.DeleteUser(ctx, "alice"))
+	if _, err := d.FindSession(ctx, "s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the sessions of the user to be deleted, got %v", err)
+	}
+	if err := d.DeleteUser(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testApiTokens(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveUser(ctx, models.User{Username: "alice"}))
+	must(t, d.SaveUser(ctx, models.User{Username: "bob"}))
+	old := models.ApiToken{Id: "t1", Name: "old", TokenHash: "h1", Scope: models.ApiTokenRead, Username: "alice", Created: day(1, 0)}
+	must(t, d.SaveApiToken(ctx, old))
+	must(t, d.SaveApiToken(ctx, models.ApiToken{Id: "t2", Name: "new", TokenHash: "h2", Scope: models.ApiTokenReadWrite, Username: "alice", Created: day(2, 0)}))
+	must(t, d.SaveApiToken(ctx, models.ApiToken{Id: "t3", TokenHash: "h3", Username: "bob", Created: day(3, 0)}))
+	if err := d.SaveApiToken(ctx, models.ApiToken{Id: "t4", TokenHash: "h1", Username: "alice"}); err == nil {
+		t.Errorf("expected an error for a duplicate token hash")
+	}
+	if err := d.SaveApiToken(ctx, models.ApiToken{Id: "t5", TokenHash: "h5", Username: "carol"}); err == nil {
+		t.Errorf("expected an error for the token of a missing user")
+	}
+
+	tokens, err := d.ApiTokens(ctx, "alice")
+	must(t, err)
+	if len(tokens) != 2 || tokens[0].Id != "t2" || tokens[1].Id != "t1" {
+		t.Errorf("expected the newest token first, got %+v", tokens)
+	}
+	if tokens[1].IsUsed() || tokens[1].Scope != models.ApiTokenRead || !tokens[1].Created.Equal(day(1, 0)) {
+		t.Errorf("expected the token as saved, got %+v", tokens[1])
+	}
+
+	used := time.Date(2025, 3, 5, 6, 7, 8, 0, time.Local)
+	must(t, d.TouchApiToken(ctx, "t1", used))
+	got, err := d.FindApiTokenByHash(ctx, "h1")
+	must(t, err)
+	if !got.LastUsed.Equal(used) {
+		t.Errorf("expected the token to be used at %v, got %v", used, got.LastUsed)
+	}
+	if _, err := d.FindApiTokenByHash(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := d.DeleteApiToken(ctx, "bob", "t1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the token of another user, got %v", err)
+	}
+	must(t, d.DeleteApiToken(ctx, "alice", "t1"))
+	must(t, d.DeleteUser(ctx, "bob"))
+	if _, err := d.FindApiTokenByHash(ctx, "h3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the tokens of the deleted user to be deleted, got %v", err)
+	}
+}
+
+func testProjects(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveProject(ctx, models.Project{Id: "p2", Name: "Beta", Owner: "alice", Created: day(1, 0), Members: []string{"carol", "alice", "carol"}}))
+	must(t, d.SaveProject(ctx, models.Project{Id: "p1", Name: "Alpha", Owner: "bob", Created: day(2, 0), Members: []string{"bob", "alice"}}))
+	must(t, d.SaveProject(ctx, models.Project{Id: "p3", Name: "Empty", Owner: "dave", Created: day(3, 0)}))
+
+	projects, err := d.Projects(ctx, "alice")
+	must(t, err)
+	want := []models.Project{
+		{Id: "p1", Name: "Alpha", Owner: "bob", Created: day(2, 0), Members: []string{"alice", "bob"}},
+		{Id: "p2", Name: "Beta", Owner: "alice", Created: day(1, 0), Members: []string{"alice", "carol"}},
+	}
+	if !reflect.DeepEqual(projects, want) {
+		t.Errorf("expected %+v, got %+v", want, projects)
+	}
+	all, err := d.AllProjects(ctx)
+	must(t, err)
+	if len(all) != 3 || all[0].Id != "p2" || all[2].Id != "p3" || all[2].Members != nil {
+		t.Errorf("expected every project by creation time, got %+v", all)
+	}
+
+	must(t, d.SaveTask(ctx, models.Task{Id: "t1", Owner: "alice", Project: "p2", Assignee: "carol"}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "t2", Owner: "alice", Project: "p2", Assignee: "alice"}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "t3", Owner: "carol", Project: "p2", Assignee: "carol"}))
+	// renaming keeps the creation time, the former members are unassigned
+	must(t, d.SaveProject(ctx, models.Project{Id: "p2", Name: "Gamma", Owner: "alice", Created: day(9, 0), Members: []string{"alice"}}))
+	p, err := d.FindProject(ctx, "p2")
+	must(t, err)
+	if p.Name != "Gamma" || !p.Created.Equal(day(1, 0)) || !reflect.DeepEqual(p.Members, []string{"alice"}) {
+		t.Errorf("expected the project to be renamed, got %+v", p)
+	}
+	if task, _ := d.FindTask(ctx, "t1"); task.Assignee != "" {
+		t.Errorf("expected the former member to be unassigned, got %q", task.Assignee)
+	}
+	if task, _ := d.FindTask(ctx, "t2"); task.Assignee != "alice" {
+		t.Errorf("expected the member to stay assigned, got %q", task.Assignee)
+	}
+
+	must(t, d.SaveTask(ctx, models.Task{Id: "t4", Owner: "alice", Project: "p1", Assignee: "bob"}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "t5", Owner: "bob", Project: "p1", Assignee: "bob"}))
+	must(t, d.DeleteProject(ctx, "p1"))
+	if task, _ := d.FindTask(ctx, "t4"); task.Project != "" || task.Assignee != "" {
+		t.Errorf("expected the task to become private and unassigned, got %+v", task)
+	}
+	if task, _ := d.FindTask(ctx, "t5"); task.Project != "" || task.Assignee != "bob" {
+		t.Errorf("expected the owner to stay assigned, got %+v", task)
+	}
+	if _, err := d.FindProject(ctx, "p1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := d.DeleteProject(ctx, "p1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testTransferOwnership(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveProject(ctx, models.Project{Id: "p1", Name: "Shared", Owner: "alice", Created: day(1, 0), Members: []string{"alice", "bob"}}))
+	must(t, d.SaveProject(ctx, models.Project{Id: "p2", Name: "Mine", Owner: "alice", Created: day(1, 0), Members: []string{"alice"}}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "t1", Owner: "alice"}))
+	must(t, d.SaveTask(ctx, models.Task{Id: "t2", Owner: "carol", Project: "p1", Assignee: "alice"}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "work", Owner: "alice", Created: day(1, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "home", Owner: "alice", Created: day(2, 0)}))
+	must(t, d.SaveTagRecord(ctx, models.TagRecord{Id: "work", Owner: "bob", Created: day(3, 0)}))
+	must(t, d.AddTagToTask(ctx, "t1", "home"))
+
+	must(t, d.TransferOwnership(ctx, "alice", "bob"))
+
+	if task, _ := d.FindTask(ctx, "t1"); task.Owner != "bob" {
+		t.Errorf("expected the task to belong to bob, got %q", task.Owner)
+	}
+	if task, _ := d.FindTask(ctx, "t2"); task.Owner != "carol" || task.Assignee != "bob" {
+		t.Errorf("expected the task to be assigned to bob, got %+v", task)
+	}
+	if tags, _ := d.Tags(ctx, "bob"); !reflect.DeepEqual(tags, []models.TaskTag{"work", "home"}) {
+		t.Errorf("expected the tags to be merged keeping those of bob, got %v", tags)
+	}
+	if tags, _ := d.Tags(ctx, "alice"); tags != nil {
+		t.Errorf("expected alice to have no tags, got %v", tags)
+	}
+	if tags, _ := d.TaskTags(ctx, "t1"); !reflect.DeepEqual(tags, []models.TaskTag{"home"}) {
+		t.Errorf("expected the task to keep its tags, got %v", tags)
+	}
+	projects, err := d.Projects(ctx, "bob")
+	must(t, err)
+	if len(projects) != 2 {
+		t.Fatalf("expected bob to be a member of both projects, got %+v", projects)
+	}
+	for _, p := range projects {
+		if p.Owner != "bob" || p.HasMember("alice") {
+			t.Errorf("expected the project to be handed over to bob, got %+v", p)
+		}
+	}
+	if projects[1].Id != "p1" || !reflect.DeepEqual(projects[1].Members, []string{"bob"}) {
+		t.Errorf("expected the memberships to be merged, got %+v", projects[1])
+	}
+}
+
+func testWebhooks(t *testing.T, d Db) {
+	ctx := context.Background()
+	h2 := models.Webhook{Id: "h2", Url: "http://b", Events: []models.WebhookEvent{models.WebhookTaskCreated}, Created: day(2, 0)}
+	h1 := models.Webhook{Id: "h1", Url: "http://a", Events: []models.WebhookEvent{models.WebhookTaskCompleted}, Tags: []models.TaskTag{"work"}, Secret: "s", Created: day(1, 0)}
+	must(t, d.SaveWebhook(ctx, h2))
+	must(t, d.SaveWebhook(ctx, h1))
+
+	hooks, err := d.Webhooks(ctx)
+	must(t, err)
+	h2.Tags = []models.TaskTag{}
+	if !reflect.DeepEqual(hooks, []models.Webhook{h1, h2}) {
+		t.Errorf("expected the webhooks by creation time, got %+v", hooks)
+	}
+	h1.Url = "http://c"
+	must(t, d.SaveWebhook(ctx, h1))
+	if hooks, _ := d.Webhooks(ctx); len(hooks) != 2 || hooks[0].Url != "http://c" {
+		t.Errorf("expected the webhook to be replaced, got %+v", hooks)
+	}
+	must(t, d.DeleteWebhook(ctx, "h2"))
+	if err := d.DeleteWebhook(ctx, "h2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	for i := range 4 {
+		must(t, d.SaveWebhookDelivery(ctx, models.WebhookDelivery{
+			Id:         100,
+			DeliveryId: string(rune('a' + i)),
+			WebhookId:  "h1",
+			Event:      models.WebhookTaskCreated,
+			Attempt:    i + 1,
+			StatusCode: 500,
+			Created:    day(1+i, 0),
+			Duration:   1500*time.Microsecond + time.Duration(i)*time.Millisecond,
+		}))
+	}
+	deliveries, err := d.WebhookDeliveries(ctx, 3)
+	must(t, err)
+	if len(deliveries) != 3 || deliveries[0].DeliveryId != "d" || deliveries[2].DeliveryId != "b" {
+		t.Fatalf("expected the latest deliveries first, got %+v", deliveries)
+	}
+	if deliveries[0].Id <= deliveries[1].Id || deliveries[0].Duration != 4*time.Millisecond || !deliveries[0].Created.Equal(day(4, 0)) {
+		t.Errorf("expected the id to be assigned and the duration kept to the millisecond, got %+v", deliveries[0])
+	}
+	must(t, d.PruneWebhookDeliveries(ctx, 2))
+	deliveries, err = d.WebhookDeliveries(ctx, 10)
+	must(t, err)
+	if len(deliveries) != 2 || deliveries[1].DeliveryId != "c" {
+		t.Errorf("expected the latest two deliveries to be kept, got %+v", deliveries)
+	}
+}
+
+func testWithTx(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveTask(ctx, models.Task{Id: "kept", Title: "Before"}))
+
+	var committed []string
+	failure := errors.New("failure")
+	err := d.WithTx(ctx, func(ctx context.Context) error {
+		if !InTx(ctx) {
+			t.Errorf("expected the context to carry the transaction")
+		}
+		must(t, d.SaveTask(ctx, models.Task{Id: "kept", Title: "After"}))
+		must(t, d.WithTx(ctx, func(ctx context.Context) error {
+			return d.SaveTask(ctx, models.Task{Id: "new"})
+		}))
+		must(t, d.SaveTag(ctx, "", "work"))
+		AfterCommit(ctx, func(context.Context) { committed = append(committed, "rolled back") })
+		if _, err := d.FindTask(ctx, "new"); err != nil {
+			t.Errorf("expected the transaction to see its changes, got %v", err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if task, _ := d.FindTask(ctx, "kept"); task.Title != "Before" {
+		t.Errorf("expected the change to be rolled back, got %q", task.Title)
+	}
+	if _, err := d.FindTask(ctx, "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the nested change to be rolled back, got %v", err)
+	}
+	if tags, _ := d.Tags(ctx, ""); tags != nil {
+		t.Errorf("expected the tag to be rolled back, got %v", tags)
+	}
+
+	err = d.WithTx(ctx, func(ctx context.Context) error {
+		must(t, d.SaveTask(ctx, models.Task{Id: "new"}))
+		AfterCommit(ctx, func(ctx context.Context) {
+			if InTx(ctx) {
+				t.Errorf("expected the context of the caller after the commit")
+			}
+			// the store can be used again once committed
+			if _, err := d.FindTask(ctx, "new"); err != nil {
+				t.Errorf("expected the task to be committed, got %v", err)
+			}
+			committed = append(committed, "committed")
+		})
+		return nil
+	})
+	must(t, err)
+	if !reflect.DeepEqual(committed, []string{"committed"}) {
+		t.Errorf("expected only the committed transaction to run AfterCommit, got %v", committed)
+	}
+}
+
+// testWithTxConcurrent checks that the transactions reading and then writing a task do not
+// overwrite the changes of each other
+func testWithTxConcurrent(t *testing.T, d Db) {
+	ctx := context.Background()
+	must(t, d.SaveTask(ctx, models.Task{Id: "counter"}))
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := d.WithTx(ctx, func(ctx context.Context) error {
+				task, err := d.FindTask(ctx, "counter")
+				if err != nil {
+					return err
+				}
+				task.Value++
+				return d.SaveTask(ctx, task)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	task, err := d.FindTask(ctx, "counter")
+	must(t, err)
+	if task.Value != workers {
+		t.Errorf("expected every transaction to be counted, got %v of %v", task.Value, workers)
+	}
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/inaryzen/priotasks/models"
 )
@@ -13,35 +15,64 @@ const (
 	PREFIX_SETTINGS = "settings:"
 )
 
-var instance Db
-
 type Db interface {
 	Init(string)
 	Close()
-	Tasks() ([]models.Task, error)
-	FindTask(taskId string) (models.Task, error)
-	FindTasks(query models.TasksQuery) ([]models.Task, error)
-	DeleteTask(taskId string) error
-	DeleteAllTasks() error
-	SaveTask(task models.Task) error
-	FindSettings(settingsId string) (models.Settings, error)
-	SaveSettings(s models.Settings) error
-	MigrationExists(id string) bool
-	RecordMigration(id string)
-	SaveTag(tagId string) error
-	AddTagToTask(taskId, tagId string) error
-	DeleteTagFromTask(taskId, tagId string) error
-	TaskTags(taskId string) ([]models.TaskTag, error)
-	Tags() ([]models.TaskTag, error)
-	TasksTags(taskIds []string) (map[string][]models.TaskTag, error)
-	DeleteTag(tagId string) error
-	DeleteTagFromAllTasks(tagId string) error
-}
-
-func SetDB(db Db) {
-	instance = db
-}
-
-func DB() Db {
-	return instance
+	Ping(ctx context.Context) error
+	// WithTx runs fn in a transaction the queries made with its context join
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	Tasks(ctx context.Context) ([]models.Task, error)
+	// FindTask returns the task; a transaction that changes it after reading it with FindTask
+	// does not overwrite the changes of another transaction
+	FindTask(ctx context.Context, taskId string) (models.Task, error)
+	FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error)
+	TaskCounts(ctx context.Context) (models.TaskCounts, error)
+	DeleteTask(ctx context.Context, taskId string) error
+	DeleteAllTasks(ctx context.Context) error
+	SaveTask(ctx context.Context, task models.Task) error
+	FindSettings(ctx context.Context, settingsId string) (models.Settings, error)
+	SaveSettings(ctx context.Context, s models.Settings) error
+	DeleteSettings(ctx context.Context, settingsId string) error
+	MigrationExists(ctx context.Context, id string) bool
+	RecordMigration(ctx context.Context, id string)
+	SaveTag(ctx context.Context, owner, tagId string) error
+	AddTagToTask(ctx context.Context, taskId, tagId string) error
+	DeleteTagFromTask(ctx context.Context, taskId, tagId string) error
+	TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error)
+	Tags(ctx context.Context, owner string) ([]models.TaskTag, error)
+	TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error)
+	DeleteTag(ctx context.Context, owner, tagId string) error
+	DeleteTagFromAllTasks(ctx context.Context, owner, tagId string) error
+	ForEachTask(ctx context.Context, fn func(models.Task) error) error
+	ForEachTag(ctx context.Context, fn func(models.TagRecord) error) error
+	ForEachTaskTag(ctx context.Context, fn func(taskId string, tag models.TaskTag) error) error
+	SaveTagRecord(ctx context.Context, tag models.TagRecord) error
+	AllSettings(ctx context.Context) ([]models.Settings, error)
+	Webhooks(ctx context.Context) ([]models.Webhook, error)
+	SaveWebhook(ctx context.Context, h models.Webhook) error
+	DeleteWebhook(ctx context.Context, webhookId string) error
+	SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error)
+	PruneWebhookDeliveries(ctx context.Context, keep int) error
+	FindUser(ctx context.Context, username string) (models.User, error)
+	CountUsers(ctx context.Context) (int, error)
+	Users(ctx context.Context) ([]models.User, error)
+	SaveUser(ctx context.Context, u models.User) error
+	SetUserAdmin(ctx context.Context, username string, admin bool) error
+	TransferOwnership(ctx context.Context, from, to string) error
+	DeleteUser(ctx context.Context, username string) error
+	SaveSession(ctx context.Context, s models.Session) error
+	FindSession(ctx context.Context, tokenHash string) (models.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) error
+	ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error)
+	FindApiTokenByHash(ctx context.Context, tokenHash string) (models.ApiToken, error)
+	SaveApiToken(ctx context.Context, t models.ApiToken) error
+	DeleteApiToken(ctx context.Context, username, tokenId string) error
+	TouchApiToken(ctx context.Context, tokenId string, used time.Time) error
+	Projects(ctx context.Context, username string) ([]models.Project, error)
+	AllProjects(ctx context.Context) ([]models.Project, error)
+	FindProject(ctx context.Context, projectId string) (models.Project, error)
+	SaveProject(ctx context.Context, p models.Project) error
+	DeleteProject(ctx context.Context, projectId string) error
 }
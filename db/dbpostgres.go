@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// DbPostgres keeps the data in a PostgreSQL database, which several instances of the server
+// can share. The times are stored in TIMESTAMPTZ columns.
+type DbPostgres struct {
+	instance *sql.DB
+}
+
+// pgMigrationLock is the advisory lock the migrations take, so that the servers starting
+// together on one database migrate it once
+const pgMigrationLock = 0x7072696f7461736b
+
+// pgMigrations create and update the schema, in order; each one runs once, in a transaction
+var pgMigrations = []struct {
+	id  string
+	sql string
+}{
+	{"postgres_add_schema", `
+		CREATE TABLE tasks (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL DEFAULT '',
+			content TEXT NOT NULL DEFAULT '',
+			created TIMESTAMPTZ NOT NULL,
+			updated TIMESTAMPTZ NOT NULL,
+			completed TIMESTAMPTZ NOT NULL,
+			priority INTEGER NOT NULL DEFAULT 0,
+			wip BOOLEAN NOT NULL DEFAULT FALSE,
+			planned BOOLEAN NOT NULL DEFAULT FALSE,
+			impact INTEGER NOT NULL DEFAULT 2,
+			cost INTEGER NOT NULL DEFAULT 2,
+			value DOUBLE PRECISION NOT NULL DEFAULT 0,
+			fun INTEGER NOT NULL DEFAULT 1,
+			owner TEXT NOT NULL DEFAULT '',
+			project TEXT NOT NULL DEFAULT '',
+			assignee TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX tasks_owner ON tasks (owner);
+		CREATE INDEX tasks_project ON tasks (project);
+
+		CREATE TABLE tags (
+			id TEXT,
+			created TIMESTAMPTZ NOT NULL,
+			owner TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (owner, id)
+		);
+		CREATE TABLE task_tags (
+			task_id TEXT REFERENCES tasks (id),
+			tag_id TEXT,
+			PRIMARY KEY (task_id, tag_id)
+		);
+		CREATE INDEX task_tags_tag_id ON task_tags (tag_id);
+
+		CREATE TABLE settings (
+			id TEXT PRIMARY KEY,
+			filter_completed BOOLEAN NOT NULL DEFAULT FALSE,
+			filter_incompleted BOOLEAN NOT NULL DEFAULT FALSE,
+			active_sort_column INTEGER NOT NULL DEFAULT 0,
+			active_sort_direction INTEGER NOT NULL DEFAULT 0,
+			completed_from TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+			completed_to TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+			filter_wip BOOLEAN NOT NULL DEFAULT FALSE,
+			filter_non_wip BOOLEAN NOT NULL DEFAULT FALSE,
+			planned BOOLEAN NOT NULL DEFAULT FALSE,
+			non_planned BOOLEAN NOT NULL DEFAULT FALSE,
+			tags JSONB,
+			search_text TEXT NOT NULL DEFAULT '',
+			enable_limit BOOLEAN NOT NULL DEFAULT TRUE,
+			limit_count INTEGER NOT NULL DEFAULT 10,
+			assigned_to_me BOOLEAN NOT NULL DEFAULT FALSE,
+			project TEXT NOT NULL DEFAULT '',
+			time_zone TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE TABLE webhooks (
+			id TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			events JSONB NOT NULL,
+			tags JSONB NOT NULL,
+			secret TEXT NOT NULL DEFAULT '',
+			created TIMESTAMPTZ NOT NULL
+		);
+		CREATE TABLE webhook_deliveries (
+			id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			delivery_id TEXT NOT NULL,
+			webhook_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			event TEXT NOT NULL,
+			task_id TEXT NOT NULL,
+			attempt INTEGER NOT NULL,
+			status_code INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			created TIMESTAMPTZ NOT NULL,
+			duration_ms BIGINT NOT NULL
+		);
+
+		CREATE TABLE users (
+			username TEXT PRIMARY KEY,
+			password_hash TEXT NOT NULL,
+			created TIMESTAMPTZ NOT NULL,
+			admin BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		CREATE TABLE sessions (
+			token_hash TEXT PRIMARY KEY,
+			username TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+			created TIMESTAMPTZ NOT NULL,
+			expires TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX sessions_username ON sessions (username);
+		CREATE TABLE api_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			scope TEXT NOT NULL,
+			username TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+			created TIMESTAMPTZ NOT NULL,
+			last_used TIMESTAMPTZ
+		);
+		CREATE INDEX api_tokens_username ON api_tokens (username);
+
+		CREATE TABLE projects (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			created TIMESTAMPTZ NOT NULL
+		);
+		CREATE TABLE project_members (
+			project_id TEXT REFERENCES projects (id),
+			username TEXT,
+			PRIMARY KEY (project_id, username)
+		);
+		CREATE INDEX project_members_username ON project_members (username);
+	`},
+}
+
+func NewDbPostgres() *DbPostgres {
+	return &DbPostgres{}
+}
+
+// Init connects to the database of the connection string, a postgres:// URL or the
+// key=value pairs of libpq, and migrates its schema
+func (d *DbPostgres) Init(dsn string) {
+	slog.Debug("Init")
+	if dsn == "" {
+		panic(errors.New("Init: the PostgreSQL connection string is empty"))
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		panic(err)
+	}
+	d.instance = db
+	if err := db.Ping(); err != nil {
+		panic(fmt.Errorf("Init: failed to connect to PostgreSQL: %w", err))
+	}
+
+	d.initMigration()
+	for _, m := range pgMigrations {
+		d.migrate(m.id, m.sql)
+	}
+}
+
+func (d *DbPostgres) initMigration() {
+	slog.Debug("initMigration")
+	err := d.WithTx(context.Background(), func(ctx context.Context) error {
+		if err := d.lockMigrations(ctx); err != nil {
+			return err
+		}
+		_, err := d.conn(ctx).ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+MIGRATION_TABLE_NAME+" (id TEXT PRIMARY KEY, time TIMESTAMPTZ)")
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// migrate applies the migration unless it was; the servers starting together wait for each
+// other on pgMigrationLock
+func (d *DbPostgres) migrate(id, migration string) {
+	err := d.WithTx(context.Background(), func(ctx context.Context) error {
+		if err := d.lockMigrations(ctx); err != nil {
+			return err
+		}
+		if d.MigrationExists(ctx, id) {
+			return nil
+		}
+		if _, err := d.conn(ctx).ExecContext(ctx, migration); err != nil {
+			return fmt.Errorf("migration %v: %w", id, err)
+		}
+		d.RecordMigration(ctx, id)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// lockMigrations takes pgMigrationLock until the transaction of ctx ends
+func (d *DbPostgres) lockMigrations(ctx context.Context) error {
+	if _, err := d.conn(ctx).ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", int64(pgMigrationLock)); err != nil {
+		return fmt.Errorf("failed to lock the migrations: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) MigrationExists(ctx context.Context, id string) bool {
+	defer observeQuery("MigrationExists", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT id FROM "+MIGRATION_TABLE_NAME+" WHERE id = $1", id)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+	var result = rows.Next()
+	slog.DebugContext(ctx, "MigrationExists", "id", id, "result", result)
+	return result
+}
+
+func (d *DbPostgres) RecordMigration(ctx context.Context, id string) {
+	defer observeQuery("RecordMigration", time.Now())
+	_, err := d.conn(ctx).ExecContext(ctx, "INSERT INTO "+MIGRATION_TABLE_NAME+" (id, time) VALUES ($1, $2)", id, storageTime(time.Now()))
+	if err != nil {
+		err := fmt.Sprintf("failed: %v: %v", id, err)
+		panic(err)
+	}
+	slog.DebugContext(ctx, "RecordMigration", "id", id)
+}
+
+// conn returns the transaction of d that ctx carries, the pool outside of them
+func (d *DbPostgres) conn(ctx context.Context) querier {
+	if uow := unitOfWorkOf(ctx, d); uow != nil {
+		return uow.tx
+	}
+	return d.instance
+}
+
+// WithTx runs fn in a transaction: every query made with the context fn gets is committed
+// together when fn succeeds, and rolled back when it fails. Nested calls join the outer
+// transaction. The transactions run side by side, a task read with FindTask stays locked for
+// the transaction until it ends.
+func (d *DbPostgres) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitOfWorkOf(ctx, d) != nil {
+		return fn(ctx)
+	}
+	tx, err := d.instance.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("WithTx: %w", err)
+	}
+	return runInTx(ctx, d, tx, fn)
+}
+
+// Ping checks that the database can still be queried
+func (d *DbPostgres) Ping(ctx context.Context) error {
+	defer observeQuery("Ping", time.Now())
+	var one int
+	if err := d.conn(ctx).QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("Ping: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) Close() {
+	slog.Debug("Close")
+	d.instance.Close()
+}
+
+// pgArgs are the arguments of a query built piece by piece
+type pgArgs []any
+
+// add appends the argument and returns its placeholder
+func (a *pgArgs) add(v any) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// pgLimit is the argument of LIMIT, where NULL takes all rows as a negative limit does on SQLite
+func pgLimit(limit int) any {
+	if limit < 0 {
+		return nil
+	}
+	return limit
+}
@@ -0,0 +1,636 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+	"github.com/lib/pq"
+)
+
+func (d *DbPostgres) scanNextTask(rows rowScanner) (models.Task, error) {
+	var task models.Task
+	err := rows.Scan(
+		&task.Id,
+		&task.Title,
+		&task.Content,
+		&task.Created,
+		&task.Updated,
+		&task.Completed,
+		&task.Priority,
+		&task.Wip,
+		&task.Planned,
+		&task.Impact,
+		&task.Cost,
+		&task.Value,
+		&task.Fun,
+		&task.Owner,
+		&task.Project,
+		&task.Assignee,
+	)
+	if err != nil {
+		return models.EMPTY_TASK, err
+	}
+	task.Created = utcTime(task.Created)
+	task.Updated = utcTime(task.Updated)
+	task.Completed = utcTime(task.Completed)
+	return task, nil
+}
+
+// queryTasks runs the query and scans the tasks it returns
+func (d *DbPostgres) queryTasks(ctx context.Context, query string, args ...any) ([]models.Task, error) {
+	rows, err := d.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Task
+	for rows.Next() {
+		task, err := d.scanNextTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, task)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tasks: %w", err)
+	}
+	return result, nil
+}
+
+func (d *DbPostgres) Tasks(ctx context.Context) ([]models.Task, error) {
+	defer observeQuery("Tasks", time.Now())
+	return d.queryTasks(ctx, "SELECT "+TASK_COLUMNS+" FROM tasks")
+}
+
+// ForEachTask streams all tasks ordered by creation time without loading them into memory
+func (d *DbPostgres) ForEachTask(ctx context.Context, fn func(models.Task) error) error {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+TASK_COLUMNS+" FROM tasks ORDER BY created, id")
+	if err != nil {
+		return fmt.Errorf("ForEachTask: failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := d.scanNextTask(rows)
+		if err != nil {
+			return fmt.Errorf("ForEachTask: %w", err)
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("ForEachTask: error iterating tasks: %w", err)
+	}
+	return nil
+}
+
+// FindTask locks the row of the task in a transaction, so that the transactions changing
+// the same task wait for each other and the one that waited reads the saved change
+func (d *DbPostgres) FindTask(ctx context.Context, taskId string) (models.Task, error) {
+	defer observeQuery("FindTask", time.Now())
+	query := "SELECT " + TASK_COLUMNS + " FROM tasks WHERE id = $1"
+	if unitOfWorkOf(ctx, d) != nil {
+		query += " FOR UPDATE"
+	}
+	row := d.conn(ctx).QueryRowContext(ctx, query, taskId)
+	task, err := d.scanNextTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.EMPTY_TASK, ErrNotFound
+	}
+	if err != nil {
+		return models.EMPTY_TASK, fmt.Errorf("failed to query task: %s: %w", taskId, err)
+	}
+	return task, nil
+}
+
+func (d *DbPostgres) DeleteTask(ctx context.Context, taskId string) error {
+	defer observeQuery("DeleteTask", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM task_tags WHERE task_id = $1", taskId); err != nil {
+			return fmt.Errorf("DeleteTask: %w", err)
+		}
+		result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM tasks WHERE id = $1", taskId)
+		if err != nil {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+		if rc, err := result.RowsAffected(); rc == 0 || err != nil {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (d *DbPostgres) DeleteAllTasks(ctx context.Context) error {
+	defer observeQuery("DeleteAllTasks", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM tasks"); err != nil {
+		return fmt.Errorf("failed to delete all tasks: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) SaveTask(ctx context.Context, task models.Task) error {
+	defer observeQuery("SaveTask", time.Now())
+	sql := "INSERT INTO tasks (" + TASK_COLUMNS + ") " +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (id) DO UPDATE SET
+			title=excluded.title,
+			content=excluded.content,
+			created=excluded.created,
+			updated=excluded.updated,
+			completed=excluded.completed,
+			priority=excluded.priority,
+			wip=excluded.wip,
+			planned=excluded.planned,
+			impact=excluded.impact,
+			cost=excluded.cost,
+			value=excluded.value,
+			fun=excluded.fun,
+			owner=excluded.owner,
+			project=excluded.project,
+			assignee=excluded.assignee
+	`
+	args := []any{
+		task.Id,
+		task.Title,
+		task.Content,
+		storageTime(task.Created),
+		storageTime(task.Updated),
+		storageTime(task.Completed),
+		task.Priority,
+		task.Wip,
+		task.Planned,
+		task.Impact,
+		task.Cost,
+		task.Value,
+		task.Fun,
+		task.Owner,
+		task.Project,
+		task.Assignee,
+	}
+	logQuery(ctx, "SaveTask", sql, args)
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("failed to save task: %v: %w", task, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) TaskCounts(ctx context.Context) (models.TaskCounts, error) {
+	defer observeQuery("TaskCounts", time.Now())
+	var counts models.TaskCounts
+	err := d.conn(ctx).QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE completed = $1),
+			COUNT(*) FILTER (WHERE completed != $1),
+			COUNT(*) FILTER (WHERE completed = $1 AND wip),
+			COUNT(*) FILTER (WHERE completed = $1 AND planned)
+		FROM tasks`,
+		storageTime(models.NOT_COMPLETED),
+	).Scan(&counts.Open, &counts.Completed, &counts.Wip, &counts.Planned)
+	if err != nil {
+		return counts, fmt.Errorf("TaskCounts: %w", err)
+	}
+	return counts, nil
+}
+
+// pgVisibleTasksCondition matches the tasks of the user and those of their projects
+func pgVisibleTasksCondition(args *pgArgs, username string) string {
+	p := args.add(username)
+	return "(owner = " + p + " OR project IN (SELECT project_id FROM project_members WHERE username = " + p + "))"
+}
+
+func (d *DbPostgres) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
+	defer observeQuery("FindTasks", time.Now())
+	var args pgArgs
+	sqlQuery := "SELECT " + TASK_COLUMNS + " FROM tasks WHERE " + pgVisibleTasksCondition(&args, query.Owner)
+
+	notCompleted := storageTime(models.NOT_COMPLETED)
+	if query.FilterCompleted {
+		sqlQuery += " AND completed = " + args.add(notCompleted)
+	} else {
+		if !query.CompletedFrom.IsZero() {
+			sqlQuery += " AND (completed >= " + args.add(storageTime(query.CompletedFrom)) + " OR completed = " + args.add(notCompleted) + ")"
+		}
+		if !query.CompletedTo.IsZero() {
+			sqlQuery += " AND (completed <= " + args.add(storageTime(query.CompletedTo)) + " OR completed = " + args.add(notCompleted) + ")"
+		}
+	}
+	if query.FilterIncompleted {
+		sqlQuery += " AND completed != " + args.add(notCompleted)
+	}
+	if query.FilterWip {
+		sqlQuery += " AND wip"
+	}
+	if query.FilterNonWip {
+		sqlQuery += " AND NOT wip"
+	}
+	if query.Planned {
+		sqlQuery += " AND planned"
+	}
+	if query.NonPlanned {
+		sqlQuery += " AND NOT planned"
+	}
+	if query.AssignedToMe {
+		sqlQuery += " AND assignee = " + args.add(query.Owner)
+	}
+	if query.Project != "" {
+		sqlQuery += " AND project = " + args.add(query.Project)
+	}
+	if len(query.Tags) > 0 {
+		tags := make([]string, len(query.Tags))
+		for i, t := range query.Tags {
+			tags[i] = string(t)
+		}
+		sqlQuery += " AND id IN (SELECT task_id FROM task_tags WHERE tag_id = ANY(" + args.add(pq.Array(tags)) + "))"
+	}
+	if query.SearchText != "" {
+		// as LIKE of SQLite: no escape character, _ and % stay wildcards
+		p := args.add("%" + query.SearchText + "%")
+		sqlQuery += " AND (title ILIKE " + p + " ESCAPE '' OR content ILIKE " + p + " ESCAPE '')"
+	}
+
+	if query.SortColumn != models.ColumnUndefined {
+		sqlQuery += " ORDER BY "
+		switch query.SortColumn {
+		case models.Completed:
+			sqlQuery += "completed"
+		case models.Created:
+			sqlQuery += "created"
+		case models.Priority:
+			sqlQuery += "priority"
+		case models.ColumnImpact:
+			sqlQuery += "impact"
+		case models.ColumnWip:
+			sqlQuery += "wip"
+		case models.ColumnPlanned:
+			sqlQuery += "planned"
+		case models.ColumnCost:
+			sqlQuery += "cost"
+		case models.ColumnValue:
+			sqlQuery += "value"
+		case models.ColumnFun:
+			sqlQuery += "fun"
+		default:
+			sqlQuery += "created" // default sort
+		}
+
+		if query.SortDirection == models.Desc {
+			sqlQuery += " DESC"
+		} else {
+			sqlQuery += " ASC"
+		}
+	}
+
+	if query.EnableLimit && query.LimitCount > 0 {
+		sqlQuery += " LIMIT " + args.add(query.LimitCount)
+	}
+
+	slog.DebugContext(ctx, "FindTasks", "query", query, "sql", sqlQuery, "args", args)
+	return d.queryTasks(ctx, sqlQuery, args...)
+}
+
+func (d *DbPostgres) SaveTag(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("SaveTag", time.Now())
+	sql := "INSERT INTO tags (" + TAGS_COLUMNS + ") VALUES ($1, $2, $3)"
+	args := []any{tagId, storageTime(time.Now()), owner}
+	logQuery(ctx, "SaveTag", sql, args)
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveTag: error; tagId=%v; %w", tagId, err)
+	}
+	return nil
+}
+
+// SaveTagRecord saves the tag preserving its creation time
+func (d *DbPostgres) SaveTagRecord(ctx context.Context, tag models.TagRecord) error {
+	defer observeQuery("SaveTagRecord", time.Now())
+	sql := "INSERT INTO tags (" + TAGS_COLUMNS + ") VALUES ($1, $2, $3)"
+	args := []any{string(tag.Id), storageTime(tag.Created), tag.Owner}
+	logQuery(ctx, "SaveTagRecord", sql, args)
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveTagRecord: error; tagId=%v; %w", tag.Id, err)
+	}
+	return nil
+}
+
+// AddTagToTask tags the task with a tag of the task owner; ErrNotFound when
+// either the task or the tag does not exist
+func (d *DbPostgres) AddTagToTask(ctx context.Context, taskId, tagId string) error {
+	defer observeQuery("AddTagToTask", time.Now())
+	sql := "INSERT INTO task_tags (" + TASKS_TAGS_COLUMNS + ") " +
+		"SELECT tasks.id, tags.id FROM tasks JOIN tags ON tags.owner = tasks.owner AND tags.id = $1 WHERE tasks.id = $2"
+	args := []any{tagId, taskId}
+	logQuery(ctx, "AddTagToTask", sql, args)
+
+	result, err := d.conn(ctx).ExecContext(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to add tag to task; taskId=%v; tagId=%v; %w", taskId, tagId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("AddTagToTask: failed to get affected rows; taskId=%v; tagId=%v; %w", taskId, tagId, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbPostgres) DeleteTagFromTask(ctx context.Context, taskId, tagId string) error {
+	defer observeQuery("DeleteTagFromTask", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM task_tags WHERE task_id = $1 AND tag_id = $2", taskId, tagId)
+	if err != nil {
+		return fmt.Errorf("DeleteTagFromTask: failed to delete tag from task; taskId=%v; tagId=%v; %w", taskId, tagId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteTagFromTask: failed to get affected rows; taskId=%v; tagId=%v; %w", taskId, tagId, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbPostgres) DeleteTagFromAllTasks(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("DeleteTagFromAllTasks", time.Now())
+	sql := "DELETE FROM task_tags WHERE tag_id = $1 AND task_id IN (SELECT id FROM tasks WHERE owner = $2)"
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, tagId, owner); err != nil {
+		return fmt.Errorf("DeleteTagFromAllTasks: failed to delete tag associations; tagId=%v; %w", tagId, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) DeleteTag(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("DeleteTag", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM tags WHERE owner = $1 AND id = $2", owner, tagId)
+	if err != nil {
+		return fmt.Errorf("DeleteTag: error; tagId=%v; %w", tagId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteTag: failed to get affected rows; tagId=%v; %w", tagId, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// queryTags runs the query and scans the tags of its first column
+func (d *DbPostgres) queryTags(ctx context.Context, query string, args ...any) ([]models.TaskTag, error) {
+	rows, err := d.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []models.TaskTag
+	for rows.Next() {
+		var tagId string
+		if err := rows.Scan(&tagId); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, models.TaskTag(tagId))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (d *DbPostgres) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
+	defer observeQuery("TaskTags", time.Now())
+	tags, err := d.queryTags(ctx, "SELECT tag_id FROM task_tags WHERE task_id = $1", taskId)
+	if err != nil {
+		return nil, fmt.Errorf("TaskTags: task %s: %w", taskId, err)
+	}
+	return tags, nil
+}
+
+func (d *DbPostgres) Tags(ctx context.Context, owner string) ([]models.TaskTag, error) {
+	defer observeQuery("Tags", time.Now())
+	tags, err := d.queryTags(ctx, "SELECT id FROM tags WHERE owner = $1 ORDER BY created DESC", owner)
+	if err != nil {
+		return nil, fmt.Errorf("Tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (d *DbPostgres) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
+	defer observeQuery("TasksTags", time.Now())
+	result := make(map[string][]models.TaskTag)
+	if len(taskIds) == 0 {
+		return result, nil
+	}
+
+	var args pgArgs
+	sql := "SELECT " + TASKS_TAGS_COLUMNS + " FROM task_tags WHERE task_id = ANY(" + args.add(pq.Array(taskIds)) + ")" +
+		" AND task_id IN (SELECT id FROM tasks WHERE " + pgVisibleTasksCondition(&args, owner) + ")"
+	logQuery(ctx, "TasksTags", sql, args)
+
+	err := d.forEachTaskTag(ctx, sql, args, func(taskId string, tag models.TaskTag) error {
+		result[taskId] = append(result[taskId], tag)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TasksTags: %w", err)
+	}
+	return result, nil
+}
+
+func (d *DbPostgres) ForEachTaskTag(ctx context.Context, fn func(taskId string, tag models.TaskTag) error) error {
+	err := d.forEachTaskTag(ctx, "SELECT "+TASKS_TAGS_COLUMNS+" FROM task_tags ORDER BY task_id, tag_id", nil, fn)
+	if err != nil {
+		return fmt.Errorf("ForEachTaskTag: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) forEachTaskTag(ctx context.Context, query string, args []any, fn func(taskId string, tag models.TaskTag) error) error {
+	rows, err := d.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskId, tagId string
+		if err := rows.Scan(&taskId, &tagId); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if err := fn(taskId, models.TaskTag(tagId)); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating tags: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) ForEachTag(ctx context.Context, fn func(models.TagRecord) error) error {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+TAGS_COLUMNS+" FROM tags ORDER BY owner, created, id")
+	if err != nil {
+		return fmt.Errorf("ForEachTag: failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag models.TagRecord
+		if err := rows.Scan(&tag.Id, &tag.Created, &tag.Owner); err != nil {
+			return fmt.Errorf("ForEachTag: failed to scan tag: %w", err)
+		}
+		tag.Created = utcTime(tag.Created)
+		if err := fn(tag); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("ForEachTag: error iterating tags: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) scanSettings(row rowScanner) (models.Settings, error) {
+	var settings models.Settings
+	var tagsText []byte
+	q := &settings.TasksQuery
+	err := row.Scan(
+		&settings.Id,
+		&q.FilterCompleted,
+		&q.FilterIncompleted,
+		&q.SortColumn,
+		&q.SortDirection,
+		&q.CompletedFrom,
+		&q.CompletedTo,
+		&q.FilterWip,
+		&q.FilterNonWip,
+		&q.Planned,
+		&q.NonPlanned,
+		&tagsText,
+		&q.SearchText,
+		&q.EnableLimit,
+		&q.LimitCount,
+		&q.AssignedToMe,
+		&q.Project,
+		&settings.TimeZone,
+	)
+	if err != nil {
+		return models.Settings{}, err
+	}
+	q.CompletedFrom = utcTime(q.CompletedFrom)
+	q.CompletedTo = utcTime(q.CompletedTo)
+	if len(tagsText) > 0 {
+		if err := json.Unmarshal(tagsText, &q.Tags); err != nil {
+			return models.Settings{}, fmt.Errorf("failed to pars tags: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+func (d *DbPostgres) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
+	defer observeQuery("FindSettings", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+SETTINGS_COLUMNS+" FROM settings WHERE id = $1", settingsId)
+	settings, err := d.scanSettings(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Settings{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Settings{}, fmt.Errorf("failed to fetch settings: %s: %w", settingsId, err)
+	}
+	return settings, nil
+}
+
+func (d *DbPostgres) AllSettings(ctx context.Context) ([]models.Settings, error) {
+	defer observeQuery("AllSettings", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+SETTINGS_COLUMNS+" FROM settings ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("AllSettings: failed to query settings: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Settings
+	for rows.Next() {
+		settings, err := d.scanSettings(rows)
+		if err != nil {
+			return nil, fmt.Errorf("AllSettings: %w", err)
+		}
+		result = append(result, settings)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AllSettings: error iterating settings: %w", err)
+	}
+	return result, nil
+}
+
+func (d *DbPostgres) DeleteSettings(ctx context.Context, settingsId string) error {
+	defer observeQuery("DeleteSettings", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM settings WHERE id = $1", settingsId); err != nil {
+		return fmt.Errorf("DeleteSettings: failed to delete settings %v: %w", settingsId, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) SaveSettings(ctx context.Context, s models.Settings) error {
+	defer observeQuery("SaveSettings", time.Now())
+	sqlQuery := "INSERT INTO settings (" + SETTINGS_COLUMNS + ") " +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		ON CONFLICT (id) DO UPDATE SET
+			filter_completed=excluded.filter_completed,
+			filter_incompleted=excluded.filter_incompleted,
+			active_sort_column=excluded.active_sort_column,
+			active_sort_direction=excluded.active_sort_direction,
+			completed_from=excluded.completed_from,
+			completed_to=excluded.completed_to,
+			filter_wip=excluded.filter_wip,
+			filter_non_wip=excluded.filter_non_wip,
+			planned=excluded.planned,
+			non_planned=excluded.non_planned,
+			tags=excluded.tags,
+			search_text=excluded.search_text,
+			enable_limit=excluded.enable_limit,
+			limit_count=excluded.limit_count,
+			assigned_to_me=excluded.assigned_to_me,
+			project=excluded.project,
+			time_zone=excluded.time_zone
+	`
+	tagsText, err := json.Marshal(s.TasksQuery.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %v: %w", s.TasksQuery.Tags, err)
+	}
+	slog.DebugContext(ctx, "SaveSettings", "query", s.TasksQuery)
+
+	args := []any{
+		s.Id,
+		s.TasksQuery.FilterCompleted,
+		s.TasksQuery.FilterIncompleted,
+		s.TasksQuery.SortColumn,
+		s.TasksQuery.SortDirection,
+		storageTime(s.TasksQuery.CompletedFrom),
+		storageTime(s.TasksQuery.CompletedTo),
+		s.TasksQuery.FilterWip,
+		s.TasksQuery.FilterNonWip,
+		s.TasksQuery.Planned,
+		s.TasksQuery.NonPlanned,
+		string(tagsText),
+		s.TasksQuery.SearchText,
+		s.TasksQuery.EnableLimit,
+		s.TasksQuery.LimitCount,
+		s.TasksQuery.AssignedToMe,
+		s.TasksQuery.Project,
+		s.TimeZone,
+	}
+	if _, err = d.conn(ctx).ExecContext(ctx, sqlQuery, args...); err != nil {
+		return fmt.Errorf("failed to save settings: %v: %w", s, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+// POSTGRES_TEST_DSN_ENV names the connection string of the database the tests of DbPostgres
+// run on, e.g. postgres://priotasks@localhost/priotasks_test?sslmode=disable; the tests are
+// skipped without it. Every test works in a schema of its own, dropped when it ends.
+const POSTGRES_TEST_DSN_ENV = "PRIOTASKS_TEST_POSTGRES_DSN"
+
+var postgresTestSchemas atomic.Int64
+
+// postgresTestDSN connects to the schema of the test, which is dropped when it ends
+func postgresTestDSN(t *testing.T) string {
+	dsn := os.Getenv(POSTGRES_TEST_DSN_ENV)
+	if dsn == "" {
+		t.Skip(POSTGRES_TEST_DSN_ENV + " is not set")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+	schema := fmt.Sprintf("priotasks_test_%d_%d", os.Getpid(), postgresTestSchemas.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("could not create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+	return withSearchPath(dsn, schema)
+}
+
+func setupPostgresDB(t *testing.T) *DbPostgres {
+	db := NewDbPostgres()
+	db.Init(postgresTestDSN(t))
+	t.Cleanup(db.Close)
+	return db
+}
+
+// withSearchPath adds the schema to the connection string, either a URL or key=value pairs
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+func TestConformance_DbPostgres(t *testing.T) {
+	if os.Getenv(POSTGRES_TEST_DSN_ENV) == "" {
+		t.Skip(POSTGRES_TEST_DSN_ENV + " is not set")
+	}
+	runConformance(t, func(t *testing.T) Db { return setupPostgresDB(t) })
+}
+
+func TestDbPostgres_InitTwice(t *testing.T) {
+	dsn := postgresTestDSN(t)
+	first := NewDbPostgres()
+	first.Init(dsn)
+	defer first.Close()
+
+	// a second server on the same database finds the schema migrated
+	second := NewDbPostgres()
+	second.Init(dsn)
+	defer second.Close()
+	for _, m := range pgMigrations {
+		if !second.MigrationExists(context.Background(), m.id) {
+			t.Errorf("expected migration %v to be recorded", m.id)
+		}
+	}
+}
+
+// renameInTx renames the task in a transaction, reading it first as the services do; held is
+// closed once the task is saved, the transaction ends when release is closed
+func renameInTx(d *DbPostgres, taskId, title string, held, release chan struct{}) error {
+	return d.WithTx(context.Background(), func(ctx context.Context) error {
+		task, err := d.FindTask(ctx, taskId)
+		if err != nil {
+			return err
+		}
+		task.Title = title
+		if err := d.SaveTask(ctx, task); err != nil {
+			return err
+		}
+		close(held)
+		<-release
+		return nil
+	})
+}
+
+func TestDbPostgres_ConcurrentWriters(t *testing.T) {
+	d := setupPostgresDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, id := range []string{"t1", "t2"} {
+		must(t, d.SaveTask(ctx, models.Task{Id: id, Title: "Before", Created: now, Updated: now, Completed: models.NOT_COMPLETED}))
+	}
+
+	firstHeld, firstRelease := make(chan struct{}), make(chan struct{})
+	first := make(chan error, 1)
+	go func() { first <- renameInTx(d, "t1", "First", firstHeld, firstRelease) }()
+	select {
+	case <-firstHeld:
+	case err := <-first:
+		t.Fatalf("the first writer failed: %v", err)
+	}
+	defer func() {
+		select {
+		case <-firstRelease:
+		default:
+			close(firstRelease)
+		}
+	}()
+
+	// a writer of another task does not wait for the open transaction
+	secondHeld, secondRelease := make(chan struct{}), make(chan struct{})
+	close(secondRelease)
+	second := make(chan error, 1)
+	go func() { second <- renameInTx(d, "t2", "Second", secondHeld, secondRelease) }()
+	select {
+	case err := <-second:
+		must(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writer of another task waited for the open transaction")
+	}
+
+	// a writer of the same task waits for it, then reads its change
+	read := make(chan string, 1)
+	third := make(chan error, 1)
+	go func() {
+		third <- d.WithTx(ctx, func(ctx context.Context) error {
+			task, err := d.FindTask(ctx, "t1")
+			read <- task.Title
+			return err
+		})
+	}()
+	select {
+	case title := <-read:
+		t.Fatalf("expected the writer of the same task to wait, it read %q", title)
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(firstRelease)
+	must(t, <-first)
+	if title := <-read; title != "First" {
+		t.Errorf("expected the waiting writer to read the saved change, got %q", title)
+	}
+	must(t, <-third)
+}
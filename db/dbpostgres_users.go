@@ -0,0 +1,419 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func (d *DbPostgres) scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	if err := row.Scan(&u.Username, &u.PasswordHash, &u.Created, &u.Admin); err != nil {
+		return u, err
+	}
+	u.Created = utcTime(u.Created)
+	return u, nil
+}
+
+func (d *DbPostgres) FindUser(ctx context.Context, username string) (models.User, error) {
+	defer observeQuery("FindUser", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+USERS_COLUMNS+" FROM users WHERE username = $1", username)
+	u, err := d.scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	if err != nil {
+		return u, fmt.Errorf("FindUser: %w", err)
+	}
+	return u, nil
+}
+
+func (d *DbPostgres) Users(ctx context.Context) ([]models.User, error) {
+	defer observeQuery("Users", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+USERS_COLUMNS+" FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("Users: failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := d.scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Users: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Users: error iterating users: %w", err)
+	}
+	return users, nil
+}
+
+func (d *DbPostgres) CountUsers(ctx context.Context) (int, error) {
+	defer observeQuery("CountUsers", time.Now())
+	var count int
+	if err := d.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return 0, fmt.Errorf("CountUsers: %w", err)
+	}
+	return count, nil
+}
+
+// SaveUser inserts the user or replaces the password of an existing one
+func (d *DbPostgres) SaveUser(ctx context.Context, u models.User) error {
+	defer observeQuery("SaveUser", time.Now())
+	sql := "INSERT INTO users (" + USERS_COLUMNS + ") VALUES ($1, $2, $3, $4) ON CONFLICT (username) DO UPDATE SET password_hash = excluded.password_hash"
+	logQuery(ctx, "SaveUser", sql, []any{u.Username})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, u.Username, u.PasswordHash, storageTime(u.Created), u.Admin); err != nil {
+		return fmt.Errorf("SaveUser: failed to save user %v: %w", u.Username, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) SetUserAdmin(ctx context.Context, username string, admin bool) error {
+	defer observeQuery("SetUserAdmin", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "UPDATE users SET admin = $1 WHERE username = $2", admin, username)
+	if err != nil {
+		return fmt.Errorf("SetUserAdmin: failed to update user %v: %w", username, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SetUserAdmin: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TransferOwnership hands the tasks, the tags, the projects, the project memberships and
+// the assignments of one user over to another; the tags both of them have are merged
+func (d *DbPostgres) TransferOwnership(ctx context.Context, from, to string) error {
+	defer observeQuery("TransferOwnership", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		statements := []string{
+			"UPDATE tasks SET owner = $1 WHERE owner = $2",
+			"UPDATE tasks SET assignee = $1 WHERE assignee = $2",
+			"INSERT INTO tags (" + TAGS_COLUMNS + ") SELECT id, created, $1::TEXT FROM tags WHERE owner = $2 ON CONFLICT DO NOTHING",
+			"UPDATE projects SET owner = $1 WHERE owner = $2",
+			"INSERT INTO project_members (" + PROJECT_MEMBERS_COLUMNS + ") SELECT project_id, $1::TEXT FROM project_members WHERE username = $2 ON CONFLICT DO NOTHING",
+		}
+		for _, stmt := range statements {
+			if _, err := d.conn(ctx).ExecContext(ctx, stmt, to, from); err != nil {
+				return fmt.Errorf("TransferOwnership: from %q to %q: %w", from, to, err)
+			}
+		}
+		for _, stmt := range []string{"DELETE FROM tags WHERE owner = $1", "DELETE FROM project_members WHERE username = $1"} {
+			if _, err := d.conn(ctx).ExecContext(ctx, stmt, from); err != nil {
+				return fmt.Errorf("TransferOwnership: from %q to %q: %w", from, to, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteUser deletes the user and signs out their sessions
+func (d *DbPostgres) DeleteUser(ctx context.Context, username string) error {
+	defer observeQuery("DeleteUser", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE username = $1", username)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: failed to delete user %v: %w", username, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteUser: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbPostgres) SaveSession(ctx context.Context, s models.Session) error {
+	defer observeQuery("SaveSession", time.Now())
+	sql := "INSERT INTO sessions (" + SESSIONS_COLUMNS + ") VALUES ($1, $2, $3, $4)"
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, s.TokenHash, s.Username, storageTime(s.Created), storageTime(s.Expires)); err != nil {
+		return fmt.Errorf("SaveSession: failed to save session of %v: %w", s.Username, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) FindSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	defer observeQuery("FindSession", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+SESSIONS_COLUMNS+" FROM sessions WHERE token_hash = $1", tokenHash)
+	var s models.Session
+	err := row.Scan(&s.TokenHash, &s.Username, &s.Created, &s.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	if err != nil {
+		return s, fmt.Errorf("FindSession: %w", err)
+	}
+	s.Created = utcTime(s.Created)
+	s.Expires = utcTime(s.Expires)
+	return s, nil
+}
+
+func (d *DbPostgres) DeleteSession(ctx context.Context, tokenHash string) error {
+	defer observeQuery("DeleteSession", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = $1", tokenHash); err != nil {
+		return fmt.Errorf("DeleteSession: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions that expired before the time
+func (d *DbPostgres) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	defer observeQuery("DeleteExpiredSessions", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM sessions WHERE expires <= $1", storageTime(now)); err != nil {
+		return fmt.Errorf("DeleteExpiredSessions: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) scanApiToken(row rowScanner) (models.ApiToken, error) {
+	var t models.ApiToken
+	var scope string
+	var lastUsed sql.NullTime
+	if err := row.Scan(&t.Id, &t.Name, &t.TokenHash, &scope, &t.Username, &t.Created, &lastUsed); err != nil {
+		return t, err
+	}
+	t.Scope = models.ApiTokenScope(scope)
+	t.Created = utcTime(t.Created)
+	if lastUsed.Valid {
+		t.LastUsed = utcTime(lastUsed.Time)
+	}
+	return t, nil
+}
+
+// ApiTokens returns the tokens of the user, the newest first
+func (d *DbPostgres) ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error) {
+	defer observeQuery("ApiTokens", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+API_TOKENS_COLUMNS+" FROM api_tokens WHERE username = $1 ORDER BY created DESC, id", username)
+	if err != nil {
+		return nil, fmt.Errorf("ApiTokens: failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.ApiToken
+	for rows.Next() {
+		t, err := d.scanApiToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ApiTokens: %w", err)
+		}
+		result = append(result, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ApiTokens: error iterating api tokens: %w", err)
+	}
+	return result, nil
+}
+
+func (d *DbPostgres) FindApiTokenByHash(ctx context.Context, tokenHash string) (models.ApiToken, error) {
+	defer observeQuery("FindApiTokenByHash", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+API_TOKENS_COLUMNS+" FROM api_tokens WHERE token_hash = $1", tokenHash)
+	t, err := d.scanApiToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrNotFound
+	}
+	if err != nil {
+		return t, fmt.Errorf("FindApiTokenByHash: %w", err)
+	}
+	return t, nil
+}
+
+func (d *DbPostgres) SaveApiToken(ctx context.Context, t models.ApiToken) error {
+	defer observeQuery("SaveApiToken", time.Now())
+	sql := "INSERT INTO api_tokens (" + API_TOKENS_COLUMNS + ") VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	var lastUsed any
+	if t.IsUsed() {
+		lastUsed = storageTime(t.LastUsed)
+	}
+	args := []any{t.Id, t.Name, t.TokenHash, string(t.Scope), t.Username, storageTime(t.Created), lastUsed}
+	logQuery(ctx, "SaveApiToken", sql, []any{t.Id, t.Name, t.Scope, t.Username})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveApiToken: failed to save api token %v: %w", t.Id, err)
+	}
+	return nil
+}
+
+// DeleteApiToken revokes the token of the user
+func (d *DbPostgres) DeleteApiToken(ctx context.Context, username, tokenId string) error {
+	defer observeQuery("DeleteApiToken", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM api_tokens WHERE id = $1 AND username = $2", tokenId, username)
+	if err != nil {
+		return fmt.Errorf("DeleteApiToken: failed to delete api token %v: %w", tokenId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteApiToken: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbPostgres) TouchApiToken(ctx context.Context, tokenId string, used time.Time) error {
+	defer observeQuery("TouchApiToken", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "UPDATE api_tokens SET last_used = $1 WHERE id = $2", storageTime(used), tokenId); err != nil {
+		return fmt.Errorf("TouchApiToken: %w", err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) scanProject(row rowScanner) (models.Project, error) {
+	var p models.Project
+	if err := row.Scan(&p.Id, &p.Name, &p.Owner, &p.Created); err != nil {
+		return p, err
+	}
+	p.Created = utcTime(p.Created)
+	return p, nil
+}
+
+// queryProjects runs the query and scans the projects it returns with their members
+func (d *DbPostgres) queryProjects(ctx context.Context, query string, args ...any) ([]models.Project, error) {
+	rows, err := d.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Project
+	for rows.Next() {
+		p, err := d.scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %w", err)
+	}
+	rows.Close()
+
+	for i := range result {
+		if result[i].Members, err = d.projectMembers(ctx, result[i].Id); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Projects returns the projects the user is a member of, with their members
+func (d *DbPostgres) Projects(ctx context.Context, username string) ([]models.Project, error) {
+	defer observeQuery("Projects", time.Now())
+	sql := "SELECT " + PROJECTS_COLUMNS + " FROM projects WHERE id IN (SELECT project_id FROM project_members WHERE username = $1) ORDER BY name, id"
+	projects, err := d.queryProjects(ctx, sql, username)
+	if err != nil {
+		return nil, fmt.Errorf("Projects: %w", err)
+	}
+	return projects, nil
+}
+
+// AllProjects returns every project with its members, ordered by creation time
+func (d *DbPostgres) AllProjects(ctx context.Context) ([]models.Project, error) {
+	defer observeQuery("AllProjects", time.Now())
+	projects, err := d.queryProjects(ctx, "SELECT "+PROJECTS_COLUMNS+" FROM projects ORDER BY created, id")
+	if err != nil {
+		return nil, fmt.Errorf("AllProjects: %w", err)
+	}
+	return projects, nil
+}
+
+func (d *DbPostgres) FindProject(ctx context.Context, projectId string) (models.Project, error) {
+	defer observeQuery("FindProject", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+PROJECTS_COLUMNS+" FROM projects WHERE id = $1", projectId)
+	p, err := d.scanProject(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	}
+	if err != nil {
+		return p, fmt.Errorf("FindProject: %w", err)
+	}
+	if p.Members, err = d.projectMembers(ctx, projectId); err != nil {
+		return p, fmt.Errorf("FindProject: %w", err)
+	}
+	return p, nil
+}
+
+func (d *DbPostgres) projectMembers(ctx context.Context, projectId string) ([]string, error) {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT username FROM project_members WHERE project_id = $1 ORDER BY username", projectId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the members of project %v: %w", projectId, err)
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, username)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating members: %w", err)
+	}
+	return members, nil
+}
+
+// SaveProject inserts or renames the project and replaces its members
+func (d *DbPostgres) SaveProject(ctx context.Context, p models.Project) error {
+	defer observeQuery("SaveProject", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		sql := "INSERT INTO projects (" + PROJECTS_COLUMNS + ") VALUES ($1, $2, $3, $4) " +
+			"ON CONFLICT (id) DO UPDATE SET name=excluded.name, owner=excluded.owner"
+		args := []any{p.Id, p.Name, p.Owner, storageTime(p.Created)}
+		logQuery(ctx, "SaveProject", sql, args)
+		tx := d.conn(ctx)
+		if _, err := tx.ExecContext(ctx, sql, args...); err != nil {
+			return fmt.Errorf("SaveProject: failed to save project %v: %w", p.Id, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM project_members WHERE project_id = $1", p.Id); err != nil {
+			return fmt.Errorf("SaveProject: failed to clear the members of %v: %w", p.Id, err)
+		}
+		for _, username := range p.Members {
+			_, err := tx.ExecContext(ctx, "INSERT INTO project_members ("+PROJECT_MEMBERS_COLUMNS+") VALUES ($1, $2) ON CONFLICT DO NOTHING", p.Id, username)
+			if err != nil {
+				return fmt.Errorf("SaveProject: failed to add member %v to %v: %w", username, p.Id, err)
+			}
+		}
+		// the tasks of the project keep only assignees who are still members
+		_, err := tx.ExecContext(ctx, "UPDATE tasks SET assignee = '' WHERE project = $1 AND assignee != '' AND assignee NOT IN (SELECT username FROM project_members WHERE project_id = $1)", p.Id)
+		if err != nil {
+			return fmt.Errorf("SaveProject: failed to unassign former members of %v: %w", p.Id, err)
+		}
+		return nil
+	})
+}
+
+// DeleteProject deletes the project, its tasks become private tasks of their owners
+// again and are unassigned from everyone else
+func (d *DbPostgres) DeleteProject(ctx context.Context, projectId string) error {
+	defer observeQuery("DeleteProject", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		tx := d.conn(ctx)
+		_, err := tx.ExecContext(ctx, "UPDATE tasks SET project = '', assignee = CASE WHEN assignee = owner THEN assignee ELSE '' END WHERE project = $1", projectId)
+		if err != nil {
+			return fmt.Errorf("DeleteProject: failed to release the tasks of %v: %w", projectId, err)
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM project_members WHERE project_id = $1", projectId); err != nil {
+			return fmt.Errorf("DeleteProject: failed to delete the members of %v: %w", projectId, err)
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = $1", projectId)
+		if err != nil {
+			return fmt.Errorf("DeleteProject: failed to delete project %v: %w", projectId, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
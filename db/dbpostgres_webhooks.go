@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func (d *DbPostgres) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	defer observeQuery("Webhooks", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+WEBHOOKS_COLUMNS+" FROM webhooks ORDER BY created, id")
+	if err != nil {
+		return nil, fmt.Errorf("Webhooks: failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Webhook
+	for rows.Next() {
+		var h models.Webhook
+		var eventsText, tagsText []byte
+		if err := rows.Scan(&h.Id, &h.Url, &eventsText, &tagsText, &h.Secret, &h.Created); err != nil {
+			return nil, fmt.Errorf("Webhooks: failed to scan webhook: %w", err)
+		}
+		if err := json.Unmarshal(eventsText, &h.Events); err != nil {
+			return nil, fmt.Errorf("Webhooks: invalid events of webhook %v: %w", h.Id, err)
+		}
+		if err := json.Unmarshal(tagsText, &h.Tags); err != nil {
+			return nil, fmt.Errorf("Webhooks: invalid tags of webhook %v: %w", h.Id, err)
+		}
+		h.Created = utcTime(h.Created)
+		result = append(result, h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Webhooks: error iterating webhooks: %w", err)
+	}
+	return result, nil
+}
+
+// SaveWebhook inserts the webhook or replaces the one with the same id
+func (d *DbPostgres) SaveWebhook(ctx context.Context, h models.Webhook) error {
+	defer observeQuery("SaveWebhook", time.Now())
+	events, err := json.Marshal(h.Events)
+	if err != nil {
+		return fmt.Errorf("SaveWebhook: %w", err)
+	}
+	tags := []byte("[]")
+	if len(h.Tags) > 0 {
+		if tags, err = json.Marshal(h.Tags); err != nil {
+			return fmt.Errorf("SaveWebhook: %w", err)
+		}
+	}
+	sql := "INSERT INTO webhooks (" + WEBHOOKS_COLUMNS + ") VALUES ($1, $2, $3, $4, $5, $6) " +
+		"ON CONFLICT (id) DO UPDATE SET url=excluded.url, events=excluded.events, tags=excluded.tags, secret=excluded.secret, created=excluded.created"
+	args := []any{h.Id, h.Url, string(events), string(tags), h.Secret, storageTime(h.Created)}
+	logQuery(ctx, "SaveWebhook", sql, []any{h.Id, h.Url, string(events), string(tags)})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveWebhook: failed to save webhook %v: %w", h.Id, err)
+	}
+	return nil
+}
+
+func (d *DbPostgres) DeleteWebhook(ctx context.Context, webhookId string) error {
+	defer observeQuery("DeleteWebhook", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", webhookId)
+	if err != nil {
+		return fmt.Errorf("DeleteWebhook: failed to delete webhook %v: %w", webhookId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteWebhook: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveWebhookDelivery appends the attempt to the delivery log, the id is assigned by the database
+func (d *DbPostgres) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	defer observeQuery("SaveWebhookDelivery", time.Now())
+	sql := "INSERT INTO webhook_deliveries (delivery_id, webhook_id, url, event, task_id, attempt, status_code, error, created, duration_ms) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	args := []any{
+		delivery.DeliveryId,
+		delivery.WebhookId,
+		delivery.Url,
+		string(delivery.Event),
+		delivery.TaskId,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.Error,
+		storageTime(delivery.Created),
+		delivery.Duration.Milliseconds(),
+	}
+	logQuery(ctx, "SaveWebhookDelivery", sql, args)
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveWebhookDelivery: failed to save delivery %v: %w", delivery.DeliveryId, err)
+	}
+	return nil
+}
+
+// WebhookDeliveries returns the latest attempts first, all of them for a negative limit
+func (d *DbPostgres) WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	defer observeQuery("WebhookDeliveries", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+WEBHOOK_DELIVERIES_COLUMNS+" FROM webhook_deliveries ORDER BY id DESC LIMIT $1", pgLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("WebhookDeliveries: failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var event string
+		var durationMs int64
+		err := rows.Scan(
+			&delivery.Id,
+			&delivery.DeliveryId,
+			&delivery.WebhookId,
+			&delivery.Url,
+			&event,
+			&delivery.TaskId,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&delivery.Created,
+			&durationMs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("WebhookDeliveries: failed to scan delivery: %w", err)
+		}
+		delivery.Event = models.WebhookEvent(event)
+		delivery.Duration = time.Duration(durationMs) * time.Millisecond
+		delivery.Created = utcTime(delivery.Created)
+		result = append(result, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("WebhookDeliveries: error iterating deliveries: %w", err)
+	}
+	return result, nil
+}
+
+// PruneWebhookDeliveries keeps only the latest attempts in the delivery log
+func (d *DbPostgres) PruneWebhookDeliveries(ctx context.Context, keep int) error {
+	defer observeQuery("PruneWebhookDeliveries", time.Now())
+	sql := "DELETE FROM webhook_deliveries WHERE id NOT IN (SELECT id FROM webhook_deliveries ORDER BY id DESC LIMIT $1)"
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, pgLimit(keep)); err != nil {
+		return fmt.Errorf("PruneWebhookDeliveries: %w", err)
+	}
+	return nil
+}
@@ -1,25 +1,25 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
-	"github.com/inaryzen/priotasks/common"
 	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/models"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	SETTINGS_COLUMNS = "id, filter_completed, filter_incompleted, active_sort_column, active_sort_direction, completed_from, completed_to, filter_wip, filter_non_wip, planned, non_planned, tags, search_text, enable_limit, limit_count"
+	SETTINGS_COLUMNS = "id, filter_completed, filter_incompleted, active_sort_column, active_sort_direction, completed_from, completed_to, filter_wip, filter_non_wip, planned, non_planned, tags, search_text, enable_limit, limit_count, assigned_to_me, project, time_zone"
 )
 
 func (d *DbSQLite) initSettings() {
-	common.Debug("initSettings")
+	slog.Debug("initSettings")
 	_, err := d.instance.Exec(`
 		CREATE TABLE IF NOT EXISTS settings (
 			id TEXT PRIMARY KEY,
@@ -36,7 +36,7 @@ func (d *DbSQLite) initSettings() {
 		);
 	`)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 
 	d.addSettingsCompletedFrom()
@@ -47,50 +47,79 @@ func (d *DbSQLite) initSettings() {
 	d.settingsTableAddTagsColumn()
 	d.settingsTableAddSearchTextColumn()
 	d.settingsTableAddLimitColumns()
+	d.settingsTableAddProjectColumns()
+	d.settingsTableAddTimeZoneColumn()
+}
+
+func (d *DbSQLite) settingsTableAddTimeZoneColumn() {
+	id := "settings_table_add_time_zone_column"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec("ALTER TABLE settings ADD COLUMN time_zone TEXT DEFAULT ''")
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
+}
+
+func (d *DbSQLite) settingsTableAddProjectColumns() {
+	id := "settings_table_add_assigned_to_me_and_project_columns"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec(`
+			ALTER TABLE settings ADD COLUMN assigned_to_me BOOLEAN DEFAULT 0;
+			ALTER TABLE settings ADD COLUMN project TEXT DEFAULT '';
+		`)
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
 }
 
 func (d *DbSQLite) settingsTableAddTagsColumn() {
 	id := "settings_table_add_tags_column"
-	if !d.MigrationExists(id) {
+	if !d.MigrationExists(context.Background(), id) {
 		_, err := d.instance.Exec("ALTER TABLE settings ADD COLUMN tags TEXT DEFAULT ''")
 		if err != nil {
 			panic(err)
 		} else {
-			d.RecordMigration(id)
+			d.RecordMigration(context.Background(), id)
 		}
 	}
 }
 
 func (d *DbSQLite) settingsTableAddSearchTextColumn() {
 	id := "settings_table_add_search_text_column"
-	if !d.MigrationExists(id) {
+	if !d.MigrationExists(context.Background(), id) {
 		_, err := d.instance.Exec("ALTER TABLE settings ADD COLUMN search_text TEXT DEFAULT ''")
 		if err != nil {
 			panic(err)
 		} else {
-			d.RecordMigration(id)
+			d.RecordMigration(context.Background(), id)
 		}
 	}
 }
 
 func (d *DbSQLite) settingsTableAddLimitColumns() {
 	enableLimitId := "settings_table_add_enable_limit_column"
-	if !d.MigrationExists(enableLimitId) {
+	if !d.MigrationExists(context.Background(), enableLimitId) {
 		_, err := d.instance.Exec("ALTER TABLE settings ADD COLUMN enable_limit BOOLEAN DEFAULT 1")
 		if err != nil {
 			panic(err)
 		} else {
-			d.RecordMigration(enableLimitId)
+			d.RecordMigration(context.Background(), enableLimitId)
 		}
 	}
 
 	limitCountId := "settings_table_add_limit_count_column"
-	if !d.MigrationExists(limitCountId) {
+	if !d.MigrationExists(context.Background(), limitCountId) {
 		_, err := d.instance.Exec("ALTER TABLE settings ADD COLUMN limit_count INTEGER DEFAULT 10")
 		if err != nil {
 			panic(err)
 		} else {
-			d.RecordMigration(limitCountId)
+			d.RecordMigration(context.Background(), limitCountId)
 		}
 	}
 }
@@ -146,12 +175,50 @@ func (d *DbSQLite) addSettingsPlannedAndNonPlanned() {
 	}
 }
 
-func (d *DbSQLite) FindSettings(settingsId string) (models.Settings, error) {
+func (d *DbSQLite) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
+	defer observeQuery("FindSettings", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+SETTINGS_COLUMNS+" FROM settings WHERE id = ?", settingsId)
+	settings, err := scanSettings(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Settings{}, ErrNotFound
+		}
+		return models.Settings{}, fmt.Errorf("failed to fetch settings: %s: %w", settingsId, err)
+	}
+	return settings, nil
+}
+
+func (d *DbSQLite) AllSettings(ctx context.Context) ([]models.Settings, error) {
+	defer observeQuery("AllSettings", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+SETTINGS_COLUMNS+" FROM settings ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("AllSettings: failed to query settings: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Settings
+	for rows.Next() {
+		settings, err := scanSettings(rows)
+		if err != nil {
+			return nil, fmt.Errorf("AllSettings: %w", err)
+		}
+		result = append(result, settings)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AllSettings: error iterating settings: %w", err)
+	}
+	return result, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSettings(row rowScanner) (models.Settings, error) {
 	var settings models.Settings
 	var completedFrom, completedTo string
 	var tagsText string
 
-	row := d.instance.QueryRow("SELECT "+SETTINGS_COLUMNS+" FROM settings WHERE id = ?", settingsId)
 	err := row.Scan(
 		&settings.Id,
 		&settings.TasksQuery.FilterCompleted,
@@ -168,27 +235,25 @@ func (d *DbSQLite) FindSettings(settingsId string) (models.Settings, error) {
 		&settings.TasksQuery.SearchText,
 		&settings.TasksQuery.EnableLimit,
 		&settings.TasksQuery.LimitCount,
+		&settings.TasksQuery.AssignedToMe,
+		&settings.TasksQuery.Project,
+		&settings.TimeZone,
 	)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.Settings{}, ErrNotFound
-		}
-		return models.Settings{}, fmt.Errorf("failed to fetch settings: %s: %w", settingsId, err)
+		return models.Settings{}, err
 	}
 
-	if completedFrom == "" {
-		completedFrom = time.Time{}.Format(consts.DEFAULT_DATE_FORMAT)
-	}
-	settings.TasksQuery.CompletedFrom, err = time.Parse(consts.DEFAULT_DATE_FORMAT, completedFrom)
-	if err != nil {
-		return models.Settings{}, fmt.Errorf("failed to parse completed_from: %w", err)
-	}
-	if completedTo == "" {
-		completedTo = time.Time{}.Format(consts.DEFAULT_DATE_FORMAT)
+	if completedFrom != "" {
+		settings.TasksQuery.CompletedFrom, err = parseStorageTime(completedFrom)
+		if err != nil {
+			return models.Settings{}, fmt.Errorf("failed to parse completed_from: %w", err)
+		}
 	}
-	settings.TasksQuery.CompletedTo, err = time.Parse(consts.DEFAULT_DATE_FORMAT, completedTo)
-	if err != nil {
-		return models.Settings{}, fmt.Errorf("failed to parse completed_to: %w", err)
+	if completedTo != "" {
+		settings.TasksQuery.CompletedTo, err = parseStorageTime(completedTo)
+		if err != nil {
+			return models.Settings{}, fmt.Errorf("failed to parse completed_to: %w", err)
+		}
 	}
 	if tagsText != "" {
 		err = json.Unmarshal([]byte(tagsText), &settings.TasksQuery.Tags)
@@ -199,10 +264,19 @@ func (d *DbSQLite) FindSettings(settingsId string) (models.Settings, error) {
 	return settings, nil
 }
 
-func (d *DbSQLite) SaveSettings(s models.Settings) error {
+func (d *DbSQLite) DeleteSettings(ctx context.Context, settingsId string) error {
+	defer observeQuery("DeleteSettings", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM settings WHERE id = ?", settingsId); err != nil {
+		return fmt.Errorf("DeleteSettings: failed to delete settings %v: %w", settingsId, err)
+	}
+	return nil
+}
+
+func (d *DbSQLite) SaveSettings(ctx context.Context, s models.Settings) error {
+	defer observeQuery("SaveSettings", time.Now())
 	sqlQuery :=
 		"INSERT INTO settings (" + SETTINGS_COLUMNS + ") " +
-			`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         ON CONFLICT(id) DO UPDATE SET
             filter_completed=excluded.filter_completed,
             filter_incompleted=excluded.filter_incompleted,
@@ -217,22 +291,17 @@ func (d *DbSQLite) SaveSettings(s models.Settings) error {
 			tags=excluded.tags,
 			search_text=excluded.search_text,
 			enable_limit=excluded.enable_limit,
-			limit_count=excluded.limit_count
+			limit_count=excluded.limit_count,
+			assigned_to_me=excluded.assigned_to_me,
+			project=excluded.project,
+			time_zone=excluded.time_zone
     `
-	completedFrom := time.Time{}.Format(consts.DEFAULT_DATE_FORMAT)
-	if !s.TasksQuery.CompletedFrom.IsZero() {
-		completedFrom = s.TasksQuery.CompletedFrom.Format(consts.DEFAULT_DATE_FORMAT)
-	}
-	completedTo := time.Time{}.Format(consts.DEFAULT_DATE_FORMAT)
-	if !s.TasksQuery.CompletedTo.IsZero() {
-		completedTo = s.TasksQuery.CompletedTo.Format(consts.DEFAULT_DATE_FORMAT)
-	}
 	tagsText, err := json.Marshal(s.TasksQuery.Tags)
 	if err != nil {
 		return fmt.Errorf("failed to marshal tags: %v: %w", s.TasksQuery.Tags, err)
 	}
 
-	common.Debug("SaveSettings: %v", s.TasksQuery)
+	slog.DebugContext(ctx, "SaveSettings", "query", s.TasksQuery)
 
 	args := []any{
 		s.Id,
@@ -240,8 +309,8 @@ func (d *DbSQLite) SaveSettings(s models.Settings) error {
 		s.TasksQuery.FilterIncompleted,
 		s.TasksQuery.SortColumn,
 		s.TasksQuery.SortDirection,
-		completedFrom,
-		completedTo,
+		storageTime(s.TasksQuery.CompletedFrom),
+		storageTime(s.TasksQuery.CompletedTo),
 		s.TasksQuery.FilterWip,
 		s.TasksQuery.FilterNonWip,
 		s.TasksQuery.Planned,
@@ -250,9 +319,12 @@ func (d *DbSQLite) SaveSettings(s models.Settings) error {
 		s.TasksQuery.SearchText,
 		s.TasksQuery.EnableLimit,
 		s.TasksQuery.LimitCount,
+		s.TasksQuery.AssignedToMe,
+		s.TasksQuery.Project,
+		s.TimeZone,
 	}
 
-	_, err = d.instance.Exec(sqlQuery, args...)
+	_, err = d.conn(ctx).ExecContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return fmt.Errorf("failed to save settings: %v: %w", s, err)
 	}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -32,13 +33,13 @@ func TestSaveAndFindSettings_Success(t *testing.T) {
 	}
 
 	// Save settings
-	err := db.SaveSettings(settings)
+	err := db.SaveSettings(context.Background(), settings)
 	if err != nil {
 		t.Fatalf("SaveSettings failed: %v", err)
 	}
 
 	// Find settings
-	found, err := db.FindSettings(settings.Id)
+	found, err := db.FindSettings(context.Background(), settings.Id)
 	if err != nil {
 		t.Fatalf("FindSettings failed: %v", err)
 	}
@@ -76,7 +77,7 @@ func TestSaveAndFindSettings_Success(t *testing.T) {
 func TestFindSettings_NonExistent(t *testing.T) {
 	db := setupTestDB(t)
 
-	_, err := db.FindSettings("non-existent-id")
+	_, err := db.FindSettings(context.Background(), "non-existent-id")
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound for non-existent settings, got: %v", err)
 	}
@@ -98,7 +99,7 @@ func TestSaveSettings_Update(t *testing.T) {
 	}
 
 	// Save initial settings
-	err := db.SaveSettings(settings)
+	err := db.SaveSettings(context.Background(), settings)
 	if err != nil {
 		t.Fatalf("Initial SaveSettings failed: %v", err)
 	}
@@ -110,13 +111,13 @@ func TestSaveSettings_Update(t *testing.T) {
 	settings.TasksQuery.LimitCount = 50
 
 	// Update settings
-	err = db.SaveSettings(settings)
+	err = db.SaveSettings(context.Background(), settings)
 	if err != nil {
 		t.Fatalf("Update SaveSettings failed: %v", err)
 	}
 
 	// Verify updates
-	found, err := db.FindSettings(settings.Id)
+	found, err := db.FindSettings(context.Background(), settings.Id)
 	if err != nil {
 		t.Fatalf("FindSettings failed after update: %v", err)
 	}
@@ -150,13 +151,13 @@ func TestSaveSettings_WithDates(t *testing.T) {
 	}
 
 	// Save settings
-	err := db.SaveSettings(settings)
+	err := db.SaveSettings(context.Background(), settings)
 	if err != nil {
 		t.Fatalf("SaveSettings failed: %v", err)
 	}
 
 	// Find settings
-	found, err := db.FindSettings(settings.Id)
+	found, err := db.FindSettings(context.Background(), settings.Id)
 	if err != nil {
 		t.Fatalf("FindSettings failed: %v", err)
 	}
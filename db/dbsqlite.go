@@ -1,10 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
+	"time"
 
 	"github.com/inaryzen/priotasks/common"
 	_ "modernc.org/sqlite"
@@ -12,6 +14,17 @@ import (
 
 type DbSQLite struct {
 	instance *sql.DB
+	// legacyLocation is the time zone the times written before they were stored in UTC are
+	// read in, the local time zone of the server when nil
+	legacyLocation *time.Location
+}
+
+var queryDuration = common.NewHistogramVec("priotasks_db_query_duration_seconds",
+	"Duration of the database queries by the operation of the database.", common.QUERY_BUCKETS, "operation")
+
+// observeQuery records the duration of the operation started at start, deferred by the operations
+func observeQuery(operation string, start time.Time) {
+	queryDuration.Observe(time.Since(start).Seconds(), operation)
 }
 
 func NewDbSQLite() *DbSQLite {
@@ -19,30 +32,37 @@ func NewDbSQLite() *DbSQLite {
 }
 
 func (d *DbSQLite) Init(dbFile string) {
-	common.Debug("dbsql init...")
+	slog.Debug("Init")
 	if dbFile == "" {
 		dir, err := common.ResolveAppDir()
 		if err != nil {
-			log.Fatalf("Failed to get home directory: %v", err)
+			panic(fmt.Errorf("Init: failed to resolve the app directory: %w", err))
 		}
 		dbFile = filepath.Join(dir, "db.sqlite")
 	}
-	common.Debug("Init: dbFile=%v", dbFile)
+	slog.Debug("Init", "db_file", dbFile)
 
-	db, err := sql.Open("sqlite", dbFile)
+	// the transactions take the write lock when they begin, so that they wait for each other
+	// instead of failing to upgrade their read locks
+	db, err := sql.Open("sqlite", dbFile+"?_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 	d.instance = db
 	_, err = d.instance.Exec("PRAGMA foreign_keys = ON")
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 
 	d.initMigration()
 	d.initTasks()
 	d.initSettings()
 	d.initTags()
+	d.initWebhooks()
+	d.initUsers()
+	d.initApiTokens()
+	d.initProjects()
+	d.migrateTimesToUTC()
 }
 
 func (d *DbSQLite) columnExists(tableName, columnName string) bool {
@@ -70,7 +90,17 @@ func (d *DbSQLite) columnExists(tableName, columnName string) bool {
 	return false
 }
 
+// Ping checks that the database file can still be queried
+func (d *DbSQLite) Ping(ctx context.Context) error {
+	defer observeQuery("Ping", time.Now())
+	var one int
+	if err := d.conn(ctx).QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("Ping: %w", err)
+	}
+	return nil
+}
+
 func (d *DbSQLite) Close() {
-	common.Debug("closing db...")
+	slog.Debug("Close")
 	d.instance.Close()
 }
@@ -1,23 +1,22 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
-	"github.com/inaryzen/priotasks/common"
-	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/models"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	TASK_COLUMNS = "id, title, content, created, updated, completed, priority, wip, planned, impact, cost, value, fun"
+	TASK_COLUMNS = "id, title, content, created, updated, completed, priority, wip, planned, impact, cost, value, fun, owner, project, assignee"
 )
 
 func (d *DbSQLite) initTasks() {
-	common.Debug("initTasks")
+	slog.Debug("initTasks")
 	var err error
 	_, err = d.instance.Exec(`
 		CREATE TABLE IF NOT EXISTS tasks (
@@ -35,7 +34,7 @@ func (d *DbSQLite) initTasks() {
 		);
 	`)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 
 	d.addTasksWipColumn()
@@ -44,16 +43,49 @@ func (d *DbSQLite) initTasks() {
 	d.addTasksCostColumn()
 	d.addValueColumn()
 	d.addTasksFunColumn()
+	d.addTasksOwnerColumn()
+	d.addTasksProjectColumns()
+}
+
+func (d *DbSQLite) addTasksProjectColumns() {
+	id := "task_table_add_project_and_assignee_columns"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec(`
+			ALTER TABLE tasks ADD COLUMN project TEXT NOT NULL DEFAULT '';
+			ALTER TABLE tasks ADD COLUMN assignee TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS tasks_project ON tasks (project);
+		`)
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
+}
+
+func (d *DbSQLite) addTasksOwnerColumn() {
+	id := "task_table_add_owner_column"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec(`
+			ALTER TABLE tasks ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS tasks_owner ON tasks (owner);
+		`)
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
 }
 
 func (d *DbSQLite) addValueColumn() {
 	id := "task_table_add_value_column"
-	if !d.MigrationExists(id) {
+	if !d.MigrationExists(context.Background(), id) {
 		_, err := d.instance.Exec("ALTER TABLE tasks ADD COLUMN value REAL DEFAULT 0")
 		if err != nil {
 			panic(err)
 		} else {
-			d.RecordMigration(id)
+			d.RecordMigration(context.Background(), id)
 		}
 	}
 }
@@ -122,6 +154,9 @@ func (d *DbSQLite) scanNextTask(rows *sql.Rows) (models.Task, error) {
 		&task.Cost,
 		&task.Value,
 		&task.Fun,
+		&task.Owner,
+		&task.Project,
+		&task.Assignee,
 	)
 	if err != nil {
 		return models.EMPTY_TASK, err
@@ -130,17 +165,17 @@ func (d *DbSQLite) scanNextTask(rows *sql.Rows) (models.Task, error) {
 	task.Wip = wip == 1
 	task.Planned = planned == 1
 
-	task.Created, err = time.Parse(consts.DEFAULT_TIME_FORMAT, created)
+	task.Created, err = parseStorageTime(created)
 	if err != nil {
 		return models.EMPTY_TASK, fmt.Errorf("failed to parse created time: %w", err)
 	}
 
-	task.Updated, err = time.Parse(consts.DEFAULT_TIME_FORMAT, updated)
+	task.Updated, err = parseStorageTime(updated)
 	if err != nil {
 		return models.EMPTY_TASK, fmt.Errorf("failed to parse updated time: %w", err)
 	}
 
-	task.Completed, err = time.Parse(consts.DEFAULT_TIME_FORMAT, completed)
+	task.Completed, err = parseStorageTime(completed)
 	if err != nil {
 		return models.EMPTY_TASK, fmt.Errorf("failed to parse completed time: %w", err)
 	}
@@ -148,8 +183,9 @@ func (d *DbSQLite) scanNextTask(rows *sql.Rows) (models.Task, error) {
 	return task, nil
 }
 
-func (d *DbSQLite) Tasks() (result []models.Task, err error) {
-	rows, err := d.instance.Query("SELECT " + TASK_COLUMNS + " FROM tasks")
+func (d *DbSQLite) Tasks(ctx context.Context) (result []models.Task, err error) {
+	defer observeQuery("Tasks", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+TASK_COLUMNS+" FROM tasks")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch records: %w", err)
 	}
@@ -165,64 +201,77 @@ func (d *DbSQLite) Tasks() (result []models.Task, err error) {
 	return result, nil
 }
 
-func (d *DbSQLite) FindTask(taskId string) (models.Task, error) {
-	rows, err := d.instance.Query("SELECT "+TASK_COLUMNS+" FROM tasks WHERE id = ?", taskId)
+// ForEachTask streams all tasks ordered by creation time without loading them into memory
+func (d *DbSQLite) ForEachTask(ctx context.Context, fn func(models.Task) error) error {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+TASK_COLUMNS+" FROM tasks ORDER BY created, id")
 	if err != nil {
-		return models.EMPTY_TASK, fmt.Errorf("failed to query task: %s: %w", taskId, err)
+		return fmt.Errorf("ForEachTask: failed to query tasks: %w", err)
 	}
 	defer rows.Close()
 
-	if !rows.Next() {
-		return models.EMPTY_TASK, ErrNotFound
+	for rows.Next() {
+		task, err := d.scanNextTask(rows)
+		if err != nil {
+			return fmt.Errorf("ForEachTask: %w", err)
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
 	}
-
-	return d.scanNextTask(rows)
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("ForEachTask: error iterating tasks: %w", err)
+	}
+	return nil
 }
 
-func (d *DbSQLite) DeleteTask(taskId string) error {
-
-	tx, err := d.instance.Begin()
+func (d *DbSQLite) FindTask(ctx context.Context, taskId string) (models.Task, error) {
+	defer observeQuery("FindTask", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+TASK_COLUMNS+" FROM tasks WHERE id = ?", taskId)
 	if err != nil {
-		return fmt.Errorf("DeleteTask: %w", err)
+		return models.EMPTY_TASK, fmt.Errorf("failed to query task: %s: %w", taskId, err)
 	}
+	defer rows.Close()
 
-	err = d.deleteAllTagsFromTask(taskId, tx)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("DeleteTask: %w", err)
+	if !rows.Next() {
+		return models.EMPTY_TASK, ErrNotFound
 	}
 
-	result, err := tx.Exec("DELETE FROM tasks WHERE id = ?", taskId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to delete task: %v", err)
-	}
+	return d.scanNextTask(rows)
+}
 
-	rc, err := result.RowsAffected()
-	if rc == 0 || err != nil {
-		tx.Rollback()
-		return ErrNotFound
-	}
+func (d *DbSQLite) DeleteTask(ctx context.Context, taskId string) error {
+	defer observeQuery("DeleteTask", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		if err := d.deleteAllTagsFromTask(ctx, taskId); err != nil {
+			return fmt.Errorf("DeleteTask: %w", err)
+		}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("DeleteTask: %w", err)
-	}
+		result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM tasks WHERE id = ?", taskId)
+		if err != nil {
+			return fmt.Errorf("failed to delete task: %v", err)
+		}
 
-	return nil
+		rc, err := result.RowsAffected()
+		if rc == 0 || err != nil {
+			return ErrNotFound
+		}
+		return nil
+	})
 }
 
-func (d *DbSQLite) DeleteAllTasks() error {
-	_, err := d.instance.Exec("DELETE FROM tasks")
+func (d *DbSQLite) DeleteAllTasks(ctx context.Context) error {
+	defer observeQuery("DeleteAllTasks", time.Now())
+	_, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM tasks")
 	if err != nil {
 		return fmt.Errorf("failed to delete all tasks: %v", err)
 	}
 	return nil
 }
 
-func (d *DbSQLite) SaveTask(task models.Task) error {
+func (d *DbSQLite) SaveTask(ctx context.Context, task models.Task) error {
+	defer observeQuery("SaveTask", time.Now())
 	sql := "INSERT INTO tasks (" + TASK_COLUMNS + ") " +
-		`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			title=excluded.title,
 			content=excluded.content,
@@ -235,15 +284,18 @@ func (d *DbSQLite) SaveTask(task models.Task) error {
 			impact=excluded.impact,
 			cost=excluded.cost,
 			value=excluded.value,
-			fun=excluded.fun
+			fun=excluded.fun,
+			owner=excluded.owner,
+			project=excluded.project,
+			assignee=excluded.assignee
 	`
 	args := []any{
 		task.Id,
 		task.Title,
 		task.Content,
-		task.Created.Format(consts.DEFAULT_TIME_FORMAT),
-		task.Updated.Format(consts.DEFAULT_TIME_FORMAT),
-		task.Completed.Format(consts.DEFAULT_TIME_FORMAT),
+		storageTime(task.Created),
+		storageTime(task.Updated),
+		storageTime(task.Completed),
 		task.Priority,
 		task.Wip,
 		task.Planned,
@@ -251,44 +303,65 @@ func (d *DbSQLite) SaveTask(task models.Task) error {
 		task.Cost,
 		task.Value,
 		task.Fun,
+		task.Owner,
+		task.Project,
+		task.Assignee,
 	}
-	logQuery("SaveTask", sql, args)
-	_, err := d.instance.Exec(sql, args...)
+	logQuery(ctx, "SaveTask", sql, args)
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("failed to save task: %v: %w", task, err)
 	}
 	return nil
 }
 
-func logQuery(prefix, sql string, args []interface{}) {
-	common.Debug("%v: sqlQuery: %v", prefix, sql)
-	common.Debug("%v: args: %v", prefix, args)
+func (d *DbSQLite) TaskCounts(ctx context.Context) (models.TaskCounts, error) {
+	defer observeQuery("TaskCounts", time.Now())
+	var counts models.TaskCounts
+	notCompleted := storageTime(models.NOT_COMPLETED)
+	err := d.conn(ctx).QueryRowContext(ctx, `
+		SELECT
+			COUNT(CASE WHEN completed = ? THEN 1 END),
+			COUNT(CASE WHEN completed != ? THEN 1 END),
+			COUNT(CASE WHEN completed = ? AND wip THEN 1 END),
+			COUNT(CASE WHEN completed = ? AND planned THEN 1 END)
+		FROM tasks`,
+		notCompleted, notCompleted, notCompleted, notCompleted,
+	).Scan(&counts.Open, &counts.Completed, &counts.Wip, &counts.Planned)
+	if err != nil {
+		return counts, fmt.Errorf("TaskCounts: %w", err)
+	}
+	return counts, nil
+}
+
+func logQuery(ctx context.Context, prefix, sql string, args []interface{}) {
+	slog.DebugContext(ctx, prefix, "sql", sql, "args", args)
 }
 
-func (d *DbSQLite) FindTasks(query models.TasksQuery) ([]models.Task, error) {
+func (d *DbSQLite) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
+	defer observeQuery("FindTasks", time.Now())
 	var args []any
 	sqlQuery := "SELECT " + TASK_COLUMNS + " FROM tasks"
-	sqlQuery += " WHERE 1=1"
-
-	common.Debug("FindTasks: query: %v", query)
+	sqlQuery += " WHERE " + visibleTasksCondition
+	args = append(args, query.Owner, query.Owner)
 
 	if query.FilterCompleted {
 		sqlQuery += " AND completed = ?"
-		notCompleted := models.NOT_COMPLETED.Format(consts.DEFAULT_TIME_FORMAT)
+		notCompleted := storageTime(models.NOT_COMPLETED)
 		args = append(args, notCompleted)
 	} else {
 		if !query.CompletedFrom.IsZero() {
 			sqlQuery += " AND (completed >= ? OR completed = ?)"
-			args = append(args, query.CompletedFrom.Format(consts.DEFAULT_TIME_FORMAT), models.NOT_COMPLETED.Format(consts.DEFAULT_TIME_FORMAT))
+			args = append(args, storageTime(query.CompletedFrom), storageTime(models.NOT_COMPLETED))
 		}
 		if !query.CompletedTo.IsZero() {
 			sqlQuery += " AND (completed <= ? OR completed = ?)"
-			args = append(args, query.CompletedTo.Format(consts.DEFAULT_TIME_FORMAT), models.NOT_COMPLETED.Format(consts.DEFAULT_TIME_FORMAT))
+			args = append(args, storageTime(query.CompletedTo), storageTime(models.NOT_COMPLETED))
 		}
 	}
 	if query.FilterIncompleted {
 		sqlQuery += " AND completed != ?"
-		notCompleted := models.NOT_COMPLETED.Format(consts.DEFAULT_TIME_FORMAT)
+		notCompleted := storageTime(models.NOT_COMPLETED)
 		args = append(args, notCompleted)
 	}
 	if query.FilterWip {
@@ -303,6 +376,14 @@ func (d *DbSQLite) FindTasks(query models.TasksQuery) ([]models.Task, error) {
 	if query.NonPlanned {
 		sqlQuery += " AND planned = 0"
 	}
+	if query.AssignedToMe {
+		sqlQuery += " AND assignee = ?"
+		args = append(args, query.Owner)
+	}
+	if query.Project != "" {
+		sqlQuery += " AND project = ?"
+		args = append(args, query.Project)
+	}
 	if len(query.Tags) > 0 {
 		sqlQuery += " AND id in ( select task_id from TasksTags where tag_id in ("
 		for i, t := range query.Tags {
@@ -359,10 +440,9 @@ func (d *DbSQLite) FindTasks(query models.TasksQuery) ([]models.Task, error) {
 		args = append(args, query.LimitCount)
 	}
 
-	common.Debug("FindTasks: sqlQuery: %v", sqlQuery)
-	common.Debug("FindTasks: args: %v", args)
+	slog.DebugContext(ctx, "FindTasks", "query", query, "sql", sqlQuery, "args", args)
 
-	rows, err := d.instance.Query(sqlQuery, args...)
+	rows, err := d.conn(ctx).QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tasks: %w", err)
 	}
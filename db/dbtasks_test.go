@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/uuid"
@@ -15,18 +17,18 @@ func TestAddTagToTask_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
 	// Create and save a test tag
 	tagId := uuid.New().String()
-	if err := db.SaveTag(tagId); err != nil {
+	if err := db.SaveTag(context.Background(), "", tagId); err != nil {
 		t.Fatalf("failed to create test tag: %v", err)
 	}
 
 	// Test adding tag to task
-	err := db.AddTagToTask(task.Id, tagId)
+	err := db.AddTagToTask(context.Background(), task.Id, tagId)
 	if err != nil {
 		t.Errorf("AddTagToTask failed: %v", err)
 	}
@@ -49,12 +51,12 @@ func TestAddTagToTask_InvalidTask(t *testing.T) {
 
 	// Create and save a test tag
 	tagId := uuid.New().String()
-	if err := db.SaveTag(tagId); err != nil {
+	if err := db.SaveTag(context.Background(), "", tagId); err != nil {
 		t.Fatalf("failed to create test tag: %v", err)
 	}
 
 	// Test adding invalid task/tag combination
-	err := db.AddTagToTask("non-existent-task", tagId)
+	err := db.AddTagToTask(context.Background(), "non-existent-task", tagId)
 	if err == nil {
 		t.Error("expected error when adding tag to non-existent task, got nil")
 	}
@@ -68,12 +70,12 @@ func TestAddTagToTask_InvalidTag(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
 	// Test adding with non-existent tag
-	err := db.AddTagToTask(task.Id, "non-existent-tag")
+	err := db.AddTagToTask(context.Background(), task.Id, "non-existent-tag")
 	if err == nil {
 		t.Error("expected error when adding non-existent tag, got nil")
 	}
@@ -87,7 +89,7 @@ func TestTaskTags_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
@@ -99,16 +101,16 @@ func TestTaskTags_Success(t *testing.T) {
 	}
 
 	for _, tagId := range expectedTags {
-		if err := db.SaveTag(tagId); err != nil {
+		if err := db.SaveTag(context.Background(), "", tagId); err != nil {
 			t.Fatalf("failed to create tag: %v", err)
 		}
-		if err := db.AddTagToTask(task.Id, tagId); err != nil {
+		if err := db.AddTagToTask(context.Background(), task.Id, tagId); err != nil {
 			t.Fatalf("failed to add tag to task: %v", err)
 		}
 	}
 
 	// Test retrieving tags
-	tags, err := db.TaskTags(task.Id)
+	tags, err := db.TaskTags(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("TaskTags failed: %v", err)
 	}
@@ -135,7 +137,7 @@ func TestTaskTags_NonExistentTask(t *testing.T) {
 	db := setupTestDB(t)
 
 	// Test retrieving tags for non-existent task
-	tags, err := db.TaskTags("non-existent-task")
+	tags, err := db.TaskTags(context.Background(), "non-existent-task")
 	if err != nil {
 		t.Fatalf("TaskTags failed unexpectedly: %v", err)
 	}
@@ -158,13 +160,13 @@ func TestTags_Success(t *testing.T) {
 
 	// Save tags to database
 	for _, tagId := range expectedTags {
-		if err := db.SaveTag(tagId); err != nil {
+		if err := db.SaveTag(context.Background(), "", tagId); err != nil {
 			t.Fatalf("failed to create tag: %v", err)
 		}
 	}
 
 	// Test retrieving all tags
-	tags, err := db.Tags()
+	tags, err := db.Tags(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Tags failed: %v", err)
 	}
@@ -192,7 +194,7 @@ func TestTags_EmptyDatabase(t *testing.T) {
 	db := setupTestDB(t)
 
 	// Test retrieving tags from empty database
-	tags, err := db.Tags()
+	tags, err := db.Tags(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Tags failed unexpectedly: %v", err)
 	}
@@ -211,7 +213,7 @@ func TestDeleteTask_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
@@ -221,28 +223,28 @@ func TestDeleteTask_Success(t *testing.T) {
 		uuid.New().String(),
 	}
 	for _, tagId := range tags {
-		if err := db.SaveTag(tagId); err != nil {
+		if err := db.SaveTag(context.Background(), "", tagId); err != nil {
 			t.Fatalf("failed to create tag: %v", err)
 		}
-		if err := db.AddTagToTask(task.Id, tagId); err != nil {
+		if err := db.AddTagToTask(context.Background(), task.Id, tagId); err != nil {
 			t.Fatalf("failed to add tag to task: %v", err)
 		}
 	}
 
 	// Delete the task
-	err := db.DeleteTask(task.Id)
+	err := db.DeleteTask(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("DeleteTask failed: %v", err)
 	}
 
 	// Verify task is deleted
-	_, err = db.FindTask(task.Id)
+	_, err = db.FindTask(context.Background(), task.Id)
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound after deletion, got: %v", err)
 	}
 
 	// Verify tags are unlinked
-	taskTags, err := db.TaskTags(task.Id)
+	taskTags, err := db.TaskTags(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("failed to query task tags: %v", err)
 	}
@@ -254,7 +256,7 @@ func TestDeleteTask_Success(t *testing.T) {
 func TestDeleteTask_NonExistent(t *testing.T) {
 	db := setupTestDB(t)
 
-	err := db.DeleteTask("non-existent-task")
+	err := db.DeleteTask(context.Background(), "non-existent-task")
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound when deleting non-existent task, got: %v", err)
 	}
@@ -268,19 +270,35 @@ func TestDeleteTask_WithNoTags(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task No Tags",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
 	// Delete the task
-	err := db.DeleteTask(task.Id)
+	err := db.DeleteTask(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("DeleteTask failed: %v", err)
 	}
 
 	// Verify task is deleted
-	_, err = db.FindTask(task.Id)
+	_, err = db.FindTask(context.Background(), task.Id)
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound after deletion, got: %v", err)
 	}
 }
+
+func TestFindTasks_Cancelled(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.SaveTask(context.Background(), models.Task{Id: "t1", Title: "Task"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.FindTasks(ctx, models.TasksQuery{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the query to be cancelled, got %v", err)
+	}
+	if err := db.SaveTask(ctx, models.Task{Id: "t2", Title: "Task"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the save to be cancelled, got %v", err)
+	}
+}
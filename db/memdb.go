@@ -0,0 +1,999 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+var (
+	errUniqueConstraint     = errors.New("UNIQUE constraint failed")
+	errForeignKeyConstraint = errors.New("FOREIGN KEY constraint failed")
+)
+
+// MemDB keeps the data in memory with the semantics of DbSQLite: the same filters, orderings,
+// errors and constraints, and the times are kept to the second as DbSQLite reads them back.
+// It is for the tests and the demo mode, nothing is persisted.
+type MemDB struct {
+	mu    sync.Mutex
+	state memState
+}
+
+type memTask struct {
+	task models.Task
+	// rowid is the insertion order, the order DbSQLite returns the rows in when unsorted
+	rowid int64
+}
+
+type memTag struct {
+	tag   models.TagRecord
+	rowid int64
+}
+
+type memTagKey struct{ owner, id string }
+
+type memTaskTagKey struct{ taskId, tagId string }
+
+// memState holds the tables; the values are replaced, never changed in place, so that a
+// shallow copy of the maps is a snapshot
+type memState struct {
+	rowid      int64
+	tasks      map[string]memTask
+	tags       map[memTagKey]memTag
+	taskTags   map[memTaskTagKey]struct{}
+	settings   map[string]models.Settings
+	migrations map[string]bool
+	webhooks   map[string]models.Webhook
+	deliveries []models.WebhookDelivery
+	deliveryId int64
+	users      map[string]models.User
+	sessions   map[string]models.Session
+	apiTokens  map[string]models.ApiToken
+	// projects keep their members sorted, as DbSQLite returns them
+	projects map[string]models.Project
+}
+
+func NewMemDB() *MemDB {
+	return &MemDB{state: memState{
+		tasks:      map[string]memTask{},
+		tags:       map[memTagKey]memTag{},
+		taskTags:   map[memTaskTagKey]struct{}{},
+		settings:   map[string]models.Settings{},
+		migrations: map[string]bool{},
+		webhooks:   map[string]models.Webhook{},
+		users:      map[string]models.User{},
+		sessions:   map[string]models.Session{},
+		apiTokens:  map[string]models.ApiToken{},
+		projects:   map[string]models.Project{},
+	}}
+}
+
+func (s memState) clone() memState {
+	s.tasks = maps.Clone(s.tasks)
+	s.tags = maps.Clone(s.tags)
+	s.taskTags = maps.Clone(s.taskTags)
+	s.settings = maps.Clone(s.settings)
+	s.migrations = maps.Clone(s.migrations)
+	s.webhooks = maps.Clone(s.webhooks)
+	s.deliveries = slices.Clone(s.deliveries)
+	s.users = maps.Clone(s.users)
+	s.sessions = maps.Clone(s.sessions)
+	s.apiTokens = maps.Clone(s.apiTokens)
+	s.projects = maps.Clone(s.projects)
+	return s
+}
+
+func (s *memState) nextRowid() int64 {
+	s.rowid++
+	return s.rowid
+}
+
+// Init does nothing, the MemDB is ready once created
+func (d *MemDB) Init(string) {}
+
+func (d *MemDB) Close() {}
+
+func (d *MemDB) Ping(ctx context.Context) error { return nil }
+
+// lock locks the state for one operation, unless ctx is in a transaction of d, which holds
+// the lock until it ends; the returned func unlocks it
+func (d *MemDB) lock(ctx context.Context) func() {
+	if unitOfWorkOf(ctx, d) != nil {
+		return func() {}
+	}
+	d.mu.Lock()
+	return d.mu.Unlock
+}
+
+// WithTx runs fn holding the lock of the state, so that the transactions and the queries
+// made outside of them wait for each other; the state is restored when fn fails. Nested
+// calls join the outer transaction.
+func (d *MemDB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitOfWorkOf(ctx, d) != nil {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("WithTx: %w", err)
+	}
+	uow := &unitOfWork{}
+	err := func() error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		saved := d.state.clone()
+		committed := false
+		defer func() {
+			if !committed {
+				d.state = saved
+			}
+		}()
+		if err := fn(withUnitOfWork(ctx, d, uow)); err != nil {
+			return err
+		}
+		committed = true
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+	for _, after := range uow.afterCommit {
+		after(ctx)
+	}
+	return nil
+}
+
+// timeKey is the text DbSQLite stores a time as, the times are compared and sorted by it
+func timeKey(t time.Time) string {
+	return storageTime(t)
+}
+
+// storedTime is t as DbSQLite reads it back: to the second and in UTC
+func storedTime(t time.Time) time.Time {
+	return utcTime(t.Truncate(time.Second))
+}
+
+func storedTask(t models.Task) models.Task {
+	t.Created = storedTime(t.Created)
+	t.Updated = storedTime(t.Updated)
+	t.Completed = storedTime(t.Completed)
+	t.Tags = nil
+	return t
+}
+
+// sortedTasks returns the tasks in the order they were inserted
+func (s *memState) sortedTasks() []models.Task {
+	rows := slices.Collect(maps.Values(s.tasks))
+	slices.SortFunc(rows, func(a, b memTask) int { return cmp.Compare(a.rowid, b.rowid) })
+	var result []models.Task
+	for _, row := range rows {
+		result = append(result, row.task)
+	}
+	return result
+}
+
+// visible reports whether the task is one of the user or of their projects
+func (s *memState) visible(t models.Task, username string) bool {
+	if t.Owner == username {
+		return true
+	}
+	p, ok := s.projects[t.Project]
+	return ok && p.HasMember(username)
+}
+
+func (d *MemDB) Tasks(ctx context.Context) ([]models.Task, error) {
+	defer d.lock(ctx)()
+	return d.state.sortedTasks(), nil
+}
+
+// ForEachTask calls fn with the tasks ordered by creation time; fn may use the MemDB
+func (d *MemDB) ForEachTask(ctx context.Context, fn func(models.Task) error) error {
+	unlock := d.lock(ctx)
+	tasks := d.state.sortedTasks()
+	unlock()
+	slices.SortStableFunc(tasks, func(a, b models.Task) int {
+		return cmp.Or(strings.Compare(timeKey(a.Created), timeKey(b.Created)), strings.Compare(a.Id, b.Id))
+	})
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *MemDB) FindTask(ctx context.Context, taskId string) (models.Task, error) {
+	defer d.lock(ctx)()
+	row, ok := d.state.tasks[taskId]
+	if !ok {
+		return models.EMPTY_TASK, ErrNotFound
+	}
+	return row.task, nil
+}
+
+func (d *MemDB) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
+	defer d.lock(ctx)()
+	notCompleted := timeKey(models.NOT_COMPLETED)
+	var result []models.Task
+	for _, t := range d.state.sortedTasks() {
+		if !d.state.visible(t, query.Owner) {
+			continue
+		}
+		completed := timeKey(t.Completed)
+		if query.FilterCompleted {
+			if completed != notCompleted {
+				continue
+			}
+		} else {
+			if !query.CompletedFrom.IsZero() && completed < timeKey(query.CompletedFrom) && completed != notCompleted {
+				continue
+			}
+			if !query.CompletedTo.IsZero() && completed > timeKey(query.CompletedTo) && completed != notCompleted {
+				continue
+			}
+		}
+		if query.FilterIncompleted && completed == notCompleted {
+			continue
+		}
+		if (query.FilterWip && !t.Wip) || (query.FilterNonWip && t.Wip) {
+			continue
+		}
+		if (query.Planned && !t.Planned) || (query.NonPlanned && t.Planned) {
+			continue
+		}
+		if query.AssignedToMe && t.Assignee != query.Owner {
+			continue
+		}
+		if query.Project != "" && t.Project != query.Project {
+			continue
+		}
+		if len(query.Tags) > 0 && !slices.ContainsFunc(query.Tags, func(tag models.TaskTag) bool {
+			_, ok := d.state.taskTags[memTaskTagKey{t.Id, string(tag)}]
+			return ok
+		}) {
+			continue
+		}
+		if query.SearchText != "" {
+			pattern := "%" + query.SearchText + "%"
+			if !like(t.Title, pattern) && !like(t.Content, pattern) {
+				continue
+			}
+		}
+		result = append(result, t)
+	}
+
+	if query.SortColumn != models.ColumnUndefined {
+		sort.SliceStable(result, func(i, j int) bool {
+			c := compareTaskColumn(result[i], result[j], query.SortColumn)
+			if query.SortDirection == models.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+
+	if query.EnableLimit && query.LimitCount > 0 && len(result) > query.LimitCount {
+		result = result[:query.LimitCount]
+	}
+	return result, nil
+}
+
+// compareTaskColumn compares the tasks by the column FindTasks sorts by, by creation
+// time for the columns that are not stored
+func compareTaskColumn(a, b models.Task, column models.SortColumn) int {
+	switch column {
+	case models.Completed:
+		return strings.Compare(timeKey(a.Completed), timeKey(b.Completed))
+	case models.Priority:
+		return cmp.Compare(a.Priority, b.Priority)
+	case models.ColumnImpact:
+		return cmp.Compare(a.Impact, b.Impact)
+	case models.ColumnWip:
+		return compareBool(a.Wip, b.Wip)
+	case models.ColumnPlanned:
+		return compareBool(a.Planned, b.Planned)
+	case models.ColumnCost:
+		return cmp.Compare(a.Cost, b.Cost)
+	case models.ColumnValue:
+		return cmp.Compare(a.Value, b.Value)
+	case models.ColumnFun:
+		return cmp.Compare(a.Fun, b.Fun)
+	default:
+		return strings.Compare(timeKey(a.Created), timeKey(b.Created))
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// like matches s against a LIKE pattern the way SQLite does: % is any run of characters,
+// _ is one character and the ASCII letters match regardless of their case
+func like(s, pattern string) bool {
+	return likeRunes([]rune(s), []rune(pattern))
+}
+
+func likeRunes(s, p []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '%':
+			for len(p) > 0 && p[0] == '%' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := range len(s) + 1 {
+				if likeRunes(s[i:], p) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || foldASCII(s[0]) != foldASCII(p[0]) {
+				return false
+			}
+		}
+		s, p = s[1:], p[1:]
+	}
+	return len(s) == 0
+}
+
+func foldASCII(r rune) rune {
+	if r <= unicode.MaxASCII {
+		return unicode.ToLower(r)
+	}
+	return r
+}
+
+func (d *MemDB) TaskCounts(ctx context.Context) (models.TaskCounts, error) {
+	defer d.lock(ctx)()
+	var counts models.TaskCounts
+	for _, row := range d.state.tasks {
+		if row.task.IsCompleted() {
+			counts.Completed++
+			continue
+		}
+		counts.Open++
+		if row.task.Wip {
+			counts.Wip++
+		}
+		if row.task.Planned {
+			counts.Planned++
+		}
+	}
+	return counts, nil
+}
+
+// DeleteTask deletes the task together with its tags
+func (d *MemDB) DeleteTask(ctx context.Context, taskId string) error {
+	defer d.lock(ctx)()
+	if _, ok := d.state.tasks[taskId]; !ok {
+		return ErrNotFound
+	}
+	for key := range d.state.taskTags {
+		if key.taskId == taskId {
+			delete(d.state.taskTags, key)
+		}
+	}
+	delete(d.state.tasks, taskId)
+	return nil
+}
+
+// DeleteAllTasks fails while any task is tagged, as the foreign key of DbSQLite does
+func (d *MemDB) DeleteAllTasks(ctx context.Context) error {
+	defer d.lock(ctx)()
+	if len(d.state.taskTags) > 0 {
+		return fmt.Errorf("failed to delete all tasks: %w", errForeignKeyConstraint)
+	}
+	clear(d.state.tasks)
+	return nil
+}
+
+// SaveTask inserts the task or replaces the one with the same id
+func (d *MemDB) SaveTask(ctx context.Context, task models.Task) error {
+	defer d.lock(ctx)()
+	row, ok := d.state.tasks[task.Id]
+	if !ok {
+		row.rowid = d.state.nextRowid()
+	}
+	row.task = storedTask(task)
+	d.state.tasks[task.Id] = row
+	return nil
+}
+
+// storedSettings is s as DbSQLite reads it back: the query without its owner
+func storedSettings(s models.Settings) models.Settings {
+	q := &s.TasksQuery
+	q.CompletedFrom, q.CompletedTo = storedTime(q.CompletedFrom), storedTime(q.CompletedTo)
+	q.Tags = slices.Clone(q.Tags)
+	q.Owner = ""
+	return s
+}
+
+func (d *MemDB) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
+	defer d.lock(ctx)()
+	s, ok := d.state.settings[settingsId]
+	if !ok {
+		return models.Settings{}, ErrNotFound
+	}
+	s.TasksQuery.Tags = slices.Clone(s.TasksQuery.Tags)
+	return s, nil
+}
+
+func (d *MemDB) AllSettings(ctx context.Context) ([]models.Settings, error) {
+	defer d.lock(ctx)()
+	var result []models.Settings
+	for _, id := range slices.Sorted(maps.Keys(d.state.settings)) {
+		s := d.state.settings[id]
+		s.TasksQuery.Tags = slices.Clone(s.TasksQuery.Tags)
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (d *MemDB) SaveSettings(ctx context.Context, s models.Settings) error {
+	defer d.lock(ctx)()
+	d.state.settings[s.Id] = storedSettings(s)
+	return nil
+}
+
+func (d *MemDB) DeleteSettings(ctx context.Context, settingsId string) error {
+	defer d.lock(ctx)()
+	delete(d.state.settings, settingsId)
+	return nil
+}
+
+func (d *MemDB) MigrationExists(ctx context.Context, id string) bool {
+	defer d.lock(ctx)()
+	return d.state.migrations[id]
+}
+
+func (d *MemDB) RecordMigration(ctx context.Context, id string) {
+	defer d.lock(ctx)()
+	if d.state.migrations[id] {
+		panic(fmt.Sprintf("failed: %v: %v", id, errUniqueConstraint))
+	}
+	d.state.migrations[id] = true
+}
+
+func (d *MemDB) SaveTag(ctx context.Context, owner, tagId string) error {
+	defer d.lock(ctx)()
+	if err := d.state.insertTag(models.TagRecord{Id: models.TaskTag(tagId), Created: time.Now(), Owner: owner}); err != nil {
+		return fmt.Errorf("SaveTag: error; tagId=%v; %w", tagId, err)
+	}
+	return nil
+}
+
+// SaveTagRecord saves the tag preserving its creation time
+func (d *MemDB) SaveTagRecord(ctx context.Context, tag models.TagRecord) error {
+	defer d.lock(ctx)()
+	if err := d.state.insertTag(tag); err != nil {
+		return fmt.Errorf("SaveTagRecord: error; tagId=%v; %w", tag.Id, err)
+	}
+	return nil
+}
+
+func (s *memState) insertTag(tag models.TagRecord) error {
+	key := memTagKey{tag.Owner, string(tag.Id)}
+	if _, ok := s.tags[key]; ok {
+		return errUniqueConstraint
+	}
+	tag.Created = storedTime(tag.Created)
+	s.tags[key] = memTag{tag: tag, rowid: s.nextRowid()}
+	return nil
+}
+
+// AddTagToTask tags the task with a tag of the task owner; ErrNotFound when
+// either the task or the tag does not exist
+func (d *MemDB) AddTagToTask(ctx context.Context, taskId, tagId string) error {
+	defer d.lock(ctx)()
+	row, ok := d.state.tasks[taskId]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := d.state.tags[memTagKey{row.task.Owner, tagId}]; !ok {
+		return ErrNotFound
+	}
+	key := memTaskTagKey{taskId, tagId}
+	if _, ok := d.state.taskTags[key]; ok {
+		return fmt.Errorf("failed to add tag to task; taskId=%v; tagId=%v; %w", taskId, tagId, errUniqueConstraint)
+	}
+	d.state.taskTags[key] = struct{}{}
+	return nil
+}
+
+func (d *MemDB) DeleteTagFromTask(ctx context.Context, taskId, tagId string) error {
+	defer d.lock(ctx)()
+	key := memTaskTagKey{taskId, tagId}
+	if _, ok := d.state.taskTags[key]; !ok {
+		return ErrNotFound
+	}
+	delete(d.state.taskTags, key)
+	return nil
+}
+
+func (d *MemDB) DeleteTagFromAllTasks(ctx context.Context, owner, tagId string) error {
+	defer d.lock(ctx)()
+	for key := range d.state.taskTags {
+		if key.tagId == tagId && d.state.tasks[key.taskId].task.Owner == owner {
+			delete(d.state.taskTags, key)
+		}
+	}
+	return nil
+}
+
+func (d *MemDB) DeleteTag(ctx context.Context, owner, tagId string) error {
+	defer d.lock(ctx)()
+	key := memTagKey{owner, tagId}
+	if _, ok := d.state.tags[key]; !ok {
+		return ErrNotFound
+	}
+	delete(d.state.tags, key)
+	return nil
+}
+
+// sortedTaskTags returns the tags of the tasks ordered by task, then by tag
+func (s *memState) sortedTaskTags() []memTaskTagKey {
+	return slices.SortedFunc(maps.Keys(s.taskTags), func(a, b memTaskTagKey) int {
+		return cmp.Or(strings.Compare(a.taskId, b.taskId), strings.Compare(a.tagId, b.tagId))
+	})
+}
+
+func (d *MemDB) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
+	defer d.lock(ctx)()
+	var tags []models.TaskTag
+	for _, key := range d.state.sortedTaskTags() {
+		if key.taskId == taskId {
+			tags = append(tags, models.TaskTag(key.tagId))
+		}
+	}
+	return tags, nil
+}
+
+func (d *MemDB) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
+	defer d.lock(ctx)()
+	result := make(map[string][]models.TaskTag)
+	for _, key := range d.state.sortedTaskTags() {
+		if !slices.Contains(taskIds, key.taskId) || !d.state.visible(d.state.tasks[key.taskId].task, owner) {
+			continue
+		}
+		result[key.taskId] = append(result[key.taskId], models.TaskTag(key.tagId))
+	}
+	return result, nil
+}
+
+// sortedTags returns the tags in the order they were inserted
+func (s *memState) sortedTags() []models.TagRecord {
+	rows := slices.Collect(maps.Values(s.tags))
+	slices.SortFunc(rows, func(a, b memTag) int { return cmp.Compare(a.rowid, b.rowid) })
+	var result []models.TagRecord
+	for _, row := range rows {
+		result = append(result, row.tag)
+	}
+	return result
+}
+
+// Tags returns the tags of the owner, the newest first
+func (d *MemDB) Tags(ctx context.Context, owner string) ([]models.TaskTag, error) {
+	defer d.lock(ctx)()
+	records := d.state.sortedTags()
+	slices.SortStableFunc(records, func(a, b models.TagRecord) int {
+		return strings.Compare(timeKey(b.Created), timeKey(a.Created))
+	})
+	var tags []models.TaskTag
+	for _, tag := range records {
+		if tag.Owner == owner {
+			tags = append(tags, tag.Id)
+		}
+	}
+	return tags, nil
+}
+
+// ForEachTag calls fn with the tags ordered by owner and creation time; fn may use the MemDB
+func (d *MemDB) ForEachTag(ctx context.Context, fn func(models.TagRecord) error) error {
+	unlock := d.lock(ctx)
+	records := d.state.sortedTags()
+	unlock()
+	slices.SortStableFunc(records, func(a, b models.TagRecord) int {
+		return cmp.Or(
+			strings.Compare(a.Owner, b.Owner),
+			strings.Compare(timeKey(a.Created), timeKey(b.Created)),
+			strings.Compare(string(a.Id), string(b.Id)),
+		)
+	})
+	for _, tag := range records {
+		if err := fn(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachTaskTag calls fn with the tags of the tasks ordered by task; fn may use the MemDB
+func (d *MemDB) ForEachTaskTag(ctx context.Context, fn func(taskId string, tag models.TaskTag) error) error {
+	unlock := d.lock(ctx)
+	keys := d.state.sortedTaskTags()
+	unlock()
+	for _, key := range keys {
+		if err := fn(key.taskId, models.TaskTag(key.tagId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedWebhook is h as DbSQLite reads it back, without tags it has an empty list
+func storedWebhook(h models.Webhook) models.Webhook {
+	h.Events = slices.Clone(h.Events)
+	h.Tags = slices.Clone(h.Tags)
+	if len(h.Tags) == 0 {
+		h.Tags = []models.TaskTag{}
+	}
+	h.Created = storedTime(h.Created)
+	return h
+}
+
+func (d *MemDB) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	defer d.lock(ctx)()
+	hooks := slices.SortedFunc(maps.Values(d.state.webhooks), func(a, b models.Webhook) int {
+		return cmp.Or(strings.Compare(timeKey(a.Created), timeKey(b.Created)), strings.Compare(a.Id, b.Id))
+	})
+	var result []models.Webhook
+	for _, h := range hooks {
+		result = append(result, storedWebhook(h))
+	}
+	return result, nil
+}
+
+// SaveWebhook inserts the webhook or replaces the one with the same id
+func (d *MemDB) SaveWebhook(ctx context.Context, h models.Webhook) error {
+	defer d.lock(ctx)()
+	d.state.webhooks[h.Id] = storedWebhook(h)
+	return nil
+}
+
+func (d *MemDB) DeleteWebhook(ctx context.Context, webhookId string) error {
+	defer d.lock(ctx)()
+	if _, ok := d.state.webhooks[webhookId]; !ok {
+		return ErrNotFound
+	}
+	delete(d.state.webhooks, webhookId)
+	return nil
+}
+
+// SaveWebhookDelivery appends the attempt to the delivery log and assigns its id
+func (d *MemDB) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	defer d.lock(ctx)()
+	d.state.deliveryId++
+	delivery.Id = d.state.deliveryId
+	delivery.Created = storedTime(delivery.Created)
+	delivery.Duration = delivery.Duration.Truncate(time.Millisecond)
+	d.state.deliveries = append(d.state.deliveries, delivery)
+	return nil
+}
+
+// WebhookDeliveries returns the latest attempts first, all of them when limit is negative
+func (d *MemDB) WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	defer d.lock(ctx)()
+	var result []models.WebhookDelivery
+	for i := len(d.state.deliveries) - 1; i >= 0 && (limit < 0 || len(result) < limit); i-- {
+		result = append(result, d.state.deliveries[i])
+	}
+	return result, nil
+}
+
+// PruneWebhookDeliveries keeps only the latest attempts in the delivery log
+func (d *MemDB) PruneWebhookDeliveries(ctx context.Context, keep int) error {
+	defer d.lock(ctx)()
+	if keep >= 0 && len(d.state.deliveries) > keep {
+		d.state.deliveries = slices.Clone(d.state.deliveries[len(d.state.deliveries)-keep:])
+	}
+	return nil
+}
+
+func (d *MemDB) FindUser(ctx context.Context, username string) (models.User, error) {
+	defer d.lock(ctx)()
+	u, ok := d.state.users[username]
+	if !ok {
+		return u, ErrNotFound
+	}
+	return u, nil
+}
+
+func (d *MemDB) Users(ctx context.Context) ([]models.User, error) {
+	defer d.lock(ctx)()
+	var users []models.User
+	for _, username := range slices.Sorted(maps.Keys(d.state.users)) {
+		users = append(users, d.state.users[username])
+	}
+	return users, nil
+}
+
+func (d *MemDB) CountUsers(ctx context.Context) (int, error) {
+	defer d.lock(ctx)()
+	return len(d.state.users), nil
+}
+
+// SaveUser inserts the user or replaces the password of an existing one
+func (d *MemDB) SaveUser(ctx context.Context, u models.User) error {
+	defer d.lock(ctx)()
+	if existing, ok := d.state.users[u.Username]; ok {
+		existing.PasswordHash = u.PasswordHash
+		d.state.users[u.Username] = existing
+		return nil
+	}
+	u.Created = storedTime(u.Created)
+	d.state.users[u.Username] = u
+	return nil
+}
+
+func (d *MemDB) SetUserAdmin(ctx context.Context, username string, admin bool) error {
+	defer d.lock(ctx)()
+	u, ok := d.state.users[username]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Admin = admin
+	d.state.users[username] = u
+	return nil
+}
+
+// TransferOwnership hands the tasks, the tags, the projects, the project memberships and
+// the assignments of one user over to another; the tags both of them have are merged
+func (d *MemDB) TransferOwnership(ctx context.Context, from, to string) error {
+	defer d.lock(ctx)()
+	s := &d.state
+	for id, row := range s.tasks {
+		if row.task.Owner == from {
+			row.task.Owner = to
+		}
+		if row.task.Assignee == from {
+			row.task.Assignee = to
+		}
+		s.tasks[id] = row
+	}
+	for _, tag := range s.sortedTags() {
+		if tag.Owner != from {
+			continue
+		}
+		delete(s.tags, memTagKey{from, string(tag.Id)})
+		tag.Owner = to
+		s.insertTag(tag) // the tag of the same name the other user has is kept
+	}
+	for id, p := range s.projects {
+		if p.Owner == from {
+			p.Owner = to
+		}
+		if p.HasMember(from) {
+			members := slices.Clone(p.Members)
+			members[slices.Index(members, from)] = to
+			p.Members = projectMembers(members)
+		}
+		s.projects[id] = p
+	}
+	return nil
+}
+
+// DeleteUser deletes the user and signs out their sessions
+func (d *MemDB) DeleteUser(ctx context.Context, username string) error {
+	defer d.lock(ctx)()
+	if _, ok := d.state.users[username]; !ok {
+		return ErrNotFound
+	}
+	delete(d.state.users, username)
+	maps.DeleteFunc(d.state.sessions, func(_ string, s models.Session) bool { return s.Username == username })
+	maps.DeleteFunc(d.state.apiTokens, func(_ string, t models.ApiToken) bool { return t.Username == username })
+	return nil
+}
+
+func (d *MemDB) SaveSession(ctx context.Context, s models.Session) error {
+	defer d.lock(ctx)()
+	if _, ok := d.state.sessions[s.TokenHash]; ok {
+		return fmt.Errorf("SaveSession: failed to save session of %v: %w", s.Username, errUniqueConstraint)
+	}
+	if _, ok := d.state.users[s.Username]; !ok {
+		return fmt.Errorf("SaveSession: failed to save session of %v: %w", s.Username, errForeignKeyConstraint)
+	}
+	s.Created = storedTime(s.Created)
+	s.Expires = storedTime(s.Expires)
+	d.state.sessions[s.TokenHash] = s
+	return nil
+}
+
+func (d *MemDB) FindSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	defer d.lock(ctx)()
+	s, ok := d.state.sessions[tokenHash]
+	if !ok {
+		return s, ErrNotFound
+	}
+	return s, nil
+}
+
+func (d *MemDB) DeleteSession(ctx context.Context, tokenHash string) error {
+	defer d.lock(ctx)()
+	delete(d.state.sessions, tokenHash)
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions that expired before the time
+func (d *MemDB) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	defer d.lock(ctx)()
+	maps.DeleteFunc(d.state.sessions, func(_ string, s models.Session) bool {
+		return timeKey(s.Expires) <= timeKey(now)
+	})
+	return nil
+}
+
+// ApiTokens returns the tokens of the user, the newest first
+func (d *MemDB) ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error) {
+	defer d.lock(ctx)()
+	tokens := slices.SortedFunc(maps.Values(d.state.apiTokens), func(a, b models.ApiToken) int {
+		return cmp.Or(strings.Compare(timeKey(b.Created), timeKey(a.Created)), strings.Compare(a.Id, b.Id))
+	})
+	var result []models.ApiToken
+	for _, t := range tokens {
+		if t.Username == username {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (d *MemDB) FindApiTokenByHash(ctx context.Context, tokenHash string) (models.ApiToken, error) {
+	defer d.lock(ctx)()
+	for _, t := range d.state.apiTokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return models.ApiToken{}, ErrNotFound
+}
+
+func (d *MemDB) SaveApiToken(ctx context.Context, t models.ApiToken) error {
+	defer d.lock(ctx)()
+	_, duplicate := d.state.apiTokens[t.Id]
+	for _, other := range d.state.apiTokens {
+		duplicate = duplicate || other.TokenHash == t.TokenHash
+	}
+	if duplicate {
+		return fmt.Errorf("SaveApiToken: failed to save api token %v: %w", t.Id, errUniqueConstraint)
+	}
+	if _, ok := d.state.users[t.Username]; !ok {
+		return fmt.Errorf("SaveApiToken: failed to save api token %v: %w", t.Id, errForeignKeyConstraint)
+	}
+	t.Created = storedTime(t.Created)
+	if t.IsUsed() {
+		t.LastUsed = storedTime(t.LastUsed)
+	}
+	d.state.apiTokens[t.Id] = t
+	return nil
+}
+
+// DeleteApiToken revokes the token of the user
+func (d *MemDB) DeleteApiToken(ctx context.Context, username, tokenId string) error {
+	defer d.lock(ctx)()
+	t, ok := d.state.apiTokens[tokenId]
+	if !ok || t.Username != username {
+		return ErrNotFound
+	}
+	delete(d.state.apiTokens, tokenId)
+	return nil
+}
+
+func (d *MemDB) TouchApiToken(ctx context.Context, tokenId string, used time.Time) error {
+	defer d.lock(ctx)()
+	if t, ok := d.state.apiTokens[tokenId]; ok {
+		t.LastUsed = storedTime(used)
+		d.state.apiTokens[tokenId] = t
+	}
+	return nil
+}
+
+// projectMembers sorts the members and drops the duplicates, nil without members
+func projectMembers(members []string) []string {
+	members = slices.Compact(slices.Sorted(slices.Values(members)))
+	if len(members) == 0 {
+		return nil
+	}
+	return members
+}
+
+// Projects returns the projects the user is a member of, with their members
+func (d *MemDB) Projects(ctx context.Context, username string) ([]models.Project, error) {
+	defer d.lock(ctx)()
+	projects := slices.SortedFunc(maps.Values(d.state.projects), func(a, b models.Project) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Id, b.Id))
+	})
+	var result []models.Project
+	for _, p := range projects {
+		if p.HasMember(username) {
+			p.Members = slices.Clone(p.Members)
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// AllProjects returns every project with its members, ordered by creation time
+func (d *MemDB) AllProjects(ctx context.Context) ([]models.Project, error) {
+	defer d.lock(ctx)()
+	projects := slices.SortedFunc(maps.Values(d.state.projects), func(a, b models.Project) int {
+		return cmp.Or(strings.Compare(timeKey(a.Created), timeKey(b.Created)), strings.Compare(a.Id, b.Id))
+	})
+	var result []models.Project
+	for _, p := range projects {
+		p.Members = slices.Clone(p.Members)
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func (d *MemDB) FindProject(ctx context.Context, projectId string) (models.Project, error) {
+	defer d.lock(ctx)()
+	p, ok := d.state.projects[projectId]
+	if !ok {
+		return models.Project{}, ErrNotFound
+	}
+	p.Members = slices.Clone(p.Members)
+	return p, nil
+}
+
+// SaveProject inserts or renames the project and replaces its members
+func (d *MemDB) SaveProject(ctx context.Context, p models.Project) error {
+	defer d.lock(ctx)()
+	if existing, ok := d.state.projects[p.Id]; ok {
+		p.Created = existing.Created
+	} else {
+		p.Created = storedTime(p.Created)
+	}
+	p.Members = projectMembers(p.Members)
+	d.state.projects[p.Id] = p
+	// the tasks of the project keep only assignees who are still members
+	for id, row := range d.state.tasks {
+		if row.task.Project == p.Id && row.task.Assignee != "" && !p.HasMember(row.task.Assignee) {
+			row.task.Assignee = ""
+			d.state.tasks[id] = row
+		}
+	}
+	return nil
+}
+
+// DeleteProject deletes the project, its tasks become private tasks of their owners
+// again and are unassigned from everyone else
+func (d *MemDB) DeleteProject(ctx context.Context, projectId string) error {
+	defer d.lock(ctx)()
+	if _, ok := d.state.projects[projectId]; !ok {
+		return ErrNotFound
+	}
+	for id, row := range d.state.tasks {
+		if row.task.Project == projectId {
+			row.task.Project = ""
+			if row.task.Assignee != row.task.Owner {
+				row.task.Assignee = ""
+			}
+			d.state.tasks[id] = row
+		}
+	}
+	delete(d.state.projects, projectId)
+	return nil
+}
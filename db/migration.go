@@ -1,10 +1,10 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"time"
-
-	"github.com/inaryzen/priotasks/common"
 )
 
 const (
@@ -12,7 +12,7 @@ const (
 )
 
 func (d *DbSQLite) initMigration() {
-	common.Debug("initMigration")
+	slog.Debug("initMigration")
 	var err error
 	_, err = d.instance.Exec(fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %v (
@@ -25,27 +25,26 @@ func (d *DbSQLite) initMigration() {
 	}
 }
 
-func (d *DbSQLite) MigrationExists(id string) bool {
-	rows, err := d.instance.Query("select * from "+MIGRATION_TABLE_NAME+" where id = ?", id)
+func (d *DbSQLite) MigrationExists(ctx context.Context, id string) bool {
+	defer observeQuery("MigrationExists", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "select * from "+MIGRATION_TABLE_NAME+" where id = ?", id)
 	if err != nil {
 		panic(err)
 	}
 	defer rows.Close()
 	var result = rows.Next()
-	common.Debug("migrationExist: id=%v, result=%v", id, result)
+	slog.DebugContext(ctx, "MigrationExists", "id", id, "result", result)
 	return result
 }
 
-func (d *DbSQLite) RecordMigration(id string) {
+func (d *DbSQLite) RecordMigration(ctx context.Context, id string) {
+	defer observeQuery("RecordMigration", time.Now())
 	sql := "insert into " + MIGRATION_TABLE_NAME + " (id, time) values (?, ?)"
-	args := []interface{}{id, time.Now()}
-	common.Debug("recordMigration: sql: %v", sql)
-	common.Debug("recordMigration: args: %v", args)
-
-	_, err := d.instance.Exec(sql, args...)
+	args := []interface{}{id, storageTime(time.Now())}
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		err := fmt.Sprintf("failed: %v: %v", id, err)
 		panic(err)
 	}
-	common.Debug("RecordMigration: id=%v", id)
+	slog.DebugContext(ctx, "RecordMigration", "id", id)
 }
@@ -1,30 +1,99 @@
 package db
 
 import (
+	"context"
+	"time"
+
 	"github.com/inaryzen/priotasks/models"
 )
 
 type NoOpDB struct{}
 
-func (m *NoOpDB) Init(p string)                                            {}
-func (m *NoOpDB) Close()                                                   {}
-func (m *NoOpDB) Tasks() ([]models.Task, error)                            { return nil, nil }
-func (m *NoOpDB) FindTasks(query models.TasksQuery) ([]models.Task, error) { return nil, nil }
-func (m *NoOpDB) DeleteTask(taskId string) error                           { return nil }
-func (m *NoOpDB) DeleteAllTasks() error                                    { return nil }
-func (m *NoOpDB) FindSettings(settingsId string) (models.Settings, error) {
+func (m *NoOpDB) Init(p string)                  {}
+func (m *NoOpDB) Close()                         {}
+func (m *NoOpDB) Ping(ctx context.Context) error { return nil }
+func (m *NoOpDB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+func (m *NoOpDB) TaskCounts(ctx context.Context) (models.TaskCounts, error) {
+	return models.TaskCounts{}, nil
+}
+func (m *NoOpDB) Tasks(ctx context.Context) ([]models.Task, error) { return nil, nil }
+func (m *NoOpDB) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
+	return nil, nil
+}
+func (m *NoOpDB) DeleteTask(ctx context.Context, taskId string) error { return nil }
+func (m *NoOpDB) DeleteAllTasks(ctx context.Context) error            { return nil }
+func (m *NoOpDB) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
 	return models.Settings{}, nil
 }
-func (m *NoOpDB) SaveSettings(s models.Settings) error                            { return nil }
-func (m *NoOpDB) SaveTag(tagId string) error                                      { return nil }
-func (m *NoOpDB) TaskTags(taskId string) ([]models.TaskTag, error)                { return nil, nil }
-func (m *NoOpDB) Tags() ([]models.TaskTag, error)                                 { return nil, nil }
-func (m *NoOpDB) AddTagToTask(taskId, tagId string) error                         { return nil }
-func (m *NoOpDB) DeleteTagFromTask(taskId, tagId string) error                    { return nil }
-func (m *NoOpDB) FindTask(taskId string) (models.Task, error)                     { return models.Task{}, nil }
-func (m *NoOpDB) SaveTask(task models.Task) error                                 { return nil }
-func (m *NoOpDB) MigrationExists(id string) bool                                  { return false }
-func (m *NoOpDB) RecordMigration(id string)                                       {}
-func (m *NoOpDB) TasksTags(taskIds []string) (map[string][]models.TaskTag, error) { return nil, nil }
-func (m *NoOpDB) DeleteTag(tagId string) error                                    { return nil }
-func (m *NoOpDB) DeleteTagFromAllTasks(tagId string) error                        { return nil }
+func (m *NoOpDB) DeleteSettings(ctx context.Context, settingsId string) error { return nil }
+func (m *NoOpDB) SaveSettings(ctx context.Context, s models.Settings) error   { return nil }
+func (m *NoOpDB) SaveTag(ctx context.Context, owner, tagId string) error      { return nil }
+func (m *NoOpDB) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
+	return nil, nil
+}
+func (m *NoOpDB) Tags(ctx context.Context, owner string) ([]models.TaskTag, error)  { return nil, nil }
+func (m *NoOpDB) AddTagToTask(ctx context.Context, taskId, tagId string) error      { return nil }
+func (m *NoOpDB) DeleteTagFromTask(ctx context.Context, taskId, tagId string) error { return nil }
+func (m *NoOpDB) FindTask(ctx context.Context, taskId string) (models.Task, error) {
+	return models.Task{}, nil
+}
+func (m *NoOpDB) SaveTask(ctx context.Context, task models.Task) error { return nil }
+func (m *NoOpDB) MigrationExists(ctx context.Context, id string) bool  { return false }
+func (m *NoOpDB) RecordMigration(ctx context.Context, id string)       {}
+func (m *NoOpDB) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
+	return nil, nil
+}
+func (m *NoOpDB) DeleteTag(ctx context.Context, owner, tagId string) error              { return nil }
+func (m *NoOpDB) DeleteTagFromAllTasks(ctx context.Context, owner, tagId string) error  { return nil }
+func (m *NoOpDB) ForEachTask(ctx context.Context, fn func(models.Task) error) error     { return nil }
+func (m *NoOpDB) ForEachTag(ctx context.Context, fn func(models.TagRecord) error) error { return nil }
+func (m *NoOpDB) ForEachTaskTag(ctx context.Context, fn func(taskId string, tag models.TaskTag) error) error {
+	return nil
+}
+func (m *NoOpDB) SaveTagRecord(ctx context.Context, tag models.TagRecord) error { return nil }
+func (m *NoOpDB) AllSettings(ctx context.Context) ([]models.Settings, error)    { return nil, nil }
+func (m *NoOpDB) Webhooks(ctx context.Context) ([]models.Webhook, error)        { return nil, nil }
+func (m *NoOpDB) SaveWebhook(ctx context.Context, h models.Webhook) error       { return nil }
+func (m *NoOpDB) DeleteWebhook(ctx context.Context, webhookId string) error     { return nil }
+func (m *NoOpDB) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	return nil
+}
+func (m *NoOpDB) WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+func (m *NoOpDB) PruneWebhookDeliveries(ctx context.Context, keep int) error { return nil }
+func (m *NoOpDB) FindUser(ctx context.Context, username string) (models.User, error) {
+	return models.User{}, ErrNotFound
+}
+func (m *NoOpDB) CountUsers(ctx context.Context) (int, error)                         { return 0, nil }
+func (m *NoOpDB) Users(ctx context.Context) ([]models.User, error)                    { return nil, nil }
+func (m *NoOpDB) SaveUser(ctx context.Context, u models.User) error                   { return nil }
+func (m *NoOpDB) SetUserAdmin(ctx context.Context, username string, admin bool) error { return nil }
+func (m *NoOpDB) TransferOwnership(ctx context.Context, from, to string) error        { return nil }
+func (m *NoOpDB) DeleteUser(ctx context.Context, username string) error               { return nil }
+func (m *NoOpDB) SaveSession(ctx context.Context, s models.Session) error             { return nil }
+func (m *NoOpDB) DeleteSession(ctx context.Context, tokenHash string) error           { return nil }
+func (m *NoOpDB) DeleteExpiredSessions(ctx context.Context, now time.Time) error      { return nil }
+func (m *NoOpDB) FindSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	return models.Session{}, ErrNotFound
+}
+func (m *NoOpDB) ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error) {
+	return nil, nil
+}
+func (m *NoOpDB) FindApiTokenByHash(ctx context.Context, tokenHash string) (models.ApiToken, error) {
+	return models.ApiToken{}, ErrNotFound
+}
+func (m *NoOpDB) SaveApiToken(ctx context.Context, t models.ApiToken) error               { return nil }
+func (m *NoOpDB) DeleteApiToken(ctx context.Context, username, tokenId string) error      { return nil }
+func (m *NoOpDB) TouchApiToken(ctx context.Context, tokenId string, used time.Time) error { return nil }
+func (m *NoOpDB) Projects(ctx context.Context, username string) ([]models.Project, error) {
+	return nil, nil
+}
+func (m *NoOpDB) AllProjects(ctx context.Context) ([]models.Project, error) { return nil, nil }
+func (m *NoOpDB) FindProject(ctx context.Context, projectId string) (models.Project, error) {
+	return models.Project{}, ErrNotFound
+}
+func (m *NoOpDB) SaveProject(ctx context.Context, p models.Project) error   { return nil }
+func (m *NoOpDB) DeleteProject(ctx context.Context, projectId string) error { return nil }
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	PROJECTS_COLUMNS        = "id, name, owner, created"
+	PROJECT_MEMBERS_COLUMNS = "project_id, username"
+
+	// visibleTasksCondition matches the tasks of the user and those of their projects;
+	// it takes the username twice
+	visibleTasksCondition = "(owner = ? OR project IN (SELECT project_id FROM ProjectMembers WHERE username = ?))"
+)
+
+func (d *DbSQLite) initProjects() {
+	slog.Debug("initProjects")
+	d.addProjectsTables()
+}
+
+func (d *DbSQLite) addProjectsTables() {
+	id := "add_projects"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec(`
+			CREATE TABLE IF NOT EXISTS projects (
+				id TEXT PRIMARY KEY,
+				name TEXT,
+				owner TEXT,
+				created TEXT
+			);
+			CREATE TABLE IF NOT EXISTS ProjectMembers (
+				project_id TEXT,
+				username TEXT,
+				PRIMARY KEY (project_id, username),
+				FOREIGN KEY (project_id) REFERENCES projects(id)
+			);
+			CREATE INDEX IF NOT EXISTS project_members_username ON ProjectMembers (username);
+		`)
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
+}
+
+func scanProject(row interface{ Scan(...any) error }) (models.Project, error) {
+	var p models.Project
+	var created string
+	if err := row.Scan(&p.Id, &p.Name, &p.Owner, &created); err != nil {
+		return p, err
+	}
+	var err error
+	p.Created, err = parseStorageTime(created)
+	if err != nil {
+		return p, fmt.Errorf("invalid created of project %v: %w", p.Id, err)
+	}
+	return p, nil
+}
+
+// Projects returns the projects the user is a member of, with their members
+func (d *DbSQLite) Projects(ctx context.Context, username string) ([]models.Project, error) {
+	defer observeQuery("Projects", time.Now())
+	sql := "SELECT " + PROJECTS_COLUMNS + " FROM projects WHERE id IN (SELECT project_id FROM ProjectMembers WHERE username = ?) ORDER BY name, id"
+	rows, err := d.conn(ctx).QueryContext(ctx, sql, username)
+	if err != nil {
+		return nil, fmt.Errorf("Projects: failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Projects: %w", err)
+		}
+		result = append(result, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Projects: error iterating projects: %w", err)
+	}
+	rows.Close()
+
+	for i := range result {
+		if result[i].Members, err = d.projectMembers(ctx, result[i].Id); err != nil {
+			return nil, fmt.Errorf("Projects: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// AllProjects returns every project with its members, ordered by creation time
+func (d *DbSQLite) AllProjects(ctx context.Context) ([]models.Project, error) {
+	defer observeQuery("AllProjects", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+PROJECTS_COLUMNS+" FROM projects ORDER BY created, id")
+	if err != nil {
+		return nil, fmt.Errorf("AllProjects: failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("AllProjects: %w", err)
+		}
+		result = append(result, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AllProjects: error iterating projects: %w", err)
+	}
+	rows.Close()
+
+	for i := range result {
+		if result[i].Members, err = d.projectMembers(ctx, result[i].Id); err != nil {
+			return nil, fmt.Errorf("AllProjects: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (d *DbSQLite) FindProject(ctx context.Context, projectId string) (models.Project, error) {
+	defer observeQuery("FindProject", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+PROJECTS_COLUMNS+" FROM projects WHERE id = ?", projectId)
+	p, err := scanProject(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	}
+	if err != nil {
+		return p, fmt.Errorf("FindProject: %w", err)
+	}
+	if p.Members, err = d.projectMembers(ctx, projectId); err != nil {
+		return p, fmt.Errorf("FindProject: %w", err)
+	}
+	return p, nil
+}
+
+func (d *DbSQLite) projectMembers(ctx context.Context, projectId string) ([]string, error) {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT username FROM ProjectMembers WHERE project_id = ? ORDER BY username", projectId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the members of project %v: %w", projectId, err)
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, username)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating members: %w", err)
+	}
+	return members, nil
+}
+
+// SaveProject inserts or renames the project and replaces its members
+func (d *DbSQLite) SaveProject(ctx context.Context, p models.Project) error {
+	defer observeQuery("SaveProject", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		sql := "INSERT INTO projects (" + PROJECTS_COLUMNS + ") VALUES (?, ?, ?, ?) " +
+			"ON CONFLICT(id) DO UPDATE SET name=excluded.name, owner=excluded.owner"
+		args := []any{p.Id, p.Name, p.Owner, storageTime(p.Created)}
+		logQuery(ctx, "SaveProject", sql, args)
+		tx := d.conn(ctx)
+		if _, err := tx.ExecContext(ctx, sql, args...); err != nil {
+			return fmt.Errorf("SaveProject: failed to save project %v: %w", p.Id, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM ProjectMembers WHERE project_id = ?", p.Id); err != nil {
+			return fmt.Errorf("SaveProject: failed to clear the members of %v: %w", p.Id, err)
+		}
+		for _, username := range p.Members {
+			_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO ProjectMembers ("+PROJECT_MEMBERS_COLUMNS+") VALUES (?, ?)", p.Id, username)
+			if err != nil {
+				return fmt.Errorf("SaveProject: failed to add member %v to %v: %w", username, p.Id, err)
+			}
+		}
+		// the tasks of the project keep only assignees who are still members
+		_, err := tx.ExecContext(ctx, "UPDATE tasks SET assignee = '' WHERE project = ? AND assignee != '' AND assignee NOT IN (SELECT username FROM ProjectMembers WHERE project_id = ?)", p.Id, p.Id)
+		if err != nil {
+			return fmt.Errorf("SaveProject: failed to unassign former members of %v: %w", p.Id, err)
+		}
+		return nil
+	})
+}
+
+// DeleteProject deletes the project, its tasks become private tasks of their owners
+// again and are unassigned from everyone else
+func (d *DbSQLite) DeleteProject(ctx context.Context, projectId string) error {
+	defer observeQuery("DeleteProject", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		tx := d.conn(ctx)
+		_, err := tx.ExecContext(ctx, "UPDATE tasks SET project = '', assignee = CASE WHEN assignee = owner THEN assignee ELSE '' END WHERE project = ?", projectId)
+		if err != nil {
+			return fmt.Errorf("DeleteProject: failed to release the tasks of %v: %w", projectId, err)
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM ProjectMembers WHERE project_id = ?", projectId); err != nil {
+			return fmt.Errorf("DeleteProject: failed to delete the members of %v: %w", projectId, err)
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = ?", projectId)
+		if err != nil {
+			return fmt.Errorf("DeleteProject: failed to delete project %v: %w", projectId, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
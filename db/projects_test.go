@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestProjects_Visibility(t *testing.T) {
+	db := setupTestDB(t)
+	p := models.Project{Id: "p1", Name: "Launch", Owner: "alice", Created: time.Now(), Members: []string{"alice", "bob"}}
+	if err := db.SaveProject(context.Background(), p); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+	for _, task := range []models.Task{
+		{Id: "private", Title: "private", Owner: "alice"},
+		{Id: "shared", Title: "shared", Owner: "alice", Project: "p1", Assignee: "bob"},
+		{Id: "carol", Title: "carol", Owner: "carol"},
+	} {
+		if err := db.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+
+	tasks, err := db.FindTasks(context.Background(), models.TasksQuery{Owner: "bob"})
+	if err != nil || len(tasks) != 1 || tasks[0].Id != "shared" || tasks[0].Assignee != "bob" {
+		t.Fatalf("expected bob to see the shared task only, got %+v, %v", tasks, err)
+	}
+	tasks, err = db.FindTasks(context.Background(), models.TasksQuery{Owner: "alice"})
+	if err != nil || len(tasks) != 2 {
+		t.Errorf("expected alice to see both of her tasks, got %+v, %v", tasks, err)
+	}
+	tasks, err = db.FindTasks(context.Background(), models.TasksQuery{Owner: "alice", AssignedToMe: true})
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("expected no task assigned to alice, got %+v, %v", tasks, err)
+	}
+	tasks, err = db.FindTasks(context.Background(), models.TasksQuery{Owner: "alice", Project: "p1"})
+	if err != nil || len(tasks) != 1 || tasks[0].Id != "shared" {
+		t.Errorf("expected the project filter to keep the shared task, got %+v, %v", tasks, err)
+	}
+
+	projects, err := db.Projects(context.Background(), "bob")
+	if err != nil || len(projects) != 1 || len(projects[0].Members) != 2 {
+		t.Errorf("expected bob to be a member of the project, got %+v, %v", projects, err)
+	}
+	if projects, _ := db.Projects(context.Background(), "carol"); len(projects) != 0 {
+		t.Errorf("expected carol to have no projects, got %+v", projects)
+	}
+}
+
+func TestSaveProject_UnassignsFormerMembers(t *testing.T) {
+	db := setupTestDB(t)
+	p := models.Project{Id: "p1", Name: "Launch", Owner: "alice", Created: time.Now(), Members: []string{"alice", "bob"}}
+	if err := db.SaveProject(context.Background(), p); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+	if err := db.SaveTask(context.Background(), models.Task{Id: "t1", Owner: "alice", Project: "p1", Assignee: "bob"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+
+	p.Members = []string{"alice"}
+	if err := db.SaveProject(context.Background(), p); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+	task, err := db.FindTask(context.Background(), "t1")
+	if err != nil || task.Assignee != "" || task.Project != "p1" {
+		t.Errorf("expected the task to stay in the project unassigned, got %+v, %v", task, err)
+	}
+	if tasks, _ := db.FindTasks(context.Background(), models.TasksQuery{Owner: "bob"}); len(tasks) != 0 {
+		t.Errorf("expected bob to lose access, got %+v", tasks)
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	db := setupTestDB(t)
+	p := models.Project{Id: "p1", Name: "Launch", Owner: "alice", Created: time.Now(), Members: []string{"alice", "bob"}}
+	if err := db.SaveProject(context.Background(), p); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+	for _, task := range []models.Task{
+		{Id: "t1", Owner: "alice", Project: "p1", Assignee: "bob"},
+		{Id: "t2", Owner: "alice", Project: "p1", Assignee: "alice"},
+	} {
+		if err := db.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+
+	if err := db.DeleteProject(context.Background(), "p1"); err != nil {
+		t.Fatalf("DeleteProject failed: %v", err)
+	}
+	if _, err := db.FindProject(context.Background(), "p1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after the delete, got %v", err)
+	}
+	t1, _ := db.FindTask(context.Background(), "t1")
+	t2, _ := db.FindTask(context.Background(), "t2")
+	if t1.Project != "" || t1.Assignee != "" || t2.Project != "" || t2.Assignee != "alice" {
+		t.Errorf("expected the tasks to become private to alice, got %+v, %+v", t1, t2)
+	}
+	if err := db.DeleteProject(context.Background(), "p1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing project, got %v", err)
+	}
+}
@@ -1,30 +1,85 @@
 package db
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
-	"github.com/inaryzen/priotasks/common"
-	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/models"
 	_ "modernc.org/sqlite"
 )
 
 const (
 	TASKS_TAGS_COLUMNS = "task_id, tag_id"
-	TAGS_COLUMNS       = "id, created"
+	TAGS_COLUMNS       = "id, created, owner"
 )
 
 func (d *DbSQLite) initTags() {
-	common.Debug("initTags")
+	slog.Debug("initTags")
 	d.addTagsTable()
+	d.addTagsOwner()
+}
+
+// addTagsOwner rebuilds the tags so that every user has their own, the tags
+// of TasksTags are then resolved through the owner of the task
+func (d *DbSQLite) addTagsOwner() {
+	id := "tags_table_add_owner"
+	ctx := context.Background()
+	if d.MigrationExists(ctx, id) {
+		return
+	}
+	conn, err := d.instance.Conn(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	// the foreign keys cannot be switched inside of a transaction
+	if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		panic(err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+	_, err = tx.Exec(`
+		CREATE TABLE tags_new (
+			id TEXT,
+			created TEXT,
+			owner TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (owner, id)
+		);
+		INSERT INTO tags_new (id, created) SELECT id, created FROM tags;
+		DROP TABLE tags;
+		ALTER TABLE tags_new RENAME TO tags;
+
+		CREATE TABLE TasksTags_new (
+			task_id TEXT,
+			tag_id TEXT,
+			PRIMARY KEY (task_id, tag_id),
+			FOREIGN KEY (task_id) REFERENCES tasks(id)
+		);
+		INSERT INTO TasksTags_new (task_id, tag_id) SELECT task_id, tag_id FROM TasksTags;
+		DROP TABLE TasksTags;
+		ALTER TABLE TasksTags_new RENAME TO TasksTags;
+	`)
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	d.RecordMigration(ctx, id)
 }
 
 func (d *DbSQLite) addTagsTable() {
 	id := "add_tags_support"
-	if !d.MigrationExists(id) {
+	if !d.MigrationExists(context.Background(), id) {
 		tagsTableSql := `
 		CREATE TABLE IF NOT EXISTS tags (
 			id TEXT PRIMARY KEY,
@@ -50,62 +105,94 @@ func (d *DbSQLite) addTagsTable() {
 			panic(err)
 		}
 
-		d.RecordMigration(id)
+		d.RecordMigration(context.Background(), id)
 	}
 }
 
-func (d *DbSQLite) SaveTag(tagId string) error {
-	sql := "INSERT INTO tags (" + TAGS_COLUMNS + ") " + " VALUES (?, ?)"
+func (d *DbSQLite) SaveTag(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("SaveTag", time.Now())
+	sql := "INSERT INTO tags (" + TAGS_COLUMNS + ") " + " VALUES (?, ?, ?)"
 	args := []any{
 		tagId,
-		time.Now().Format(consts.DEFAULT_TIME_FORMAT),
+		storageTime(time.Now()),
+		owner,
 	}
-	logQuery("SaveTag", sql, args)
+	logQuery(ctx, "SaveTag", sql, args)
 
-	_, err := d.instance.Exec(sql, args...)
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("SaveTag: error; tagId=%v; %w", tagId, err)
 	}
 	return nil
 }
 
-func (d *DbSQLite) AddTagToTask(taskId, tagId string) error {
-	sql := "INSERT INTO TasksTags (" + TASKS_TAGS_COLUMNS + ") " + " VALUES (?, ?)"
+// SaveTagRecord saves the tag preserving its creation time
+func (d *DbSQLite) SaveTagRecord(ctx context.Context, tag models.TagRecord) error {
+	defer observeQuery("SaveTagRecord", time.Now())
+	sql := "INSERT INTO tags (" + TAGS_COLUMNS + ") " + " VALUES (?, ?, ?)"
+	args := []any{
+		string(tag.Id),
+		storageTime(tag.Created),
+		tag.Owner,
+	}
+	logQuery(ctx, "SaveTagRecord", sql, args)
+
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("SaveTagRecord: error; tagId=%v; %w", tag.Id, err)
+	}
+	return nil
+}
+
+// AddTagToTask tags the task with a tag of the task owner; ErrNotFound when
+// either the task or the tag does not exist
+func (d *DbSQLite) AddTagToTask(ctx context.Context, taskId, tagId string) error {
+	defer observeQuery("AddTagToTask", time.Now())
+	sql := "INSERT INTO TasksTags (" + TASKS_TAGS_COLUMNS + ") " +
+		"SELECT tasks.id, tags.id FROM tasks JOIN tags ON tags.owner = tasks.owner AND tags.id = ? WHERE tasks.id = ?"
 	args := []any{
-		taskId,
 		tagId,
+		taskId,
 	}
-	logQuery("AddTagToTask", sql, args)
+	logQuery(ctx, "AddTagToTask", sql, args)
 
-	_, err := d.instance.Exec(sql, args...)
+	result, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("failed to add tag to task; taskId=%v; tagId=%v; %w", taskId, tagId, err)
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("AddTagToTask: failed to get affected rows; taskId=%v; tagId=%v; %w", taskId, tagId, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-func (d *DbSQLite) deleteAllTagsFromTask(taskId string, tx *sql.Tx) error {
+func (d *DbSQLite) deleteAllTagsFromTask(ctx context.Context, taskId string) error {
 	sql := "DELETE FROM TasksTags WHERE task_id = ?"
 	args := []any{
 		taskId,
 	}
-	logQuery("deleteAllTagsFromTask", sql, args)
-	_, err := tx.Exec(sql, args...)
+	logQuery(ctx, "deleteAllTagsFromTask", sql, args)
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("deleteAllTagsFromTask: %w", err)
 	}
 	return nil
 }
 
-func (d *DbSQLite) DeleteTagFromTask(taskId, tagId string) error {
+func (d *DbSQLite) DeleteTagFromTask(ctx context.Context, taskId, tagId string) error {
+	defer observeQuery("DeleteTagFromTask", time.Now())
 	sql := "DELETE FROM TasksTags WHERE task_id = ? AND tag_id = ?"
 	args := []any{
 		taskId,
 		tagId,
 	}
-	logQuery("DeleteTagFromTask", sql, args)
+	logQuery(ctx, "DeleteTagFromTask", sql, args)
 
-	result, err := d.instance.Exec(sql, args...)
+	result, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("DeleteTagFromTask: failed to delete tag from task; taskId=%v; tagId=%v; %w", taskId, tagId, err)
 	}
@@ -122,24 +209,26 @@ func (d *DbSQLite) DeleteTagFromTask(taskId, tagId string) error {
 	return nil
 }
 
-func (d *DbSQLite) DeleteTagFromAllTasks(tagId string) error {
-	sql := "DELETE FROM TasksTags WHERE tag_id = ?"
-	args := []any{tagId}
-	logQuery("DeleteTagFromAllTasks", sql, args)
+func (d *DbSQLite) DeleteTagFromAllTasks(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("DeleteTagFromAllTasks", time.Now())
+	sql := "DELETE FROM TasksTags WHERE tag_id = ? AND task_id IN (SELECT id FROM tasks WHERE owner = ?)"
+	args := []any{tagId, owner}
+	logQuery(ctx, "DeleteTagFromAllTasks", sql, args)
 
-	_, err := d.instance.Exec(sql, args...)
+	_, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("DeleteTagFromAllTasks: failed to delete tag associations; tagId=%v; %w", tagId, err)
 	}
 	return nil
 }
 
-func (d *DbSQLite) DeleteTag(tagId string) error {
-	sql := "DELETE FROM tags WHERE id = ?"
-	args := []any{tagId}
-	logQuery("DeleteTag", sql, args)
+func (d *DbSQLite) DeleteTag(ctx context.Context, owner, tagId string) error {
+	defer observeQuery("DeleteTag", time.Now())
+	sql := "DELETE FROM tags WHERE owner = ? AND id = ?"
+	args := []any{owner, tagId}
+	logQuery(ctx, "DeleteTag", sql, args)
 
-	result, err := d.instance.Exec(sql, args...)
+	result, err := d.conn(ctx).ExecContext(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("DeleteTag: error; tagId=%v; %w", tagId, err)
 	}
@@ -153,12 +242,13 @@ func (d *DbSQLite) DeleteTag(tagId string) error {
 	return nil
 }
 
-func (d *DbSQLite) TaskTags(taskId string) ([]models.TaskTag, error) {
+func (d *DbSQLite) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
+	defer observeQuery("TaskTags", time.Now())
 	sql := "SELECT " + TASKS_TAGS_COLUMNS + " FROM TasksTags WHERE task_id = ?"
 	args := []interface{}{taskId}
-	logQuery("TaskTags", sql, args)
+	logQuery(ctx, "TaskTags", sql, args)
 
-	rows, err := d.instance.Query(sql, args...)
+	rows, err := d.conn(ctx).QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("TaskTags: failed to query tags for task %s: %w", taskId, err)
 	}
@@ -180,26 +270,28 @@ func (d *DbSQLite) TaskTags(taskId string) ([]models.TaskTag, error) {
 	return tags, nil
 }
 
-func (d *DbSQLite) TasksTags(taskIds []string) (map[string][]models.TaskTag, error) {
+func (d *DbSQLite) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
+	defer observeQuery("TasksTags", time.Now())
 	result := make(map[string][]models.TaskTag)
 	if len(taskIds) == 0 {
 		return result, nil
 	}
 
 	placeholders := make([]string, len(taskIds))
-	args := make([]any, len(taskIds))
+	args := make([]any, 0, len(taskIds)+2)
 	for i, id := range taskIds {
 		placeholders[i] = "?"
-		args[i] = id
+		args = append(args, id)
 	}
+	args = append(args, owner, owner)
 
-	sql := fmt.Sprintf("SELECT %s FROM TasksTags WHERE task_id IN (%s)",
+	sql := fmt.Sprintf("SELECT %s FROM TasksTags WHERE task_id IN (%s) AND task_id IN (SELECT id FROM tasks WHERE "+visibleTasksCondition+")",
 		TASKS_TAGS_COLUMNS,
 		strings.Join(placeholders, ","))
 
-	logQuery("TasksTags", sql, args)
+	logQuery(ctx, "TasksTags", sql, args)
 
-	rows, err := d.instance.Query(sql, args...)
+	rows, err := d.conn(ctx).QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("TasksTags: failed to query tags for tasks: %w", err)
 	}
@@ -220,11 +312,12 @@ func (d *DbSQLite) TasksTags(taskIds []string) (map[string][]models.TaskTag, err
 	return result, nil
 }
 
-func (d *DbSQLite) Tags() ([]models.TaskTag, error) {
-	sql := "SELECT id FROM tags ORDER BY created DESC"
-	logQuery("Tags", sql, nil)
+func (d *DbSQLite) Tags(ctx context.Context, owner string) ([]models.TaskTag, error) {
+	defer observeQuery("Tags", time.Now())
+	sql := "SELECT id FROM tags WHERE owner = ? ORDER BY created DESC"
+	logQuery(ctx, "Tags", sql, []any{owner})
 
-	rows, err := d.instance.Query(sql)
+	rows, err := d.conn(ctx).QueryContext(ctx, sql, owner)
 	if err != nil {
 		return nil, fmt.Errorf("Tags: failed to query tags: %w", err)
 	}
@@ -245,3 +338,59 @@ func (d *DbSQLite) Tags() ([]models.TaskTag, error) {
 
 	return tags, nil
 }
+
+func (d *DbSQLite) ForEachTag(ctx context.Context, fn func(models.TagRecord) error) error {
+	sql := "SELECT " + TAGS_COLUMNS + " FROM tags ORDER BY owner, created, id"
+	logQuery(ctx, "ForEachTag", sql, nil)
+
+	rows, err := d.conn(ctx).QueryContext(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("ForEachTag: failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tagId, created, owner string
+		if err := rows.Scan(&tagId, &created, &owner); err != nil {
+			return fmt.Errorf("ForEachTag: failed to scan tag: %w", err)
+		}
+		createdTime, err := parseStorageTime(created)
+		if err != nil {
+			return fmt.Errorf("ForEachTag: failed to parse created time: %w", err)
+		}
+		if err := fn(models.TagRecord{Id: models.TaskTag(tagId), Created: createdTime, Owner: owner}); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("ForEachTag: error iterating tags: %w", err)
+	}
+	return nil
+}
+
+func (d *DbSQLite) ForEachTaskTag(ctx context.Context, fn func(taskId string, tag models.TaskTag) error) error {
+	sql := "SELECT " + TASKS_TAGS_COLUMNS + " FROM TasksTags ORDER BY task_id, tag_id"
+	logQuery(ctx, "ForEachTaskTag", sql, nil)
+
+	rows, err := d.conn(ctx).QueryContext(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("ForEachTaskTag: failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskId, tagId string
+		if err := rows.Scan(&taskId, &tagId); err != nil {
+			return fmt.Errorf("ForEachTaskTag: failed to scan tag: %w", err)
+		}
+		if err := fn(taskId, models.TaskTag(tagId)); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("ForEachTaskTag: error iterating tags: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/uuid"
@@ -15,23 +17,23 @@ func TestSaveAndFindTag_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
 	tag := "test-tag"
-	err := db.SaveTag(tag)
+	err := db.SaveTag(context.Background(), "", tag)
 	if err != nil {
 		t.Fatalf("SaveTag failed: %v", err)
 	}
 
 	// Add tag to task
-	err = db.AddTagToTask(task.Id, tag)
+	err = db.AddTagToTask(context.Background(), task.Id, tag)
 	if err != nil {
 		t.Fatalf("AddTagToTask failed: %v", err)
 	}
 
-	tags, err := db.Tags()
+	tags, err := db.Tags(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Tags failed: %v", err)
 	}
@@ -52,7 +54,7 @@ func TestAddAndGetTaskTags_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
@@ -61,7 +63,7 @@ func TestAddAndGetTaskTags_Success(t *testing.T) {
 	uniqueTags := make(map[string]bool)
 	for _, tag := range tags {
 		if !uniqueTags[tag] {
-			err := db.SaveTag(tag)
+			err := db.SaveTag(context.Background(), "", tag)
 			if err != nil {
 				t.Fatalf("SaveTag failed: %v", err)
 			}
@@ -71,14 +73,14 @@ func TestAddAndGetTaskTags_Success(t *testing.T) {
 
 	// Add tags to task
 	for _, tag := range tags {
-		err := db.AddTagToTask(task.Id, tag)
+		err := db.AddTagToTask(context.Background(), task.Id, tag)
 		if err != nil {
 			t.Fatalf("AddTagToTask failed: %v", err)
 		}
 	}
 
 	// Get tags for task
-	taskTags, err := db.TaskTags(task.Id)
+	taskTags, err := db.TaskTags(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("TaskTags failed: %v", err)
 	}
@@ -110,30 +112,30 @@ func TestDeleteTagFromTask_Success(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
 	tag := "test-tag"
 
 	// Setup
-	err := db.SaveTag(tag)
+	err := db.SaveTag(context.Background(), "", tag)
 	if err != nil {
 		t.Fatalf("SaveTag failed: %v", err)
 	}
-	err = db.AddTagToTask(task.Id, tag)
+	err = db.AddTagToTask(context.Background(), task.Id, tag)
 	if err != nil {
 		t.Fatalf("AddTagToTask failed: %v", err)
 	}
 
 	// Delete tag
-	err = db.DeleteTagFromTask(task.Id, tag)
+	err = db.DeleteTagFromTask(context.Background(), task.Id, tag)
 	if err != nil {
 		t.Fatalf("DeleteTagFromTask failed: %v", err)
 	}
 
 	// Verify tag was deleted
-	taskTags, err := db.TaskTags(task.Id)
+	taskTags, err := db.TaskTags(context.Background(), task.Id)
 	if err != nil {
 		t.Fatalf("TaskTags failed: %v", err)
 	}
@@ -151,11 +153,11 @@ func TestDeleteTagFromTask_NonExistent(t *testing.T) {
 		Id:    uuid.New().String(),
 		Title: "Test Task",
 	}
-	if err := db.SaveTask(task); err != nil {
+	if err := db.SaveTask(context.Background(), task); err != nil {
 		t.Fatalf("failed to create test task: %v", err)
 	}
 
-	err := db.DeleteTagFromTask(task.Id, "non-existent-tag")
+	err := db.DeleteTagFromTask(context.Background(), task.Id, "non-existent-tag")
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound for non-existent tag, got: %v", err)
 	}
@@ -177,7 +179,7 @@ func TestTasksTags_Success(t *testing.T) {
 			Id:    taskId,
 			Title: "Test Task " + taskId,
 		}
-		if err := db.SaveTask(task); err != nil {
+		if err := db.SaveTask(context.Background(), task); err != nil {
 			t.Fatalf("failed to create task %s: %v", taskId, err)
 		}
 	}
@@ -187,7 +189,7 @@ func TestTasksTags_Success(t *testing.T) {
 	for _, tags := range tagsByTask {
 		for _, tag := range tags {
 			if !uniqueTags[tag] {
-				if err := db.SaveTag(tag); err != nil {
+				if err := db.SaveTag(context.Background(), "", tag); err != nil {
 					t.Fatalf("failed to save tag %s: %v", tag, err)
 				}
 				uniqueTags[tag] = true
@@ -198,14 +200,14 @@ func TestTasksTags_Success(t *testing.T) {
 	// Create task-tag associations
 	for taskId, tags := range tagsByTask {
 		for _, tag := range tags {
-			if err := db.AddTagToTask(taskId, tag); err != nil {
+			if err := db.AddTagToTask(context.Background(), taskId, tag); err != nil {
 				t.Fatalf("failed to add tag %s to task %s: %v", tag, taskId, err)
 			}
 		}
 	}
 
 	// Get tags for all tasks
-	result, err := db.TasksTags(taskIds)
+	result, err := db.TasksTags(context.Background(), "", taskIds)
 	if err != nil {
 		t.Fatalf("TasksTags failed: %v", err)
 	}
@@ -235,7 +237,7 @@ func TestTasksTags_Success(t *testing.T) {
 func TestTasksTags_EmptyInput(t *testing.T) {
 	db := setupTestDB(t)
 
-	result, err := db.TasksTags([]string{})
+	result, err := db.TasksTags(context.Background(), "", []string{})
 	if err != nil {
 		t.Fatalf("TasksTags failed with empty input: %v", err)
 	}
@@ -248,7 +250,7 @@ func TestTasksTags_EmptyInput(t *testing.T) {
 func TestTasksTags_NonExistentTasks(t *testing.T) {
 	db := setupTestDB(t)
 
-	result, err := db.TasksTags([]string{"non-existent-task"})
+	result, err := db.TasksTags(context.Background(), "", []string{"non-existent-task"})
 	if err != nil {
 		t.Fatalf("TasksTags failed with non-existent task: %v", err)
 	}
@@ -268,32 +270,32 @@ func TestDeleteTagFromAllTasks_Success(t *testing.T) {
 		{Id: uuid.New().String(), Title: "Task 3"},
 	}
 	for _, task := range tasks {
-		if err := db.SaveTask(task); err != nil {
+		if err := db.SaveTask(context.Background(), task); err != nil {
 			t.Fatalf("failed to create task %s: %v", task.Id, err)
 		}
 	}
 
 	// Create and save a tag
 	tag := "test-tag"
-	if err := db.SaveTag(tag); err != nil {
+	if err := db.SaveTag(context.Background(), "", tag); err != nil {
 		t.Fatalf("failed to save tag: %v", err)
 	}
 
 	// Add tag to all tasks
 	for _, task := range tasks {
-		if err := db.AddTagToTask(task.Id, tag); err != nil {
+		if err := db.AddTagToTask(context.Background(), task.Id, tag); err != nil {
 			t.Fatalf("failed to add tag to task %s: %v", task.Id, err)
 		}
 	}
 
 	// Delete tag from all tasks
-	if err := db.DeleteTagFromAllTasks(tag); err != nil {
+	if err := db.DeleteTagFromAllTasks(context.Background(), "", tag); err != nil {
 		t.Fatalf("DeleteTagFromAllTasks failed: %v", err)
 	}
 
 	// Verify tag was removed from all tasks
 	for _, task := range tasks {
-		taskTags, err := db.TaskTags(task.Id)
+		taskTags, err := db.TaskTags(context.Background(), task.Id)
 		if err != nil {
 			t.Fatalf("failed to get tags for task %s: %v", task.Id, err)
 		}
@@ -307,7 +309,7 @@ func TestDeleteTagFromAllTasks_NonExistentTag(t *testing.T) {
 	db := setupTestDB(t)
 
 	// Should not return error for non-existent tag
-	err := db.DeleteTagFromAllTasks("non-existent-tag")
+	err := db.DeleteTagFromAllTasks(context.Background(), "", "non-existent-tag")
 	if err != nil {
 		t.Errorf("expected no error for non-existent tag, got: %v", err)
 	}
@@ -318,17 +320,17 @@ func TestDeleteTag_Success(t *testing.T) {
 
 	// Create and save a tag
 	tag := "test-tag"
-	if err := db.SaveTag(tag); err != nil {
+	if err := db.SaveTag(context.Background(), "", tag); err != nil {
 		t.Fatalf("failed to save tag: %v", err)
 	}
 
 	// Delete the tag
-	if err := db.DeleteTag(tag); err != nil {
+	if err := db.DeleteTag(context.Background(), "", tag); err != nil {
 		t.Fatalf("DeleteTag failed: %v", err)
 	}
 
 	// Verify tag was deleted by checking Tags list
-	tags, err := db.Tags()
+	tags, err := db.Tags(context.Background(), "")
 	if err != nil {
 		t.Fatalf("failed to get tags: %v", err)
 	}
@@ -341,8 +343,102 @@ func TestDeleteTag_NonExistent(t *testing.T) {
 	db := setupTestDB(t)
 
 	// Try to delete non-existent tag
-	err := db.DeleteTag("non-existent-tag")
+	err := db.DeleteTag(context.Background(), "", "non-existent-tag")
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound for non-existent tag, got: %v", err)
 	}
 }
+
+func TestTags_ScopedToOwner(t *testing.T) {
+	db := setupTestDB(t)
+
+	aliceTask := models.Task{Id: uuid.New().String(), Title: "alice", Owner: "alice"}
+	bobTask := models.Task{Id: uuid.New().String(), Title: "bob", Owner: "bob"}
+	for _, task := range []models.Task{aliceTask, bobTask} {
+		if err := db.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+	if err := db.SaveTag(context.Background(), "alice", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if err := db.SaveTag(context.Background(), "bob", "work"); err != nil {
+		t.Fatalf("the same tag of another user should be saved, got %v", err)
+	}
+	if err := db.SaveTag(context.Background(), "bob", "home"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+
+	if err := db.AddTagToTask(context.Background(), aliceTask.Id, "home"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("a tag of another user should not be added, got %v", err)
+	}
+	for _, task := range []models.Task{aliceTask, bobTask} {
+		if err := db.AddTagToTask(context.Background(), task.Id, "work"); err != nil {
+			t.Fatalf("AddTagToTask failed: %v", err)
+		}
+	}
+
+	tags, err := db.Tags(context.Background(), "alice")
+	if err != nil || len(tags) != 1 || tags[0] != "work" {
+		t.Errorf("expected only the tag of alice, got %v, %v", tags, err)
+	}
+	tasksTags, err := db.TasksTags(context.Background(), "alice", []string{aliceTask.Id, bobTask.Id})
+	if err != nil || len(tasksTags) != 1 || len(tasksTags[aliceTask.Id]) != 1 {
+		t.Errorf("expected only the task of alice, got %v, %v", tasksTags, err)
+	}
+
+	if err := db.DeleteTagFromAllTasks(context.Background(), "alice", "work"); err != nil {
+		t.Fatalf("DeleteTagFromAllTasks failed: %v", err)
+	}
+	if err := db.DeleteTag(context.Background(), "alice", "work"); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	bobTags, err := db.TaskTags(context.Background(), bobTask.Id)
+	if err != nil || len(bobTags) != 1 {
+		t.Errorf("the tag of bob should be kept, got %v, %v", bobTags, err)
+	}
+}
+
+func TestAddTagsOwner_KeepsExistingTags(t *testing.T) {
+	db := setupTestDB(t)
+	task := models.Task{Id: uuid.New().String(), Title: "Test Task"}
+	if err := db.SaveTask(context.Background(), task); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+
+	// back to the tables as they were before the owners
+	_, err := db.instance.Exec(`
+		DROP TABLE TasksTags;
+		DROP TABLE tags;
+		CREATE TABLE tags (id TEXT PRIMARY KEY, created TEXT);
+		CREATE TABLE TasksTags (
+			task_id TEXT,
+			tag_id TEXT,
+			PRIMARY KEY (task_id, tag_id),
+			FOREIGN KEY (task_id) REFERENCES tasks(id),
+			FOREIGN KEY (tag_id) REFERENCES tags(id)
+		);
+		INSERT INTO tags (id, created) VALUES ('work', '2025-01-02 03:04:05');
+		DELETE FROM migration WHERE id = 'tags_table_add_owner';
+	`)
+	if err != nil {
+		t.Fatalf("failed to restore the old tables: %v", err)
+	}
+	if _, err = db.instance.Exec("INSERT INTO TasksTags (task_id, tag_id) VALUES (?, 'work')", task.Id); err != nil {
+		t.Fatalf("failed to tag the task: %v", err)
+	}
+
+	db.addTagsOwner()
+
+	tags, err := db.Tags(context.Background(), "")
+	if err != nil || len(tags) != 1 || tags[0] != "work" {
+		t.Errorf("expected the tag to be kept, got %v, %v", tags, err)
+	}
+	taskTags, err := db.TaskTags(context.Background(), task.Id)
+	if err != nil || len(taskTags) != 1 {
+		t.Errorf("expected the task to keep its tag, got %v, %v", taskTags, err)
+	}
+	if err := db.SaveTag(context.Background(), "alice", "work"); err != nil {
+		t.Errorf("expected the tags to be per user after the migration, got %v", err)
+	}
+}
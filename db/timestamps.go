@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+)
+
+// The times are stored in UTC, to the second, so that they compare and sort as the instants
+// they are whatever the time zone they were given in. They are read back in UTC as well, the
+// time zone of each user is applied where the times are shown. The zero time, which marks the
+// open tasks and the unset dates, stays as it is.
+
+// storageTime is the text t is stored as, RFC 3339 in UTC
+func storageTime(t time.Time) string {
+	return t.UTC().Format(consts.STORAGE_TIME_FORMAT)
+}
+
+// parseStorageTime reads a time stored by storageTime
+func parseStorageTime(text string) (time.Time, error) {
+	t, err := time.Parse(consts.STORAGE_TIME_FORMAT, text)
+	if err != nil {
+		return t, err
+	}
+	return utcTime(t), nil
+}
+
+// utcTime is the stored time t as it is read back
+func utcTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// legacyTimeColumns are the columns that held the wall clock of the server, without a time
+// zone, before the times were stored in UTC; the settings held dates
+var legacyTimeColumns = []struct {
+	table, column, layout string
+}{
+	{"tasks", "created", consts.DEFAULT_TIME_FORMAT},
+	{"tasks", "updated", consts.DEFAULT_TIME_FORMAT},
+	{"tasks", "completed", consts.DEFAULT_TIME_FORMAT},
+	{"tags", "created", consts.DEFAULT_TIME_FORMAT},
+	{"settings", "completed_from", consts.DEFAULT_DATE_FORMAT},
+	{"settings", "completed_to", consts.DEFAULT_DATE_FORMAT},
+	{"webhooks", "created", consts.DEFAULT_TIME_FORMAT},
+	{"webhook_deliveries", "created", consts.DEFAULT_TIME_FORMAT},
+	{"users", "created", consts.DEFAULT_TIME_FORMAT},
+	{"sessions", "created", consts.DEFAULT_TIME_FORMAT},
+	{"sessions", "expires", consts.DEFAULT_TIME_FORMAT},
+	{"api_tokens", "created", consts.DEFAULT_TIME_FORMAT},
+	{"api_tokens", "last_used", consts.DEFAULT_TIME_FORMAT},
+	{"projects", "created", consts.DEFAULT_TIME_FORMAT},
+}
+
+// fromLegacyTime is the time of a wall clock stored before the times were stored in UTC,
+// by a server in the time zone loc. The zero time stays zero.
+func fromLegacyTime(wallClock time.Time, loc *time.Location) time.Time {
+	if wallClock.IsZero() {
+		return time.Time{}
+	}
+	return time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(),
+		wallClock.Hour(), wallClock.Minute(), wallClock.Second(), 0, loc)
+}
+
+// legacyTimeRow is a time to convert and the rowid of its row
+type legacyTimeRow struct {
+	id   string
+	time string
+}
+
+// migrateTimesToUTC converts the times stored as the wall clock of the server into UTC. The
+// server wrote them in its local time zone unless legacyLocation tells another one.
+func (d *DbSQLite) migrateTimesToUTC() {
+	loc := d.legacyLocation
+	if loc == nil {
+		loc = time.Local
+	}
+	id := "store_times_in_utc"
+	err := d.WithTx(context.Background(), func(ctx context.Context) error {
+		if d.MigrationExists(ctx, id) {
+			return nil
+		}
+		for _, c := range legacyTimeColumns {
+			if err := d.convertLegacyTimes(ctx, c.table, c.column, c.layout, loc); err != nil {
+				return fmt.Errorf("migration %v: %w", id, err)
+			}
+		}
+		d.RecordMigration(ctx, id)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (d *DbSQLite) convertLegacyTimes(ctx context.Context, table, column, layout string, loc *time.Location) error {
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT rowid, "+column+" FROM "+table+" WHERE "+column+" != ''")
+	if err != nil {
+		return fmt.Errorf("failed to read %v.%v: %w", table, column, err)
+	}
+	var legacy []legacyTimeRow
+	for rows.Next() {
+		var row legacyTimeRow
+		if err := rows.Scan(&row.id, &row.time); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read %v.%v: %w", table, column, err)
+		}
+		legacy = append(legacy, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %v.%v: %w", table, column, err)
+	}
+
+	for _, row := range legacy {
+		wallClock, err := time.Parse(layout, row.time)
+		if err != nil {
+			return fmt.Errorf("invalid %v.%v of row %v: %w", table, column, row.id, err)
+		}
+		converted := storageTime(fromLegacyTime(wallClock, loc))
+		if _, err := d.conn(ctx).ExecContext(ctx, "UPDATE "+table+" SET "+column+" = ? WHERE rowid = ?", converted, row.id); err != nil {
+			return fmt.Errorf("failed to convert %v.%v of row %v: %w", table, column, row.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+// TestMigrateTimesToUTC converts the wall clocks written by a server in Berlin, before and
+// after the changes of daylight saving time
+func TestMigrateTimesToUTC(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbFile := filepath.Join(t.TempDir(), "legacy.sqlite")
+	legacy := NewDbSQLite()
+	legacy.Init(dbFile)
+	must(t, legacy.SaveUser(ctx, models.User{Username: "alice"}))
+	must(t, legacy.SaveSession(ctx, models.Session{TokenHash: "s1", Username: "alice"}))
+	must(t, legacy.SaveTask(ctx, models.Task{Id: "t1", Owner: "alice"}))
+	must(t, legacy.SaveSettings(ctx, models.Settings{Id: "alice"}))
+	for _, statement := range []string{
+		// 2025-03-30 02:00 CET is 03:00 CEST, 2025-10-26 03:00 CEST is 02:00 CET
+		"UPDATE tasks SET created = '2025-03-30 01:30:00', updated = '2025-03-30 03:30:00', completed = '0001-01-01 00:00:00'",
+		"UPDATE settings SET completed_from = '2025-10-26', completed_to = '0001-01-01'",
+		"UPDATE users SET created = '2025-10-26 03:30:00'",
+		"UPDATE sessions SET created = '2025-10-25 12:00:00', expires = '2025-10-26 12:00:00'",
+		"DELETE FROM migration WHERE id = 'store_times_in_utc'",
+	} {
+		if _, err := legacy.instance.Exec(statement); err != nil {
+			t.Fatalf("failed to write the legacy times: %v", err)
+		}
+	}
+	legacy.Close()
+
+	d := NewDbSQLite()
+	d.legacyLocation = loadLocation(t, "Europe/Berlin")
+	d.Init(dbFile)
+	t.Cleanup(d.Close)
+
+	task, err := d.FindTask(ctx, "t1")
+	must(t, err)
+	if want := time.Date(2025, 3, 30, 0, 30, 0, 0, time.UTC); !task.Created.Equal(want) {
+		t.Errorf("expected created at %v, got %v", want, task.Created)
+	}
+	if want := time.Date(2025, 3, 30, 1, 30, 0, 0, time.UTC); !task.Updated.Equal(want) {
+		t.Errorf("expected updated at %v, got %v", want, task.Updated)
+	}
+	if task.IsCompleted() {
+		t.Errorf("expected the task to stay open, got %v", task.Completed)
+	}
+
+	settings, err := d.FindSettings(ctx, "alice")
+	must(t, err)
+	if want := time.Date(2025, 10, 25, 22, 0, 0, 0, time.UTC); !settings.TasksQuery.CompletedFrom.Equal(want) {
+		t.Errorf("expected the completion dates to start at midnight in Berlin, %v, got %v", want, settings.TasksQuery.CompletedFrom)
+	}
+	if !settings.TasksQuery.CompletedTo.IsZero() {
+		t.Errorf("expected the unset completion date to stay zero, got %v", settings.TasksQuery.CompletedTo)
+	}
+
+	user, err := d.FindUser(ctx, "alice")
+	must(t, err)
+	if want := time.Date(2025, 10, 26, 2, 30, 0, 0, time.UTC); !user.Created.Equal(want) {
+		t.Errorf("expected the user created at %v, got %v", want, user.Created)
+	}
+	session, err := d.FindSession(ctx, "s1")
+	must(t, err)
+	// the day the clocks go back has 25 hours
+	if got := session.Expires.Sub(session.Created); got != 25*time.Hour {
+		t.Errorf("expected the session to last 25 hours, got %v", got)
+	}
+}
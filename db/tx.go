@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// unitOfWorkContextKey keys the transaction of one store, so that a context carrying the
+// transaction of a store does not make another store query in it
+type unitOfWorkContextKey struct {
+	store Db
+}
+
+// currentUnitOfWorkContextKey keys the innermost transaction of a context, whatever its store
+type currentUnitOfWorkContextKey struct{}
+
+// unitOfWork is the transaction the queries made with a context run in, together with
+// what is left to do once it is committed
+type unitOfWork struct {
+	// tx is nil for the MemDB, whose transactions hold its lock instead
+	tx          *sql.Tx
+	afterCommit []func(ctx context.Context)
+}
+
+// withUnitOfWork returns ctx carrying uow as the transaction of store
+func withUnitOfWork(ctx context.Context, store Db, uow *unitOfWork) context.Context {
+	ctx = context.WithValue(ctx, unitOfWorkContextKey{store: store}, uow)
+	return context.WithValue(ctx, currentUnitOfWorkContextKey{}, uow)
+}
+
+// unitOfWorkOf returns the transaction of store that ctx carries, nil outside of them
+func unitOfWorkOf(ctx context.Context, store Db) *unitOfWork {
+	uow, _ := ctx.Value(unitOfWorkContextKey{store: store}).(*unitOfWork)
+	return uow
+}
+
+func currentUnitOfWork(ctx context.Context) *unitOfWork {
+	uow, _ := ctx.Value(currentUnitOfWorkContextKey{}).(*unitOfWork)
+	return uow
+}
+
+// InTx reports whether the queries made with ctx run in a transaction
+func InTx(ctx context.Context) bool {
+	return currentUnitOfWork(ctx) != nil
+}
+
+// AfterCommit runs fn once the innermost transaction of ctx is committed, right away outside
+// of transactions; fn is dropped when the transaction is rolled back. It is for the side
+// effects, e.g. notifications, that must only happen for changes that were saved. fn gets
+// the context the transaction was started with.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if uow := currentUnitOfWork(ctx); uow != nil {
+		uow.afterCommit = append(uow.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// querier runs the queries of DbSQLite and DbPostgres either in a transaction or on the pool
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of d that ctx carries, the pool outside of them
+func (d *DbSQLite) conn(ctx context.Context) querier {
+	if uow := unitOfWorkOf(ctx, d); uow != nil {
+		return uow.tx
+	}
+	return d.instance
+}
+
+// WithTx runs fn in a transaction: every query made with the context fn gets is committed
+// together when fn succeeds, and rolled back when it fails. Nested calls join the outer
+// transaction.
+func (d *DbSQLite) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitOfWorkOf(ctx, d) != nil {
+		return fn(ctx)
+	}
+	tx, err := d.instance.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("WithTx: %w", err)
+	}
+	return runInTx(ctx, d, tx, fn)
+}
+
+// runInTx runs fn in the transaction tx of store, commits it when fn succeeds and rolls it
+// back otherwise, then runs what was deferred until the commit
+func runInTx(ctx context.Context, store Db, tx *sql.Tx, fn func(ctx context.Context) error) error {
+	uow := &unitOfWork{tx: tx}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(withUnitOfWork(ctx, store, uow)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("WithTx: %w", err)
+	}
+	committed = true
+	for _, after := range uow.afterCommit {
+		after(ctx)
+	}
+	return nil
+}
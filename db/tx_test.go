@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestWithTx_Commit(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	var committed []string
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		if !InTx(ctx) {
+			t.Errorf("expected the context to carry the transaction")
+		}
+		if err := db.SaveTask(ctx, models.Task{Id: "t1", Title: "Task"}); err != nil {
+			return err
+		}
+		// nested transactions join the outer one
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			return db.SaveTag(ctx, "", "work")
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := db.FindTask(ctx, "t1"); err != nil {
+			t.Errorf("expected the task to be found in the transaction, got %v", err)
+		}
+		AfterCommit(ctx, func(ctx context.Context) {
+			if InTx(ctx) {
+				t.Errorf("expected the context of the caller after the commit")
+			}
+			committed = append(committed, "t1")
+		})
+		if len(committed) != 0 {
+			t.Errorf("expected AfterCommit to wait for the commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	if _, err := db.FindTask(ctx, "t1"); err != nil {
+		t.Errorf("expected the task to be committed, got %v", err)
+	}
+	if tags, _ := db.Tags(ctx, ""); len(tags) != 1 {
+		t.Errorf("expected the tag of the nested transaction to be committed, got %v", tags)
+	}
+	if len(committed) != 1 {
+		t.Errorf("expected AfterCommit to run once, got %v", committed)
+	}
+}
+
+func TestWithTx_Rollback(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		if err := db.SaveTask(ctx, models.Task{Id: "t1", Title: "Task"}); err != nil {
+			return err
+		}
+		AfterCommit(ctx, func(ctx context.Context) {
+			t.Errorf("expected AfterCommit to be dropped on rollback")
+		})
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if _, err := db.FindTask(ctx, "t1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the task to be rolled back, got %v", err)
+	}
+
+	ran := false
+	AfterCommit(ctx, func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Errorf("expected AfterCommit to run right away outside of transactions")
+	}
+}
+
+func TestDeleteTask_JoinsTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	if err := db.SaveTask(ctx, models.Task{Id: "t1", Title: "Task"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+
+	failure := errors.New("failure")
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		if err := db.DeleteTask(ctx, "t1"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if _, err := db.FindTask(ctx, "t1"); err != nil {
+		t.Errorf("expected the deletion to be rolled back, got %v", err)
+	}
+}
+
+func TestWithTx_OtherStoreKeepsItsConnection(t *testing.T) {
+	db := setupTestDB(t)
+	other := setupTestDB(t)
+	ctx := context.Background()
+	failure := errors.New("failure")
+
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		// the other store neither joins nor runs its queries in the transaction of db
+		err := other.WithTx(ctx, func(ctx context.Context) error {
+			return other.SaveTask(ctx, models.Task{Id: "t1", Title: "Other"})
+		})
+		if err != nil {
+			return err
+		}
+		if err := other.SaveTag(ctx, "", "work"); err != nil {
+			return err
+		}
+		if _, err := db.FindTask(ctx, "t1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the task of the other store not to be found, got %v", err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if _, err := other.FindTask(ctx, "t1"); err != nil {
+		t.Errorf("expected the task of the other store to be committed, got %v", err)
+	}
+	if tags, _ := other.Tags(ctx, ""); len(tags) != 1 {
+		t.Errorf("expected the tag of the other store to be saved, got %v", tags)
+	}
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	USERS_COLUMNS    = "username, password_hash, created, admin"
+	SESSIONS_COLUMNS = "token_hash, username, created, expires"
+)
+
+func (d *DbSQLite) initUsers() {
+	slog.Debug("initUsers")
+	d.addUsersTables()
+	d.addUsersAdminColumn()
+}
+
+// addUsersAdminColumn makes the users created before the roles existed admins,
+// they have been managing the installation
+func (d *DbSQLite) addUsersAdminColumn() {
+	id := "users_table_add_admin_column"
+	if !d.MigrationExists(context.Background(), id) {
+		_, err := d.instance.Exec(`
+			ALTER TABLE users ADD COLUMN admin INTEGER NOT NULL DEFAULT 0;
+			UPDATE users SET admin = 1;
+		`)
+		if err != nil {
+			panic(err)
+		} else {
+			d.RecordMigration(context.Background(), id)
+		}
+	}
+}
+
+func scanUser(row interface{ Scan(...any) error }) (models.User, error) {
+	var u models.User
+	var created string
+	if err := row.Scan(&u.Username, &u.PasswordHash, &created, &u.Admin); err != nil {
+		return u, err
+	}
+	var err error
+	u.Created, err = parseStorageTime(created)
+	if err != nil {
+		return u, fmt.Errorf("invalid created of user %v: %w", u.Username, err)
+	}
+	return u, nil
+}
+
+func (d *DbSQLite) addUsersTables() {
+	id := "add_users"
+	if !d.MigrationExists(context.Background(), id) {
+		usersSql := `
+		CREATE TABLE IF NOT EXISTS users (
+			username TEXT PRIMARY KEY,
+			password_hash TEXT,
+			created TEXT
+		)
+		`
+		_, err := d.instance.Exec(usersSql)
+		if err != nil {
+			panic(err)
+		}
+
+		sessionsSql := `
+		CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			username TEXT,
+			created TEXT,
+			expires TEXT,
+			FOREIGN KEY (username) REFERENCES users(username) ON DELETE CASCADE
+		)
+		`
+		_, err = d.instance.Exec(sessionsSql)
+		if err != nil {
+			panic(err)
+		}
+
+		d.RecordMigration(context.Background(), id)
+	}
+}
+
+func (d *DbSQLite) FindUser(ctx context.Context, username string) (models.User, error) {
+	defer observeQuery("FindUser", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+USERS_COLUMNS+" FROM users WHERE username = ?", username)
+	u, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	if err != nil {
+		return u, fmt.Errorf("FindUser: %w", err)
+	}
+	return u, nil
+}
+
+func (d *DbSQLite) Users(ctx context.Context) ([]models.User, error) {
+	defer observeQuery("Users", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+USERS_COLUMNS+" FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("Users: failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("Users: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Users: error iterating users: %w", err)
+	}
+	return users, nil
+}
+
+func (d *DbSQLite) CountUsers(ctx context.Context) (int, error) {
+	defer observeQuery("CountUsers", time.Now())
+	var count int
+	if err := d.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return 0, fmt.Errorf("CountUsers: %w", err)
+	}
+	return count, nil
+}
+
+// SaveUser inserts the user or replaces the password of an existing one
+func (d *DbSQLite) SaveUser(ctx context.Context, u models.User) error {
+	defer observeQuery("SaveUser", time.Now())
+	sql := "INSERT INTO users (" + USERS_COLUMNS + ") VALUES (?, ?, ?, ?) ON CONFLICT(username) DO UPDATE SET password_hash = excluded.password_hash"
+	args := []any{
+		u.Username,
+		u.PasswordHash,
+		storageTime(u.Created),
+		u.Admin,
+	}
+	logQuery(ctx, "SaveUser", sql, []any{u.Username})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveUser: failed to save user %v: %w", u.Username, err)
+	}
+	return nil
+}
+
+func (d *DbSQLite) SetUserAdmin(ctx context.Context, username string, admin bool) error {
+	defer observeQuery("SetUserAdmin", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "UPDATE users SET admin = ? WHERE username = ?", admin, username)
+	if err != nil {
+		return fmt.Errorf("SetUserAdmin: failed to update user %v: %w", username, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SetUserAdmin: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TransferOwnership hands the tasks, the tags, the projects, the project memberships and
+// the assignments of one user over to another; the tags both of them have are merged
+func (d *DbSQLite) TransferOwnership(ctx context.Context, from, to string) error {
+	defer observeQuery("TransferOwnership", time.Now())
+	return d.WithTx(ctx, func(ctx context.Context) error {
+		statements := []string{
+			"UPDATE tasks SET owner = ? WHERE owner = ?",
+			"UPDATE tasks SET assignee = ? WHERE assignee = ?",
+			"INSERT OR IGNORE INTO tags (" + TAGS_COLUMNS + ") SELECT id, created, ? FROM tags WHERE owner = ?",
+			"UPDATE projects SET owner = ? WHERE owner = ?",
+			"INSERT OR IGNORE INTO ProjectMembers (" + PROJECT_MEMBERS_COLUMNS + ") SELECT project_id, ? FROM ProjectMembers WHERE username = ?",
+		}
+		for _, stmt := range statements {
+			if _, err := d.conn(ctx).ExecContext(ctx, stmt, to, from); err != nil {
+				return fmt.Errorf("TransferOwnership: from %q to %q: %w", from, to, err)
+			}
+		}
+		for _, stmt := range []string{"DELETE FROM tags WHERE owner = ?", "DELETE FROM ProjectMembers WHERE username = ?"} {
+			if _, err := d.conn(ctx).ExecContext(ctx, stmt, from); err != nil {
+				return fmt.Errorf("TransferOwnership: from %q to %q: %w", from, to, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteUser deletes the user and signs out their sessions
+func (d *DbSQLite) DeleteUser(ctx context.Context, username string) error {
+	defer observeQuery("DeleteUser", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: failed to delete user %v: %w", username, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteUser: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DbSQLite) SaveSession(ctx context.Context, s models.Session) error {
+	defer observeQuery("SaveSession", time.Now())
+	sql := "INSERT INTO sessions (" + SESSIONS_COLUMNS + ") VALUES (?, ?, ?, ?)"
+	args := []any{
+		s.TokenHash,
+		s.Username,
+		storageTime(s.Created),
+		storageTime(s.Expires),
+	}
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveSession: failed to save session of %v: %w", s.Username, err)
+	}
+	return nil
+}
+
+func (d *DbSQLite) FindSession(ctx context.Context, tokenHash string) (models.Session, error) {
+	defer observeQuery("FindSession", time.Now())
+	row := d.conn(ctx).QueryRowContext(ctx, "SELECT "+SESSIONS_COLUMNS+" FROM sessions WHERE token_hash = ?", tokenHash)
+	var s models.Session
+	var created, expires string
+	err := row.Scan(&s.TokenHash, &s.Username, &created, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	if err != nil {
+		return s, fmt.Errorf("FindSession: %w", err)
+	}
+	if s.Created, err = parseStorageTime(created); err != nil {
+		return s, fmt.Errorf("FindSession: invalid created: %w", err)
+	}
+	if s.Expires, err = parseStorageTime(expires); err != nil {
+		return s, fmt.Errorf("FindSession: invalid expires: %w", err)
+	}
+	return s, nil
+}
+
+func (d *DbSQLite) DeleteSession(ctx context.Context, tokenHash string) error {
+	defer observeQuery("DeleteSession", time.Now())
+	if _, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("DeleteSession: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions that expired before the time
+func (d *DbSQLite) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	defer observeQuery("DeleteExpiredSessions", time.Now())
+	_, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM sessions WHERE expires <= ?", storageTime(now))
+	if err != nil {
+		return fmt.Errorf("DeleteExpiredSessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestUsers_Admin(t *testing.T) {
+	db := setupTestDB(t)
+	for _, u := range []models.User{
+		{Username: "bob", PasswordHash: "h", Created: time.Now()},
+		{Username: "alice", PasswordHash: "h", Created: time.Now(), Admin: true},
+	} {
+		if err := db.SaveUser(context.Background(), u); err != nil {
+			t.Fatalf("SaveUser failed: %v", err)
+		}
+	}
+
+	users, err := db.Users(context.Background())
+	if err != nil || len(users) != 2 || users[0].Username != "alice" || !users[0].Admin || users[1].Admin {
+		t.Fatalf("expected alice as the admin and bob, got %+v, %v", users, err)
+	}
+
+	if err := db.SetUserAdmin(context.Background(), "bob", true); err != nil {
+		t.Fatalf("SetUserAdmin failed: %v", err)
+	}
+	if bob, err := db.FindUser(context.Background(), "bob"); err != nil || !bob.Admin {
+		t.Errorf("expected bob to be an admin, got %+v, %v", bob, err)
+	}
+	if err := db.SetUserAdmin(context.Background(), "carol", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	task := models.Task{Id: "t1", Title: "legacy"}
+	if err := db.SaveTask(context.Background(), task); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	for _, owner := range []string{"", "alice"} {
+		if err := db.SaveTag(context.Background(), owner, "work"); err != nil {
+			t.Fatalf("SaveTag failed: %v", err)
+		}
+	}
+	if err := db.SaveTag(context.Background(), "", "home"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if err := db.AddTagToTask(context.Background(), task.Id, "home"); err != nil {
+		t.Fatalf("AddTagToTask failed: %v", err)
+	}
+
+	if err := db.TransferOwnership(context.Background(), "", "alice"); err != nil {
+		t.Fatalf("TransferOwnership failed: %v", err)
+	}
+
+	tasks, err := db.FindTasks(context.Background(), models.TasksQuery{Owner: "alice", FilterCompleted: false})
+	if err != nil || len(tasks) != 1 || tasks[0].Owner != "alice" {
+		t.Errorf("expected the task to belong to alice, got %+v, %v", tasks, err)
+	}
+	tags, err := db.Tags(context.Background(), "alice")
+	if err != nil || len(tags) != 2 {
+		t.Errorf("expected the tags to be merged, got %v, %v", tags, err)
+	}
+	if tags, _ := db.Tags(context.Background(), ""); len(tags) != 0 {
+		t.Errorf("expected no tags left without an owner, got %v", tags)
+	}
+	tasksTags, err := db.TasksTags(context.Background(), "alice", []string{task.Id})
+	if err != nil || len(tasksTags[task.Id]) != 1 {
+		t.Errorf("expected the task to keep its tag, got %v, %v", tasksTags, err)
+	}
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	WEBHOOKS_COLUMNS           = "id, url, events, tags, secret, created"
+	WEBHOOK_DELIVERIES_COLUMNS = "id, delivery_id, webhook_id, url, event, task_id, attempt, status_code, error, created, duration_ms"
+)
+
+func (d *DbSQLite) initWebhooks() {
+	slog.Debug("initWebhooks")
+	d.addWebhooksTables()
+}
+
+func (d *DbSQLite) addWebhooksTables() {
+	id := "add_webhooks"
+	if !d.MigrationExists(context.Background(), id) {
+		webhooksSql := `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			url TEXT,
+			events TEXT,
+			tags TEXT,
+			secret TEXT,
+			created TEXT
+		)
+		`
+		_, err := d.instance.Exec(webhooksSql)
+		if err != nil {
+			panic(err)
+		}
+
+		deliveriesSql := `
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			delivery_id TEXT,
+			webhook_id TEXT,
+			url TEXT,
+			event TEXT,
+			task_id TEXT,
+			attempt INTEGER,
+			status_code INTEGER,
+			error TEXT,
+			created TEXT,
+			duration_ms INTEGER
+		)
+		`
+		_, err = d.instance.Exec(deliveriesSql)
+		if err != nil {
+			panic(err)
+		}
+
+		d.RecordMigration(context.Background(), id)
+	}
+}
+
+func (d *DbSQLite) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	defer observeQuery("Webhooks", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+WEBHOOKS_COLUMNS+" FROM webhooks ORDER BY created, id")
+	if err != nil {
+		return nil, fmt.Errorf("Webhooks: failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Webhook
+	for rows.Next() {
+		var h models.Webhook
+		var eventsText, tagsText, created string
+		if err := rows.Scan(&h.Id, &h.Url, &eventsText, &tagsText, &h.Secret, &created); err != nil {
+			return nil, fmt.Errorf("Webhooks: failed to scan webhook: %w", err)
+		}
+		if err := json.Unmarshal([]byte(eventsText), &h.Events); err != nil {
+			return nil, fmt.Errorf("Webhooks: invalid events of webhook %v: %w", h.Id, err)
+		}
+		if err := json.Unmarshal([]byte(tagsText), &h.Tags); err != nil {
+			return nil, fmt.Errorf("Webhooks: invalid tags of webhook %v: %w", h.Id, err)
+		}
+		h.Created, err = parseStorageTime(created)
+		if err != nil {
+			return nil, fmt.Errorf("Webhooks: invalid created of webhook %v: %w", h.Id, err)
+		}
+		result = append(result, h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Webhooks: error iterating webhooks: %w", err)
+	}
+	return result, nil
+}
+
+// SaveWebhook inserts the webhook or replaces the one with the same id
+func (d *DbSQLite) SaveWebhook(ctx context.Context, h models.Webhook) error {
+	defer observeQuery("SaveWebhook", time.Now())
+	events, err := json.Marshal(h.Events)
+	if err != nil {
+		return fmt.Errorf("SaveWebhook: %w", err)
+	}
+	tags := []byte("[]")
+	if len(h.Tags) > 0 {
+		tags, err = json.Marshal(h.Tags)
+		if err != nil {
+			return fmt.Errorf("SaveWebhook: %w", err)
+		}
+	}
+	sql := "INSERT OR REPLACE INTO webhooks (" + WEBHOOKS_COLUMNS + ") VALUES (?, ?, ?, ?, ?, ?)"
+	args := []any{
+		h.Id,
+		h.Url,
+		string(events),
+		string(tags),
+		h.Secret,
+		storageTime(h.Created),
+	}
+	logQuery(ctx, "SaveWebhook", sql, []any{h.Id, h.Url, string(events), string(tags)})
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveWebhook: failed to save webhook %v: %w", h.Id, err)
+	}
+	return nil
+}
+
+func (d *DbSQLite) DeleteWebhook(ctx context.Context, webhookId string) error {
+	defer observeQuery("DeleteWebhook", time.Now())
+	result, err := d.conn(ctx).ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", webhookId)
+	if err != nil {
+		return fmt.Errorf("DeleteWebhook: failed to delete webhook %v: %w", webhookId, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("DeleteWebhook: failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveWebhookDelivery appends the attempt to the delivery log, the id is assigned by the database
+func (d *DbSQLite) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	defer observeQuery("SaveWebhookDelivery", time.Now())
+	sql := "INSERT INTO webhook_deliveries (" + WEBHOOK_DELIVERIES_COLUMNS + ") VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	args := []any{
+		delivery.DeliveryId,
+		delivery.WebhookId,
+		delivery.Url,
+		string(delivery.Event),
+		delivery.TaskId,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.Error,
+		storageTime(delivery.Created),
+		delivery.Duration.Milliseconds(),
+	}
+	logQuery(ctx, "SaveWebhookDelivery", sql, args)
+
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, args...); err != nil {
+		return fmt.Errorf("SaveWebhookDelivery: failed to save delivery %v: %w", delivery.DeliveryId, err)
+	}
+	return nil
+}
+
+// WebhookDeliveries returns the latest attempts first
+func (d *DbSQLite) WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	defer observeQuery("WebhookDeliveries", time.Now())
+	rows, err := d.conn(ctx).QueryContext(ctx, "SELECT "+WEBHOOK_DELIVERIES_COLUMNS+" FROM webhook_deliveries ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookDeliveries: failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var event, created string
+		var durationMs int64
+		err := rows.Scan(
+			&delivery.Id,
+			&delivery.DeliveryId,
+			&delivery.WebhookId,
+			&delivery.Url,
+			&event,
+			&delivery.TaskId,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.Error,
+			&created,
+			&durationMs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("WebhookDeliveries: failed to scan delivery: %w", err)
+		}
+		delivery.Event = models.WebhookEvent(event)
+		delivery.Duration = time.Duration(durationMs) * time.Millisecond
+		delivery.Created, err = parseStorageTime(created)
+		if err != nil {
+			return nil, fmt.Errorf("WebhookDeliveries: invalid created of delivery %v: %w", delivery.Id, err)
+		}
+		result = append(result, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("WebhookDeliveries: error iterating deliveries: %w", err)
+	}
+	return result, nil
+}
+
+// PruneWebhookDeliveries keeps only the latest attempts in the delivery log
+func (d *DbSQLite) PruneWebhookDeliveries(ctx context.Context, keep int) error {
+	defer observeQuery("PruneWebhookDeliveries", time.Now())
+	sql := "DELETE FROM webhook_deliveries WHERE id NOT IN (SELECT id FROM webhook_deliveries ORDER BY id DESC LIMIT ?)"
+	if _, err := d.conn(ctx).ExecContext(ctx, sql, keep); err != nil {
+		return fmt.Errorf("PruneWebhookDeliveries: %w", err)
+	}
+	return nil
+}
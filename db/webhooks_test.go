@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestSaveAndDeleteWebhook(t *testing.T) {
+	db := setupTestDB(t)
+
+	h := models.Webhook{
+		Id:      "hook-1",
+		Url:     "https://example.com/hook",
+		Events:  []models.WebhookEvent{models.WebhookTaskCreated, models.WebhookTaskCompleted},
+		Tags:    []models.TaskTag{"work", "a,b"},
+		Secret:  "s3cret",
+		Created: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := db.SaveWebhook(context.Background(), h); err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+
+	hooks, err := db.Webhooks(context.Background())
+	if err != nil {
+		t.Fatalf("Webhooks failed: %v", err)
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(hooks))
+	}
+	got := hooks[0]
+	if got.Url != h.Url || got.Secret != h.Secret || !got.Created.Equal(h.Created) ||
+		!slices.Equal(got.Events, h.Events) || !slices.Equal(got.Tags, h.Tags) {
+		t.Errorf("webhook was not preserved: got %+v, want %+v", got, h)
+	}
+
+	if err := db.DeleteWebhook(context.Background(), h.Id); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if err := db.DeleteWebhook(context.Background(), h.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestWebhookDeliveries_LatestFirstAndPruned(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i := 1; i <= 5; i++ {
+		err := db.SaveWebhookDelivery(context.Background(), models.WebhookDelivery{
+			DeliveryId: "d",
+			WebhookId:  "hook-1",
+			Event:      models.WebhookTaskCreated,
+			Attempt:    i,
+			StatusCode: 500,
+			Created:    time.Now(),
+			Duration:   1500 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("SaveWebhookDelivery failed: %v", err)
+		}
+	}
+	if err := db.PruneWebhookDeliveries(context.Background(), 3); err != nil {
+		t.Fatalf("PruneWebhookDeliveries failed: %v", err)
+	}
+
+	log, err := db.WebhookDeliveries(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("WebhookDeliveries failed: %v", err)
+	}
+	var attempts []int
+	for _, d := range log {
+		attempts = append(attempts, d.Attempt)
+	}
+	if !slices.Equal(attempts, []int{5, 4, 3}) {
+		t.Errorf("expected the latest 3 attempts first, got %v", attempts)
+	}
+	if log[0].Duration != 1500*time.Millisecond {
+		t.Errorf("unexpected duration: %v", log[0].Duration)
+	}
+}
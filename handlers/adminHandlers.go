@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/services"
+)
+
+func (s *Server) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawUsersView(w, r, "")
+}
+
+func (s *Server) PostUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.svc.Users.CreateUser(r.Context(),
+		r.PostForm.Get(consts.PARAM_LOGIN_USERNAME),
+		r.PostForm.Get(consts.PARAM_LOGIN_PASSWORD),
+		r.PostForm.Get(consts.PARAM_USER_ADMIN) == "on",
+	)
+	s.handleUserChange(w, r, err)
+}
+
+func (s *Server) PostUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.svc.Users.ResetPassword(r.Context(), r.PathValue("name"), r.PostForm.Get(consts.PARAM_LOGIN_PASSWORD))
+	s.handleUserChange(w, r, err)
+}
+
+func (s *Server) PostUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.svc.Users.SetUserAdmin(r.Context(), r.PathValue("name"), r.PostForm.Get(consts.PARAM_USER_ADMIN) == "on")
+	s.handleUserChange(w, r, err)
+}
+
+// PostUserDeleteHandler deletes the user, their tasks go to the admin deleting them
+func (s *Server) PostUserDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.svc.Users.DeleteUser(r.Context(), r.PathValue("name"), currentOwner(r))
+	s.handleUserChange(w, r, err)
+}
+
+func (s *Server) handleUserChange(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, services.ErrInvalidUser), errors.Is(err, services.ErrLastAdmin):
+		w.WriteHeader(http.StatusBadRequest)
+		s.drawUsersView(w, r, err.Error())
+	case err != nil:
+		internalServerError(w, r, err)
+	default:
+		http.Redirect(w, r, consts.URL_ADMIN_USERS, http.StatusSeeOther)
+	}
+}
+
+func (s *Server) drawUsersView(w http.ResponseWriter, r *http.Request, errMsg string) {
+	users, err := s.svc.Users.Users(r.Context())
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	components.UsersView(users, errMsg).Render(r.Context(), w)
+}
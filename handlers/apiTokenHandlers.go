@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+func (s *Server) GetApiTokensHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawApiTokensView(w, r, "", "")
+}
+
+func (s *Server) PostApiTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := models.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "sign-in is disabled", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, _, err := s.svc.Users.CreateApiToken(r.Context(),
+		user.Username,
+		r.PostForm.Get(consts.PARAM_TOKEN_NAME),
+		models.ApiTokenScope(r.PostForm.Get(consts.PARAM_TOKEN_SCOPE)),
+	)
+	if errors.Is(err, services.ErrInvalidApiToken) {
+		w.WriteHeader(http.StatusBadRequest)
+		s.drawApiTokensView(w, r, "", err.Error())
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	// rendered rather than redirected to, the token is not stored
+	w.Header().Set("Cache-Control", "no-store")
+	s.drawApiTokensView(w, r, token, "")
+}
+
+func (s *Server) PostApiTokenRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := models.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "sign-in is disabled", http.StatusForbidden)
+		return
+	}
+	err := s.svc.Users.RevokeApiToken(r.Context(), user.Username, r.PathValue("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, consts.URL_SETTINGS_TOKENS, http.StatusSeeOther)
+}
+
+func (s *Server) drawApiTokensView(w http.ResponseWriter, r *http.Request, newToken string, errMsg string) {
+	var tokens []models.ApiToken
+	if user, ok := models.UserFromContext(r.Context()); ok {
+		var err error
+		if tokens, err = s.svc.Users.ApiTokens(r.Context(), user.Username); err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+	}
+	components.ApiTokensView(tokens, newToken, errMsg).Render(r.Context(), w)
+}
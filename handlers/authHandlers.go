@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+const authRealm = `Basic realm="priotasks", charset="UTF-8"`
+
+// AuthMiddleware requires a signed-in session, HTTP basic credentials for clients such as
+// calendar apps or an API token on every route except the login page, the assets and the
+// monitoring endpoints. It does nothing until a user exists.
+func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		enabled, err := s.svc.Users.AuthEnabled(r.Context())
+		if err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token, ok := bearerToken(r); ok {
+			s.serveWithApiToken(w, r, next, token)
+			return
+		}
+
+		user, err := s.authenticateRequest(r)
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(models.ContextWithUser(r.Context(), user)))
+			return
+		}
+		if !errors.Is(err, services.ErrInvalidSession) && !errors.Is(err, services.ErrInvalidCredentials) {
+			internalServerError(w, r, err)
+			return
+		}
+		rejectUnauthenticated(w, r)
+	})
+}
+
+func isPublicPath(path string) bool {
+	switch path {
+	case consts.URL_LOGIN, consts.URL_METRICS, consts.URL_HEALTHZ, consts.URL_READYZ:
+		return true
+	}
+	return strings.HasPrefix(path, "/assets/")
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// serveWithApiToken serves the request on behalf of the owner of the token, within its
+// scope. Tokens cannot be used to manage tokens.
+func (s *Server) serveWithApiToken(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	user, apiToken, err := s.svc.Users.ApiTokenUser(r.Context(), token)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="priotasks", error="invalid_token"`)
+		http.Error(w, "invalid api token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	if !apiToken.Allows(r.Method) || strings.HasPrefix(r.URL.Path, consts.URL_SETTINGS_TOKENS) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="priotasks", error="insufficient_scope"`)
+		http.Error(w, services.ErrApiTokenScope.Error(), http.StatusForbidden)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(models.ContextWithUser(r.Context(), user)))
+}
+
+func (s *Server) authenticateRequest(r *http.Request) (models.User, error) {
+	if username, password, ok := r.BasicAuth(); ok {
+		return s.svc.Users.Authenticate(r.Context(), username, password)
+	}
+	cookie, err := r.Cookie(consts.SESSION_COOKIE_NAME)
+	if err != nil {
+		return models.User{}, services.ErrInvalidSession
+	}
+	return s.svc.Users.SessionUser(r.Context(), cookie.Value)
+}
+
+// rejectUnauthenticated sends pages to the login form and asks other clients for credentials
+func rejectUnauthenticated(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", consts.URL_LOGIN)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		loginUrl := consts.URL_LOGIN + "?" + url.Values{consts.PARAM_LOGIN_NEXT: {r.URL.RequestURI()}}.Encode()
+		http.Redirect(w, r, loginUrl, http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", authRealm)
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
+
+// safeNext keeps the redirect after signing in on this site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return consts.URL_TASKS
+	}
+	return next
+}
+
+func (s *Server) GetLoginHandler(w http.ResponseWriter, r *http.Request) {
+	enabled, err := s.svc.Users.AuthEnabled(r.Context())
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	next := safeNext(r.URL.Query().Get(consts.PARAM_LOGIN_NEXT))
+	if !enabled {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	components.LoginView(next, "").Render(r.Context(), w)
+}
+
+func (s *Server) PostLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	next := safeNext(r.PostForm.Get(consts.PARAM_LOGIN_NEXT))
+	user, err := s.svc.Users.Authenticate(r.Context(), r.PostForm.Get(consts.PARAM_LOGIN_USERNAME), r.PostForm.Get(consts.PARAM_LOGIN_PASSWORD))
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		w.WriteHeader(http.StatusUnauthorized)
+		components.LoginView(next, "Invalid username or password.").Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	token, session, err := s.svc.Users.CreateSession(r.Context(), user.Username)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     consts.SESSION_COOKIE_NAME,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *Server) PostLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(consts.SESSION_COOKIE_NAME); err == nil {
+		if err := s.svc.Users.DeleteSession(r.Context(), cookie.Value); err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     consts.SESSION_COOKIE_NAME,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", consts.URL_LOGIN)
+		return
+	}
+	http.Redirect(w, r, consts.URL_LOGIN, http.StatusSeeOther)
+}
+
+// currentOwner is the username of the signed-in user, the owner of the tasks the
+// request works with; empty while sign-in is disabled
+func currentOwner(r *http.Request) string {
+	u, _ := models.UserFromContext(r.Context())
+	return u.Username
+}
+
+// AdminOnly guards the pages that manage the whole installation, such as the accounts,
+// the webhooks and the database export. Everyone may use them while sign-in is disabled.
+func AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := models.UserFromContext(r.Context()); ok && !u.Admin {
+			http.Error(w, "only admins may do this", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+// newTestServer returns a server of its own services on the store
+func newTestServer(store db.Db) *Server {
+	return NewServer(services.New(store), fstest.MapFS{}, RequestTimeouts{})
+}
+
+func setupAuthDB(t *testing.T, withUser bool) *Server {
+	s := newTestServer(db.NewMemDB())
+	if withUser {
+		if err := s.svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+			t.Fatalf("SetPassword failed: %v", err)
+		}
+	}
+	return s
+}
+
+// protectedHandler answers with the signed-in user
+func protectedHandler(s *Server) http.Handler {
+	return s.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := models.UserFromContext(r.Context())
+		w.Write([]byte("user=" + u.Username))
+	}))
+}
+
+func TestAuthMiddleware_DisabledWithoutUsers(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, false)
+	protected := protectedHandler(s)
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "user=" {
+		t.Errorf("expected the request to pass, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthMiddleware_RejectsUnauthenticated(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	protected := protectedHandler(s)
+
+	page := httptest.NewRequest(http.MethodGet, "/tasks?x=1", nil)
+	page.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, page)
+	want := consts.URL_LOGIN + "?" + url.Values{consts.PARAM_LOGIN_NEXT: {"/tasks?x=1"}}.Encode()
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != want {
+		t.Errorf("expected a redirect to %q, got %d %q", want, w.Code, w.Header().Get("Location"))
+	}
+
+	htmx := httptest.NewRequest(http.MethodPut, "/tasks", nil)
+	htmx.Header.Set("HX-Request", "true")
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, htmx)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("HX-Redirect") != consts.URL_LOGIN {
+		t.Errorf("expected 401 with HX-Redirect, got %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest("PROPFIND", "/caldav/tasks/", nil))
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("expected a basic auth challenge, got %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/assets/css/main.css", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("assets should not require signing in, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_BasicAuth(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	protected := protectedHandler(s)
+
+	r := httptest.NewRequest(http.MethodGet, "/calendar/tasks.ics", nil)
+	r.SetBasicAuth("alice", "correct horse")
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "user=alice" {
+		t.Errorf("expected alice to pass, got %d %q", w.Code, w.Body.String())
+	}
+
+	r.SetBasicAuth("alice", "wrong")
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong password, got %d", w.Code)
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	protected := protectedHandler(s)
+
+	login := func(password, next string) *httptest.ResponseRecorder {
+		form := url.Values{
+			consts.PARAM_LOGIN_USERNAME: {"alice"},
+			consts.PARAM_LOGIN_PASSWORD: {password},
+			consts.PARAM_LOGIN_NEXT:     {next},
+		}
+		r := httptest.NewRequest(http.MethodPost, consts.URL_LOGIN, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.PostLoginHandler(w, r)
+		return w
+	}
+
+	if w := login("wrong", "/tasks"); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("expected a failed login, got %d with cookies %v", w.Code, w.Result().Cookies())
+	}
+
+	w := login("correct horse", "//evil.example.com")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != consts.URL_TASKS {
+		t.Errorf("expected a redirect to the task list, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != consts.SESSION_COOKIE_NAME || !cookies[0].HttpOnly {
+		t.Fatalf("expected an http-only session cookie, got %v", cookies)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Body.String() != "user=alice" {
+		t.Errorf("expected the session to sign alice in, got %d %q", w.Code, w.Body.String())
+	}
+
+	logout := httptest.NewRequest(http.MethodPost, consts.URL_LOGOUT, nil)
+	logout.AddCookie(cookies[0])
+	s.PostLogoutHandler(httptest.NewRecorder(), logout)
+
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the session to end on logout, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_ApiTokens(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	protected := protectedHandler(s)
+	readToken, _, err := s.svc.Users.CreateApiToken(context.Background(), "alice", "reader", models.ApiTokenRead)
+	if err != nil {
+		t.Fatalf("CreateApiToken failed: %v", err)
+	}
+	writeToken, _, err := s.svc.Users.CreateApiToken(context.Background(), "alice", "writer", models.ApiTokenReadWrite)
+	if err != nil {
+		t.Fatalf("CreateApiToken failed: %v", err)
+	}
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		protected.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(http.MethodGet, "/tasks/export/csv", readToken); w.Code != http.StatusOK || w.Body.String() != "user=alice" {
+		t.Errorf("a read-only token should read, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPost, "/tasks/import/csv", readToken); w.Code != http.StatusForbidden {
+		t.Errorf("a read-only token should not write, got %d", w.Code)
+	}
+	if w := serve(http.MethodPost, "/tasks/import/csv", writeToken); w.Code != http.StatusOK {
+		t.Errorf("a read-write token should write, got %d", w.Code)
+	}
+	if w := serve(http.MethodPost, consts.URL_SETTINGS_TOKENS, writeToken); w.Code != http.StatusForbidden {
+		t.Errorf("a token should not manage tokens, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/tasks", "pt_unknown"); w.Code != http.StatusUnauthorized ||
+		!strings.Contains(w.Header().Get("WWW-Authenticate"), "invalid_token") {
+		t.Errorf("an unknown token should be rejected, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	if err := s.svc.Users.CreateUser(context.Background(), "bob", "correct horse", false); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	admin := s.AuthMiddleware(AdminOnly(s.GetUsersHandler))
+
+	serve := func(username string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, consts.URL_ADMIN_USERS, nil)
+		r.SetBasicAuth(username, "correct horse")
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		return w
+	}
+	if w := serve("bob"); w.Code != http.StatusForbidden {
+		t.Errorf("expected bob to be rejected, got %d", w.Code)
+	}
+	if w := serve("alice"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "bob") {
+		t.Errorf("expected alice to see the users, got %d", w.Code)
+	}
+}
+
+func TestUserHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	post := func(h http.HandlerFunc, path string, name string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetPathValue("name", name)
+		r = r.WithContext(models.ContextWithUser(r.Context(), models.User{Username: "alice", Admin: true}))
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	form := url.Values{consts.PARAM_LOGIN_USERNAME: {"bob"}, consts.PARAM_LOGIN_PASSWORD: {"correct horse"}}
+	if w := post(s.PostUsersHandler, consts.URL_ADMIN_USERS, "", form); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected bob to be created, got %d", w.Code)
+	}
+	if w := post(s.PostUsersHandler, consts.URL_ADMIN_USERS, "", form); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a duplicate user to be rejected, got %d", w.Code)
+	}
+	if w := post(s.PostUserRoleHandler, consts.URL_ADMIN_USER_ROLE, "alice", url.Values{}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected the last admin to be kept, got %d", w.Code)
+	}
+
+	if err := s.svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "of bob", Owner: "bob"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	if w := post(s.PostUserDeleteHandler, consts.URL_ADMIN_USER_DELETE, "bob", url.Values{}); w.Code != http.StatusSeeOther {
+		t.Fatalf("expected bob to be deleted, got %d", w.Code)
+	}
+	tasks, err := s.svc.Tasks.FindTasks(context.Background(), models.TasksQuery{Owner: "alice"})
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("expected alice to inherit the task of bob, got %v, %v", tasks, err)
+	}
+	if w := post(s.PostUserPasswordHandler, consts.URL_ADMIN_USER_PASSWORD, "bob", url.Values{consts.PARAM_LOGIN_PASSWORD: {"battery staple"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted user, got %d", w.Code)
+	}
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+// A minimal CalDAV server (RFC 4791): a single principal at URL_CALDAV with one calendar
+// collection at URL_CALDAV_TASKS holding every task as a <task id>.ics VTODO resource.
+
+const (
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+	icsExt      = ".ics"
+)
+
+var (
+	propResourceType       = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: nsDAV, Local: "displayname"}
+	propCurrentPrincipal   = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propGetETag            = xml.Name{Space: nsDAV, Local: "getetag"}
+	propGetContentType     = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propGetLastModified    = xml.Name{Space: nsDAV, Local: "getlastmodified"}
+	propCalendarHomeSet    = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propSupportedComponent = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propGetCTag            = xml.Name{Space: nsCalServer, Local: "getctag"}
+)
+
+// davPrefixes are used when writing responses; other namespaces are declared inline
+var davPrefixes = map[string]string{nsDAV: "d", nsCalDAV: "c", nsCalServer: "cs"}
+
+// davRequest is the part of a PROPFIND or REPORT body the server understands
+type davRequest struct {
+	Root        xml.Name
+	AllProp     bool
+	Props       []xml.Name
+	Hrefs       []string
+	CompFilters []string
+}
+
+// davProps maps the properties of a resource to their inner XML
+type davProps map[xml.Name]string
+
+type davResponse struct {
+	Href  string
+	Props davProps
+}
+
+func CalDAVOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, calendar-access")
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	w.WriteHeader(http.StatusOK)
+}
+
+// WellKnownCalDAVHandler points clients doing service discovery to the principal
+func WellKnownCalDAVHandler(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, consts.URL_CALDAV, http.StatusMovedPermanently)
+}
+
+func (s *Server) PropfindCalDAVPrincipalHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDavRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responses := []davResponse{{Href: consts.URL_CALDAV, Props: principalProps()}}
+	if r.Header.Get("Depth") != "0" {
+		tasks, err := s.svc.Tasks.CalDAVTasks(r.Context(), currentOwner(r))
+		if err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+		responses = append(responses, davResponse{Href: consts.URL_CALDAV_TASKS, Props: collectionProps(tasks)})
+	}
+	writeMultiStatus(w, responses, req)
+}
+
+func (s *Server) PropfindCalDAVCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDavRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.svc.Tasks.CalDAVTasks(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	responses := []davResponse{{Href: consts.URL_CALDAV_TASKS, Props: collectionProps(tasks)}}
+	if r.Header.Get("Depth") != "0" {
+		for _, t := range tasks {
+			responses = append(responses, davResponse{Href: taskHref(t.Id), Props: taskProps(r.Context(), t, req.wants(propCalendarData))})
+		}
+	}
+	writeMultiStatus(w, responses, req)
+}
+
+func (s *Server) PropfindCalDAVTaskHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDavRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	task, ok := s.resolveCalDAVTask(w, r)
+	if !ok {
+		return
+	}
+	writeMultiStatus(w, []davResponse{{Href: taskHref(task.Id), Props: taskProps(r.Context(), task, req.wants(propCalendarData))}}, req)
+}
+
+// ReportCalDAVCollectionHandler answers calendar-query and calendar-multiget reports
+func (s *Server) ReportCalDAVCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseDavRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var responses []davResponse
+	switch req.Root {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		if !req.matchesTodos() {
+			break
+		}
+		tasks, err := s.svc.Tasks.CalDAVTasks(r.Context(), currentOwner(r))
+		if err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+		for _, t := range tasks {
+			responses = append(responses, davResponse{Href: taskHref(t.Id), Props: taskProps(r.Context(), t, true)})
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			taskId, ok := taskIdFromHref(href)
+			if !ok {
+				responses = append(responses, davResponse{Href: href})
+				continue
+			}
+			task, err := s.svc.Tasks.FindCalDAVTask(r.Context(), currentOwner(r), taskId)
+			if errors.Is(err, db.ErrNotFound) {
+				responses = append(responses, davResponse{Href: href})
+				continue
+			} else if err != nil {
+				internalServerError(w, r, err)
+				return
+			}
+			responses = append(responses, davResponse{Href: href, Props: taskProps(r.Context(), task, true)})
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported report: %s", req.Root.Local), http.StatusForbidden)
+		return
+	}
+	writeMultiStatus(w, responses, req)
+}
+
+func (s *Server) GetCalDAVTaskHandler(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.resolveCalDAVTask(w, r)
+	if !ok {
+		return
+	}
+
+	etag := services.CalDAVETag(task)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, err := services.TaskToICal(task)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", etag)
+	w.Write(data)
+}
+
+// PutCalDAVTaskHandler creates or updates the task from the VTODO in the body.
+// If-Match and If-None-Match protect against overwriting changes made elsewhere.
+func (s *Server) PutCalDAVTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskId, ok := taskIdFromResource(r.PathValue("resource"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, consts.MAX_IMPORT_SIZE))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	todo, err := services.ParseVTodo(data, models.LocationFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	task, created, err := s.svc.Tasks.SaveCalDAVTask(r.Context(), currentOwner(r), taskId, todo, davPreconditions(r))
+	if errors.Is(err, services.ErrConflict) || errors.Is(err, services.ErrPreconditionFailed) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "CalDAV: saved task", "task_id", task.Id, "created", created)
+
+	w.Header().Set("ETag", services.CalDAVETag(task))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) DeleteCalDAVTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskId, ok := taskIdFromResource(r.PathValue("resource"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	err := s.svc.Tasks.DeleteCalDAVTask(r.Context(), currentOwner(r), taskId, davPreconditions(r))
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, services.ErrPreconditionFailed) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) resolveCalDAVTask(w http.ResponseWriter, r *http.Request) (models.Task, bool) {
+	taskId, ok := taskIdFromResource(r.PathValue("resource"))
+	if !ok {
+		http.NotFound(w, r)
+		return models.EMPTY_TASK, false
+	}
+	task, err := s.svc.Tasks.FindCalDAVTask(r.Context(), currentOwner(r), taskId)
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return task, false
+	} else if err != nil {
+		internalServerError(w, r, err)
+		return task, false
+	}
+	return task, true
+}
+
+// davPreconditions are the If-Match and If-None-Match headers of the request
+func davPreconditions(r *http.Request) services.DavPreconditions {
+	return services.DavPreconditions{IfMatch: r.Header.Get("If-Match"), IfNoneMatch: r.Header.Get("If-None-Match")}
+}
+
+func taskHref(taskId string) string {
+	return consts.URL_CALDAV_TASKS + url.PathEscape(taskId) + icsExt
+}
+
+func taskIdFromResource(resource string) (string, bool) {
+	taskId, ok := strings.CutSuffix(resource, icsExt)
+	return taskId, ok && taskId != ""
+}
+
+func taskIdFromHref(href string) (string, bool) {
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	resource, ok := strings.CutPrefix(href, consts.URL_CALDAV_TASKS)
+	if !ok || strings.Contains(resource, "/") {
+		return "", false
+	}
+	resource, err := url.PathUnescape(resource)
+	if err != nil {
+		return "", false
+	}
+	return taskIdFromResource(resource)
+}
+
+func principalProps() davProps {
+	home := davHref(consts.URL_CALDAV)
+	return davProps{
+		propResourceType:     "<d:collection/><d:principal/>",
+		propDisplayName:      "priotasks",
+		propCurrentPrincipal: home,
+		propPrincipalURL:     home,
+		propCalendarHomeSet:  home,
+	}
+}
+
+func collectionProps(tasks []models.Task) davProps {
+	ctag := services.CalDAVCTag(tasks)
+	return davProps{
+		propResourceType:       "<d:collection/><c:calendar/>",
+		propDisplayName:        "Tasks",
+		propCurrentPrincipal:   davHref(consts.URL_CALDAV),
+		propSupportedComponent: `<c:comp name="VTODO"/>`,
+		propGetCTag:            xmlText(ctag),
+		propGetETag:            xmlText(ctag),
+	}
+}
+
+func taskProps(ctx context.Context, t models.Task, withData bool) davProps {
+	props := davProps{
+		propResourceType:    "",
+		propGetETag:         xmlText(services.CalDAVETag(t)),
+		propGetContentType:  "text/calendar; charset=utf-8; component=VTODO",
+		propGetLastModified: t.Updated.UTC().Format(http.TimeFormat),
+	}
+	if withData {
+		data, err := services.TaskToICal(t)
+		if err != nil {
+			slog.ErrorContext(ctx, "taskProps: failed to render task", "task_id", t.Id, "error", err)
+		} else {
+			props[propCalendarData] = xmlText(string(data))
+		}
+	}
+	return props
+}
+
+func davHref(href string) string {
+	return "<d:href>" + xmlText(href) + "</d:href>"
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// wants reports whether the request names the property explicitly
+func (req davRequest) wants(name xml.Name) bool {
+	for _, p := range req.Props {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTodos is false when the calendar-query filters a component other than VTODO
+func (req davRequest) matchesTodos() bool {
+	for _, name := range req.CompFilters {
+		if !strings.EqualFold(name, "VCALENDAR") && !strings.EqualFold(name, "VTODO") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDavRequest reads a PROPFIND or REPORT body; an empty body means allprop
+func parseDavRequest(body io.Reader) (davRequest, error) {
+	var req davRequest
+	dec := xml.NewDecoder(body)
+	var stack []xml.Name
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, fmt.Errorf("parseDavRequest: %w", err)
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case len(stack) == 0:
+				req.Root = el.Name
+			case el.Name == xml.Name{Space: nsDAV, Local: "allprop"}:
+				req.AllProp = true
+			case stack[len(stack)-1] == xml.Name{Space: nsDAV, Local: "prop"} && len(stack) == 2:
+				req.Props = append(req.Props, el.Name)
+			case el.Name == xml.Name{Space: nsDAV, Local: "href"}:
+				var href string
+				if err := dec.DecodeElement(&href, &el); err != nil {
+					return req, fmt.Errorf("parseDavRequest: %w", err)
+				}
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(href))
+				continue
+			case el.Name == xml.Name{Space: nsCalDAV, Local: "comp-filter"}:
+				for _, attr := range el.Attr {
+					if attr.Name.Local == "name" {
+						req.CompFilters = append(req.CompFilters, attr.Value)
+					}
+				}
+			}
+			stack = append(stack, el.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(req.Props) == 0 {
+		req.AllProp = true
+	}
+	return req, nil
+}
+
+// writeMultiStatus answers with the requested properties of each resource; requested
+// properties a resource does not have are reported with 404, responses without
+// properties mean the resource does not exist
+func writeMultiStatus(w http.ResponseWriter, responses []davResponse, req davRequest) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCalServer + `">`)
+	for _, resp := range responses {
+		b.WriteString("<d:response>" + davHref(resp.Href))
+		if resp.Props == nil {
+			b.WriteString("<d:status>HTTP/1.1 404 Not Found</d:status></d:response>")
+			continue
+		}
+
+		var found, missing strings.Builder
+		if req.AllProp {
+			for name, value := range resp.Props {
+				if name != propCalendarData {
+					writeDavProp(&found, name, value)
+				}
+			}
+		}
+		for _, name := range req.Props {
+			if value, ok := resp.Props[name]; ok {
+				writeDavProp(&found, name, value)
+			} else {
+				writeDavProp(&missing, name, "")
+			}
+		}
+		if found.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + found.String() + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if missing.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + missing.String() + "</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, b.String())
+}
+
+func writeDavProp(b *strings.Builder, name xml.Name, value string) {
+	tag := "x:" + name.Local
+	open := tag + ` xmlns:x="` + xmlText(name.Space) + `"`
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+		open = tag
+	}
+	if value == "" {
+		b.WriteString("<" + open + "/>")
+	} else {
+		b.WriteString("<" + open + ">" + value + "</" + tag + ">")
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestParseDavRequest(t *testing.T) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <d:href>/caldav/tasks/a.ics</d:href>
+  <d:href>/caldav/tasks/b%20c.ics</d:href>
+</c:calendar-multiget>`
+
+	req, err := parseDavRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parseDavRequest failed: %v", err)
+	}
+	if req.Root != (xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}) {
+		t.Errorf("unexpected root: %v", req.Root)
+	}
+	if req.AllProp || len(req.Props) != 2 || !req.wants(propCalendarData) {
+		t.Errorf("unexpected props: %+v", req)
+	}
+	if len(req.Hrefs) != 2 {
+		t.Fatalf("unexpected hrefs: %v", req.Hrefs)
+	}
+	if id, ok := taskIdFromHref(req.Hrefs[1]); !ok || id != "b c" {
+		t.Errorf("unexpected task id from href: %q", id)
+	}
+
+	req, err = parseDavRequest(strings.NewReader(""))
+	if err != nil || !req.AllProp {
+		t.Errorf("empty body should mean allprop: %+v, %v", req, err)
+	}
+}
+
+func TestCalendarQueryFilters(t *testing.T) {
+	body := `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"/></c:comp-filter></c:filter>
+</c:calendar-query>`
+
+	req, err := parseDavRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parseDavRequest failed: %v", err)
+	}
+	if req.matchesTodos() {
+		t.Error("a VEVENT query must not return tasks")
+	}
+}
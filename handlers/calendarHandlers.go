@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+const icalStartFormat = "2006-01-02T15:04"
+
+// GetCalendarIcsHandler serves a read-only iCalendar feed. The tasks are chosen by the select
+// and tag parameters, or by the prepared-query parameter; events=1 adds time blocks sized
+// from the task cost starting at the start parameter (today 09:00 by default).
+func (s *Server) GetCalendarIcsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var tags []models.TaskTag
+	for _, tag := range params[consts.PARAM_ICAL_TAG] {
+		tags = append(tags, models.TaskTag(tag))
+	}
+	query, err := services.ICalQuery(params.Get(consts.PARAM_ICAL_SELECT), tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Owner = currentOwner(r)
+	if name := params.Get(consts.PARAM_PREPARED_QUERY); name != "" {
+		query = services.PreparedQuery(name, query, currentLocation(r))
+	}
+
+	opts := services.ICalOptions{
+		Name:   "priotasks",
+		Events: params.Get(consts.PARAM_ICAL_EVENTS) == "1",
+	}
+	if opts.Events {
+		opts.EventsStart, err = resolveEventsStart(params.Get(consts.PARAM_ICAL_START), currentLocation(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), query)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline; filename=\"tasks.ics\"")
+	if err := services.ExportTasksToICal(w, tasks, opts); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export tasks to iCalendar", "error", err)
+	}
+}
+
+// resolveEventsStart accepts a date or date and time in the time zone loc; empty means today
+// at 09:00
+func resolveEventsStart(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		now := time.Now().In(loc)
+		return time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, loc), nil
+	}
+	if t, err := time.ParseInLocation(icalStartFormat, value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(consts.DEFAULT_DATE_FORMAT, value, loc); err == nil {
+		return t.Add(9 * time.Hour), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %q", consts.PARAM_ICAL_START, value)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/inaryzen/priotasks/components"
+)
+
+const eventsKeepAlive = 30 * time.Second
+
+// GetEventsHandler streams task and tag change events as Server-Sent Events.
+// The event name is the kind, the data the task id if there is one.
+func (s *Server) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.svc.SubscribeEvents(currentOwner(r))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, e.TaskId); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// GetViewTaskRowHandler renders the row of a task for the current query; the response is
+// empty when the task is no longer part of it, which removes the row
+func (s *Server) GetViewTaskRowHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.findTasksOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+	taskId := r.PathValue("id")
+	for _, t := range tasks {
+		if t.Id == taskId {
+			components.TaskRow(t).Render(r.Context(), w)
+			return
+		}
+	}
+}
+
+func (s *Server) GetViewTaskTableHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawTaskTable(w, r)
+}
@@ -1,27 +1,30 @@
 package handlers
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/inaryzen/priotasks/models"
 	"github.com/inaryzen/priotasks/services"
 )
 
-func GetTasksYamlHandler(w http.ResponseWriter, r *http.Request) {
-	settings, err := findSettingsOrWriteError(w)
+func (s *Server) GetTasksYamlHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
 
-	tasks, err := services.FindTasks(settings.TasksQuery)
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), settings.TasksQuery)
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
 
-	yamlData, err := services.ExportTasksToYAML(tasks)
+	yamlData, err := services.ExportTasksToYAML(models.TasksIn(tasks, currentLocation(r)))
 	if err != nil {
-		log.Printf("Failed to export tasks to YAML: %v", err)
+		slog.ErrorContext(r.Context(), "failed to export tasks to YAML", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -30,6 +33,86 @@ func GetTasksYamlHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Disposition", "attachment; filename=\"tasks.yaml\"")
 	_, err = w.Write(yamlData)
 	if err != nil {
-		log.Printf("Failed to write YAML response: %v", err)
+		slog.ErrorContext(r.Context(), "failed to write YAML response", "error", err)
+	}
+}
+
+func (s *Server) GetTasksCsvHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), settings.TasksQuery)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"tasks.csv\"")
+	if err := services.ExportTasksToCSV(w, models.TasksIn(tasks, currentLocation(r))); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export tasks to CSV", "error", err)
+	}
+}
+
+func (s *Server) GetTasksTodoTxtHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), settings.TasksQuery)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"todo.txt\"")
+	if err := services.ExportTasksToTodoTxt(w, models.TasksIn(tasks, currentLocation(r))); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export tasks to todo.txt", "error", err)
+	}
+}
+
+func (s *Server) GetTasksTaskwarriorHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), settings.TasksQuery)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"taskwarrior.json\"")
+	if err := services.ExportTasksToTaskwarrior(w, tasks); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export tasks to Taskwarrior JSON", "error", err)
+	}
+}
+
+func (s *Server) GetDumpHandler(w http.ResponseWriter, r *http.Request) {
+	encoding := services.DumpEncoding(r.PathValue("encoding"))
+	var contentType string
+	switch encoding {
+	case services.DumpJSON:
+		contentType = "application/x-ndjson"
+	case services.DumpYAML:
+		contentType = "application/x-yaml"
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	fileName := fmt.Sprintf("priotasks_dump_%s.%s", time.Now().Format("20060102_150405"), encoding)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	// the response is streamed, so a failure midway can only be logged
+	if _, err := s.svc.ExportDump(r.Context(), w, encoding); err != nil {
+		slog.ErrorContext(r.Context(), "GetDumpHandler: failed to export dump", "error", err)
 	}
 }
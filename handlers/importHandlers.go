@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	importYamlTitle  = "Import YAML"
+	importYamlAccept = ".yaml,.yml"
+	importCsvTitle   = "Import CSV"
+	importCsvAccept  = ".csv,text/csv"
+	importTodoTitle  = "Import todo.txt"
+	importTodoAccept = ".txt,text/plain"
+	importTwTitle    = "Import Taskwarrior"
+	importTwAccept   = ".json,application/json"
+)
+
+func GetViewImportYamlHandler(w http.ResponseWriter, r *http.Request) {
+	components.ImportModal(importYamlTitle, consts.URL_TASKS_IMPORT_YAML, importYamlAccept, nil, "").Render(r.Context(), w)
+}
+
+func (s *Server) PostTasksYamlImportHandler(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, importYamlTitle, consts.URL_TASKS_IMPORT_YAML, importYamlAccept, s.svc.Tasks.ImportTasksFromYAML)
+}
+
+func GetViewImportCsvHandler(w http.ResponseWriter, r *http.Request) {
+	components.ImportModal(importCsvTitle, consts.URL_TASKS_IMPORT_CSV, importCsvAccept, nil, "").Render(r.Context(), w)
+}
+
+func (s *Server) PostTasksCsvImportHandler(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, importCsvTitle, consts.URL_TASKS_IMPORT_CSV, importCsvAccept, s.svc.Tasks.ImportTasksFromCSV)
+}
+
+func GetViewImportTodoTxtHandler(w http.ResponseWriter, r *http.Request) {
+	components.ImportModal(importTodoTitle, consts.URL_TASKS_IMPORT_TODO_TXT, importTodoAccept, nil, "").Render(r.Context(), w)
+}
+
+func (s *Server) PostTasksTodoTxtImportHandler(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, importTodoTitle, consts.URL_TASKS_IMPORT_TODO_TXT, importTodoAccept, s.svc.Tasks.ImportTasksFromTodoTxt)
+}
+
+func GetViewImportTaskwarriorHandler(w http.ResponseWriter, r *http.Request) {
+	components.ImportModal(importTwTitle, consts.URL_TASKS_IMPORT_TW, importTwAccept, nil, "").Render(r.Context(), w)
+}
+
+func (s *Server) PostTasksTaskwarriorImportHandler(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, importTwTitle, consts.URL_TASKS_IMPORT_TW, importTwAccept, s.svc.Tasks.ImportTasksFromTaskwarrior)
+}
+
+// handleImport reads the uploaded file, passes it to the import function and renders the report
+func handleImport(w http.ResponseWriter, r *http.Request, title, action, accept string, importFn func(context.Context, string, []byte, bool) (models.ImportReport, error)) {
+	renderError := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		components.ImportModal(title, action, accept, nil, msg).Render(r.Context(), w)
+	}
+
+	if err := r.ParseMultipartForm(consts.MAX_IMPORT_SIZE); err != nil {
+		slog.WarnContext(r.Context(), "handleImport: failed to parse form", "error", err)
+		renderError("Failed to read the uploaded file")
+		return
+	}
+	file, _, err := r.FormFile(consts.INPUT_NAME_IMPORT_FILE)
+	if err != nil {
+		slog.WarnContext(r.Context(), "handleImport: no file", "error", err)
+		renderError("Choose a file to import")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, consts.MAX_IMPORT_SIZE))
+	if err != nil {
+		slog.WarnContext(r.Context(), "handleImport: failed to read file", "error", err)
+		renderError("Failed to read the uploaded file")
+		return
+	}
+
+	dryRun := r.FormValue(consts.INPUT_NAME_IMPORT_DRY_RUN) == "on"
+	report, err := importFn(r.Context(), currentOwner(r), data, dryRun)
+	if err != nil {
+		slog.WarnContext(r.Context(), "handleImport: import failed", "error", err)
+		renderError(err.Error())
+		return
+	}
+
+	components.ImportModal(title, action, accept, &report, "").Render(r.Context(), w)
+}
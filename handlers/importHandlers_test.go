@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inaryzen/priotasks/consts"
+)
+
+func TestPostTasksYamlImportHandler_InvalidFile(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, false)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile(consts.INPUT_NAME_IMPORT_FILE, "tasks.yaml")
+	if err != nil {
+		t.Fatalf("CreateFormFile failed: %v", err)
+	}
+	file.Write([]byte("tasks: [unclosed"))
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, consts.URL_TASKS_IMPORT_YAML, &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.PostTasksYamlImportHandler(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "import-error") {
+		t.Errorf("expected the error in the import modal, got %s", w.Body.String())
+	}
+}
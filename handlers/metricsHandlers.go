@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/common"
+)
+
+const databaseCheckTimeout = 2 * time.Second
+
+var (
+	httpRequests = common.NewCounterVec("priotasks_http_requests_total",
+		"Number of HTTP requests by method, route and status.", "method", "route", "status")
+	httpDuration = common.NewHistogramVec("priotasks_http_request_duration_seconds",
+		"Duration of the HTTP requests by method and route.", common.HTTP_BUCKETS, "method", "route")
+)
+
+// MetricsMiddleware counts the requests and their latency by the route of mux serving them.
+// The route is the pattern the path matched, so that ids in paths don't add series.
+func MetricsMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if _, pattern := mux.Handler(r); pattern != "" {
+			// the method is a label of its own
+			_, path, found := strings.Cut(pattern, " ")
+			if !found {
+				path = pattern
+			}
+			route = path
+		}
+		httpRequests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// GetMetricsHandler serves the metrics in the Prometheus text format
+func GetMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := common.WriteMetrics(r.Context(), w); err != nil {
+		slog.WarnContext(r.Context(), "failed to write metrics", "error", err)
+	}
+}
+
+// GetHealthzHandler reports that the server is up and the database can be queried
+func (s *Server) GetHealthzHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeDatabaseCheck(w, r)
+}
+
+// GetReadyzHandler reports whether the server takes requests: it is neither starting nor
+// shutting down and the database can be queried
+func (s *Server) GetReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready\n"))
+		return
+	}
+	s.writeDatabaseCheck(w, r)
+}
+
+func (s *Server) writeDatabaseCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), databaseCheckTimeout)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.svc.CheckDatabase(ctx); err != nil {
+		slog.WarnContext(ctx, "database check failed", "path", r.URL.Path, "error", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("database unavailable\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
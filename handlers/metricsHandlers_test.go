@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inaryzen/priotasks/consts"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	h := MetricsMiddleware(mux, mux)
+	for _, path := range []string{"/things/1", "/things/2", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	GetMetricsHandler(w, httptest.NewRequest(http.MethodGet, consts.URL_METRICS, nil))
+	out := w.Body.String()
+	for _, expected := range []string{
+		`priotasks_http_requests_total{method="GET",route="/things/{id}",status="202"} 2`,
+		`priotasks_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`priotasks_http_request_duration_seconds_count{method="GET",route="/things/{id}"} 2`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in\n%s", expected, out)
+		}
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+
+	get := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		// the monitoring endpoints answer without credentials once sign-in is enabled
+		s.AuthMiddleware(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get(s.GetHealthzHandler, consts.URL_HEALTHZ); w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+		t.Errorf("expected healthz to be ok, got %d %q", w.Code, w.Body.String())
+	}
+	if w := get(s.GetReadyzHandler, consts.URL_READYZ); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readyz to fail before the server is ready, got %d", w.Code)
+	}
+	s.SetReady(true)
+	if w := get(s.GetReadyzHandler, consts.URL_READYZ); w.Code != http.StatusOK {
+		t.Errorf("expected readyz to be ok, got %d %q", w.Code, w.Body.String())
+	}
+	if w := get(GetMetricsHandler, consts.URL_METRICS); w.Code != http.StatusOK {
+		t.Errorf("expected the metrics to be public, got %d", w.Code)
+	}
+}
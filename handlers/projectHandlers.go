@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/services"
+)
+
+func (s *Server) GetProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawProjectsView(w, r, "")
+}
+
+func (s *Server) PostProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err := s.svc.Projects.CreateProject(r.Context(), currentOwner(r), r.PostForm.Get(consts.PARAM_PROJECT_NAME))
+	s.handleProjectChange(w, r, err)
+}
+
+func (s *Server) PostProjectMembersHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.svc.Projects.AddProjectMember(r.Context(), currentOwner(r), r.PathValue("id"), r.PostForm.Get(consts.PARAM_PROJECT_MEMBER))
+	s.handleProjectChange(w, r, err)
+}
+
+// PostProjectMemberDeleteHandler removes a member; members use it to leave the project
+func (s *Server) PostProjectMemberDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.svc.Projects.RemoveProjectMember(r.Context(), currentOwner(r), r.PathValue("id"), r.PathValue("name"))
+	s.handleProjectChange(w, r, err)
+}
+
+func (s *Server) PostProjectDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.svc.Projects.DeleteProject(r.Context(), currentOwner(r), r.PathValue("id"))
+	s.handleProjectChange(w, r, err)
+}
+
+func (s *Server) handleProjectChange(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, services.ErrInvalidProject), errors.Is(err, services.ErrProjectOwnerOnly):
+		w.WriteHeader(http.StatusBadRequest)
+		s.drawProjectsView(w, r, err.Error())
+	case err != nil:
+		internalServerError(w, r, err)
+	default:
+		http.Redirect(w, r, consts.URL_PROJECTS, http.StatusSeeOther)
+	}
+}
+
+func (s *Server) drawProjectsView(w http.ResponseWriter, r *http.Request, errMsg string) {
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	components.ProjectsView(projects, currentOwner(r), errMsg).Render(r.Context(), w)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+// GetTasksMarkdownHandler exports the current query, or the prepared query given in the
+// prepared-query parameter, without changing the user settings
+func (s *Server) GetTasksMarkdownHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+
+	query := settings.TasksQuery
+	if name := r.URL.Query().Get(consts.PARAM_PREPARED_QUERY); name != "" {
+		query = services.PreparedQuery(name, query, currentLocation(r))
+	}
+
+	tasks, err := s.svc.Tasks.FindTasks(r.Context(), query)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"tasks.md\"")
+	if err := services.ExportTasksToMarkdown(w, models.TasksIn(tasks, currentLocation(r))); err != nil {
+		slog.ErrorContext(r.Context(), "failed to export tasks to Markdown", "error", err)
+	}
+}
+
+func (s *Server) GetReportHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := resolveReportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.svc.Tasks.GenerateReport(r.Context(), currentOwner(r), from, to)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	markdownUrl := consts.URL_REPORT_MARKDOWN + "?" + r.URL.RawQuery
+	components.ReportView(report, markdownUrl).Render(r.Context(), w)
+}
+
+func (s *Server) GetReportMarkdownHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := resolveReportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.svc.Tasks.GenerateReport(r.Context(), currentOwner(r), from, to)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("report_%s.md", from.Format(consts.DEFAULT_DATE_FORMAT))
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	if err := services.ReportToMarkdown(w, report); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write the Markdown report", "error", err)
+	}
+}
+
+// resolveReportPeriod reads the inclusive report-from and report-to dates, defaulting to this week,
+// in the time zone of the user. The returned end is exclusive: midnight after the last day.
+func resolveReportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	loc := currentLocation(r)
+	from, to := services.ThisWeek(loc)
+	params := r.URL.Query()
+
+	parse := func(name string) (time.Time, bool, error) {
+		value := params.Get(name)
+		if value == "" {
+			return time.Time{}, false, nil
+		}
+		t, err := time.ParseInLocation(consts.DEFAULT_DATE_FORMAT, value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s: %q", name, value)
+		}
+		return t, true, nil
+	}
+
+	if t, ok, err := parse(consts.PARAM_REPORT_FROM); err != nil {
+		return from, to, err
+	} else if ok {
+		from = t
+		to = t.AddDate(0, 0, 7)
+	}
+	if t, ok, err := parse(consts.PARAM_REPORT_TO); err != nil {
+		return from, to, err
+	} else if ok {
+		to = t.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("%s must not be after %s", consts.PARAM_REPORT_FROM, consts.PARAM_REPORT_TO)
+	}
+	return from, to, nil
+}
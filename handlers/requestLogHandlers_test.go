@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/inaryzen/priotasks/common"
+	"github.com/inaryzen/priotasks/consts"
+)
+
+// captureJsonLogs returns the log records written during the test
+func captureJsonLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var buf bytes.Buffer
+	if err := common.InitLogging(&buf, "debug", "json"); err != nil {
+		t.Fatalf("InitLogging failed: %v", err)
+	}
+	return func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("invalid log line %q: %v", line, err)
+			}
+			records = append(records, rec)
+		}
+		return records
+	}
+}
+
+func TestRequestLogMiddleware(t *testing.T) {
+	records := captureJsonLogs(t)
+	h := RequestLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalServerError(w, r, errors.New("disk full"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tasks/reduce-priority", nil))
+	requestId := w.Header().Get(consts.REQUEST_ID_HEADER)
+	if requestId == "" {
+		t.Fatal("expected a request id in the response")
+	}
+
+	logged := records()
+	if len(logged) != 2 {
+		t.Fatalf("expected the error and the request to be logged, got %v", logged)
+	}
+	failure, request := logged[0], logged[1]
+	if failure["msg"] != "internal server error" || failure["path"] != "/tasks/reduce-priority" ||
+		failure["error"] != "disk full" || failure["request_id"] != requestId {
+		t.Errorf("unexpected error record %v", failure)
+	}
+	if request["msg"] != "request" || request["method"] != http.MethodPost || request["status"] != float64(http.StatusInternalServerError) ||
+		request["request_id"] != requestId || request["latency"] == nil {
+		t.Errorf("unexpected request record %v", request)
+	}
+}
+
+func TestRequestLogMiddleware_IncomingId(t *testing.T) {
+	captureJsonLogs(t)
+	h := RequestLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(common.RequestId(r.Context())))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	r.Header.Set(consts.REQUEST_ID_HEADER, "proxy-42")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.String() != "proxy-42" || w.Header().Get(consts.REQUEST_ID_HEADER) != "proxy-42" {
+		t.Errorf("expected the id of the proxy to be kept, got %q", w.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	r.Header.Set(consts.REQUEST_ID_HEADER, "bad id\nwith newline")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if id := w.Body.String(); id == "" || strings.Contains(id, " ") {
+		t.Errorf("expected a malformed id to be replaced, got %q", id)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	csrfTokenBytes   = 32
+	csrfCookieMaxAge = 365 * 24 * 60 * 60
+
+	// htmx is served by unpkg; the inline handlers (onclick, hx-on) and the trigger
+	// filters of htmx need 'unsafe-inline' and 'unsafe-eval'
+	contentSecurityPolicy = "default-src 'self'; " +
+		"script-src 'self' https://unpkg.com 'unsafe-inline' 'unsafe-eval'; " +
+		"style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data:; " +
+		"connect-src 'self'; " +
+		"object-src 'none'; " +
+		"base-uri 'self'; " +
+		"form-action 'self'; " +
+		"frame-ancestors 'none'"
+)
+
+// SecurityHeadersMiddleware sets the headers that keep the pages from being framed,
+// sniffed or extended with foreign scripts
+func SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "same-origin")
+		h.Set("Cross-Origin-Opener-Policy", "same-origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFMiddleware rejects state-changing requests that do not carry the token of the
+// browser's CSRF cookie, in the X-CSRF-Token header sent by htmx or in a form field.
+// Requests with an API token and CalDAV clients are exempt: browsers never attach a
+// bearer token on their own, and the CalDAV methods cannot be sent cross-site
+// without a CORS preflight.
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := csrfCookieToken(r)
+		if !ok {
+			token = newCSRFToken()
+			http.SetCookie(w, &http.Cookie{
+				Name:     consts.CSRF_COOKIE_NAME,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   csrfCookieMaxAge,
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		if !isSafeMethod(r.Method) && !isCSRFExempt(r) {
+			// a missing cookie means the token cannot have been issued to this browser
+			if !ok || !validCSRFToken(token, submittedCSRFToken(r)) {
+				slog.WarnContext(r.Context(), "CSRFMiddleware: rejected a request without a valid token", "method", r.Method, "path", r.URL.Path)
+				http.Error(w, "invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(models.ContextWithCSRFToken(r.Context(), token)))
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isCSRFExempt(r *http.Request) bool {
+	if _, ok := bearerToken(r); ok {
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, consts.URL_CALDAV) || r.URL.Path == consts.URL_WELL_KNOWN_CALDAV
+}
+
+func csrfCookieToken(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(consts.CSRF_COOKIE_NAME)
+	if err != nil {
+		return "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(decoded) != csrfTokenBytes {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+func submittedCSRFToken(r *http.Request) string {
+	if token := r.Header.Get(consts.CSRF_HEADER_NAME); token != "" {
+		return token
+	}
+	return r.PostFormValue(consts.PARAM_CSRF_TOKEN)
+}
+
+func validCSRFToken(expected, submitted string) bool {
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) == 1
+}
+
+func newCSRFToken() string {
+	b := make([]byte, csrfTokenBytes)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// HTTPSRedirectHandler sends plain HTTP requests to the same URL on the HTTPS port
+func HTTPSRedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		// 308 keeps the method and the body of the other requests
+		code := http.StatusMovedPermanently
+		if !isSafeMethod(r.Method) {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
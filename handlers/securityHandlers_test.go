@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/inaryzen/priotasks/consts"
+)
+
+// csrfProtected counts the requests that got through
+func csrfProtected(calls *int) http.Handler {
+	return SecurityHeadersMiddleware(CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+	})))
+}
+
+// issueCSRFToken returns the cookie a page load hands out to the browser
+func issueCSRFToken(t *testing.T, h http.Handler) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == consts.CSRF_COOKIE_NAME {
+			return c
+		}
+	}
+	t.Fatal("no CSRF cookie issued")
+	return nil
+}
+
+func TestCSRFMiddleware_RejectsForgedRequests(t *testing.T) {
+	var calls int
+	h := csrfProtected(&calls)
+	cookie := issueCSRFToken(t, h)
+	calls = 0
+
+	forged := []*http.Request{
+		httptest.NewRequest(http.MethodDelete, "/tasks/1", nil),
+		httptest.NewRequest(http.MethodPost, "/tasks/reduce-priority", nil),
+		httptest.NewRequest(http.MethodDelete, "/tags/work", nil),
+	}
+	// the cookie alone is what a cross-site request carries
+	withCookie := httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)
+	withCookie.AddCookie(cookie)
+	forged = append(forged, withCookie)
+	// a token of another browser
+	wrongToken := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	wrongToken.AddCookie(cookie)
+	wrongToken.Header.Set(consts.CSRF_HEADER_NAME, newCSRFToken())
+	forged = append(forged, wrongToken)
+	// the right token without the cookie it belongs to
+	noCookie := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	noCookie.Header.Set(consts.CSRF_HEADER_NAME, cookie.Value)
+	forged = append(forged, noCookie)
+
+	for _, r := range forged {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403, got %d", r.Method, r.URL.Path, w.Code)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("expected no forged request to reach the handler, got %d", calls)
+	}
+}
+
+func TestCSRFMiddleware_AcceptsToken(t *testing.T) {
+	var calls int
+	h := csrfProtected(&calls)
+	cookie := issueCSRFToken(t, h)
+	calls = 0
+
+	htmx := httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)
+	htmx.AddCookie(cookie)
+	htmx.Header.Set(consts.CSRF_HEADER_NAME, cookie.Value)
+
+	form := httptest.NewRequest(http.MethodPost, "/projects", strings.NewReader(url.Values{consts.PARAM_CSRF_TOKEN: {cookie.Value}}.Encode()))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	form.AddCookie(cookie)
+
+	apiToken := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	apiToken.Header.Set("Authorization", "Bearer pt_secret")
+
+	caldav := httptest.NewRequest(http.MethodPut, "/caldav/tasks/1.ics", nil)
+
+	for _, r := range []*http.Request{htmx, form, apiToken, caldav} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: expected 200, got %d", r.Method, r.URL.Path, w.Code)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("expected every request to reach the handler, got %d", calls)
+	}
+}
+
+func TestCSRFMiddleware_TokenInPages(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, true)
+	h := CSRFMiddleware(http.HandlerFunc(s.GetLoginHandler))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, consts.URL_LOGIN, nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != consts.CSRF_COOKIE_NAME || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly CSRF cookie, got %v", cookies)
+	}
+	if !strings.Contains(w.Body.String(), `value="`+cookies[0].Value+`"`) {
+		t.Errorf("expected the login form to carry the token")
+	}
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	var calls int
+	w := httptest.NewRecorder()
+	csrfProtected(&calls).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tasks", nil))
+	for header, want := range map[string]string{
+		"X-Frame-Options":        "DENY",
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy":        "same-origin",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s: expected %q, got %q", header, want, got)
+		}
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Errorf("unexpected Content-Security-Policy %q", csp)
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		method, target string
+		port           int
+		code           int
+		location       string
+	}{
+		{http.MethodGet, "http://tablet.lan:8080/tasks?x=1", 8443, http.StatusMovedPermanently, "https://tablet.lan:8443/tasks?x=1"},
+		{http.MethodGet, "http://192.168.1.5/tasks", 443, http.StatusMovedPermanently, "https://192.168.1.5/tasks"},
+		{http.MethodGet, "http://[::1]:8080/", 443, http.StatusMovedPermanently, "https://[::1]/"},
+		{http.MethodPost, "http://tablet.lan:8080/tasks", 8443, http.StatusPermanentRedirect, "https://tablet.lan:8443/tasks"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		HTTPSRedirectHandler(tt.port).ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.code, tt.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
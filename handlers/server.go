@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/services"
+)
+
+// Server serves one priotasks instance: it routes the requests to the handlers working with
+// its services. Servers share no state besides the metrics, so several of them can run in
+// one process.
+type Server struct {
+	svc     *services.Services
+	mux     *http.ServeMux
+	handler http.Handler
+	// ready is set once the server takes requests, and cleared when it starts shutting down
+	ready atomic.Bool
+}
+
+// NewServer wires the routes and the middleware; assets holds the static files under assets/
+func NewServer(svc *services.Services, assets fs.FS, timeouts RequestTimeouts) *Server {
+	s := &Server{svc: svc, mux: http.NewServeMux()}
+	s.routes(assets)
+	s.handler = RequestLogMiddleware(MetricsMiddleware(s.mux,
+		TimeoutMiddleware(timeouts,
+			SecurityHeadersMiddleware(CSRFMiddleware(s.AuthMiddleware(s.TimeZoneMiddleware(s.mux)))))))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *Server) SetReady(r bool) {
+	s.ready.Store(r)
+}
+
+func (s *Server) routes(assets fs.FS) {
+	mux := s.mux
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, consts.URL_TASKS, http.StatusFound) // 302
+	})
+
+	mux.HandleFunc("GET "+consts.URL_LOGIN, s.GetLoginHandler)
+	mux.HandleFunc("POST "+consts.URL_LOGIN, s.PostLoginHandler)
+	mux.HandleFunc("POST "+consts.URL_LOGOUT, s.PostLogoutHandler)
+	mux.HandleFunc("GET "+consts.URL_SETTINGS_TOKENS, s.GetApiTokensHandler)
+	mux.HandleFunc("POST "+consts.URL_SETTINGS_TOKENS, s.PostApiTokensHandler)
+	mux.HandleFunc("POST "+consts.URL_SETTINGS_TOKEN_REVOKE, s.PostApiTokenRevokeHandler)
+	mux.HandleFunc("GET "+consts.URL_SETTINGS_TIME_ZONE, s.GetTimeZoneHandler)
+	mux.HandleFunc("POST "+consts.URL_SETTINGS_TIME_ZONE, s.PostTimeZoneHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS, s.GetTasks)
+	mux.HandleFunc("POST "+consts.URL_TASKS, s.PostTaskHandler)
+	mux.HandleFunc("PUT "+consts.URL_TASKS, s.PutTaskHandler)
+	// mux.HandleFunc("POST /tasks/{id}/toggle-completed", s.PostTaskToggleCompleted)
+	mux.HandleFunc("DELETE "+consts.URL_TASKS_ID, s.DeleteTasksId)
+	mux.HandleFunc("POST /tasks/{id}/clone", s.PostTaskCloneHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS_EXPORT_YAML, s.GetTasksYamlHandler)
+	mux.HandleFunc("POST "+consts.URL_TASKS_IMPORT_YAML, s.PostTasksYamlImportHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS_EXPORT_CSV, s.GetTasksCsvHandler)
+	mux.HandleFunc("POST "+consts.URL_TASKS_IMPORT_CSV, s.PostTasksCsvImportHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS_EXPORT_TODO_TXT, s.GetTasksTodoTxtHandler)
+	mux.HandleFunc("POST "+consts.URL_TASKS_IMPORT_TODO_TXT, s.PostTasksTodoTxtImportHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS_EXPORT_TW, s.GetTasksTaskwarriorHandler)
+	mux.HandleFunc("POST "+consts.URL_TASKS_IMPORT_TW, s.PostTasksTaskwarriorImportHandler)
+	mux.HandleFunc("GET "+consts.URL_TASKS_EXPORT_MARKDOWN, s.GetTasksMarkdownHandler)
+	mux.HandleFunc("GET "+consts.URL_EXPORT_DUMP, AdminOnly(s.GetDumpHandler))
+	mux.HandleFunc("GET "+consts.URL_REPORT, s.GetReportHandler)
+	mux.HandleFunc("GET "+consts.URL_REPORT_MARKDOWN, s.GetReportMarkdownHandler)
+	mux.HandleFunc("GET "+consts.URL_CALENDAR_ICS, s.GetCalendarIcsHandler)
+	mux.HandleFunc("GET "+consts.URL_EVENTS, s.GetEventsHandler)
+	mux.HandleFunc("GET "+consts.URL_WEBHOOKS, AdminOnly(s.GetWebhooksHandler))
+	mux.HandleFunc("POST "+consts.URL_WEBHOOKS, AdminOnly(s.PostWebhooksHandler))
+	mux.HandleFunc("POST "+consts.URL_WEBHOOKS_DELETE, AdminOnly(s.PostWebhookDeleteHandler))
+	mux.HandleFunc("GET "+consts.URL_ADMIN_USERS, AdminOnly(s.GetUsersHandler))
+	mux.HandleFunc("POST "+consts.URL_ADMIN_USERS, AdminOnly(s.PostUsersHandler))
+	mux.HandleFunc("POST "+consts.URL_ADMIN_USER_PASSWORD, AdminOnly(s.PostUserPasswordHandler))
+	mux.HandleFunc("POST "+consts.URL_ADMIN_USER_ROLE, AdminOnly(s.PostUserRoleHandler))
+	mux.HandleFunc("POST "+consts.URL_ADMIN_USER_DELETE, AdminOnly(s.PostUserDeleteHandler))
+	mux.HandleFunc("GET "+consts.URL_PROJECTS, s.GetProjectsHandler)
+	mux.HandleFunc("POST "+consts.URL_PROJECTS, s.PostProjectsHandler)
+	mux.HandleFunc("POST "+consts.URL_PROJECT_MEMBERS, s.PostProjectMembersHandler)
+	mux.HandleFunc("POST "+consts.URL_PROJECT_MEMBER_DELETE, s.PostProjectMemberDeleteHandler)
+	mux.HandleFunc("POST "+consts.URL_PROJECT_DELETE, s.PostProjectDeleteHandler)
+	mux.HandleFunc(consts.URL_WELL_KNOWN_CALDAV, WellKnownCalDAVHandler)
+	mux.HandleFunc("OPTIONS "+consts.URL_CALDAV, CalDAVOptionsHandler)
+	mux.HandleFunc("PROPFIND "+consts.URL_CALDAV+"{$}", s.PropfindCalDAVPrincipalHandler)
+	mux.HandleFunc("PROPFIND "+consts.URL_CALDAV_TASKS+"{$}", s.PropfindCalDAVCollectionHandler)
+	mux.HandleFunc("REPORT "+consts.URL_CALDAV_TASKS+"{$}", s.ReportCalDAVCollectionHandler)
+	mux.HandleFunc("PROPFIND "+consts.URL_CALDAV_TASKS+"{resource}", s.PropfindCalDAVTaskHandler)
+	mux.HandleFunc("GET "+consts.URL_CALDAV_TASKS+"{resource}", s.GetCalDAVTaskHandler)
+	mux.HandleFunc("PUT "+consts.URL_CALDAV_TASKS+"{resource}", s.PutCalDAVTaskHandler)
+	mux.HandleFunc("DELETE "+consts.URL_CALDAV_TASKS+"{resource}", s.DeleteCalDAVTaskHandler)
+	mux.HandleFunc("POST /filter/{name}", s.PostFilterName)
+	mux.HandleFunc("DELETE /filter/tag/{name}", s.DeleteTagName)
+	mux.HandleFunc("POST /prepared-query/{name}", s.PostPreparedQuery)
+	mux.HandleFunc("POST "+consts.URL_TOGGLE_SORT_TABLE, s.PostToggleSortTable)
+	mux.HandleFunc("GET /view/task/{id}", s.GetViewTaskByIdHandler)
+	mux.HandleFunc("GET /view/new-task", s.GetViewEmptyTask)
+	mux.HandleFunc("GET "+consts.URL_VIEW_TASK_ASSIGNEES, s.GetViewTaskAssigneesHandler)
+	mux.HandleFunc("GET /view/task-row/{id}", s.GetViewTaskRowHandler)
+	mux.HandleFunc("GET /view/tasks-table", s.GetViewTaskTableHandler)
+	mux.HandleFunc("GET /view/import/yaml", GetViewImportYamlHandler)
+	mux.HandleFunc("GET /view/import/csv", GetViewImportCsvHandler)
+	mux.HandleFunc("GET /view/import/todotxt", GetViewImportTodoTxtHandler)
+	mux.HandleFunc("GET /view/import/taskwarrior", GetViewImportTaskwarriorHandler)
+	mux.HandleFunc("POST /tags", s.PostTagsHandler)
+	mux.HandleFunc("DELETE /tags/{name}", s.DeleteTagHandler)
+	mux.HandleFunc("POST /tasks/reduce-priority", s.PostReducePriorityHandler)
+	mux.HandleFunc("GET "+consts.URL_METRICS, GetMetricsHandler)
+	mux.HandleFunc("GET "+consts.URL_HEALTHZ, s.GetHealthzHandler)
+	mux.HandleFunc("GET "+consts.URL_READYZ, s.GetReadyzHandler)
+	mux.Handle("/assets/", http.FileServer(http.FS(assets)))
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestServer_InstancesAreIsolated(t *testing.T) {
+	t.Parallel()
+	a := setupAuthDB(t, true)
+	b := setupAuthDB(t, false)
+	if err := b.svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "Only in b"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+
+	get := func(s *Server, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	if w := get(a, consts.URL_TASKS); w.Code != http.StatusSeeOther {
+		t.Errorf("expected a to require signing in, got %d", w.Code)
+	}
+	if w := get(b, consts.URL_TASKS); w.Code != http.StatusOK {
+		t.Errorf("expected b to serve the tasks without users, got %d", w.Code)
+	}
+	tasks, err := a.svc.Tasks.FindTasks(context.Background(), models.TasksQuery{})
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("expected a to have no tasks, got %v, %v", tasks, err)
+	}
+
+	b.SetReady(true)
+	if w := get(a, consts.URL_READYZ); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a not to be ready, got %d", w.Code)
+	}
+	if w := get(b, consts.URL_READYZ); w.Code != http.StatusOK {
+		t.Errorf("expected b to be ready, got %d", w.Code)
+	}
+}
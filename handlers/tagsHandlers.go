@@ -6,13 +6,12 @@ import (
 	"github.com/inaryzen/priotasks/components"
 	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/models"
-	"github.com/inaryzen/priotasks/services"
 )
 
-func PostTagsHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PostTagsHandler(w http.ResponseWriter, r *http.Request) {
 	formValue := r.FormValue(consts.INPUT_NAME_NEW_TAG)
 	newTag := models.TaskTag(formValue)
-	err := services.SaveTag(newTag)
+	err := s.svc.Tags.SaveTag(r.Context(), currentOwner(r), newTag)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 	}
@@ -20,16 +19,16 @@ func PostTagsHandler(w http.ResponseWriter, r *http.Request) {
 	tagComponent.Render(r.Context(), w)
 }
 
-func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
 	tagName := r.PathValue("name")
-	err := services.DeleteTag(models.TaskTag(tagName))
+	err := s.svc.Tags.DeleteTag(r.Context(), currentOwner(r), models.TaskTag(tagName))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	// Re-render the tags list
-	allTags, err := services.Tags()
+	allTags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
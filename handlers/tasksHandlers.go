@@ -2,7 +2,8 @@ package handlers
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -14,53 +15,53 @@ import (
 	"github.com/inaryzen/priotasks/services"
 )
 
-func resolveTaskOrNotFound(w http.ResponseWriter, r *http.Request) (models.Task, error) {
+func (s *Server) resolveTaskOrNotFound(w http.ResponseWriter, r *http.Request) (models.Task, error) {
 	idString := r.PathValue("id")
-	card, err := db.DB().FindTask(idString)
+	card, err := s.svc.Tasks.FindTask(r.Context(), currentOwner(r), idString)
 	if errors.Is(err, db.ErrNotFound) {
 		w.WriteHeader(http.StatusNotFound)
-		log.Println(err)
+		slog.DebugContext(r.Context(), "task not found", "task_id", idString)
 	} else if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println(err)
+		internalServerError(w, r, err)
 	}
 	return card, err
 }
 
-func DeleteTasksId(w http.ResponseWriter, r *http.Request) {
-	card, err := resolveTaskOrNotFound(w, r)
+func (s *Server) DeleteTasksId(w http.ResponseWriter, r *http.Request) {
+	card, err := s.resolveTaskOrNotFound(w, r)
 	if err != nil {
 		return
 	}
-	err = services.DeleteTask(card.Id)
+	err = s.svc.Tasks.DeleteTask(r.Context(), currentOwner(r), card.Id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println(err)
+		internalServerError(w, r, err)
 		return
 	}
-	drawTaskTable(w, r)
+	s.drawTaskTable(w, r)
 }
 
-func PostTaskCloneHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PostTaskCloneHandler(w http.ResponseWriter, r *http.Request) {
 	pfx := "PostTaskCloneHandler:"
-	log.Printf("%s Starting task cloning", pfx)
 
-	task, err := resolveTaskOrNotFound(w, r)
+	task, err := s.resolveTaskOrNotFound(w, r)
 	if err != nil {
 		return
 	}
 
-	clonedTask, err := services.CloneTask(task.Id)
+	clonedTask, err := s.svc.Tasks.CloneTask(r.Context(), currentOwner(r), task.Id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Printf("%s failed to clone task: %v", pfx, err)
+		internalServerError(w, r, fmt.Errorf("%s failed to clone task: %w", pfx, err))
 		return
 	}
 
-	allTags, err := services.Tags()
+	allTags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Printf("%s failed to get all tags: %v", pfx, err)
+		internalServerError(w, r, fmt.Errorf("%s failed to get all tags: %w", pfx, err))
+		return
+	}
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, fmt.Errorf("%s failed to get projects: %w", pfx, err))
 		return
 	}
 
@@ -71,35 +72,46 @@ func PostTaskCloneHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// ai: Render the edit modal for the cloned task
-	cardsView := components.TaskModal(clonedTask, taskTagsMap, allTags)
+	cardsView := components.TaskModal(clonedTask, taskTagsMap, allTags, projects)
 	cardsView.Render(r.Context(), w)
 }
 
-func GetViewEmptyTask(w http.ResponseWriter, r *http.Request) {
-	allTags, err := services.Tags()
+func (s *Server) GetViewEmptyTask(w http.ResponseWriter, r *http.Request) {
+	allTags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
 
-	cardsView := components.TaskModal(models.EMPTY_TASK, nil, allTags)
+	cardsView := components.TaskModal(models.EMPTY_TASK, nil, allTags, projects)
 	cardsView.Render(r.Context(), w)
 }
 
-func GetViewTaskByIdHandler(w http.ResponseWriter, r *http.Request) {
-	task, err := resolveTaskOrNotFound(w, r)
+func (s *Server) GetViewTaskByIdHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := s.resolveTaskOrNotFound(w, r)
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
-	allTags, err := services.Tags()
+	// the tags of a shared task belong to its owner
+	allTags, err := s.svc.Tags.Tags(r.Context(), task.Owner)
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
-	taskTags, err := services.TaskTags(task.Id)
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
+		return
+	}
+	taskTags, err := s.svc.Tags.TaskTags(r.Context(), task.Id)
+	if err != nil {
+		internalServerError(w, r, err)
 		return
 	}
 
@@ -108,56 +120,89 @@ func GetViewTaskByIdHandler(w http.ResponseWriter, r *http.Request) {
 		taskTagsMap[tag] = true
 	}
 
-	cardsView := components.TaskModal(task, taskTagsMap, allTags)
+	cardsView := components.TaskModal(task, taskTagsMap, allTags, projects)
 	cardsView.Render(r.Context(), w)
 }
 
-func PutTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PutTaskHandler(w http.ResponseWriter, r *http.Request) {
 	task, tags := resolveTaskFromForm(r)
-	err := services.UpdateTask(task, tags)
+	err := s.svc.Tasks.UpdateTask(r.Context(), task, tags)
+	var conflict *services.ConflictError
+	if errors.As(err, &conflict) {
+		slog.InfoContext(r.Context(), "PutTaskHandler: rejected a stale update", "task_id", task.Id)
+		// htmx only swaps successful responses, so the conflict view is sent with 200
+		// and redirected from the task table to the modal
+		w.Header().Set("HX-Retarget", "#modal-card")
+		w.Header().Set("HX-Reswap", "outerHTML")
+		projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
+		if err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+		components.TaskConflictModal(task, tags, conflict.Current, projects).Render(r.Context(), w)
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidAssignment) || errors.Is(err, services.ErrMissingVersion) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
-	drawTaskTable(w, r)
+	s.drawTaskTable(w, r)
 }
 
-func PostTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PostTaskHandler(w http.ResponseWriter, r *http.Request) {
 	task, tags := resolveTaskFromForm(r)
-	err := services.SaveNewTask(task, tags)
+	err := s.svc.Tasks.SaveNewTask(r.Context(), task, tags)
+	if errors.Is(err, services.ErrInvalidAssignment) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
-	drawTaskTable(w, r)
+	s.drawTaskTable(w, r)
 }
 
 func resolveTaskFromForm(r *http.Request) (models.Task, []models.TaskTag) {
 	formPriority := r.FormValue("modal-task-priority")
 	prio, err := models.StrToTaskPriority(formPriority)
 	if err != nil {
-		log.Printf("failed to parse Priority: %v: %v", formPriority, err)
+		slog.WarnContext(r.Context(), "failed to parse the priority", "value", formPriority, "error", err)
 	}
 
 	formImpact := r.FormValue("modal-task-impact")
 	impact, err := models.StrToImpact(formImpact)
 	if err != nil {
-		log.Printf("failed to parse Impact: %v: %v", formImpact, err)
+		slog.WarnContext(r.Context(), "failed to parse the impact", "value", formImpact, "error", err)
 	}
 
 	formCost := r.FormValue(consts.MODAL_TASK_COST_NAME)
 	cost, err := models.StrToEnum[models.TaskCost](formCost)
 	if err != nil {
-		log.Printf("failed to parse Cost: %v: %v", formCost, err)
+		slog.WarnContext(r.Context(), "failed to parse the cost", "value", formCost, "error", err)
 	}
 
 	formFun := r.FormValue("modal-task-fun")
 	fun, err := models.StrToEnum[models.TaskFun](formFun)
 	if err != nil {
-		log.Printf("failed to parse Fun: %v: %v", formFun, err)
+		slog.WarnContext(r.Context(), "failed to parse the fun", "value", formFun, "error", err)
 	}
 
 	// Parse checkbox values - they will be "on" if checked, or empty if unchecked
 	wipValue := r.FormValue("task-wip") == "on"
 	plannedValue := r.FormValue("task-planned") == "on"
+	formVersion := r.FormValue(consts.MODAL_TASK_VERSION_NAME)
+	version, err := models.ParseTaskVersion(formVersion)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to parse the version", "value", formVersion, "error", err)
+	}
+
 	var completed time.Time
 	if r.FormValue("task-completed") == "on" {
 		completed = time.Now()
@@ -177,6 +222,7 @@ func resolveTaskFromForm(r *http.Request) (models.Task, []models.TaskTag) {
 		Id:        r.FormValue("card-id"),
 		Content:   r.FormValue("card-text"),
 		Title:     r.FormValue("card-title"),
+		Updated:   version,
 		Priority:  prio,
 		Wip:       wipValue,
 		Planned:   plannedValue,
@@ -184,15 +230,49 @@ func resolveTaskFromForm(r *http.Request) (models.Task, []models.TaskTag) {
 		Completed: completed,
 		Cost:      cost,
 		Fun:       fun,
+		Owner:     currentOwner(r),
+		Project:   r.FormValue(consts.MODAL_TASK_PROJECT_NAME),
+		Assignee:  r.FormValue(consts.MODAL_TASK_ASSIGNEE_NAME),
 	}, taskTags
 }
 
-func drawTaskTable(w http.ResponseWriter, r *http.Request) {
-	settings, err := findSettingsOrWriteError(w)
+// GetViewTaskAssigneesHandler renders the assignee select for the project chosen in the task modal
+func (s *Server) GetViewTaskAssigneesHandler(w http.ResponseWriter, r *http.Request) {
+	projectId := r.FormValue(consts.MODAL_TASK_PROJECT_NAME)
+	if projectId == "" {
+		// a private task can only be assigned to its owner
+		owner := currentOwner(r)
+		if taskId := r.FormValue("card-id"); taskId != "" {
+			task, err := s.svc.Tasks.FindTask(r.Context(), owner, taskId)
+			if errors.Is(err, db.ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			} else if err != nil {
+				internalServerError(w, r, err)
+				return
+			}
+			owner = task.Owner
+		}
+		components.TaskAssigneeSelect("", []string{owner}).Render(r.Context(), w)
+		return
+	}
+	p, err := s.svc.Projects.FindProject(r.Context(), currentOwner(r), projectId)
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	components.TaskAssigneeSelect("", p.Members).Render(r.Context(), w)
+}
+
+func (s *Server) drawTaskTable(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
-	cards, err := findTasksOrWriteError(w)
+	cards, err := s.findTasksOrWriteError(w, r)
 	if err != nil {
 		return
 	}
@@ -200,85 +280,93 @@ func drawTaskTable(w http.ResponseWriter, r *http.Request) {
 	cardsView.Render(r.Context(), w)
 }
 
-func findTasksOrWriteError(w http.ResponseWriter) (cards []models.Task, err error) {
-	settings, err := findSettingsOrWriteError(w)
+func (s *Server) findTasksOrWriteError(w http.ResponseWriter, r *http.Request) (cards []models.Task, err error) {
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
-	cards, err = services.FindTasks(settings.TasksQuery)
+	cards, err = s.svc.Tasks.FindTasks(r.Context(), settings.TasksQuery)
 
 	if err != nil {
-		log.Printf("%s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		internalServerError(w, r, err)
 	}
 	return
 }
 
-func findSettingsOrWriteError(w http.ResponseWriter) (models.Settings, error) {
-	settings, err := services.FindUserSettings()
+func (s *Server) findSettingsOrWriteError(w http.ResponseWriter, r *http.Request) (models.Settings, error) {
+	settings, err := s.svc.Settings.FindUserSettings(r.Context(), currentOwner(r))
 	if err != nil {
-		log.Printf("%s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		internalServerError(w, r, err)
 	}
 	return settings, err
 }
 
-func GetTasks(w http.ResponseWriter, r *http.Request) {
-	drawTaskView(w, r)
+func (s *Server) GetTasks(w http.ResponseWriter, r *http.Request) {
+	s.drawTaskView(w, r)
 }
 
-func drawTaskViewBody(w http.ResponseWriter, r *http.Request) {
-	cards, err := findTasksOrWriteError(w)
+func (s *Server) drawTaskViewBody(w http.ResponseWriter, r *http.Request) {
+	cards, err := s.findTasksOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
-	settings, err := findSettingsOrWriteError(w)
+	tags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
 	if err != nil {
+		internalServerError(w, r, err)
 		return
 	}
-	tags, err := services.Tags()
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
 
 	// Calculate total time
 	totalTimeFormatted := models.FormatTotalTime(models.CalculateTotalTime(cards))
 
-	body := components.TasksViewBody(cards, settings, tags, totalTimeFormatted)
+	body := components.TasksViewBody(cards, settings, tags, projects, totalTimeFormatted)
 	body.Render(r.Context(), w)
 }
 
-func drawTaskView(w http.ResponseWriter, r *http.Request) {
-	tasks, err := findTasksOrWriteError(w)
+func (s *Server) drawTaskView(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.findTasksOrWriteError(w, r)
 	if err != nil {
 		return
 	}
-	settings, err := findSettingsOrWriteError(w)
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
-	tags, err := services.Tags()
+	tags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
+		return
+	}
+	projects, err := s.svc.Projects.Projects(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, err)
 		return
 	}
 
 	// Calculate total time
 	totalTimeFormatted := models.FormatTotalTime(models.CalculateTotalTime(tasks))
 
-	cardsView := components.TasksView(tasks, settings, tags, totalTimeFormatted)
+	cardsView := components.TasksView(tasks, settings, tags, projects, totalTimeFormatted)
 	cardsView.Render(r.Context(), w)
 }
 
-func PostToggleSortTable(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PostToggleSortTable(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		http.Error(w, "Unable to parse form", http.StatusBadRequest)
 		return
 	}
 
-	settings, err := findSettingsOrWriteError(w)
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
@@ -289,27 +377,25 @@ func PostToggleSortTable(w http.ResponseWriter, r *http.Request) {
 	param = r.Form.Get(consts.SORT_DIRECTION_NAME)
 	sortDirection := models.DirectionFromString(param)
 
-	err = services.ToggleSorting(settings, sortColumn, sortDirection)
+	err = s.svc.Settings.ToggleSorting(r.Context(), settings, sortColumn, sortDirection)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println(err)
+		internalServerError(w, r, err)
 	}
 
-	drawTaskTable(w, r)
+	s.drawTaskTable(w, r)
 }
 
-func PostReducePriorityHandler(w http.ResponseWriter, r *http.Request) {
-	settings, err := findSettingsOrWriteError(w)
+func (s *Server) PostReducePriorityHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.findSettingsOrWriteError(w, r)
 	if err != nil {
 		return
 	}
 
-	err = services.ReducePriorityForVisibleTasks(settings.TasksQuery)
+	err = s.svc.Tasks.ReducePriorityForVisibleTasks(r.Context(), settings.TasksQuery)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Println(err)
+		internalServerError(w, r, err)
 		return
 	}
 
-	drawTaskViewBody(w, r)
+	s.drawTaskViewBody(w, r)
 }
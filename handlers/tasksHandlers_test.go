@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+type TaskMockDB struct {
+	MockDB
+	task models.Task
+}
+
+func (m *TaskMockDB) FindTask(ctx context.Context, taskId string) (models.Task, error) {
+	if taskId != m.task.Id {
+		return models.EMPTY_TASK, db.ErrNotFound
+	}
+	return m.task, nil
+}
+
+func (m *TaskMockDB) SaveTask(ctx context.Context, task models.Task) error {
+	m.task = task
+	return nil
+}
+
+func TestPutTaskHandler_Conflict(t *testing.T) {
+	t.Parallel()
+	stored := models.Task{Id: "t1", Title: "Changed elsewhere", Updated: time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)}
+	mockDB := &TaskMockDB{task: stored}
+	s := newTestServer(mockDB)
+
+	stale := models.Task{Updated: stored.Updated.Add(-time.Minute)}
+	form := url.Values{
+		"card-id":                      {"t1"},
+		"card-title":                   {"Mine"},
+		consts.MODAL_TASK_VERSION_NAME: {stale.Version()},
+	}
+	req := httptest.NewRequest(http.MethodPut, consts.URL_TASKS, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	s.PutTaskHandler(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("HX-Retarget") != "#modal-card" {
+		t.Errorf("expected the conflict view retargeted to the modal, got %d %v", rr.Code, rr.Header())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Changed elsewhere") || !strings.Contains(body, "Mine") {
+		t.Errorf("conflict view should show both versions:\n%s", body)
+	}
+	if !strings.Contains(body, `value="`+stored.Version()+`"`) {
+		t.Errorf("conflict view should resubmit against the current version:\n%s", body)
+	}
+	if mockDB.task.Title != "Changed elsewhere" {
+		t.Errorf("stale write was applied: %+v", mockDB.task)
+	}
+}
+
+func TestPutTaskHandler_MissingVersion(t *testing.T) {
+	t.Parallel()
+	mockDB := &TaskMockDB{task: models.Task{Id: "t1", Title: "Stored", Updated: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)}}
+	s := newTestServer(mockDB)
+
+	form := url.Values{"card-id": {"t1"}, "card-title": {"Blind"}}
+	req := httptest.NewRequest(http.MethodPut, consts.URL_TASKS, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	s.PutTaskHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected an update without a version to be rejected, got %d", rr.Code)
+	}
+	if mockDB.task.Title != "Stored" {
+		t.Errorf("update without a version was applied: %+v", mockDB.task)
+	}
+}
+
+func TestTaskHandlers_OtherUsersTasksNotFound(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(&TaskMockDB{task: models.Task{Id: "t1", Title: "Of alice", Owner: "alice"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/view/task/t1", nil)
+	req.SetPathValue("id", "t1")
+	req = req.WithContext(models.ContextWithUser(req.Context(), models.User{Username: "bob"}))
+	rr := httptest.NewRecorder()
+	s.GetViewTaskByIdHandler(rr, req)
+	if rr.Code != http.StatusNotFound || strings.Contains(rr.Body.String(), "Of alice") {
+		t.Errorf("expected bob not to see the task of alice, got %d", rr.Code)
+	}
+
+	form := url.Values{"card-id": {"t1"}, "card-title": {"Taken"}}
+	req = httptest.NewRequest(http.MethodPut, consts.URL_TASKS, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(models.ContextWithUser(req.Context(), models.User{Username: "bob"}))
+	rr = httptest.NewRecorder()
+	s.PutTaskHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected bob not to update the task of alice, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+// TimeZoneMiddleware puts the time zone of the user into the request context: the pages show
+// the times in it and the dates of the forms are read in it
+func (s *Server) TimeZoneMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		loc, err := s.svc.Settings.Location(r.Context(), currentOwner(r))
+		if err != nil {
+			internalServerError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(models.ContextWithLocation(r.Context(), loc)))
+	})
+}
+
+// currentLocation is the time zone of the user of the request
+func currentLocation(r *http.Request) *time.Location {
+	return models.LocationFromContext(r.Context())
+}
+
+func (s *Server) GetTimeZoneHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawTimeZoneView(w, r, "")
+}
+
+func (s *Server) PostTimeZoneHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.PostForm.Get(consts.PARAM_TIME_ZONE))
+	err := s.svc.Settings.SetTimeZone(r.Context(), currentOwner(r), name)
+	if errors.Is(err, services.ErrInvalidTimeZone) {
+		w.WriteHeader(http.StatusBadRequest)
+		s.drawTimeZoneView(w, r, err.Error())
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, consts.URL_SETTINGS_TIME_ZONE, http.StatusSeeOther)
+}
+
+func (s *Server) drawTimeZoneView(w http.ResponseWriter, r *http.Request, errMsg string) {
+	settings, err := s.findSettingsOrWriteError(w, r)
+	if err != nil {
+		return
+	}
+	components.TimeZoneView(settings.TimeZone, s.svc.Settings.DefaultLocation().String(), errMsg).Render(r.Context(), w)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+)
+
+// RequestTimeouts bound the time a request may spend, e.g. on database queries, by its type;
+// a zero timeout leaves the requests of its type without deadline
+type RequestTimeouts struct {
+	// Read is for the pages, views and other requests that don't change anything
+	Read time.Duration
+	// Write is for the requests changing tasks, tags, settings and users
+	Write time.Duration
+	// Bulk is for the imports, exports, dumps and reports that go through all tasks
+	Bulk time.Duration
+}
+
+// bulkPaths are the prefixes of the paths served with RequestTimeouts.Bulk
+var bulkPaths = []string{
+	"/tasks/export/",
+	"/tasks/import/",
+	"/export/dump/",
+	consts.URL_REPORT,
+	consts.URL_CALENDAR_ICS,
+}
+
+// TimeoutMiddleware gives the context of every request the deadline of its type. The queries
+// of a request are cancelled once it runs out, as well as when the client goes away. The event
+// stream stays open until the client or the server closes it.
+func TimeoutMiddleware(timeouts RequestTimeouts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := timeouts.forRequest(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (t RequestTimeouts) forRequest(r *http.Request) time.Duration {
+	if r.URL.Path == consts.URL_EVENTS {
+		return 0
+	}
+	for _, prefix := range bulkPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return t.Bulk
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "REPORT":
+		return t.Read
+	}
+	return t.Write
+}
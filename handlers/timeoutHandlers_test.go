@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	timeouts := RequestTimeouts{Read: time.Second, Write: 2 * time.Second, Bulk: time.Minute}
+	tests := []struct {
+		method, path string
+		expected     time.Duration
+	}{
+		{http.MethodGet, "/tasks", time.Second},
+		{"PROPFIND", "/caldav/tasks/", time.Second},
+		{http.MethodPost, "/tasks", 2 * time.Second},
+		{http.MethodDelete, "/tasks/1", 2 * time.Second},
+		{http.MethodGet, "/tasks/export/csv", time.Minute},
+		{http.MethodPost, "/tasks/import/yaml", time.Minute},
+		{http.MethodGet, "/export/dump/json", time.Minute},
+		{http.MethodGet, "/report/markdown", time.Minute},
+		{http.MethodGet, "/events", 0},
+	}
+	for _, tt := range tests {
+		var deadline time.Time
+		var hasDeadline bool
+		h := TimeoutMiddleware(timeouts, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, hasDeadline = r.Context().Deadline()
+		}))
+		start := time.Now()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+		if tt.expected == 0 {
+			if hasDeadline {
+				t.Errorf("%s %s: expected no deadline", tt.method, tt.path)
+			}
+			continue
+		}
+		if left := deadline.Sub(start); !hasDeadline || left < tt.expected || left > tt.expected+time.Second/2 {
+			t.Errorf("%s %s: expected a deadline in %v, got %v", tt.method, tt.path, tt.expected, left)
+		}
+	}
+
+	h := TimeoutMiddleware(RequestTimeouts{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Errorf("expected no deadline with the timeouts disabled")
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tasks", nil))
+}
+
+func TestInternalServerError_Context(t *testing.T) {
+	captureJsonLogs(t)
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	w := httptest.NewRecorder()
+	internalServerError(w, httptest.NewRequest(http.MethodGet, "/tasks", nil).WithContext(expired), errors.New("interrupted"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a timed out request to be unavailable, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	internalServerError(w, httptest.NewRequest(http.MethodGet, "/tasks", nil), errors.New("disk full"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected an internal server error, got %d", w.Code)
+	}
+}
@@ -1,42 +1,42 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/inaryzen/priotasks/common"
 	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/models"
-	"github.com/inaryzen/priotasks/services"
 )
 
-func PostPreparedQuery(w http.ResponseWriter, r *http.Request) {
+func (s *Server) PostPreparedQuery(w http.ResponseWriter, r *http.Request) {
 	preparedQueryName := r.PathValue("name")
-	err := services.ApplyPreparedQuery(preparedQueryName)
+	err := s.svc.Settings.ApplyPreparedQuery(r.Context(), currentOwner(r), preparedQueryName)
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 	}
-	drawTaskViewBody(w, r)
+	s.drawTaskViewBody(w, r)
 }
 
-func DeleteTagName(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteTagName(w http.ResponseWriter, r *http.Request) {
 	tagStr := r.PathValue("name")
-	err := services.RemoveTagFromSettings(models.TaskTag(tagStr))
+	err := s.svc.Settings.RemoveTagFromSettings(r.Context(), currentOwner(r), models.TaskTag(tagStr))
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 	}
-	drawTaskViewBody(w, r)
+	s.drawTaskViewBody(w, r)
 }
 
-func PostFilterName(w http.ResponseWriter, r *http.Request) {
-	s, err := services.FindUserSettings()
-	t := s.TasksQuery
+func (s *Server) PostFilterName(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.svc.Settings.FindUserSettings(r.Context(), currentOwner(r))
+	t := settings.TasksQuery
 
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
 
@@ -55,9 +55,9 @@ func PostFilterName(w http.ResponseWriter, r *http.Request) {
 		value := r.Form.Get(consts.FILTER_COMPLETED_FROM)
 		var completedFrom time.Time = models.NOT_COMPLETED
 		if value != "" {
-			completedFrom, err = time.Parse(consts.DEFAULT_DATE_FORMAT, value)
+			completedFrom, err = time.ParseInLocation(consts.DEFAULT_DATE_FORMAT, value, currentLocation(r))
 			if err != nil {
-				postFilterNameError(w, filterName, err)
+				s.postFilterNameError(w, r, filterName, err)
 				return
 			}
 		}
@@ -66,9 +66,9 @@ func PostFilterName(w http.ResponseWriter, r *http.Request) {
 		value := r.Form.Get(consts.FILTER_COMPLETED_TO)
 		var completedTo time.Time = models.NOT_COMPLETED
 		if value != "" {
-			completedTo, err = time.Parse(consts.DEFAULT_DATE_FORMAT, value)
+			completedTo, err = time.ParseInLocation(consts.DEFAULT_DATE_FORMAT, value, currentLocation(r))
 			if err != nil {
-				postFilterNameError(w, filterName, err)
+				s.postFilterNameError(w, r, filterName, err)
 				return
 			}
 		}
@@ -96,6 +96,11 @@ func PostFilterName(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		t.Tags = append(t.Tags, models.TaskTag(tagStr))
+	case consts.FILTER_ASSIGNED_TO_ME:
+		filter := r.Form.Get(consts.FILTER_ASSIGNED_TO_ME)
+		t.AssignedToMe = filter != ""
+	case consts.FILTER_PROJECT:
+		t.Project = r.Form.Get(consts.FILTER_PROJECT)
 	case consts.FILTER_SEARCH:
 		searchText := r.Form.Get(consts.FILTER_SEARCH)
 		t.SearchText = searchText
@@ -108,38 +113,47 @@ func PostFilterName(w http.ResponseWriter, r *http.Request) {
 		if limitCountStr != "" {
 			limitCount, err := strconv.Atoi(limitCountStr)
 			if err != nil || limitCount < 1 {
-				postFilterNameError(w, filterName, fmt.Errorf("invalid limit count: %s", limitCountStr))
+				s.postFilterNameError(w, r, filterName, fmt.Errorf("invalid limit count: %s", limitCountStr))
 				return
 			}
 			t.LimitCount = limitCount
 		}
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
-		log.Println("unknown filter name")
+		slog.WarnContext(r.Context(), "PostFilterName: unknown filter name", "filter", filterName)
 		return
 	}
 
-	s.TasksQuery = t
-	err = services.UpdateUserSettings(s)
+	settings.TasksQuery = t
+	err = s.svc.Settings.UpdateUserSettings(r.Context(), settings)
 	if err != nil {
-		internalServerError(w, err)
+		internalServerError(w, r, err)
 		return
 	}
 
-	common.Debug("PostFilterName: %v", s)
-	common.Debug("PostFilterName: %v", t)
-	common.Debug("PostFilterName: %v", filterName)
+	slog.DebugContext(r.Context(), "PostFilterName", "filter", filterName, "query", t)
 
 	w.WriteHeader(http.StatusOK)
-	drawTaskViewBody(w, r)
+	s.drawTaskViewBody(w, r)
 }
 
-func postFilterNameError(w http.ResponseWriter, filterName string, err error) {
+func (s *Server) postFilterNameError(w http.ResponseWriter, r *http.Request, filterName string, err error) {
 	w.WriteHeader(http.StatusInternalServerError)
-	log.Printf("PostFilterName: error updating filter %v: %v", filterName, err)
+	slog.ErrorContext(r.Context(), "PostFilterName: error updating filter", "filter", filterName, "error", err)
 }
 
-func internalServerError(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
-	log.Printf("PostFilterName: internal error: %v", err)
+// internalServerError answers 500 and logs the error with the route it happened on
+func internalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	// the driver doesn't always wrap the error of the context its query was cancelled with
+	switch ctxErr := r.Context().Err(); {
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		w.WriteHeader(http.StatusServiceUnavailable)
+		slog.WarnContext(r.Context(), "request timed out", "method", r.Method, "path", r.URL.Path, "error", err)
+	case errors.Is(ctxErr, context.Canceled):
+		// the client went away, there is nobody to answer
+		slog.DebugContext(r.Context(), "request cancelled", "method", r.Method, "path", r.URL.Path, "error", err)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "internal server error", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
 }
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/db"
@@ -16,16 +18,16 @@ type MockDB struct {
 	settings models.Settings
 }
 
-func (m *MockDB) FindSettings(settingsId string) (models.Settings, error) {
+func (m *MockDB) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
 	return m.settings, nil
 }
 
-func (m *MockDB) SaveSettings(s models.Settings) error {
+func (m *MockDB) SaveSettings(ctx context.Context, s models.Settings) error {
 	m.settings = s
 	return nil
 }
 
-func setupTestHandler() *MockDB {
+func setupTestHandler() (*Server, *MockDB) {
 	mockDB := &MockDB{
 		settings: models.Settings{
 			Id: "UserSettings",
@@ -37,19 +39,19 @@ func setupTestHandler() *MockDB {
 			},
 		},
 	}
-	db.SetDB(mockDB)
-	return mockDB
+	return newTestServer(mockDB), mockDB
 }
 
 func TestPostFilterName_EmptyTag(t *testing.T) {
-	mockDB := setupTestHandler()
+	t.Parallel()
+	s, mockDB := setupTestHandler()
 
 	req := httptest.NewRequest(http.MethodPost, "/filter/"+consts.FILTER_TAGS, strings.NewReader(consts.FILTER_TAGS+"="))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetPathValue("name", consts.FILTER_TAGS)
 	rr := httptest.NewRecorder()
 
-	PostFilterName(rr, req)
+	s.PostFilterName(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
@@ -61,14 +63,15 @@ func TestPostFilterName_EmptyTag(t *testing.T) {
 }
 
 func TestPostFilterName_ValidTag(t *testing.T) {
-	mockDB := setupTestHandler()
+	t.Parallel()
+	s, mockDB := setupTestHandler()
 
 	req := httptest.NewRequest(http.MethodPost, "/filter/"+consts.FILTER_TAGS, strings.NewReader(consts.FILTER_TAGS+"=test-tag"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetPathValue("name", consts.FILTER_TAGS)
 	rr := httptest.NewRecorder()
 
-	PostFilterName(rr, req)
+	s.PostFilterName(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -83,7 +86,8 @@ func TestPostFilterName_ValidTag(t *testing.T) {
 }
 
 func TestPostFilterName_CompletedFilter(t *testing.T) {
-	mockDB := setupTestHandler()
+	t.Parallel()
+	s, mockDB := setupTestHandler()
 
 	req := httptest.NewRequest(http.MethodPost, "/filter/"+consts.FILTER_NAME_HIDE_COMPLETED,
 		strings.NewReader(consts.FILTER_NAME_HIDE_COMPLETED+"=true"))
@@ -91,7 +95,7 @@ func TestPostFilterName_CompletedFilter(t *testing.T) {
 	req.SetPathValue("name", consts.FILTER_NAME_HIDE_COMPLETED)
 	rr := httptest.NewRecorder()
 
-	PostFilterName(rr, req)
+	s.PostFilterName(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -103,15 +107,55 @@ func TestPostFilterName_CompletedFilter(t *testing.T) {
 }
 
 func TestPostFilterName_InvalidFilter(t *testing.T) {
-	// mockDB := setupTestHandler()
+	t.Parallel()
+	s, _ := setupTestHandler()
 
 	req := httptest.NewRequest(http.MethodPost, "/filter/invalid-filter", strings.NewReader("value=test"))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	rr := httptest.NewRecorder()
 
-	PostFilterName(rr, req)
+	s.PostFilterName(rr, req)
 
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 }
+
+func TestPostFilterName_CompletedFromInTimeZone(t *testing.T) {
+	t.Parallel()
+	s, mockDB := setupTestHandler()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/filter/"+consts.FILTER_COMPLETED_FROM,
+		strings.NewReader(consts.FILTER_COMPLETED_FROM+"=2025-03-30"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("name", consts.FILTER_COMPLETED_FROM)
+	req = req.WithContext(models.ContextWithLocation(req.Context(), berlin))
+	rr := httptest.NewRecorder()
+
+	s.PostFilterName(rr, req)
+
+	if want := time.Date(2025, 3, 30, 0, 0, 0, 0, berlin); !mockDB.settings.TasksQuery.CompletedFrom.Equal(want) {
+		t.Errorf("expected the date to start at midnight in Berlin, %v, got %v", want, mockDB.settings.TasksQuery.CompletedFrom)
+	}
+}
+
+func TestTimeZoneMiddleware(t *testing.T) {
+	t.Parallel()
+	s := setupAuthDB(t, false)
+	if err := s.svc.Settings.SetTimeZone(context.Background(), "", "Asia/Tokyo"); err != nil {
+		t.Fatalf("SetTimeZone failed: %v", err)
+	}
+	h := s.TimeZoneMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(currentLocation(r).String()))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, consts.URL_TASKS, nil))
+	if got := rr.Body.String(); got != "Asia/Tokyo" {
+		t.Errorf("expected the time zone of the user, got %q", got)
+	}
+}
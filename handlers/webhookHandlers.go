@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/inaryzen/priotasks/components"
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"github.com/inaryzen/priotasks/services"
+)
+
+func (s *Server) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	s.drawWebhooksView(w, r, "")
+}
+
+func (s *Server) PostWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events []models.WebhookEvent
+	for _, e := range r.PostForm[consts.PARAM_WEBHOOK_EVENT] {
+		events = append(events, models.WebhookEvent(e))
+	}
+	var tags []models.TaskTag
+	for _, tag := range r.PostForm[consts.PARAM_WEBHOOK_TAG] {
+		tags = append(tags, models.TaskTag(tag))
+	}
+
+	_, err := s.svc.Webhooks.CreateWebhook(r.Context(),
+		r.PostForm.Get(consts.PARAM_WEBHOOK_URL),
+		events,
+		tags,
+		r.PostForm.Get(consts.PARAM_WEBHOOK_SECRET),
+	)
+	if errors.Is(err, services.ErrInvalidWebhook) {
+		w.WriteHeader(http.StatusBadRequest)
+		s.drawWebhooksView(w, r, err.Error())
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, consts.URL_WEBHOOKS, http.StatusSeeOther)
+}
+
+func (s *Server) PostWebhookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.svc.Webhooks.DeleteWebhook(r.Context(), r.PathValue("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, consts.URL_WEBHOOKS, http.StatusSeeOther)
+}
+
+func (s *Server) drawWebhooksView(w http.ResponseWriter, r *http.Request, errMsg string) {
+	hooks, err := s.svc.Webhooks.Webhooks(r.Context())
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	deliveries, err := s.svc.Webhooks.WebhookDeliveries(r.Context(), consts.WEBHOOK_LOG_PAGE_SIZE)
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	allTags, err := s.svc.Tags.Tags(r.Context(), currentOwner(r))
+	if err != nil {
+		internalServerError(w, r, err)
+		return
+	}
+	components.WebhooksView(hooks, deliveries, allTags, errMsg).Render(r.Context(), w)
+}
@@ -1,122 +1,386 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"embed"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/inaryzen/priotasks/common"
-	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/db"
 	"github.com/inaryzen/priotasks/handlers"
 	"github.com/inaryzen/priotasks/services"
+	"golang.org/x/term"
 )
 
 //go:embed assets/*
 var assets embed.FS
 
 func main() {
-	printVersion()
-
 	common.InitConfig()
+	if err := common.InitLogging(os.Stderr, common.Conf.LogLevel, common.Conf.LogFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logVersion()
 
-	var port string = fmt.Sprintf(":%v", common.Conf.ServerPort)
-	server := &http.Server{Addr: port}
+	addr := net.JoinHostPort(common.Conf.BindAddress, strconv.Itoa(common.Conf.ServerPort))
+	timeouts := handlers.RequestTimeouts{
+		Read:  common.Conf.ReadTimeout,
+		Write: common.Conf.WriteTimeout,
+		Bulk:  common.Conf.BulkTimeout,
+	}
+	// cancelled when the requests still running keep the server from shutting down in time
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	// run backup before opening DB file
-	if !backup() {
+	store, dsn, err := newStore()
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	store.Init(dsn)
+	defer store.Close()
+
+	svc := services.New(store)
+	if common.Conf.TimeZone != nil {
+		svc.SetTimeZone(common.Conf.TimeZone)
+	}
+	svc.Migrate()
+	if common.Conf.Demo {
+		if err := svc.SeedDemo(context.Background()); err != nil {
+			slog.Error(err.Error())
+			return
+		}
+		slog.Info("demo mode: serving sample data kept in memory, the changes are lost on exit")
+	}
+
+	if common.Conf.CopyToPostgres {
+		if err := runCopyCommand(svc); err != nil {
+			slog.Error(err.Error())
+			// the deferred calls do not run on exit
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if common.Conf.DumpExport != "" || common.Conf.DumpImport != "" {
+		if err := runDumpCommand(svc); err != nil {
+			slog.Error(err.Error())
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if common.Conf.SetPassword != "" || common.Conf.DeleteUser != "" {
+		if err := runUserCommand(svc); err != nil {
+			slog.Error(err.Error())
+			store.Close()
+			os.Exit(1)
+		}
 		return
 	}
 
-	newDb := db.NewDbSQLite()
-	db.SetDB(newDb)
-	db.DB().Init("")
+	certFile, keyFile, err := resolveTLSFiles()
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	appDir, err := common.ResolveAppDir()
+	if err != nil {
+		slog.Error("failed to resolve app directory", "error", err)
+		return
+	}
+	svc.RegisterMetrics(appDir)
 
-	defer db.DB().Close()
+	var redirectServer *http.Server
+	if common.Conf.TLS && common.Conf.HTTPRedirectPort != 0 {
+		redirectAddr := net.JoinHostPort(common.Conf.BindAddress, strconv.Itoa(common.Conf.HTTPRedirectPort))
+		redirectServer = &http.Server{Addr: redirectAddr, Handler: handlers.HTTPSRedirectHandler(common.Conf.ServerPort)}
+		go startRedirectServer(redirectServer)
+	}
 
-	services.Init()
-	configureServerMux()
-	go startServer(server)
+	app := handlers.NewServer(svc, assets, timeouts)
+	server := &http.Server{
+		Addr:        addr,
+		Handler:     app,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+	server.RegisterOnShutdown(svc.CloseEvents)
+	// the port and the certificate are checked before the server reports ready
+	listener, err := listen(server, certFile, keyFile)
+	if err != nil {
+		slog.Error(err.Error())
+		store.Close()
+		os.Exit(1)
+	}
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- startServer(server, listener) }()
+	app.SetReady(true)
 
-	<-stop
+	select {
+	case <-stop:
+	case err := <-serverErr:
+		slog.Error("the server stopped", "error", err)
+		store.Close()
+		os.Exit(1)
+	}
+	app.SetReady(false)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	fmt.Println()
-	log.Println("shutting down the server...")
+	slog.Info("shutting down the server")
 
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("server forced to shutdown", "error", err)
+		// the queries of the requests still running would keep the database from closing
+		cancelRequests()
+		store.Close()
+		os.Exit(1)
+	}
+	if err := svc.WaitWebhooks(ctx); err != nil {
+		slog.Warn("stopped waiting for the webhook deliveries", "error", err)
+	}
+}
+
+// resolveTLSFiles returns the certificate and key to serve HTTPS with, empty without -tls
+func resolveTLSFiles() (certFile, keyFile string, err error) {
+	if !common.Conf.TLS {
+		return "", "", nil
+	}
+	if common.Conf.TLSCert != "" || common.Conf.TLSKey != "" {
+		if common.Conf.TLSCert == "" || common.Conf.TLSKey == "" {
+			return "", "", fmt.Errorf("both -tls-cert and -tls-key are required")
+		}
+		return common.Conf.TLSCert, common.Conf.TLSKey, nil
+	}
+	appDir, err := common.ResolveAppDir()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve app directory: %w", err)
+	}
+	return services.EnsureSelfSignedCert(appDir)
+}
+
+// newStore returns the store to keep the data in and the connection string to open it with:
+// the sample data in memory with -demo, the PostgreSQL database with -db postgres, otherwise
+// the SQLite file, backed up first. The copy to PostgreSQL reads the SQLite file.
+func newStore() (db.Db, string, error) {
+	if common.Conf.Demo {
+		return db.NewMemDB(), "", nil
+	}
+	database := common.Conf.Database
+	if common.Conf.CopyToPostgres {
+		database = common.DATABASE_SQLITE
+	}
+	switch database {
+	case common.DATABASE_SQLITE:
+		// run backup before opening DB file
+		if !backup() {
+			return nil, "", fmt.Errorf("the database was not opened without a backup")
+		}
+		return db.NewDbSQLite(), "", nil
+	case common.DATABASE_POSTGRES:
+		if common.Conf.PostgresDSN == "" {
+			return nil, "", fmt.Errorf("-db postgres requires -postgres-dsn or $%s", common.POSTGRES_DSN_ENV)
+		}
+		return db.NewDbPostgres(), common.Conf.PostgresDSN, nil
+	default:
+		return nil, "", fmt.Errorf("unknown -db %q: expected sqlite or postgres", database)
 	}
 }
 
 func backup() bool {
 	appDir, err := common.ResolveAppDir()
 	if err != nil {
-		log.Printf("failed to resolve app directory: %v", err)
+		slog.Error("failed to resolve app directory", "error", err)
 		return false
 	}
 	backupService, err := services.NewBackupService(appDir)
 	if err != nil {
-		log.Printf("failed to initialize backup service: %v", err)
+		slog.Error("failed to initialize backup service", "error", err)
 		return false
 	}
 	if err := backupService.CreateBackup(); err != nil {
-		log.Printf("failed to create backup: %v", err)
+		slog.Error("failed to create backup", "error", err)
 		return false
 	}
 	return true
 }
 
-func printVersion() {
+// runDumpCommand exports the database to, or imports it from, the file given by the -export/-import flags
+func runDumpCommand(svc *services.Services) error {
+	if common.Conf.DumpImport != "" {
+		return runDumpImport(svc, common.Conf.DumpImport)
+	}
+	return runDumpExport(svc, common.Conf.DumpExport)
+}
+
+func runDumpExport(svc *services.Services, path string) error {
+	encoding, err := services.DumpEncodingFromFileName(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer f.Close()
+
+	stats, err := svc.ExportDump(context.Background(), f, encoding)
+	if err != nil {
+		return err
+	}
+	slog.Info("exported the database", "tasks", stats.Tasks, "tags", stats.Tags, "file", path)
+	return nil
+}
+
+func runDumpImport(svc *services.Services, path string) error {
+	encoding, err := services.DumpEncodingFromFileName(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open dump file: %w", err)
+	}
+	defer f.Close()
+
+	stats, err := svc.ImportDump(context.Background(), f, encoding)
+	if err != nil {
+		return err
+	}
+	slog.Info("imported the database", "tasks", stats.Tasks, "tags", stats.Tags, "file", path)
+	return nil
+}
+
+// runCopyCommand copies the SQLite database of svc into the PostgreSQL database given by -postgres-dsn
+func runCopyCommand(svc *services.Services) error {
+	if common.Conf.PostgresDSN == "" {
+		return fmt.Errorf("-copy-to-postgres requires -postgres-dsn or $%s", common.POSTGRES_DSN_ENV)
+	}
+	store := db.NewDbPostgres()
+	store.Init(common.Conf.PostgresDSN)
+	defer store.Close()
+	target := services.New(store)
+	// the data is copied as migrated on SQLite, the migrations of the target run while it is empty
+	target.Migrate()
+
+	stats, err := svc.CopyTo(context.Background(), target)
+	if err != nil {
+		return err
+	}
+	slog.Info("copied the database to PostgreSQL", "users", stats.Users, "tasks", stats.Tasks, "tags", stats.Tags,
+		"projects", stats.Projects, "webhooks", stats.Webhooks)
+	return nil
+}
+
+// runUserCommand manages the users given by the -set-password/-delete-user flags
+func runUserCommand(svc *services.Services) error {
+	if common.Conf.DeleteUser != "" {
+		if err := svc.Users.DeleteUser(context.Background(), common.Conf.DeleteUser, ""); err != nil {
+			return fmt.Errorf("failed to delete user %v: %w", common.Conf.DeleteUser, err)
+		}
+		slog.Info("deleted user", "username", common.Conf.DeleteUser)
+		return nil
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return fmt.Errorf("failed to read the password: %w", err)
+	}
+	if err := svc.Users.SetPassword(context.Background(), common.Conf.SetPassword, password); err != nil {
+		return err
+	}
+	slog.Info("saved the password", "username", common.Conf.SetPassword)
+	return nil
+}
+
+// readPassword prompts for the password on a terminal, otherwise reads the first line of stdin
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Print("Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(password), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func logVersion() {
 	buildInfo, ok := debug.ReadBuildInfo()
 	if !ok {
-		fmt.Println("Unable to determine version information.")
+		slog.Warn("unable to determine version information")
 		return
 	}
-	fmt.Printf("version:%s\n", buildInfo.Main.Version)
-	fmt.Printf("sum:%s\n", buildInfo.Main.Sum)
+	slog.Info("priotasks", "version", buildInfo.Main.Version, "sum", buildInfo.Main.Sum)
 }
 
-func configureServerMux() {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, consts.URL_TASKS, http.StatusFound) // 302
-	})
-
-	http.HandleFunc("GET "+consts.URL_TASKS, handlers.GetTasks)
-	http.HandleFunc("POST "+consts.URL_TASKS, handlers.PostTaskHandler)
-	http.HandleFunc("PUT "+consts.URL_TASKS, handlers.PutTaskHandler)
-	// http.HandleFunc("POST /tasks/{id}/toggle-completed", handlers.PostTaskToggleCompleted)
-	http.HandleFunc("DELETE "+consts.URL_TASKS_ID, handlers.DeleteTasksId)
-	http.HandleFunc("POST /tasks/{id}/clone", handlers.PostTaskCloneHandler)
-	http.HandleFunc("GET "+consts.URL_TASKS_EXPORT_YAML, handlers.GetTasksYamlHandler)
-	http.HandleFunc("POST /filter/{name}", handlers.PostFilterName)
-	http.HandleFunc("DELETE /filter/tag/{name}", handlers.DeleteTagName)
-	http.HandleFunc("POST /prepared-query/{name}", handlers.PostPreparedQuery)
-	http.HandleFunc("POST "+consts.URL_TOGGLE_SORT_TABLE, handlers.PostToggleSortTable)
-	http.HandleFunc("GET /view/task/{id}", handlers.GetViewTaskByIdHandler)
-	http.HandleFunc("GET /view/new-task", handlers.GetViewEmptyTask)
-	http.HandleFunc("POST /tags", handlers.PostTagsHandler)
-	http.HandleFunc("DELETE /tags/{name}", handlers.DeleteTagHandler)
-	http.HandleFunc("POST /tasks/reduce-priority", handlers.PostReducePriorityHandler)
-	http.Handle("/assets/", http.FileServer(http.FS(assets)))
+// listen binds the address of s and, with certFile, loads the certificate it serves HTTPS with
+func listen(s *http.Server, certFile, keyFile string) (net.Listener, error) {
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the certificate: %w", err)
+		}
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %v: %w", s.Addr, err)
+	}
+	return listener, nil
+}
+
+// startServer serves s on listener until it is shut down; the error is never http.ErrServerClosed
+func startServer(s *http.Server, listener net.Listener) error {
+	host := common.Conf.BindAddress
+	if host == "" {
+		host = "localhost"
+	}
+	var err error
+	if s.TLSConfig != nil {
+		slog.Info("starting the server", "url", "https://"+net.JoinHostPort(host, strconv.Itoa(common.Conf.ServerPort)))
+		err = s.ServeTLS(listener, "", "")
+	} else {
+		slog.Info("starting the server", "url", "http://"+net.JoinHostPort(host, strconv.Itoa(common.Conf.ServerPort)))
+		err = s.Serve(listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
-func startServer(s *http.Server) {
-	log.Println("starting the server...")
-	log.Printf("http://localhost%s \n", s.Addr)
+func startRedirectServer(s *http.Server) {
+	slog.Info("redirecting http to https", "addr", s.Addr)
 	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		fmt.Printf("Error starting server: %v\n", err)
+		slog.Error("failed to start the redirect server", "error", err)
 	}
 }
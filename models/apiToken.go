@@ -0,0 +1,39 @@
+package models
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+type ApiTokenScope string
+
+const (
+	ApiTokenRead      ApiTokenScope = "read"
+	ApiTokenReadWrite ApiTokenScope = "read-write"
+)
+
+var API_TOKEN_SCOPES = []ApiTokenScope{ApiTokenRead, ApiTokenReadWrite}
+
+// readMethods do not change anything, including the WebDAV ones used by CalDAV clients
+var readMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "REPORT"}
+
+// ApiToken grants scripts access on behalf of the user. Only the hash of the token is stored.
+type ApiToken struct {
+	Id        string
+	Name      string
+	TokenHash string
+	Scope     ApiTokenScope
+	Username  string
+	Created   time.Time
+	LastUsed  time.Time
+}
+
+// Allows reports whether a request with the method is in the scope of the token
+func (t ApiToken) Allows(method string) bool {
+	return t.Scope == ApiTokenReadWrite || slices.Contains(readMethods, method)
+}
+
+func (t ApiToken) IsUsed() bool {
+	return !t.LastUsed.IsZero()
+}
@@ -0,0 +1,28 @@
+package models
+
+// ImportConflict describes an imported task that was not applied, or as a warning what of
+// an applied task was left out
+type ImportConflict struct {
+	TaskId string
+	Title  string
+	Reason string
+}
+
+// ImportReport summarizes what an import changed, or would change in dry-run mode
+type ImportReport struct {
+	DryRun    bool
+	Created   []Task
+	Updated   []Task
+	Unchanged []Task
+	Conflicts []ImportConflict
+	// Warnings are the tasks imported without some of what the file had for them
+	Warnings []ImportConflict
+	NewTags  []TaskTag
+}
+
+func (r ImportReport) IsEmpty() bool {
+	return len(r.Created) == 0 &&
+		len(r.Updated) == 0 &&
+		len(r.Unchanged) == 0 &&
+		len(r.Conflicts) == 0
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Project shares its tasks between its members. The owner manages the members
+// and is always one of them.
+type Project struct {
+	Id      string
+	Name    string
+	Owner   string
+	Created time.Time
+	Members []string
+}
+
+func (p Project) HasMember(username string) bool {
+	return slices.Contains(p.Members, username)
+}
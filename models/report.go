@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// UNTAGGED_GROUP names the report group of completed tasks without tags
+const UNTAGGED_GROUP TaskTag = "Untagged"
+
+// ReportGroup holds the completed tasks sharing a tag
+type ReportGroup struct {
+	Tag          TaskTag
+	Tasks        []Task
+	TotalMinutes int
+}
+
+// Report summarizes a period: what was completed, grouped by tag, and what is still open
+type Report struct {
+	From           time.Time
+	To             time.Time
+	Completed      []Task
+	CompletedByTag []ReportGroup
+	TotalMinutes   int
+	HighPriority   []Task
+	Wip            []Task
+}
+
+// LastDay is the last day included in the report, To being exclusive
+func (r Report) LastDay() time.Time {
+	return r.To.AddDate(0, 0, -1)
+}
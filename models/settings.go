@@ -68,6 +68,9 @@ func DirectionFromString(str string) (result SortDirection) {
 type Settings struct {
 	Id         string
 	TasksQuery TasksQuery
+	// TimeZone is the IANA name of the time zone of the user, empty for the default time
+	// zone of the server
+	TimeZone string `json:",omitempty" yaml:",omitempty"`
 }
 
 func (s Settings) IsSorted(c SortColumn, d SortDirection) bool {
@@ -89,6 +92,13 @@ type TasksQuery struct {
 	SearchText        string
 	EnableLimit       bool
 	LimitCount        int
+	// AssignedToMe limits the query to the tasks assigned to Owner
+	AssignedToMe bool
+	// Project limits the query to the tasks of the project
+	Project string
+	// Owner is the user the query runs for: their own tasks and those of their projects.
+	// It is not stored with the settings.
+	Owner string
 }
 
 func (t TasksQuery) RemoveTag(target TaskTag) TasksQuery {
@@ -118,7 +128,9 @@ func (t TasksQuery) String() string {
 			"Tags: %v, "+
 			"SearchText: %v, "+
 			"EnableLimit: %v, "+
-			"LimitCount: %v",
+			"LimitCount: %v, "+
+			"AssignedToMe: %v, "+
+			"Project: %v",
 		t.FilterCompleted,
 		t.CompletedFrom,
 		t.CompletedTo,
@@ -133,6 +145,8 @@ func (t TasksQuery) String() string {
 		t.SearchText,
 		t.EnableLimit,
 		t.LimitCount,
+		t.AssignedToMe,
+		t.Project,
 	)
 }
 
@@ -151,5 +165,7 @@ func (s TasksQuery) Reset() TasksQuery {
 	s.SearchText = ""
 	s.EnableLimit = true
 	s.LimitCount = 10
+	s.AssignedToMe = false
+	s.Project = ""
 	return s
 }
@@ -2,12 +2,13 @@ package models
 
 import (
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/inaryzen/priotasks/common"
+	"gopkg.in/yaml.v3"
 )
 
 var EMPTY_TASK = Task{
@@ -30,6 +31,13 @@ func (t TaskTag) IsEmpty() bool {
 	return t == EMPTY_TAG
 }
 
+// TagRecord is a tag as it is stored, with its creation time and the user it belongs to
+type TagRecord struct {
+	Id      TaskTag
+	Created time.Time
+	Owner   string `json:",omitempty" yaml:",omitempty"`
+}
+
 type TaskPriority int
 
 const (
@@ -76,21 +84,47 @@ func (p TaskPriority) Reduce() TaskPriority {
 	}
 }
 
-func (p TaskPriority) MarshalYAML() (any, error) {
+// Name is the human-readable name used by the exports
+func (p TaskPriority) Name() string {
 	switch p {
 	case PriorityUrgent:
-		return "Urgent", nil
+		return "Urgent"
 	case PriorityHigh:
-		return "High", nil
+		return "High"
 	case PriorityMedium:
-		return "Medium", nil
+		return "Medium"
 	case PriorityLow:
-		return "Low", nil
+		return "Low"
+	default:
+		return "Unknown"
+	}
+}
+
+func (p TaskPriority) MarshalYAML() (any, error) {
+	return p.Name(), nil
+}
+
+// ParseTaskPriority is the reverse of TaskPriority.Name
+func ParseTaskPriority(a string) (TaskPriority, error) {
+	switch a {
+	case "Urgent":
+		return PriorityUrgent, nil
+	case "High":
+		return PriorityHigh, nil
+	case "Medium":
+		return PriorityMedium, nil
+	case "Low":
+		return PriorityLow, nil
 	default:
-		return "Unknown", nil
+		return PriorityMedium, fmt.Errorf("unknown priority: %q", a)
 	}
 }
 
+func (p *TaskPriority) UnmarshalYAML(value *yaml.Node) (err error) {
+	*p, err = ParseTaskPriority(value.Value)
+	return
+}
+
 type TaskImpact int
 
 const (
@@ -127,23 +161,51 @@ func (i TaskImpact) ToHumanString() string {
 	}
 }
 
-func (i TaskImpact) MarshalYAML() (any, error) {
+// Name is the human-readable name used by the exports
+func (i TaskImpact) Name() string {
 	switch i {
 	case ImpactHigh:
-		return "High", nil
+		return "High"
 	case ImpactConsiderable:
-		return "Considerable", nil
+		return "Considerable"
 	case ImpactModerate:
-		return "Moderate", nil
+		return "Moderate"
 	case ImpactLow:
-		return "Low", nil
+		return "Low"
 	case ImpactSlight:
-		return "Slight", nil
+		return "Slight"
+	default:
+		return "Unknown"
+	}
+}
+
+func (i TaskImpact) MarshalYAML() (any, error) {
+	return i.Name(), nil
+}
+
+// ParseTaskImpact is the reverse of TaskImpact.Name
+func ParseTaskImpact(a string) (TaskImpact, error) {
+	switch a {
+	case "High":
+		return ImpactHigh, nil
+	case "Considerable":
+		return ImpactConsiderable, nil
+	case "Moderate":
+		return ImpactModerate, nil
+	case "Low":
+		return ImpactLow, nil
+	case "Slight":
+		return ImpactSlight, nil
 	default:
-		return "Unknown", nil
+		return ImpactModerate, fmt.Errorf("unknown impact: %q", a)
 	}
 }
 
+func (i *TaskImpact) UnmarshalYAML(value *yaml.Node) (err error) {
+	*i, err = ParseTaskImpact(value.Value)
+	return
+}
+
 func StrToEnum[T ~int](a string) (T, error) {
 	val, err := strconv.Atoi(a)
 	if err != nil {
@@ -183,25 +245,76 @@ func (i TaskCost) ToHumanString() string {
 	}
 }
 
-func (c TaskCost) MarshalYAML() (any, error) {
+// Name is the human-readable name used by the exports
+func (c TaskCost) Name() string {
+	switch c {
+	case CostXS:
+		return "XS (~10m)"
+	case CostS:
+		return "S (~30m)"
+	case CostM:
+		return "M (~1h)"
+	case CostL:
+		return "L (~2h)"
+	case CostXL:
+		return "XL (~4h)"
+	case CostXXL:
+		return "XXL (~8h)"
+	default:
+		return "Unknown"
+	}
+}
+
+// Minutes is the estimated time of the cost in minutes
+func (c TaskCost) Minutes() int {
 	switch c {
 	case CostXS:
-		return "XS (~10m)", nil
+		return 10
 	case CostS:
-		return "S (~30m)", nil
+		return 30
 	case CostM:
-		return "M (~1h)", nil
+		return 60
 	case CostL:
-		return "L (~2h)", nil
+		return 120
 	case CostXL:
-		return "XL (~4h)", nil
+		return 240
 	case CostXXL:
-		return "XXL (~8h)", nil
+		return 480
 	default:
-		return "Unknown", nil
+		return 0
 	}
 }
 
+func (c TaskCost) MarshalYAML() (any, error) {
+	return c.Name(), nil
+}
+
+// ParseTaskCost is the reverse of TaskCost.Name; the size alone ("S") is accepted as well
+func ParseTaskCost(a string) (TaskCost, error) {
+	size, _, _ := strings.Cut(a, " ")
+	switch size {
+	case "XS":
+		return CostXS, nil
+	case "S":
+		return CostS, nil
+	case "M":
+		return CostM, nil
+	case "L":
+		return CostL, nil
+	case "XL":
+		return CostXL, nil
+	case "XXL":
+		return CostXXL, nil
+	default:
+		return CostM, fmt.Errorf("unknown cost: %q", a)
+	}
+}
+
+func (c *TaskCost) UnmarshalYAML(value *yaml.Node) (err error) {
+	*c, err = ParseTaskCost(value.Value)
+	return
+}
+
 type TaskFun int
 
 const (
@@ -241,21 +354,47 @@ func (f TaskFun) GetMultiplier() float32 {
 	}
 }
 
-func (f TaskFun) MarshalYAML() (any, error) {
+// Name is the human-readable name used by the exports
+func (f TaskFun) Name() string {
 	switch f {
 	case FunS:
-		return "S", nil
+		return "S"
 	case FunM:
-		return "M", nil
+		return "M"
 	case FunL:
-		return "L", nil
+		return "L"
 	case FunXL:
-		return "XL", nil
+		return "XL"
+	default:
+		return "Unknown"
+	}
+}
+
+func (f TaskFun) MarshalYAML() (any, error) {
+	return f.Name(), nil
+}
+
+// ParseTaskFun is the reverse of TaskFun.Name
+func ParseTaskFun(a string) (TaskFun, error) {
+	switch a {
+	case "S":
+		return FunS, nil
+	case "M":
+		return FunM, nil
+	case "L":
+		return FunL, nil
+	case "XL":
+		return FunXL, nil
 	default:
-		return "Unknown", nil
+		return FunM, fmt.Errorf("unknown fun: %q", a)
 	}
 }
 
+func (f *TaskFun) UnmarshalYAML(value *yaml.Node) (err error) {
+	*f, err = ParseTaskFun(value.Value)
+	return
+}
+
 type Task struct {
 	Id        string
 	Title     string
@@ -271,6 +410,20 @@ type Task struct {
 	Fun       TaskFun
 	Value     float32
 	Tags      []TaskTag
+	// Owner is the username of the user the task belongs to, empty while sign-in is disabled
+	Owner string `json:",omitempty" yaml:",omitempty"`
+	// Project is the id of the project the task is shared in, empty for a private task
+	Project string `json:",omitempty" yaml:",omitempty"`
+	// Assignee is the username of the member working on the task
+	Assignee string `json:",omitempty" yaml:",omitempty"`
+}
+
+// TaskCounts is the number of tasks of all users by state; wip and planned count open tasks
+type TaskCounts struct {
+	Open      int
+	Completed int
+	Wip       int
+	Planned   int
 }
 
 func titleFromContent(content string) string {
@@ -302,6 +455,7 @@ func (t Task) AsNewTask() Task {
 	}
 	t.Id = uuid.NewString()
 	t.Created = time.Now()
+	t.Updated = t.Created
 	return t
 }
 
@@ -310,7 +464,7 @@ func (c Task) Update(change Task) Task {
 		change.Title = titleFromContent(change.Content)
 	}
 
-	common.Debug("Update: c.IsCompleted()=%v; change.IsCompleted=%v", c.IsCompleted(), change.IsCompleted())
+	slog.Debug("Task.Update", "completed", c.IsCompleted(), "change_completed", change.IsCompleted())
 
 	var completed = c.Completed
 	if !c.IsCompleted() || !change.IsCompleted() {
@@ -332,9 +486,59 @@ func (c Task) Update(change Task) Task {
 		Fun:       change.Fun,
 		Value:     change.Value,
 		Tags:      change.Tags,
+		Owner:     c.Owner,
+		Project:   change.Project,
+		Assignee:  change.Assignee,
 	}
 }
 
+// Version identifies the stored state of the task for optimistic concurrency control.
+// Updated has a one second resolution, every update moves it forward by at least a second.
+func (c Task) Version() string {
+	if c.Updated.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(c.Updated.Unix(), 10)
+}
+
+// ParseTaskVersion returns the Updated time a version was taken from; empty means no version
+func ParseTaskVersion(version string) (time.Time, error) {
+	if version == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid task version: %q", version)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// InLocation is t in the time zone loc; the zero time, which marks the open tasks and the
+// unset dates, stays zero
+func InLocation(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.In(loc)
+}
+
+// In returns the task with its times in the time zone loc
+func (c Task) In(loc *time.Location) Task {
+	c.Created = InLocation(c.Created, loc)
+	c.Updated = InLocation(c.Updated, loc)
+	c.Completed = InLocation(c.Completed, loc)
+	return c
+}
+
+// TasksIn returns the tasks with their times in the time zone loc
+func TasksIn(tasks []Task, loc *time.Location) []Task {
+	result := make([]Task, len(tasks))
+	for i, t := range tasks {
+		result[i] = t.In(loc)
+	}
+	return result
+}
+
 func (c Task) IsCompleted() bool {
 	return c.Completed != NOT_COMPLETED
 }
@@ -402,18 +606,9 @@ func (t Task) IsEmpty() bool {
 
 // CalculateTotalTime calculates the total time in minutes for a slice of tasks
 func CalculateTotalTime(tasks []Task) int {
-	costToMinutes := map[TaskCost]int{
-		CostXS:  10,
-		CostS:   30,
-		CostM:   60,
-		CostL:   120,
-		CostXL:  240,
-		CostXXL: 480,
-	}
-
 	totalMinutes := 0
 	for _, task := range tasks {
-		totalMinutes += costToMinutes[task.Cost]
+		totalMinutes += task.Cost.Minutes()
 	}
 
 	return totalMinutes
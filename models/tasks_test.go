@@ -222,3 +222,33 @@ func Test_Update(t *testing.T) {
 		})
 	}
 }
+
+func Test_EnumsYAMLRoundTrip(t *testing.T) {
+	for p := PriorityLow; p <= PriorityUrgent; p++ {
+		s, _ := p.MarshalYAML()
+		if got, err := ParseTaskPriority(s.(string)); err != nil || got != p {
+			t.Errorf("ParseTaskPriority(%v) = %v, %v; want %v", s, got, err, p)
+		}
+	}
+	for i := ImpactSlight; i <= ImpactHigh; i++ {
+		s, _ := i.MarshalYAML()
+		if got, err := ParseTaskImpact(s.(string)); err != nil || got != i {
+			t.Errorf("ParseTaskImpact(%v) = %v, %v; want %v", s, got, err, i)
+		}
+	}
+	for c := CostXS; c <= CostXXL; c++ {
+		s, _ := c.MarshalYAML()
+		if got, err := ParseTaskCost(s.(string)); err != nil || got != c {
+			t.Errorf("ParseTaskCost(%v) = %v, %v; want %v", s, got, err, c)
+		}
+	}
+	for f := FunS; f <= FunXL; f++ {
+		s, _ := f.MarshalYAML()
+		if got, err := ParseTaskFun(s.(string)); err != nil || got != f {
+			t.Errorf("ParseTaskFun(%v) = %v, %v; want %v", s, got, err, f)
+		}
+	}
+	if _, err := ParseTaskCost("Huge"); err == nil {
+		t.Error("ParseTaskCost should fail for unknown values")
+	}
+}
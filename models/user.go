@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type User struct {
+	Username     string
+	PasswordHash string
+	Created      time.Time
+	// Admin users manage the accounts
+	Admin bool
+}
+
+// Session is a signed-in browser. Only the hash of the cookie token is stored.
+type Session struct {
+	TokenHash string
+	Username  string
+	Created   time.Time
+	Expires   time.Time
+}
+
+func (s Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.Expires)
+}
+
+type userContextKey struct{}
+
+// ContextWithUser returns the request context of the authenticated user
+func ContextWithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+// UserFromContext returns the authenticated user; false when authentication is disabled
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+type csrfContextKey struct{}
+
+// ContextWithCSRFToken returns the request context carrying the CSRF token of the browser
+func ContextWithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfContextKey{}, token)
+}
+
+// CSRFTokenFromContext returns the token the pages send back with their mutating requests
+func CSRFTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfContextKey{}).(string)
+	return token
+}
+
+type locationContextKey struct{}
+
+// ContextWithLocation returns the request context carrying the time zone of the user
+func ContextWithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationContextKey{}, loc)
+}
+
+// LocationFromContext returns the time zone the times are shown and the dates are read in;
+// the local time zone of the server when the context has none
+func LocationFromContext(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(locationContextKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.Local
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookTaskCreated       WebhookEvent = "task.created"
+	WebhookTaskCompleted     WebhookEvent = "task.completed"
+	WebhookTaskReprioritized WebhookEvent = "task.reprioritized"
+)
+
+var WEBHOOK_EVENTS = []WebhookEvent{WebhookTaskCreated, WebhookTaskCompleted, WebhookTaskReprioritized}
+
+// Webhook is a subscription: the task events to send to the URL, optionally only for tasks
+// with one of the tags. A non-empty secret signs every request.
+type Webhook struct {
+	Id      string
+	Url     string
+	Events  []WebhookEvent
+	Tags    []TaskTag
+	Secret  string
+	Created time.Time
+}
+
+// Matches reports whether the event of a task with the tags is sent to the webhook
+func (h Webhook) Matches(event WebhookEvent, tags []TaskTag) bool {
+	if !slices.Contains(h.Events, event) {
+		return false
+	}
+	if len(h.Tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if slices.Contains(h.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records a single attempt to deliver an event
+type WebhookDelivery struct {
+	Id         int64
+	DeliveryId string
+	WebhookId  string
+	Url        string
+	Event      WebhookEvent
+	TaskId     string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Created    time.Time
+	Duration   time.Duration
+}
+
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
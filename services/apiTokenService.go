@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	API_TOKEN_PREFIX      = "pt_"
+	MAX_API_TOKEN_NAME    = 100
+	API_TOKEN_TOUCH_DELAY = time.Minute
+)
+
+var (
+	ErrInvalidApiToken = errors.New("invalid api token")
+	ErrApiTokenScope   = errors.New("the api token does not allow this request")
+)
+
+func (svc *UserService) ApiTokens(ctx context.Context, username string) ([]models.ApiToken, error) {
+	return svc.store.ApiTokens(ctx, username)
+}
+
+// CreateApiToken returns the new token, which is shown once: only its hash is stored
+func (svc *UserService) CreateApiToken(ctx context.Context, username, name string, scope models.ApiTokenScope) (string, models.ApiToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MAX_API_TOKEN_NAME {
+		return "", models.ApiToken{}, fmt.Errorf("%w: the name must have 1 to %d characters", ErrInvalidApiToken, MAX_API_TOKEN_NAME)
+	}
+	if !slices.Contains(models.API_TOKEN_SCOPES, scope) {
+		return "", models.ApiToken{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidApiToken, scope)
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return "", models.ApiToken{}, fmt.Errorf("CreateApiToken: %w", err)
+	}
+	token := API_TOKEN_PREFIX + secret
+	t := models.ApiToken{
+		Id:        uuid.New().String(),
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scope:     scope,
+		Username:  username,
+		Created:   time.Now(),
+	}
+	if err := svc.store.SaveApiToken(ctx, t); err != nil {
+		return "", t, fmt.Errorf("CreateApiToken: %w", err)
+	}
+	return token, t, nil
+}
+
+func (svc *UserService) RevokeApiToken(ctx context.Context, username, tokenId string) error {
+	return svc.store.DeleteApiToken(ctx, username, tokenId)
+}
+
+// ApiTokenUser returns the owner of the token and records its use
+func (svc *UserService) ApiTokenUser(ctx context.Context, token string) (models.User, models.ApiToken, error) {
+	if !strings.HasPrefix(token, API_TOKEN_PREFIX) {
+		return models.User{}, models.ApiToken{}, ErrInvalidCredentials
+	}
+	t, err := svc.store.FindApiTokenByHash(ctx, hashToken(token))
+	if errors.Is(err, db.ErrNotFound) {
+		return models.User{}, t, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, t, fmt.Errorf("ApiTokenUser: %w", err)
+	}
+	u, err := svc.store.FindUser(ctx, t.Username)
+	if errors.Is(err, db.ErrNotFound) {
+		return u, t, ErrInvalidCredentials
+	}
+	if err != nil {
+		return u, t, fmt.Errorf("ApiTokenUser: %w", err)
+	}
+
+	// the last use is precise to API_TOKEN_TOUCH_DELAY, sparing a write per request
+	now := time.Now()
+	if !t.IsUsed() || now.Sub(t.LastUsed) >= API_TOKEN_TOUCH_DELAY {
+		if err := svc.store.TouchApiToken(ctx, t.Id, now); err != nil {
+			slog.ErrorContext(ctx, "ApiTokenUser: failed to record the use of the token", "error", err)
+		}
+		t.LastUsed = now
+	}
+	return u, t, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_ApiTokens_CreateUseRevoke(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+
+	token, created, err := svc.Users.CreateApiToken(context.Background(), "alice", "backup", models.ApiTokenRead)
+	if err != nil {
+		t.Fatalf("CreateApiToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, API_TOKEN_PREFIX) || strings.Contains(created.TokenHash, token) {
+		t.Errorf("unexpected token %q with hash %q", token, created.TokenHash)
+	}
+
+	u, apiToken, err := svc.Users.ApiTokenUser(context.Background(), token)
+	if err != nil || u.Username != "alice" || apiToken.Id != created.Id {
+		t.Fatalf("expected the token of alice, got %+v, %+v, %v", u, apiToken, err)
+	}
+	tokens, err := svc.Users.ApiTokens(context.Background(), "alice")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected 1 token, got %v, %v", tokens, err)
+	}
+	if !tokens[0].IsUsed() {
+		t.Error("the last use should be recorded")
+	}
+
+	if _, _, err := svc.Users.ApiTokenUser(context.Background(), API_TOKEN_PREFIX+"unknown"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for an unknown token, got %v", err)
+	}
+
+	if err := svc.Users.RevokeApiToken(context.Background(), "bob", created.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("only the owner should revoke a token, got %v", err)
+	}
+	if err := svc.Users.RevokeApiToken(context.Background(), "alice", created.Id); err != nil {
+		t.Fatalf("RevokeApiToken failed: %v", err)
+	}
+	if _, _, err := svc.Users.ApiTokenUser(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected a revoked token to be rejected, got %v", err)
+	}
+}
+
+func Test_CreateApiToken_Validation(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if _, _, err := svc.Users.CreateApiToken(context.Background(), "alice", " ", models.ApiTokenRead); !errors.Is(err, ErrInvalidApiToken) {
+		t.Errorf("expected ErrInvalidApiToken for an empty name, got %v", err)
+	}
+	if _, _, err := svc.Users.CreateApiToken(context.Background(), "alice", "script", "admin"); !errors.Is(err, ErrInvalidApiToken) {
+		t.Errorf("expected ErrInvalidApiToken for an unknown scope, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SESSION_DURATION       = 30 * 24 * time.Hour
+	SESSION_TOKEN_BYTES    = 32
+	MIN_PASSWORD_LENGTH    = 8
+	PASSWORD_HASH_COST     = bcrypt.DefaultCost
+	MAX_USERNAME_LENGTH    = 64
+	MAX_PASSWORD_LENGTH    = 72 // bcrypt ignores the rest
+	SESSION_CLEANUP_PERIOD = time.Hour
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("invalid session")
+	ErrInvalidUser        = errors.New("invalid user")
+	ErrLastAdmin          = errors.New("the last admin cannot be removed")
+)
+
+// dummyPasswordHash is compared with for unknown users so that they take as long as known ones
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("priotasks-dummy-password"), PASSWORD_HASH_COST)
+	return hash
+})
+
+// sessionCleanup is when the expired sessions were deleted last
+type sessionCleanup struct {
+	sync.Mutex
+	last time.Time
+}
+
+// AuthEnabled reports whether sign-in is required, which is when any user exists
+func (svc *UserService) AuthEnabled(ctx context.Context) (bool, error) {
+	count, err := svc.store.CountUsers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("AuthEnabled: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetPassword creates the user or changes their password. The first user becomes an admin
+// and takes over the tasks, tags and settings created while sign-in was disabled.
+func (svc *UserService) SetPassword(ctx context.Context, username, password string) error {
+	username = strings.TrimSpace(username)
+	hash, err := hashPassword(username, password)
+	if err != nil {
+		return err
+	}
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		return svc.savePassword(ctx, username, hash)
+	})
+}
+
+// hashPassword validates the credentials and hashes the password, outside of the transaction
+// saving it as the hashing takes a while
+func hashPassword(username, password string) ([]byte, error) {
+	if username == "" || len(username) > MAX_USERNAME_LENGTH {
+		return nil, fmt.Errorf("%w: the username must have 1 to %d characters", ErrInvalidUser, MAX_USERNAME_LENGTH)
+	}
+	if len(password) < MIN_PASSWORD_LENGTH || len(password) > MAX_PASSWORD_LENGTH {
+		return nil, fmt.Errorf("%w: the password must have %d to %d bytes", ErrInvalidUser, MIN_PASSWORD_LENGTH, MAX_PASSWORD_LENGTH)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PASSWORD_HASH_COST)
+	if err != nil {
+		return nil, fmt.Errorf("hashPassword: %w", err)
+	}
+	return hash, nil
+}
+
+func (svc *UserService) savePassword(ctx context.Context, username string, hash []byte) error {
+	count, err := svc.store.CountUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("SetPassword: %w", err)
+	}
+	first := count == 0
+	// the admin flag is only stored with new users
+	err = svc.store.SaveUser(ctx, models.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Created:      time.Now(),
+		Admin:        first,
+	})
+	if err != nil {
+		return fmt.Errorf("SetPassword: %w", err)
+	}
+	if first {
+		if err := svc.transferData(ctx, "", username); err != nil {
+			return fmt.Errorf("SetPassword: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateUser adds an account, unlike SetPassword it fails for an existing user
+func (svc *UserService) CreateUser(ctx context.Context, username, password string, admin bool) error {
+	username = strings.TrimSpace(username)
+	hash, err := hashPassword(username, password)
+	if err != nil {
+		return err
+	}
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := svc.store.FindUser(ctx, username); err == nil {
+			return fmt.Errorf("%w: %v already exists", ErrInvalidUser, username)
+		} else if !errors.Is(err, db.ErrNotFound) {
+			return fmt.Errorf("CreateUser: %w", err)
+		}
+		if err := svc.savePassword(ctx, username, hash); err != nil {
+			return err
+		}
+		if admin {
+			return svc.SetUserAdmin(ctx, username, true)
+		}
+		return nil
+	})
+}
+
+// ResetPassword changes the password of an existing user; db.ErrNotFound for unknown users
+func (svc *UserService) ResetPassword(ctx context.Context, username, password string) error {
+	if _, err := svc.store.FindUser(ctx, username); err != nil {
+		return err
+	}
+	return svc.SetPassword(ctx, username, password)
+}
+
+// Users returns all accounts ordered by username
+func (svc *UserService) Users(ctx context.Context) ([]models.User, error) {
+	return svc.store.Users(ctx)
+}
+
+// SetUserAdmin grants or revokes the admin role, at least one admin is kept
+func (svc *UserService) SetUserAdmin(ctx context.Context, username string, admin bool) error {
+	if !admin {
+		if err := svc.ensureOtherAdmin(ctx, username); err != nil {
+			return err
+		}
+	}
+	return svc.store.SetUserAdmin(ctx, username, admin)
+}
+
+// DeleteUser deletes the account and hands its tasks, tags and settings over to the heir.
+// With an empty heir they go to the first remaining admin, or back to the installation
+// without sign-in when no users remain.
+func (svc *UserService) DeleteUser(ctx context.Context, username, heir string) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := svc.ensureOtherAdmin(ctx, username); err != nil {
+			return err
+		}
+		if heir == username {
+			return fmt.Errorf("%w: %v cannot inherit their own tasks", ErrInvalidUser, username)
+		}
+		users, err := svc.store.Users(ctx)
+		if err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+		if heir == "" {
+			for _, u := range users {
+				if u.Admin && u.Username != username {
+					heir = u.Username
+					break
+				}
+			}
+		}
+		if err := svc.store.DeleteUser(ctx, username); err != nil {
+			return err
+		}
+		if err := svc.transferData(ctx, username, heir); err != nil {
+			return fmt.Errorf("DeleteUser: %w", err)
+		}
+		return nil
+	})
+}
+
+// ensureOtherAdmin fails when the user is the only admin while other users exist
+func (svc *UserService) ensureOtherAdmin(ctx context.Context, username string) error {
+	users, err := svc.store.Users(ctx)
+	if err != nil {
+		return fmt.Errorf("ensureOtherAdmin: %w", err)
+	}
+	admins, isAdmin := 0, false
+	for _, u := range users {
+		if u.Admin {
+			admins++
+			isAdmin = isAdmin || u.Username == username
+		}
+	}
+	if isAdmin && admins == 1 && len(users) > 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// transferData moves the tasks, tags and settings of one user to another; the settings
+// are only kept when the other user has none
+func (svc *UserService) transferData(ctx context.Context, from, to string) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		if err := svc.store.TransferOwnership(ctx, from, to); err != nil {
+			return err
+		}
+		svc.publishTasksChanged(ctx, to)
+		svc.publishTagsChanged(ctx, to)
+
+		s, err := svc.store.FindSettings(ctx, SettingsId(from))
+		if errors.Is(err, db.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("transferData: %w", err)
+		}
+		_, err = svc.store.FindSettings(ctx, SettingsId(to))
+		if errors.Is(err, db.ErrNotFound) {
+			s.Id = SettingsId(to)
+			if err := svc.store.SaveSettings(ctx, s); err != nil {
+				return fmt.Errorf("transferData: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("transferData: %w", err)
+		}
+		return svc.store.DeleteSettings(ctx, SettingsId(from))
+	})
+}
+
+// Authenticate checks the password of the user
+func (svc *UserService) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	u, err := svc.store.FindUser(ctx, username)
+	if errors.Is(err, db.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return u, ErrInvalidCredentials
+	}
+	if err != nil {
+		return u, fmt.Errorf("Authenticate: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return u, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, SESSION_TOKEN_BYTES)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateSession signs the user in and returns the token for the session cookie
+func (svc *UserService) CreateSession(ctx context.Context, username string) (string, models.Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", models.Session{}, fmt.Errorf("CreateSession: %w", err)
+	}
+	now := time.Now()
+	s := models.Session{
+		TokenHash: hashToken(token),
+		Username:  username,
+		Created:   now,
+		Expires:   now.Add(SESSION_DURATION),
+	}
+	if err := svc.store.SaveSession(ctx, s); err != nil {
+		return "", s, fmt.Errorf("CreateSession: %w", err)
+	}
+	svc.cleanupSessions(ctx, now)
+	return token, s, nil
+}
+
+// SessionUser returns the user signed in with the token
+func (svc *UserService) SessionUser(ctx context.Context, token string) (models.User, error) {
+	if token == "" {
+		return models.User{}, ErrInvalidSession
+	}
+	s, err := svc.store.FindSession(ctx, hashToken(token))
+	if errors.Is(err, db.ErrNotFound) {
+		return models.User{}, ErrInvalidSession
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("SessionUser: %w", err)
+	}
+	if s.IsExpired(time.Now()) {
+		return models.User{}, ErrInvalidSession
+	}
+	u, err := svc.store.FindUser(ctx, s.Username)
+	if errors.Is(err, db.ErrNotFound) {
+		return u, ErrInvalidSession
+	}
+	if err != nil {
+		return u, fmt.Errorf("SessionUser: %w", err)
+	}
+	return u, nil
+}
+
+// DeleteSession signs the session out
+func (svc *UserService) DeleteSession(ctx context.Context, token string) error {
+	return svc.store.DeleteSession(ctx, hashToken(token))
+}
+
+// cleanupSessions deletes the expired sessions at most once per SESSION_CLEANUP_PERIOD
+func (svc *UserService) cleanupSessions(ctx context.Context, now time.Time) {
+	svc.cleanup.Lock()
+	defer svc.cleanup.Unlock()
+	if now.Sub(svc.cleanup.last) < SESSION_CLEANUP_PERIOD {
+		return
+	}
+	svc.cleanup.last = now
+	if err := svc.store.DeleteExpiredSessions(ctx, now); err != nil {
+		slog.ErrorContext(ctx, "cleanupSessions: failed to delete expired sessions", "error", err)
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_AuthEnabled_OnceAUserExists(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if enabled, err := svc.Users.AuthEnabled(context.Background()); err != nil || enabled {
+		t.Fatalf("expected auth to be disabled without users, got %v, %v", enabled, err)
+	}
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if enabled, err := svc.Users.AuthEnabled(context.Background()); err != nil || !enabled {
+		t.Errorf("expected auth to be enabled, got %v, %v", enabled, err)
+	}
+}
+
+func Test_SetPassword_Validation(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	for _, tt := range []struct{ username, password string }{
+		{"", "correct horse"},
+		{"  ", "correct horse"},
+		{"alice", "short"},
+	} {
+		if err := svc.Users.SetPassword(context.Background(), tt.username, tt.password); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("SetPassword(%q, %q): expected ErrInvalidUser, got %v", tt.username, tt.password, err)
+		}
+	}
+}
+
+func Test_Authenticate(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+
+	u, err := svc.Users.Authenticate(context.Background(), "alice", "correct horse")
+	if err != nil || u.Username != "alice" {
+		t.Fatalf("expected alice to sign in, got %+v, %v", u, err)
+	}
+	if _, err := svc.Users.Authenticate(context.Background(), "alice", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := svc.Users.Authenticate(context.Background(), "bob", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	if err := svc.Users.SetPassword(context.Background(), "alice", "battery staple"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if _, err := svc.Users.Authenticate(context.Background(), "alice", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("the old password should no longer work, got %v", err)
+	}
+	if _, err := svc.Users.Authenticate(context.Background(), "alice", "battery staple"); err != nil {
+		t.Errorf("the new password should work, got %v", err)
+	}
+}
+
+func Test_Sessions(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+
+	token, _, err := svc.Users.CreateSession(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if u, err := svc.Users.SessionUser(context.Background(), token); err != nil || u.Username != "alice" {
+		t.Fatalf("expected the session of alice, got %+v, %v", u, err)
+	}
+	if _, err := svc.Users.SessionUser(context.Background(), token+"x"); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession for an unknown token, got %v", err)
+	}
+
+	if err := svc.Users.DeleteSession(context.Background(), token); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := svc.Users.SessionUser(context.Background(), token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession after signing out, got %v", err)
+	}
+}
+
+func Test_Sessions_ExpiredAndDeletedUser(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+
+	expired := models.Session{
+		TokenHash: hashToken("expired"),
+		Username:  "alice",
+		Created:   time.Now().Add(-SESSION_DURATION - time.Hour),
+		Expires:   time.Now().Add(-time.Hour),
+	}
+	if err := svc.store.SaveSession(context.Background(), expired); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	if _, err := svc.Users.SessionUser(context.Background(), "expired"); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession for an expired session, got %v", err)
+	}
+
+	token, _, err := svc.Users.CreateSession(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := svc.Users.DeleteUser(context.Background(), "alice", ""); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := svc.Users.SessionUser(context.Background(), token); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession after deleting the user, got %v", err)
+	}
+}
+
+func Test_Accounts_FirstUserAdoptsDataAndLastAdminIsKept(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Tags.SaveTag(context.Background(), "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "Before sign-in"}, []models.TaskTag{"work"}); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	if err := svc.Settings.SetCompletedFilter(context.Background(), "", false); err != nil {
+		t.Fatalf("SetCompletedFilter failed: %v", err)
+	}
+
+	if err := svc.Users.SetPassword(context.Background(), "alice", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	if err := svc.Users.SetPassword(context.Background(), "bob", "correct horse"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	users, err := svc.Users.Users(context.Background())
+	if err != nil || len(users) != 2 || !users[0].Admin || users[1].Admin {
+		t.Fatalf("expected alice to be the only admin, got %+v, %v", users, err)
+	}
+
+	tasks, err := svc.Tasks.FindTasks(context.Background(), models.TasksQuery{Owner: "alice"})
+	if err != nil || len(tasks) != 1 || len(tasks[0].Tags) != 1 {
+		t.Errorf("expected alice to own the task with its tag, got %+v, %v", tasks, err)
+	}
+	if s, err := svc.Settings.FindUserSettings(context.Background(), "alice"); err != nil || s.TasksQuery.FilterCompleted {
+		t.Errorf("expected alice to take over the settings, got %+v, %v", s, err)
+	}
+
+	if err := svc.Users.SetUserAdmin(context.Background(), "alice", false); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin when demoting alice, got %v", err)
+	}
+	if err := svc.Users.DeleteUser(context.Background(), "alice", ""); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin when deleting alice, got %v", err)
+	}
+	if err := svc.Users.SetUserAdmin(context.Background(), "bob", true); err != nil {
+		t.Fatalf("SetUserAdmin failed: %v", err)
+	}
+	if err := svc.Users.DeleteUser(context.Background(), "alice", ""); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	tasks, err = svc.Tasks.FindTasks(context.Background(), models.TasksQuery{Owner: "bob"})
+	if err != nil || len(tasks) != 1 || tasks[0].Owner != "bob" {
+		t.Errorf("expected bob to inherit the task of alice, got %+v, %v", tasks, err)
+	}
+}
@@ -3,13 +3,11 @@ package services
 import (
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"time"
-
-	"github.com/inaryzen/priotasks/common"
 )
 
 const (
@@ -48,6 +46,27 @@ func (s *BackupService) CreateBackup() error {
 	return s.cleanupOldBackups()
 }
 
+// LatestBackup returns the most recent backup file, nil when there is none
+func (s *BackupService) LatestBackup() (os.FileInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(s.baseDir, "priotasks_db_backup_*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup files: %w", err)
+	}
+
+	var latest os.FileInfo
+	for _, file := range matches {
+		info, err := os.Stat(file)
+		if err != nil {
+			// removed by the cleanup in the meantime
+			continue
+		}
+		if latest == nil || info.ModTime().After(latest.ModTime()) {
+			latest = info
+		}
+	}
+	return latest, nil
+}
+
 // cleanupOldBackups ensures only the most recent backup files are kept
 func (s *BackupService) cleanupOldBackups() error {
 	pattern := filepath.Join(s.baseDir, "priotasks_db_backup_*.db")
@@ -71,9 +90,9 @@ func (s *BackupService) cleanupOldBackups() error {
 	for _, file := range matches[maxBackupFiles:] {
 		err := os.Remove(file)
 		if err != nil {
-			common.Debug("Failed to remove old backup %s: %v", file, err)
+			slog.Warn("failed to remove old backup", "file", file, "error", err)
 		} else {
-			log.Printf("removed backup: %v\n", file)
+			slog.Info("removed backup", "file", file)
 		}
 	}
 
@@ -96,7 +115,7 @@ func (s *BackupService) copyFile(src, dst string) error {
 
 	_, err = io.Copy(destination, source)
 	if err == nil {
-		log.Printf("created backup: %v\n", dst)
+		slog.Info("created backup", "file", dst)
 	}
 	return err
 }
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+var (
+	ErrNoVTodo            = errors.New("no VTODO component found")
+	ErrPreconditionFailed = errors.New("the task does not match the preconditions of the request")
+)
+
+// DavPreconditions are the If-Match and If-None-Match headers of a CalDAV request, empty
+// when not sent
+type DavPreconditions struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// Allow evaluates the preconditions against the current resource, whose entity tag is etag
+// when it exists
+func (p DavPreconditions) Allow(exists bool, etag string) bool {
+	if p.IfMatch != "" {
+		if !exists || (p.IfMatch != "*" && !etagListContains(p.IfMatch, etag)) {
+			return false
+		}
+	}
+	if p.IfNoneMatch != "" && exists {
+		if p.IfNoneMatch == "*" || etagListContains(p.IfNoneMatch, etag) {
+			return false
+		}
+	}
+	return true
+}
+
+func etagListContains(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ICalTodo holds the VTODO properties a CalDAV client can change
+type ICalTodo struct {
+	UID         string
+	Summary     string
+	Description string
+	// Priority is the iCalendar priority, 0 means undefined
+	Priority   int
+	Status     string
+	Completed  time.Time
+	Categories []models.TaskTag
+}
+
+// CalDAVETag is the entity tag of the task resource, derived from the last change time
+func CalDAVETag(t models.Task) string {
+	return fmt.Sprintf(`"%d"`, taskStamp(t).Unix())
+}
+
+// CalDAVCTag changes whenever a task is added, changed or removed
+func CalDAVCTag(tasks []models.Task) string {
+	var latest time.Time
+	for _, t := range tasks {
+		if stamp := taskStamp(t); stamp.After(latest) {
+			latest = stamp
+		}
+	}
+	return fmt.Sprintf(`"%d-%d"`, latest.Unix(), len(tasks))
+}
+
+// CalDAVTasks returns every task of the CalDAV collection of the user
+func (svc *TaskService) CalDAVTasks(ctx context.Context, owner string) ([]models.Task, error) {
+	return svc.FindTasks(ctx, models.TasksQuery{SortColumn: models.Created, SortDirection: models.Asc, Owner: owner})
+}
+
+// FindCalDAVTask returns the task of the user with its tags; db.ErrNotFound if it does not exist
+func (svc *TaskService) FindCalDAVTask(ctx context.Context, owner, taskId string) (models.Task, error) {
+	task, err := svc.FindTask(ctx, owner, taskId)
+	if err != nil {
+		return task, err
+	}
+	task.Tags, err = svc.tags.TaskTags(ctx, taskId)
+	if err != nil {
+		return task, fmt.Errorf("FindCalDAVTask: %w", err)
+	}
+	return task, nil
+}
+
+// TaskToICal renders a single task as a VCALENDAR object
+func TaskToICal(t models.Task) ([]byte, error) {
+	var buf bytes.Buffer
+	err := ExportTasksToICal(&buf, []models.Task{t}, ICalOptions{})
+	return buf.Bytes(), err
+}
+
+// SaveCalDAVTask creates the task with the given id or updates it through UpdateTask.
+// Properties without an iCalendar counterpart (impact, cost, fun, planned) are kept.
+// The preconditions are checked against the task read in the transaction, so that a change
+// made since the client read it is not overwritten: ErrPreconditionFailed when they fail.
+// The id of a task of another user is rejected with ErrConflict.
+func (svc *TaskService) SaveCalDAVTask(ctx context.Context, owner, taskId string, todo ICalTodo, pre DavPreconditions) (models.Task, bool, error) {
+	pfx := "SaveCalDAVTask:"
+
+	var task models.Task
+	var created bool
+	err := svc.store.WithTx(ctx, func(ctx context.Context) error {
+		existing, err := svc.FindCalDAVTask(ctx, owner, taskId)
+		created = errors.Is(err, db.ErrNotFound)
+		if err != nil && !created {
+			return fmt.Errorf("%s %w", pfx, err)
+		}
+		if !pre.Allow(!created, CalDAVETag(existing)) {
+			return ErrPreconditionFailed
+		}
+		if created {
+			if _, err := svc.store.FindTask(ctx, taskId); err == nil {
+				return fmt.Errorf("%s the id %s is taken: %w", pfx, taskId, ErrConflict)
+			}
+		}
+
+		if err := svc.tags.ensureTags(ctx, owner, todo.Categories); err != nil {
+			return fmt.Errorf("%s %w", pfx, err)
+		}
+
+		if created {
+			task := MergeVTodo(models.Task{Completed: models.NOT_COMPLETED}, todo).AsNewTask()
+			task.Id = taskId
+			task.Owner = owner
+			if err := svc.SaveTask(ctx, task); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			if err := svc.updateTaskTags(ctx, task.Id, todo.Categories); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			svc.publishTaskSaved(ctx, task)
+			svc.webhooks.fireTaskWebhooks(ctx, nil, task, todo.Categories)
+		} else {
+			changed := MergeVTodo(existing, todo)
+			changed.Owner = owner // the user making the change, the task may be shared with them
+			if err := svc.UpdateTask(ctx, changed, todo.Categories); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+		}
+
+		task, err = svc.FindCalDAVTask(ctx, owner, taskId)
+		if err != nil {
+			return fmt.Errorf("%s %w", pfx, err)
+		}
+		return nil
+	})
+	return task, created, err
+}
+
+// DeleteCalDAVTask deletes the task of the user if it matches the preconditions, which are
+// checked in the same transaction: ErrPreconditionFailed when they fail, db.ErrNotFound
+// when there is no such task
+func (svc *TaskService) DeleteCalDAVTask(ctx context.Context, owner, taskId string, pre DavPreconditions) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		existing, err := svc.FindTask(ctx, owner, taskId)
+		if err != nil {
+			return err
+		}
+		if !pre.Allow(true, CalDAVETag(existing)) {
+			return ErrPreconditionFailed
+		}
+		return svc.DeleteTask(ctx, owner, taskId)
+	})
+}
+
+// MergeVTodo applies the VTODO to the task
+func MergeVTodo(task models.Task, todo ICalTodo) models.Task {
+	task.Title = todo.Summary
+	task.Content = todo.Description
+	if todo.Priority != 0 {
+		task.Priority = taskPriorityFromICal(todo.Priority)
+	}
+
+	switch {
+	case todo.Status == "COMPLETED" || !todo.Completed.IsZero():
+		if !task.IsCompleted() {
+			task.Completed = todo.Completed
+			if task.Completed.IsZero() {
+				task.Completed = time.Now()
+			}
+		}
+	default:
+		task.Completed = models.NOT_COMPLETED
+		task.Wip = todo.Status == "IN-PROCESS"
+	}
+
+	task.Tags = todo.Categories
+	return task
+}
+
+// taskPriorityFromICal maps 1-9 back to the priorities, the inverse of icalPriority
+func taskPriorityFromICal(p int) models.TaskPriority {
+	switch {
+	case p == 1:
+		return models.PriorityUrgent
+	case p <= 4:
+		return models.PriorityHigh
+	case p == 5:
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+// ensureTags creates the tags of the user that do not exist yet
+func (svc *TagService) ensureTags(ctx context.Context, owner string, tags []models.TaskTag) error {
+	existing, err := svc.Tags(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("ensureTags: %w", err)
+	}
+	known := make(map[models.TaskTag]bool, len(existing))
+	for _, tag := range existing {
+		known[tag] = true
+	}
+	for _, tag := range tags {
+		if known[tag] {
+			continue
+		}
+		if err := svc.SaveTag(ctx, owner, tag); err != nil {
+			return fmt.Errorf("ensureTags: %w", err)
+		}
+		known[tag] = true
+	}
+	return nil
+}
+
+// ParseVTodo reads the first VTODO component of an iCalendar object, the floating times
+// being in loc
+func ParseVTodo(data []byte, loc *time.Location) (ICalTodo, error) {
+	var todo ICalTodo
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text)
+
+	found := false
+	depth := 0 // nesting below VTODO, e.g. VALARM
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			continue
+		}
+		name, params, value := parseContentLine(line)
+		if !found {
+			if name == "BEGIN" && strings.EqualFold(value, "VTODO") {
+				found = true
+			}
+			continue
+		}
+		if name == "BEGIN" {
+			depth++
+			continue
+		}
+		if name == "END" {
+			if depth == 0 {
+				return normalizeTodo(todo), nil
+			}
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			todo.UID = value
+		case "SUMMARY":
+			todo.Summary = icalUnescape(value)
+		case "DESCRIPTION":
+			todo.Description = icalUnescape(value)
+		case "PRIORITY":
+			p, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || p < 0 || p > 9 {
+				return todo, fmt.Errorf("ParseVTodo: invalid PRIORITY: %q", value)
+			}
+			todo.Priority = p
+		case "STATUS":
+			todo.Status = strings.ToUpper(strings.TrimSpace(value))
+		case "COMPLETED":
+			t, err := parseICalTime(value, params["TZID"], loc)
+			if err != nil {
+				return todo, fmt.Errorf("ParseVTodo: invalid COMPLETED: %w", err)
+			}
+			todo.Completed = t
+		case "CATEGORIES":
+			for _, c := range splitICalList(value) {
+				if tag := models.TaskTag(strings.TrimSpace(icalUnescape(c))); !tag.IsEmpty() {
+					todo.Categories = append(todo.Categories, tag)
+				}
+			}
+		}
+	}
+	if found {
+		return todo, fmt.Errorf("ParseVTodo: VTODO is not terminated")
+	}
+	return todo, ErrNoVTodo
+}
+
+func normalizeTodo(todo ICalTodo) ICalTodo {
+	var tags []models.TaskTag
+	for _, tag := range todo.Categories {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	todo.Categories = tags
+	return todo
+}
+
+// parseContentLine splits "NAME;PARAM=VALUE:value"; parameter values may be quoted
+func parseContentLine(line string) (string, map[string]string, string) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		key, val, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// splitICalList splits a value list on commas that are not escaped
+func splitICalList(value string) []string {
+	var result []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			result = append(result, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(result, current.String())
+}
+
+var icalUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icalUnescape(s string) string {
+	return icalUnescaper.Replace(s)
+}
+
+// parseICalTime accepts UTC, floating and TZID date-times as well as dates; the floating
+// ones are in loc
+func parseICalTime(value, tzid string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(ICAL_TIME_FORMAT, value)
+	}
+	if tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if len(value) == len("20060102") {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const testVTodo = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Test//Test//EN\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:abc@example.com\r\n" +
+	"SUMMARY:Buy milk\\, eggs\\; and a very long line that has to be folded by the client b\r\n" +
+	" ecause it is long\r\n" +
+	"DESCRIPTION:first\\nsecond\r\n" +
+	"PRIORITY:2\r\n" +
+	"STATUS:COMPLETED\r\n" +
+	"COMPLETED;TZID=Europe/Berlin:20250301T120000\r\n" +
+	"CATEGORIES:home,shop\\,ping\r\n" +
+	"CATEGORIES:home\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"DESCRIPTION:alarm\r\n" +
+	"END:VALARM\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func Test_ParseVTodo(t *testing.T) {
+	todo, err := ParseVTodo([]byte(testVTodo), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseVTodo failed: %v", err)
+	}
+
+	if todo.Summary != "Buy milk, eggs; and a very long line that has to be folded by the client because it is long" {
+		t.Errorf("unexpected summary: %q", todo.Summary)
+	}
+	if todo.Description != "first\nsecond" {
+		t.Errorf("unexpected description, the alarm must not override it: %q", todo.Description)
+	}
+	if todo.Priority != 2 || todo.Status != "COMPLETED" {
+		t.Errorf("unexpected priority or status: %+v", todo)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if !todo.Completed.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected completed time: %v", todo.Completed)
+	}
+	if !slices.Equal(todo.Categories, []models.TaskTag{"home", "shop,ping"}) {
+		t.Errorf("unexpected categories: %v", todo.Categories)
+	}
+
+	if _, err := ParseVTodo([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), time.UTC); !errors.Is(err, ErrNoVTodo) {
+		t.Errorf("expected ErrNoVTodo, got %v", err)
+	}
+}
+
+func Test_ParseVTodo_RoundTrip(t *testing.T) {
+	task := models.Task{
+		Id:        "t1",
+		Title:     "Round, trip; \\ test",
+		Content:   "multi\nline",
+		Created:   time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC),
+		Completed: models.NOT_COMPLETED,
+		Priority:  models.PriorityHigh,
+		Wip:       true,
+		Tags:      []models.TaskTag{"a", "b"},
+	}
+	data, err := TaskToICal(task)
+	if err != nil {
+		t.Fatalf("TaskToICal failed: %v", err)
+	}
+	todo, err := ParseVTodo(data, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseVTodo failed: %v", err)
+	}
+
+	merged := MergeVTodo(models.Task{Completed: models.NOT_COMPLETED}, todo)
+	if merged.Title != task.Title || merged.Content != task.Content || merged.Priority != task.Priority ||
+		!merged.Wip || merged.IsCompleted() || !slices.Equal(merged.Tags, task.Tags) {
+		t.Errorf("round trip changed the task:\nwant %+v\ngot  %+v", task, merged)
+	}
+}
+
+func Test_SaveCalDAVTask(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+
+	created := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	if err := svc.Tasks.SaveTask(context.Background(), models.Task{Id: "t1", Title: "Old", Created: created, Updated: created, Completed: models.NOT_COMPLETED, Impact: models.ImpactHigh, Cost: models.CostL}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	before, _ := svc.Tasks.FindCalDAVTask(context.Background(), "", "t1")
+
+	task, isNew, err := svc.Tasks.SaveCalDAVTask(context.Background(), "", "t1", ICalTodo{Summary: "New", Status: "COMPLETED", Categories: []models.TaskTag{"phone"}}, DavPreconditions{})
+	if err != nil {
+		t.Fatalf("SaveCalDAVTask failed: %v", err)
+	}
+	if isNew {
+		t.Error("existing task reported as created")
+	}
+	if task.Title != "New" || !task.IsCompleted() || !slices.Equal(task.Tags, []models.TaskTag{"phone"}) {
+		t.Errorf("task was not updated: %+v", task)
+	}
+	if task.Impact != models.ImpactHigh || task.Cost != models.CostL {
+		t.Errorf("properties without an iCalendar counterpart were lost: %+v", task)
+	}
+	if CalDAVETag(task) == CalDAVETag(before) {
+		t.Errorf("ETag did not change: %s", CalDAVETag(task))
+	}
+
+	task, isNew, err = svc.Tasks.SaveCalDAVTask(context.Background(), "", "client-chosen", ICalTodo{Summary: "From phone", Categories: []models.TaskTag{"phone"}}, DavPreconditions{})
+	if err != nil {
+		t.Fatalf("SaveCalDAVTask failed: %v", err)
+	}
+	if !isNew || task.Id != "client-chosen" || task.IsCompleted() {
+		t.Errorf("unexpected new task: %+v", task)
+	}
+}
+
+func Test_DavPreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		pre     DavPreconditions
+		exists  bool
+		allowed bool
+	}{
+		{"no headers", DavPreconditions{}, true, true},
+		{"if-match current", DavPreconditions{IfMatch: `"1"`}, true, true},
+		{"if-match stale", DavPreconditions{IfMatch: `"0"`}, true, false},
+		{"if-match list", DavPreconditions{IfMatch: `"0", W/"1"`}, true, true},
+		{"if-match missing resource", DavPreconditions{IfMatch: "*"}, false, false},
+		{"if-none-match create", DavPreconditions{IfNoneMatch: "*"}, false, true},
+		{"if-none-match existing", DavPreconditions{IfNoneMatch: "*"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pre.Allow(tt.exists, `"1"`); got != tt.allowed {
+				t.Errorf("Allow() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func Test_SaveCalDAVTask_StaleETag(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ctx := context.Background()
+
+	created := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	if err := svc.Tasks.SaveTask(ctx, models.Task{Id: "t1", Title: "Old", Created: created, Updated: created, Completed: models.NOT_COMPLETED}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	synced, _ := svc.Tasks.FindCalDAVTask(ctx, "", "t1")
+	stale := DavPreconditions{IfMatch: CalDAVETag(synced)}
+
+	// the task is edited on the web after the client read it
+	edited := synced
+	edited.Title = "Edited on the web"
+	if err := svc.Tasks.UpdateTask(ctx, edited, nil); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+
+	_, _, err := svc.Tasks.SaveCalDAVTask(ctx, "", "t1", ICalTodo{Summary: "From phone"}, stale)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expected the stale ETag to fail, got %v", err)
+	}
+	if err := svc.Tasks.DeleteCalDAVTask(ctx, "", "t1", stale); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expected the stale ETag to fail the deletion, got %v", err)
+	}
+	task, _ := svc.Tasks.FindCalDAVTask(ctx, "", "t1")
+	if task.Title != "Edited on the web" {
+		t.Errorf("the web edit was overwritten: %+v", task)
+	}
+
+	current := DavPreconditions{IfMatch: CalDAVETag(task)}
+	if _, _, err := svc.Tasks.SaveCalDAVTask(ctx, "", "t1", ICalTodo{Summary: "From phone"}, current); err != nil {
+		t.Fatalf("SaveCalDAVTask with the current ETag failed: %v", err)
+	}
+	task, _ = svc.Tasks.FindCalDAVTask(ctx, "", "t1")
+	if err := svc.Tasks.DeleteCalDAVTask(ctx, "", "t1", DavPreconditions{IfMatch: CalDAVETag(task)}); err != nil {
+		t.Fatalf("DeleteCalDAVTask with the current ETag failed: %v", err)
+	}
+	if _, err := svc.Tasks.FindCalDAVTask(ctx, "", "t1"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected the task to be deleted, got %v", err)
+	}
+}
+
+// failingTagDB fails to tag the tasks, after the task and its tags were saved
+type failingTagDB struct {
+	*db.MemDB
+}
+
+func (d failingTagDB) AddTagToTask(ctx context.Context, taskId, tagId string) error {
+	return fmt.Errorf("database connection error")
+}
+
+func Test_SaveCalDAVTask_RollsBackOnTagFailure(t *testing.T) {
+	t.Parallel()
+	svc := New(failingTagDB{db.NewMemDB()})
+	ctx := context.Background()
+
+	_, _, err := svc.Tasks.SaveCalDAVTask(ctx, "", "from-phone", ICalTodo{Summary: "From phone", Categories: []models.TaskTag{"phone"}}, DavPreconditions{})
+	if err == nil {
+		t.Fatal("expected the tag step to fail")
+	}
+	if tasks, err := svc.store.Tasks(ctx); err != nil || len(tasks) != 0 {
+		t.Errorf("expected no task to be left behind, got %v %v", tasks, err)
+	}
+	if tags, err := svc.Tags.Tags(ctx, ""); err != nil || len(tags) != 0 {
+		t.Errorf("expected no tag to be left behind, got %v %v", tags, err)
+	}
+}
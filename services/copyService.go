@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+var ErrCopyTargetHasUsers = errors.New("the target database already contains users")
+
+// CopyStats counts the records copied
+type CopyStats struct {
+	Users      int
+	ApiTokens  int
+	Tags       int
+	Projects   int
+	Tasks      int
+	TaskTags   int
+	Settings   int
+	Webhooks   int
+	Deliveries int
+}
+
+// CopyTo copies the whole database into the store of target, e.g. from SQLite to PostgreSQL:
+// the users with their api tokens, the data of all users, the webhooks and their delivery
+// log. The sessions are not copied, the users sign in again. The target must be empty, a
+// copy that fails leaves it empty.
+func (svc *Services) CopyTo(ctx context.Context, target *Services) (CopyStats, error) {
+	pfx := "CopyTo:"
+	var stats CopyStats
+
+	if err := target.ensureEmptyDatabase(ctx); err != nil {
+		return stats, fmt.Errorf("%s %w", pfx, err)
+	}
+	if count, err := target.store.CountUsers(ctx); err != nil {
+		return stats, fmt.Errorf("%s %w", pfx, err)
+	} else if count > 0 {
+		return stats, fmt.Errorf("%s %w", pfx, ErrCopyTargetHasUsers)
+	}
+
+	from, to := svc.store, target.store
+	// the source is read with ctx, outside of the transaction of the target
+	err := to.WithTx(ctx, func(txCtx context.Context) error {
+		users, err := from.Users(ctx)
+		if err != nil {
+			return fmt.Errorf("%s failed to read users: %w", pfx, err)
+		}
+		for _, u := range users {
+			if err := to.SaveUser(txCtx, u); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			stats.Users++
+			tokens, err := from.ApiTokens(ctx, u.Username)
+			if err != nil {
+				return fmt.Errorf("%s failed to read api tokens: %w", pfx, err)
+			}
+			for _, token := range tokens {
+				if err := to.SaveApiToken(txCtx, token); err != nil {
+					return fmt.Errorf("%s %w", pfx, err)
+				}
+				stats.ApiTokens++
+			}
+		}
+
+		err = from.ForEachTag(ctx, func(tag models.TagRecord) error {
+			stats.Tags++
+			return to.SaveTagRecord(txCtx, tag)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to copy tags: %w", pfx, err)
+		}
+
+		projects, err := from.AllProjects(ctx)
+		if err != nil {
+			return fmt.Errorf("%s failed to read projects: %w", pfx, err)
+		}
+		for _, p := range projects {
+			if err := to.SaveProject(txCtx, p); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			stats.Projects++
+		}
+
+		err = from.ForEachTask(ctx, func(task models.Task) error {
+			stats.Tasks++
+			return to.SaveTask(txCtx, task)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to copy tasks: %w", pfx, err)
+		}
+
+		err = from.ForEachTaskTag(ctx, func(taskId string, tag models.TaskTag) error {
+			stats.TaskTags++
+			return to.AddTagToTask(txCtx, taskId, string(tag))
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to copy task tags: %w", pfx, err)
+		}
+
+		allSettings, err := from.AllSettings(ctx)
+		if err != nil {
+			return fmt.Errorf("%s failed to read settings: %w", pfx, err)
+		}
+		for _, s := range allSettings {
+			if err := to.SaveSettings(txCtx, s); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			stats.Settings++
+		}
+
+		webhooks, err := from.Webhooks(ctx)
+		if err != nil {
+			return fmt.Errorf("%s failed to read webhooks: %w", pfx, err)
+		}
+		for _, h := range webhooks {
+			if err := to.SaveWebhook(txCtx, h); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			stats.Webhooks++
+		}
+
+		// the ids of the log are assigned again, the oldest attempts are saved first to keep the order
+		deliveries, err := from.WebhookDeliveries(ctx, -1)
+		if err != nil {
+			return fmt.Errorf("%s failed to read webhook deliveries: %w", pfx, err)
+		}
+		for _, delivery := range slices.Backward(deliveries) {
+			if err := to.SaveWebhookDelivery(txCtx, delivery); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+			stats.Deliveries++
+		}
+		return nil
+	})
+	if err != nil {
+		return CopyStats{}, err
+	}
+
+	slog.InfoContext(ctx, pfx, "stats", stats)
+	return stats, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+// populateCopySource adds the users, their tokens and the webhooks to the data of the dumps
+func populateCopySource(t *testing.T, svc *Services) {
+	ctx := context.Background()
+	populateDumpSource(t, svc)
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, u := range []models.User{
+		{Username: "alice", PasswordHash: "hash-a", Created: created, Admin: true},
+		{Username: "bob", PasswordHash: "hash-b", Created: created.Add(time.Hour)},
+	} {
+		if err := svc.store.SaveUser(ctx, u); err != nil {
+			t.Fatalf("SaveUser failed: %v", err)
+		}
+	}
+	token := models.ApiToken{Id: "token-1", Name: "cli", TokenHash: "token-hash", Scope: models.ApiTokenReadWrite, Username: "alice", Created: created.Local()}
+	if err := svc.store.SaveApiToken(ctx, token); err != nil {
+		t.Fatalf("SaveApiToken failed: %v", err)
+	}
+	hook := models.Webhook{Id: "hook-1", Url: "https://example.com/hook", Events: []models.WebhookEvent{models.WebhookTaskCreated}, Tags: []models.TaskTag{"work"}, Created: created}
+	if err := svc.store.SaveWebhook(ctx, hook); err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		delivery := models.WebhookDelivery{DeliveryId: "delivery-1", WebhookId: hook.Id, Url: hook.Url, Event: models.WebhookTaskCreated, TaskId: "task-", Attempt: attempt, StatusCode: 500 - attempt*150, Created: created.Add(time.Duration(attempt) * time.Minute)}
+		if err := svc.store.SaveWebhookDelivery(ctx, delivery); err != nil {
+			t.Fatalf("SaveWebhookDelivery failed: %v", err)
+		}
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source := setupSQLiteDB(t)
+	populateCopySource(t, source)
+	target := setupMemDB(t)
+
+	stats, err := source.CopyTo(ctx, target)
+	if err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	want := CopyStats{Users: 2, ApiTokens: 1, Tags: 2, Projects: 1, Tasks: 25, TaskTags: 22, Settings: 1, Webhooks: 1, Deliveries: 2}
+	if stats != want {
+		t.Errorf("expected stats %+v, got %+v", want, stats)
+	}
+
+	var first, second bytes.Buffer
+	if _, err := source.ExportDump(ctx, &first, DumpJSON); err != nil {
+		t.Fatalf("ExportDump failed: %v", err)
+	}
+	if _, err := target.ExportDump(ctx, &second, DumpJSON); err != nil {
+		t.Fatalf("ExportDump failed: %v", err)
+	}
+	if dumpBody(first.Bytes(), DumpJSON) != dumpBody(second.Bytes(), DumpJSON) {
+		t.Errorf("the copy differs from the source:\n%s\n----\n%s", first.String(), second.String())
+	}
+
+	for _, read := range []struct {
+		name string
+		fn   func(store db.Db) (any, error)
+	}{
+		{"users", func(store db.Db) (any, error) { return store.Users(ctx) }},
+		{"api tokens", func(store db.Db) (any, error) { return store.ApiTokens(ctx, "alice") }},
+		{"webhooks", func(store db.Db) (any, error) { return store.Webhooks(ctx) }},
+		{"webhook deliveries", func(store db.Db) (any, error) { return store.WebhookDeliveries(ctx, -1) }},
+	} {
+		got, err := read.fn(target.store)
+		if err != nil {
+			t.Fatalf("failed to read the copied %s: %v", read.name, err)
+		}
+		expected, err := read.fn(source.store)
+		if err != nil {
+			t.Fatalf("failed to read the %s: %v", read.name, err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected the %s %+v, got %+v", read.name, expected, got)
+		}
+	}
+}
+
+func TestCopyTo_RequiresEmptyTarget(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source := setupMemDB(t)
+	if err := source.store.SaveUser(ctx, models.User{Username: "alice"}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	withTasks := setupMemDB(t)
+	if err := withTasks.store.SaveTask(ctx, models.Task{Id: "1"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	if _, err := source.CopyTo(ctx, withTasks); !errors.Is(err, ErrDumpNotEmpty) {
+		t.Errorf("expected ErrDumpNotEmpty, got %v", err)
+	}
+
+	withUsers := setupMemDB(t)
+	if err := withUsers.store.SaveUser(ctx, models.User{Username: "bob"}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	if _, err := source.CopyTo(ctx, withUsers); !errors.Is(err, ErrCopyTargetHasUsers) {
+		t.Errorf("expected ErrCopyTargetHasUsers, got %v", err)
+	}
+	if users, _ := withUsers.store.Users(ctx); len(users) != 1 {
+		t.Errorf("expected the target to be left as it was, got %v", users)
+	}
+}
+
+func TestCopyTo_RollsBackOnFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source := setupMemDB(t)
+	if err := source.store.SaveTask(ctx, models.Task{Id: "1"}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	if err := source.store.SaveTag(ctx, "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if err := source.store.AddTagToTask(ctx, "1", "work"); err != nil {
+		t.Fatalf("AddTagToTask failed: %v", err)
+	}
+	// the task is copied, but its tag cannot be
+	if err := source.store.DeleteTag(ctx, "", "work"); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+
+	target := setupMemDB(t)
+	if _, err := source.CopyTo(ctx, target); err == nil {
+		t.Fatal("expected the tag of the task to fail the copy")
+	}
+	if err := target.ensureEmptyDatabase(ctx); err != nil {
+		t.Errorf("expected the target to stay empty, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const CSV_TAGS_SEPARATOR = ";"
+
+var csvColumns = []string{"id", "title", "content", "created", "updated", "completed", "priority", "impact", "cost", "fun", "wip", "planned", "value", "tags"}
+
+// ExportTasksToCSV writes the tasks with human-readable enum values and tags joined by CSV_TAGS_SEPARATOR
+func ExportTasksToCSV(w io.Writer, tasks []models.Task) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return fmt.Errorf("ExportTasksToCSV: failed to write header: %w", err)
+	}
+
+	for _, t := range tasks {
+		completed := ""
+		if t.IsCompleted() {
+			completed = t.Completed.Format(consts.DEFAULT_TIME_FORMAT)
+		}
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = string(tag)
+		}
+		record := []string{
+			t.Id,
+			t.Title,
+			t.Content,
+			t.Created.Format(consts.DEFAULT_TIME_FORMAT),
+			t.Updated.Format(consts.DEFAULT_TIME_FORMAT),
+			completed,
+			t.Priority.Name(),
+			t.Impact.Name(),
+			t.Cost.Name(),
+			t.Fun.Name(),
+			strconv.FormatBool(t.Wip),
+			strconv.FormatBool(t.Planned),
+			strconv.FormatFloat(float64(t.Value), 'f', 2, 32),
+			strings.Join(tags, CSV_TAGS_SEPARATOR),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("ExportTasksToCSV: failed to write task %s: %w", t.Id, err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("ExportTasksToCSV: %w", err)
+	}
+	return nil
+}
+
+// ImportTasksFromCSV upserts tasks from CSV. The header row names the columns, matched
+// case-insensitively against the export columns; only title or content is required.
+// Rows that fail validation are reported as conflicts with their row number. The times
+// without a time zone are read in the time zone of the user.
+func (svc *TaskService) ImportTasksFromCSV(ctx context.Context, owner string, data []byte, dryRun bool) (models.ImportReport, error) {
+	report := models.ImportReport{DryRun: dryRun}
+	loc, err := svc.settings.Location(ctx, owner)
+	if err != nil {
+		return report, fmt.Errorf("failed to find the time zone: %w", err)
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return report, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		if _, ok := columns["content"]; !ok {
+			return report, fmt.Errorf("the CSV header must contain a title or content column")
+		}
+	}
+
+	var tasks []models.Task
+	var rowErrors []models.ImportConflict
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read the CSV row %d: %w", row, err)
+		}
+
+		task, err := taskFromCSVRecord(record, columns, loc)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportConflict{
+				TaskId: task.Id,
+				Title:  task.Title,
+				Reason: fmt.Sprintf("row %d: %v", row, err),
+			})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	report, err = svc.importTasks(ctx, owner, tasks, dryRun)
+	report.Conflicts = append(rowErrors, report.Conflicts...)
+	return report, err
+}
+
+func taskFromCSVRecord(record []string, columns map[string]int, loc *time.Location) (models.Task, error) {
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	task := models.EMPTY_TASK
+	task.Id = value("id")
+	task.Title = value("title")
+	task.Content = value("content")
+
+	var errs []string
+	parseTime := func(name string) time.Time {
+		str := value(name)
+		if str == "" {
+			return time.Time{}
+		}
+		t, err := parseImportTime(str, loc)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %q", name, str))
+		}
+		return t
+	}
+	task.Created = parseTime("created")
+	task.Updated = parseTime("updated")
+	task.Completed = parseTime("completed")
+
+	parseBool := func(name string) bool {
+		str := value(name)
+		if str == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(strings.ToLower(str))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %q", name, str))
+		}
+		return b
+	}
+	task.Wip = parseBool("wip")
+	task.Planned = parseBool("planned")
+
+	var err error
+	if str := value("priority"); str != "" {
+		if task.Priority, err = models.ParseTaskPriority(str); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if str := value("impact"); str != "" {
+		if task.Impact, err = models.ParseTaskImpact(str); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if str := value("cost"); str != "" {
+		if task.Cost, err = models.ParseTaskCost(str); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if str := value("fun"); str != "" {
+		if task.Fun, err = models.ParseTaskFun(str); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	for _, tag := range strings.Split(value("tags"), CSV_TAGS_SEPARATOR) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			task.Tags = append(task.Tags, models.TaskTag(tag))
+		}
+	}
+
+	if len(errs) > 0 {
+		return task, errors.New(strings.Join(errs, "; "))
+	}
+	return task, nil
+}
+
+// parseImportTime accepts the time formats used by the exports, the times without a time
+// zone being in loc
+func parseImportTime(str string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{consts.DEFAULT_TIME_FORMAT, time.RFC3339, consts.DEFAULT_DATE_FORMAT} {
+		if t, err := time.ParseInLocation(layout, str, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format: %q", str)
+}
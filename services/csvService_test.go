@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_ExportTasksToCSV_HumanReadable(t *testing.T) {
+	tasks := []models.Task{
+		{
+			Id:       "1",
+			Title:    "Comma, \"quoted\" title",
+			Created:  time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			Priority: models.PriorityHigh,
+			Impact:   models.ImpactLow,
+			Cost:     models.CostL,
+			Fun:      models.FunXL,
+			Tags:     []models.TaskTag{"work", "urgent"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportTasksToCSV(&buf, tasks); err != nil {
+		t.Fatalf("ExportTasksToCSV failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, expected := range []string{
+		"id,title,content,created",
+		`"Comma, ""quoted"" title"`,
+		"2025-01-01 12:00:00",
+		",High,Low,L (~2h),XL,",
+		"work;urgent",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("CSV output missing %q:\n%s", expected, out)
+		}
+	}
+}
+
+func Test_ImportTasksFromCSV_RoundTrip(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+	tasks := []models.Task{
+		{
+			Id:        "1",
+			Title:     "First",
+			Content:   "multi\nline",
+			Created:   time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			Updated:   time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC),
+			Completed: time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC),
+			Priority:  models.PriorityUrgent,
+			Impact:    models.ImpactHigh,
+			Cost:      models.CostXXL,
+			Fun:       models.FunS,
+			Planned:   true,
+			Tags:      []models.TaskTag{"work", "urgent"},
+		},
+	}
+	// the times are exported and imported in the time zone of the user
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load the time zone: %v", err)
+	}
+	svc.SetTimeZone(newYork)
+	var buf bytes.Buffer
+	if err := ExportTasksToCSV(&buf, models.TasksIn(tasks, newYork)); err != nil {
+		t.Fatalf("ExportTasksToCSV failed: %v", err)
+	}
+
+	report, err := svc.Tasks.ImportTasksFromCSV(context.Background(), "", buf.Bytes(), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromCSV failed: %v", err)
+	}
+	if len(report.Created) != 1 || len(report.Conflicts) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	got := mockDB.tasks["1"]
+	want := tasks[0]
+	if got.Title != want.Title || got.Content != want.Content || !got.Completed.Equal(want.Completed) ||
+		got.Priority != want.Priority || got.Impact != want.Impact || got.Cost != want.Cost ||
+		got.Fun != want.Fun || got.Planned != want.Planned || got.Wip != want.Wip {
+		t.Errorf("imported task differs:\n got=%+v\nwant=%+v", got, want)
+	}
+	if len(mockDB.taskTags["1"]) != 2 || !mockDB.tags["urgent"] {
+		t.Errorf("tags were not created: tags=%v taskTags=%v", mockDB.tags, mockDB.taskTags)
+	}
+}
+
+func Test_ImportTasksFromCSV_RowErrors(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+	data := "Title,Priority,Cost,WIP,Completed,Tags\n" +
+		"Good,High,S,true,,a; b\n" +
+		"Bad priority,Whenever,S,false,,\n" +
+		"Bad date,Low,M,maybe,yesterday,\n" +
+		",Low,M,false,,\n"
+
+	report, err := svc.Tasks.ImportTasksFromCSV(context.Background(), "", []byte(data), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromCSV failed: %v", err)
+	}
+	if len(report.Created) != 1 {
+		t.Errorf("expected 1 created task, got %d", len(report.Created))
+	}
+	if len(report.Conflicts) != 3 {
+		t.Fatalf("expected 3 row errors, got %+v", report.Conflicts)
+	}
+	if !strings.HasPrefix(report.Conflicts[0].Reason, "row 3:") || !strings.Contains(report.Conflicts[0].Reason, "priority") {
+		t.Errorf("unexpected reason: %v", report.Conflicts[0].Reason)
+	}
+	if !strings.Contains(report.Conflicts[1].Reason, "wip") || !strings.Contains(report.Conflicts[1].Reason, "completed") {
+		t.Errorf("unexpected reason: %v", report.Conflicts[1].Reason)
+	}
+	if len(mockDB.tasks) != 1 || len(mockDB.tags) != 2 {
+		t.Errorf("unexpected db state: tasks=%d tags=%v", len(mockDB.tasks), mockDB.tags)
+	}
+}
+
+func Test_ImportTasksFromCSV_MissingColumns(t *testing.T) {
+	t.Parallel()
+	svc, _ := setupTestDB()
+	if _, err := svc.Tasks.ImportTasksFromCSV(context.Background(), "", []byte("priority,cost\nHigh,S\n"), true); err == nil {
+		t.Error("expected an error when neither title nor content column is present")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inaryzen/priotasks/models"
+)
+
+// demoTask is a sample task of the demo mode, its times are days before the seeding
+type demoTask struct {
+	title        string
+	content      string
+	priority     models.TaskPriority
+	impact       models.TaskImpact
+	cost         models.TaskCost
+	fun          models.TaskFun
+	wip          bool
+	planned      bool
+	createdAgo   int
+	completedAgo int // open when negative
+	tags         []models.TaskTag
+}
+
+var demoTags = []models.TaskTag{"learning", "errands", "home", "work"}
+
+var demoTasks = []demoTask{
+	{"Prepare the quarterly report", "Collect the numbers of the three teams and draft the summary.", models.PriorityHigh, models.ImpactHigh, models.CostL, models.FunS, true, false, 6, -1, []models.TaskTag{"work"}},
+	{"Migrate the CI pipeline", "Move the build to the new runners before the old ones are switched off.", models.PriorityUrgent, models.ImpactHigh, models.CostXL, models.FunS, true, true, 4, -1, []models.TaskTag{"work"}},
+	{"Review the pull requests", "Two of them are waiting since last week.", models.PriorityMedium, models.ImpactConsiderable, models.CostS, models.FunM, true, false, 2, -1, []models.TaskTag{"work"}},
+	{"Book the dentist appointment", "", models.PriorityUrgent, models.ImpactModerate, models.CostXS, models.FunS, false, true, 1, -1, []models.TaskTag{"home"}},
+	{"Plan the summer holiday", "Compare the trains and the flights, ask the others about the dates.", models.PriorityLow, models.ImpactConsiderable, models.CostM, models.FunXL, false, true, 10, -1, []models.TaskTag{"home"}},
+	{"Fix the leaking tap", "The spare washers are in the toolbox.", models.PriorityHigh, models.ImpactModerate, models.CostS, models.FunS, false, false, 3, -1, []models.TaskTag{"home"}},
+	{"Buy groceries", "Milk, bread, apples, coffee.", models.PriorityMedium, models.ImpactLow, models.CostXS, models.FunM, false, false, 0, -1, []models.TaskTag{"errands"}},
+	{"Learn the generics of Go", "Work through the tutorial and rewrite the helpers of the side project.", models.PriorityLow, models.ImpactModerate, models.CostL, models.FunL, false, false, 14, -1, []models.TaskTag{"learning"}},
+	{"Renew the passport", "", models.PriorityMedium, models.ImpactHigh, models.CostS, models.FunS, false, false, 20, 5, []models.TaskTag{"errands"}},
+	{"Set up the new laptop", "Install the tools and copy the dotfiles.", models.PriorityHigh, models.ImpactConsiderable, models.CostM, models.FunL, false, false, 9, 1, []models.TaskTag{"work"}},
+	{"Read The Pragmatic Programmer", "", models.PriorityLow, models.ImpactLow, models.CostXL, models.FunXL, false, false, 30, 12, []models.TaskTag{"learning"}},
+	{"Call grandma", "", models.PriorityMedium, models.ImpactModerate, models.CostXS, models.FunL, false, false, 2, 0, nil},
+}
+
+// SeedDemo fills the empty store of the demo mode with sample tags and tasks, open and
+// completed ones, of the anonymous user
+func (svc *Services) SeedDemo(ctx context.Context) error {
+	now := time.Now()
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		for _, tag := range demoTags {
+			if err := svc.tags.SaveTag(ctx, "", tag); err != nil {
+				return fmt.Errorf("SeedDemo: %w", err)
+			}
+		}
+		for _, d := range demoTasks {
+			task := models.Task{
+				Id:        uuid.NewString(),
+				Title:     d.title,
+				Content:   d.content,
+				Created:   now.AddDate(0, 0, -d.createdAgo),
+				Completed: models.NOT_COMPLETED,
+				Priority:  d.priority,
+				Impact:    d.impact,
+				Cost:      d.cost,
+				Fun:       d.fun,
+				Wip:       d.wip,
+				Planned:   d.planned,
+			}
+			task.Updated = task.Created
+			if d.completedAgo >= 0 {
+				task.Completed = now.AddDate(0, 0, -d.completedAgo)
+				task.Updated = task.Completed
+			}
+			if err := svc.tasks.SaveTask(ctx, task); err != nil {
+				return fmt.Errorf("SeedDemo: %w", err)
+			}
+			for _, tag := range d.tags {
+				if err := svc.tags.AddTagToTask(ctx, task.Id, tag); err != nil {
+					return fmt.Errorf("SeedDemo: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
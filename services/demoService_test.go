@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestSeedDemo(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ctx := context.Background()
+	if err := svc.SeedDemo(ctx); err != nil {
+		t.Fatalf("SeedDemo failed: %v", err)
+	}
+
+	counts, err := svc.store.TaskCounts(ctx)
+	if err != nil {
+		t.Fatalf("TaskCounts failed: %v", err)
+	}
+	if counts.Open == 0 || counts.Completed == 0 || counts.Wip == 0 || counts.Planned == 0 {
+		t.Errorf("expected tasks in every state, got %+v", counts)
+	}
+	if counts.Open+counts.Completed != len(demoTasks) {
+		t.Errorf("expected %d tasks, got %+v", len(demoTasks), counts)
+	}
+
+	tags, err := svc.Tags.Tags(ctx, "")
+	if err != nil || len(tags) != len(demoTags) {
+		t.Fatalf("expected the sample tags, got %v %v", tags, err)
+	}
+	// the sample data is shown by the default query of the task table
+	work, err := svc.Tasks.FindTasks(ctx, models.TasksQuery{FilterCompleted: true, Tags: []models.TaskTag{"work"}})
+	if err != nil {
+		t.Fatalf("FindTasks failed: %v", err)
+	}
+	if len(work) != 3 {
+		t.Errorf("expected the 3 open tasks of work, got %d", len(work))
+	}
+	for _, task := range work {
+		if task.Value == 0 {
+			t.Errorf("expected the value of %q to be calculated", task.Title)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+	"gopkg.in/yaml.v3"
+)
+
+// A dump is a stream of records, the first one being the header. JSON dumps hold one record
+// per line, YAML dumps one record per document, so both can be written and read without
+// loading the whole database into memory.
+const (
+	DUMP_FORMAT_NAME    = "priotasks-dump"
+	DUMP_FORMAT_VERSION = 1
+
+	DUMP_KIND_HEADER   = "header"
+	DUMP_KIND_TAG      = "tag"
+	DUMP_KIND_TASK     = "task"
+	DUMP_KIND_TASK_TAG = "task-tag"
+	DUMP_KIND_SETTINGS = "settings"
+	DUMP_KIND_PROJECT  = "project"
+)
+
+var (
+	ErrDumpNotEmpty      = errors.New("the database already contains tasks, tags or projects")
+	ErrDumpInvalidHeader = errors.New("the dump does not start with a valid header")
+)
+
+type DumpEncoding string
+
+const (
+	DumpJSON DumpEncoding = "json"
+	DumpYAML DumpEncoding = "yaml"
+)
+
+// DumpEncodingFromFileName resolves the encoding from the file extension
+func DumpEncodingFromFileName(name string) (DumpEncoding, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".jsonl", ".ndjson":
+		return DumpJSON, nil
+	case ".yaml", ".yml":
+		return DumpYAML, nil
+	default:
+		return "", fmt.Errorf("unknown dump file extension: %q", name)
+	}
+}
+
+type DumpHeader struct {
+	Format   string    `json:"format" yaml:"format"`
+	Version  int       `json:"version" yaml:"version"`
+	Exported time.Time `json:"exported" yaml:"exported"`
+}
+
+type DumpTaskTag struct {
+	TaskId string         `json:"taskId" yaml:"taskId"`
+	Tag    models.TaskTag `json:"tag" yaml:"tag"`
+}
+
+type DumpRecord struct {
+	Kind     string            `json:"kind" yaml:"kind"`
+	Header   *DumpHeader       `json:"header,omitempty" yaml:"header,omitempty"`
+	Tag      *models.TagRecord `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Task     *models.Task      `json:"task,omitempty" yaml:"task,omitempty"`
+	TaskTag  *DumpTaskTag      `json:"taskTag,omitempty" yaml:"taskTag,omitempty"`
+	Settings *models.Settings  `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Project  *models.Project   `json:"project,omitempty" yaml:"project,omitempty"`
+}
+
+// DumpStats counts the records written or read
+type DumpStats struct {
+	Tags     int
+	Tasks    int
+	TaskTags int
+	Settings int
+	Projects int
+}
+
+type dumpEncoder interface {
+	Encode(v any) error
+}
+
+type dumpDecoder interface {
+	Decode(v any) error
+}
+
+// ExportDump writes the whole database: tags, projects, tasks, task-tag links and settings of all users
+func (svc *Services) ExportDump(ctx context.Context, w io.Writer, encoding DumpEncoding) (DumpStats, error) {
+	pfx := "ExportDump:"
+	var stats DumpStats
+
+	var enc dumpEncoder
+	switch encoding {
+	case DumpJSON:
+		enc = json.NewEncoder(w)
+	case DumpYAML:
+		yamlEnc := yaml.NewEncoder(w)
+		defer yamlEnc.Close()
+		enc = yamlEnc
+	default:
+		return stats, fmt.Errorf("%s unknown encoding: %q", pfx, encoding)
+	}
+
+	header := DumpHeader{Format: DUMP_FORMAT_NAME, Version: DUMP_FORMAT_VERSION, Exported: time.Now().UTC()}
+	if err := enc.Encode(DumpRecord{Kind: DUMP_KIND_HEADER, Header: &header}); err != nil {
+		return stats, fmt.Errorf("%s failed to write header: %w", pfx, err)
+	}
+
+	err := svc.store.ForEachTag(ctx, func(tag models.TagRecord) error {
+		stats.Tags++
+		return enc.Encode(DumpRecord{Kind: DUMP_KIND_TAG, Tag: &tag})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s failed to write tags: %w", pfx, err)
+	}
+
+	projects, err := svc.store.AllProjects(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("%s failed to read projects: %w", pfx, err)
+	}
+	for _, p := range projects {
+		stats.Projects++
+		if err := enc.Encode(DumpRecord{Kind: DUMP_KIND_PROJECT, Project: &p}); err != nil {
+			return stats, fmt.Errorf("%s failed to write projects: %w", pfx, err)
+		}
+	}
+
+	err = svc.store.ForEachTask(ctx, func(task models.Task) error {
+		stats.Tasks++
+		return enc.Encode(DumpRecord{Kind: DUMP_KIND_TASK, Task: &task})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s failed to write tasks: %w", pfx, err)
+	}
+
+	err = svc.store.ForEachTaskTag(ctx, func(taskId string, tag models.TaskTag) error {
+		stats.TaskTags++
+		return enc.Encode(DumpRecord{Kind: DUMP_KIND_TASK_TAG, TaskTag: &DumpTaskTag{TaskId: taskId, Tag: tag}})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s failed to write task tags: %w", pfx, err)
+	}
+
+	allSettings, err := svc.store.AllSettings(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("%s failed to read settings: %w", pfx, err)
+	}
+	for _, s := range allSettings {
+		stats.Settings++
+		if err := enc.Encode(DumpRecord{Kind: DUMP_KIND_SETTINGS, Settings: &s}); err != nil {
+			return stats, fmt.Errorf("%s failed to write settings: %w", pfx, err)
+		}
+	}
+
+	slog.InfoContext(ctx, pfx, "stats", stats)
+	return stats, nil
+}
+
+// ImportDump restores a dump produced by ExportDump. The target database must not contain
+// any tasks or tags; records are stored as they are, without recalculating values. A dump
+// that fails to import leaves the database empty.
+func (svc *Services) ImportDump(ctx context.Context, r io.Reader, encoding DumpEncoding) (DumpStats, error) {
+	pfx := "ImportDump:"
+	var stats DumpStats
+
+	var dec dumpDecoder
+	switch encoding {
+	case DumpJSON:
+		dec = json.NewDecoder(r)
+	case DumpYAML:
+		dec = yaml.NewDecoder(r)
+	default:
+		return stats, fmt.Errorf("%s unknown encoding: %q", pfx, encoding)
+	}
+
+	if err := svc.ensureEmptyDatabase(ctx); err != nil {
+		return stats, fmt.Errorf("%s %w", pfx, err)
+	}
+
+	var header DumpRecord
+	if err := dec.Decode(&header); err != nil {
+		return stats, fmt.Errorf("%s %w: %w", pfx, ErrDumpInvalidHeader, err)
+	}
+	if header.Kind != DUMP_KIND_HEADER || header.Header == nil || header.Header.Format != DUMP_FORMAT_NAME {
+		return stats, fmt.Errorf("%s %w", pfx, ErrDumpInvalidHeader)
+	}
+	if header.Header.Version > DUMP_FORMAT_VERSION {
+		return stats, fmt.Errorf("%s unsupported dump version %d, max supported is %d", pfx, header.Header.Version, DUMP_FORMAT_VERSION)
+	}
+
+	owners := make(map[string]bool)
+	err := svc.store.WithTx(ctx, func(ctx context.Context) error {
+		for {
+			var rec DumpRecord
+			err := dec.Decode(&rec)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%s failed to decode record %d: %w", pfx, stats.total()+1, err)
+			}
+
+			switch {
+			case rec.Kind == DUMP_KIND_TAG && rec.Tag != nil:
+				err = svc.store.SaveTagRecord(ctx, *rec.Tag)
+				owners[rec.Tag.Owner] = true
+				stats.Tags++
+			case rec.Kind == DUMP_KIND_PROJECT && rec.Project != nil:
+				err = svc.store.SaveProject(ctx, *rec.Project)
+				stats.Projects++
+			case rec.Kind == DUMP_KIND_TASK && rec.Task != nil:
+				err = svc.store.SaveTask(ctx, *rec.Task)
+				owners[rec.Task.Owner] = true
+				stats.Tasks++
+			case rec.Kind == DUMP_KIND_TASK_TAG && rec.TaskTag != nil:
+				err = svc.store.AddTagToTask(ctx, rec.TaskTag.TaskId, string(rec.TaskTag.Tag))
+				stats.TaskTags++
+			case rec.Kind == DUMP_KIND_SETTINGS && rec.Settings != nil:
+				err = svc.store.SaveSettings(ctx, *rec.Settings)
+				stats.Settings++
+			default:
+				err = fmt.Errorf("unexpected record kind %q", rec.Kind)
+			}
+			if err != nil {
+				return fmt.Errorf("%s record %d: %w", pfx, stats.total(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	slog.InfoContext(ctx, pfx, "stats", stats)
+	for owner := range owners {
+		svc.publishTagsChanged(ctx, owner)
+		svc.publishTasksChanged(ctx, owner)
+	}
+	return stats, nil
+}
+
+func (s DumpStats) total() int {
+	return s.Tags + s.Tasks + s.TaskTags + s.Settings + s.Projects
+}
+
+// ensureEmptyDatabase checks that no user has any tasks, tags or projects
+func (svc *Services) ensureEmptyDatabase(ctx context.Context) error {
+	if err := svc.store.ForEachTag(ctx, func(models.TagRecord) error { return ErrDumpNotEmpty }); err != nil {
+		return err
+	}
+	if projects, err := svc.store.AllProjects(ctx); err != nil {
+		return err
+	} else if len(projects) > 0 {
+		return ErrDumpNotEmpty
+	}
+	return svc.store.ForEachTask(ctx, func(models.Task) error { return ErrDumpNotEmpty })
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func setupSQLiteDB(t *testing.T) *Services {
+	d := db.NewDbSQLite()
+	d.Init(filepath.Join(t.TempDir(), "db.sqlite"))
+	t.Cleanup(d.Close)
+	return New(d)
+}
+
+func populateDumpSource(t *testing.T, svc *Services) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tag := range []models.TaskTag{"work", "home"} {
+		if err := svc.Tags.SaveTag(context.Background(), "", tag); err != nil {
+			t.Fatalf("SaveTag failed: %v", err)
+		}
+	}
+	project := models.Project{Id: "project-1", Name: "Shared", Created: created, Members: []string{""}}
+	if err := svc.store.SaveProject(context.Background(), project); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		task := models.Task{
+			Id:        "task-" + strings.Repeat("x", i),
+			Title:     "Task",
+			Content:   "Line 1\nLine 2: with \"quotes\"",
+			Created:   created.Add(time.Duration(i) * time.Minute),
+			Updated:   created.Add(time.Duration(i) * time.Hour),
+			Completed: models.NOT_COMPLETED,
+			Priority:  models.TaskPriority(i % 4),
+			Impact:    models.TaskImpact(i % 5),
+			Cost:      models.TaskCost(i % 6),
+			Fun:       models.TaskFun(i % 4),
+			Wip:       i%2 == 0,
+			Planned:   i%3 == 0,
+		}
+		if i%5 == 0 {
+			task.Completed = created.Add(48 * time.Hour)
+		}
+		if i%4 == 0 {
+			task.Project = project.Id
+		}
+		if err := svc.Tasks.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+		if i%2 == 0 {
+			svc.Tags.AddTagToTask(context.Background(), task.Id, "work")
+		}
+		if i%3 == 0 {
+			svc.Tags.AddTagToTask(context.Background(), task.Id, "home")
+		}
+	}
+	settings := models.Settings{Id: SETTINGS_ID, TasksQuery: models.TasksQuery{}.Reset()}
+	settings.TasksQuery.Tags = []models.TaskTag{"work"}
+	settings.TasksQuery.SearchText = "Task"
+	if err := svc.Settings.UpdateUserSettings(context.Background(), settings); err != nil {
+		t.Fatalf("UpdateUserSettings failed: %v", err)
+	}
+}
+
+// dumpBody drops the header line or document, which holds the export time
+func dumpBody(data []byte, encoding DumpEncoding) string {
+	s := string(data)
+	sep := "\n"
+	if encoding == DumpYAML {
+		sep = "\n---\n"
+	}
+	_, body, _ := strings.Cut(s, sep)
+	return body
+}
+
+func Test_ExportDump_ImportDump_RoundTrip(t *testing.T) {
+	for _, encoding := range []DumpEncoding{DumpJSON, DumpYAML} {
+		t.Run(string(encoding), func(t *testing.T) {
+			source := setupSQLiteDB(t)
+			populateDumpSource(t, source)
+
+			var first bytes.Buffer
+			stats, err := source.ExportDump(context.Background(), &first, encoding)
+			if err != nil {
+				t.Fatalf("ExportDump failed: %v", err)
+			}
+			if stats.Tasks != 25 || stats.Tags != 2 || stats.Settings != 1 || stats.Projects != 1 {
+				t.Errorf("unexpected export stats: %+v", stats)
+			}
+
+			target := setupSQLiteDB(t)
+			imported, err := target.ImportDump(context.Background(), bytes.NewReader(first.Bytes()), encoding)
+			if err != nil {
+				t.Fatalf("ImportDump failed: %v", err)
+			}
+			if imported != stats {
+				t.Errorf("import stats %+v differ from export stats %+v", imported, stats)
+			}
+
+			var second bytes.Buffer
+			if _, err := target.ExportDump(context.Background(), &second, encoding); err != nil {
+				t.Fatalf("second ExportDump failed: %v", err)
+			}
+			if dumpBody(first.Bytes(), encoding) != dumpBody(second.Bytes(), encoding) {
+				t.Errorf("re-exported dump differs from the original:\n%s\n----\n%s", first.String(), second.String())
+			}
+		})
+	}
+}
+
+func Test_ImportDump_RequiresEmptyDatabase(t *testing.T) {
+	t.Parallel()
+	svc := setupSQLiteDB(t)
+	populateDumpSource(t, svc)
+
+	var buf bytes.Buffer
+	if _, err := svc.ExportDump(context.Background(), &buf, DumpJSON); err != nil {
+		t.Fatalf("ExportDump failed: %v", err)
+	}
+	_, err := svc.ImportDump(context.Background(), &buf, DumpJSON)
+	if !errors.Is(err, ErrDumpNotEmpty) {
+		t.Errorf("expected ErrDumpNotEmpty, got %v", err)
+	}
+}
+
+func Test_ImportDump_InvalidHeader(t *testing.T) {
+	t.Parallel()
+	svc := setupSQLiteDB(t)
+
+	_, err := svc.ImportDump(context.Background(), strings.NewReader(`{"kind":"task","task":{"Id":"1"}}`), DumpJSON)
+	if !errors.Is(err, ErrDumpInvalidHeader) {
+		t.Errorf("expected ErrDumpInvalidHeader, got %v", err)
+	}
+}
+
+func Test_DumpEncodingFromFileName(t *testing.T) {
+	if e, err := DumpEncodingFromFileName("backup.YAML"); err != nil || e != DumpYAML {
+		t.Errorf("unexpected result: %v, %v", e, err)
+	}
+	if e, err := DumpEncodingFromFileName("backup.jsonl"); err != nil || e != DumpJSON {
+		t.Errorf("unexpected result: %v, %v", e, err)
+	}
+	if _, err := DumpEncodingFromFileName("backup.txt"); err == nil {
+		t.Error("expected an error for unknown extension")
+	}
+}
+
+func Test_ImportDump_RollsBackOnInvalidRecord(t *testing.T) {
+	t.Parallel()
+	svc := setupSQLiteDB(t)
+	dump := `{"kind":"header","header":{"format":"` + DUMP_FORMAT_NAME + `","version":1}}
+{"kind":"tag","tag":{"Id":"work"}}
+{"kind":"task","task":{"Id":"1","Title":"Task"}}
+{"kind":"unknown"}
+`
+	_, err := svc.ImportDump(context.Background(), strings.NewReader(dump), DumpJSON)
+	if err == nil {
+		t.Fatal("expected the unknown record to fail the import")
+	}
+	if err := svc.ensureEmptyDatabase(context.Background()); err != nil {
+		t.Errorf("expected the database to stay empty, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+type EventKind string
+
+const (
+	// EventTaskSaved carries the id of a task that was created or changed
+	EventTaskSaved EventKind = "task-saved"
+	// EventTaskDeleted carries the id of a deleted task
+	EventTaskDeleted EventKind = "task-deleted"
+	// EventTasksChanged means any number of tasks may have changed
+	EventTasksChanged EventKind = "tasks-changed"
+	EventTagsChanged  EventKind = "tags-changed"
+	// EventTaskAssigned carries the id of a task someone else assigned to the user
+	EventTaskAssigned EventKind = "task-assigned"
+
+	EVENT_BUFFER_SIZE = 64
+)
+
+type Event struct {
+	Kind   EventKind
+	TaskId string
+	// Owner is the user the event is for: the owner of the changed tasks or tags, or
+	// a member of the project they are shared in
+	Owner string
+}
+
+// eventBus fans change events out to the subscribers, e.g. the SSE connections of open tabs.
+// Publishing never blocks: a subscriber that does not keep up gets a single
+// EventTasksChanged in place of the events it missed.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]string // the owner whose events the channel receives
+	closed      bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan Event]string)}
+}
+
+// SubscribeEvents returns a channel receiving the change events of the owner and a function
+// to stop receiving them. The channel is closed when unsubscribing or on CloseEvents.
+func (svc *Services) SubscribeEvents(owner string) (<-chan Event, func()) {
+	return svc.events.subscribe(owner)
+}
+
+// CloseEvents ends all subscriptions, so long-lived connections return on shutdown
+func (svc *Services) CloseEvents() {
+	svc.events.close()
+}
+
+func (b *eventBus) subscribe(owner string) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, EVENT_BUFFER_SIZE)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = owner
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publishEvent sends the event to the subscribers of its owner once the changes it is about
+// are committed
+func (svc *instance) publishEvent(ctx context.Context, e Event) {
+	db.AfterCommit(ctx, func(ctx context.Context) {
+		svc.events.send(ctx, e)
+	})
+}
+
+func (b *eventBus) send(ctx context.Context, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slog.DebugContext(ctx, "publishEvent", "kind", e.Kind, "owner", e.Owner, "task_id", e.TaskId)
+	for ch, owner := range b.subscribers {
+		if owner != e.Owner {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			// the buffer is full; make room and ask for a full refresh instead
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- Event{Kind: EventTasksChanged, Owner: e.Owner}:
+			default:
+			}
+		}
+	}
+}
+
+func (svc *instance) publishTaskSaved(ctx context.Context, task models.Task) {
+	for _, u := range svc.projects.taskAudience(ctx, task) {
+		svc.publishEvent(ctx, Event{Kind: EventTaskSaved, TaskId: task.Id, Owner: u})
+	}
+}
+
+func (svc *instance) publishTaskDeleted(ctx context.Context, task models.Task) {
+	for _, u := range svc.projects.taskAudience(ctx, task) {
+		svc.publishEvent(ctx, Event{Kind: EventTaskDeleted, TaskId: task.Id, Owner: u})
+	}
+}
+
+func (svc *instance) publishTaskAssigned(ctx context.Context, task models.Task) {
+	svc.publishEvent(ctx, Event{Kind: EventTaskAssigned, TaskId: task.Id, Owner: task.Assignee})
+}
+
+// publishTasksChanged asks the owner and the members of their projects for a full refresh,
+// any of the changed tasks may be shared
+func (svc *instance) publishTasksChanged(ctx context.Context, owner string) {
+	audience := []string{owner}
+	projects, err := svc.store.Projects(ctx, owner)
+	if err != nil {
+		slog.ErrorContext(ctx, "publishTasksChanged: failed to find the projects", "owner", owner, "error", err)
+	}
+	for _, p := range projects {
+		for _, m := range p.Members {
+			if !slices.Contains(audience, m) {
+				audience = append(audience, m)
+			}
+		}
+	}
+	for _, u := range audience {
+		svc.publishEvent(ctx, Event{Kind: EventTasksChanged, Owner: u})
+	}
+}
+
+func (svc *instance) publishTagsChanged(ctx context.Context, owner string) {
+	svc.publishEvent(ctx, Event{Kind: EventTagsChanged, Owner: owner})
+}
+
+// publishTasksSaved announces a batch of saved tasks of the owner as a single event
+func (svc *instance) publishTasksSaved(ctx context.Context, owner string, tasks []models.Task) {
+	if len(tasks) == 1 {
+		svc.publishTaskSaved(ctx, tasks[0])
+	} else if len(tasks) > 1 {
+		svc.publishTasksChanged(ctx, owner)
+	}
+}
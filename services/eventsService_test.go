@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func receiveEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func Test_Events_PublishedOnChanges(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ch, unsubscribe := svc.SubscribeEvents("")
+	defer unsubscribe()
+
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "New"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	saved := receiveEvent(t, ch)
+	if saved.Kind != EventTaskSaved || saved.TaskId == "" {
+		t.Fatalf("unexpected event: %+v", saved)
+	}
+
+	task, err := svc.Tasks.FindTask(context.Background(), "", saved.TaskId)
+	if err != nil {
+		t.Fatalf("FindTask failed: %v", err)
+	}
+	task.Title = "Changed"
+	if err := svc.Tasks.UpdateTask(context.Background(), task, nil); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if e := receiveEvent(t, ch); e != saved {
+		t.Errorf("unexpected event after update: %+v", e)
+	}
+
+	if err := svc.Tags.SaveTag(context.Background(), "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if e := receiveEvent(t, ch); e.Kind != EventTagsChanged {
+		t.Errorf("unexpected event after SaveTag: %+v", e)
+	}
+
+	if err := svc.Tasks.DeleteTask(context.Background(), "", saved.TaskId); err != nil {
+		t.Fatalf("DeleteTask failed: %v", err)
+	}
+	if e := receiveEvent(t, ch); e.Kind != EventTaskDeleted || e.TaskId != saved.TaskId {
+		t.Errorf("unexpected event after delete: %+v", e)
+	}
+}
+
+func Test_Events_SlowSubscriberGetsFullRefresh(t *testing.T) {
+	t.Parallel()
+	svc := New(&db.NoOpDB{})
+	ch, unsubscribe := svc.SubscribeEvents("")
+	defer unsubscribe()
+
+	for i := 0; i < EVENT_BUFFER_SIZE+10; i++ {
+		svc.publishTaskSaved(context.Background(), models.Task{Id: "t"})
+	}
+
+	var last Event
+	for len(ch) > 0 {
+		last = <-ch
+	}
+	if last.Kind != EventTasksChanged {
+		t.Errorf("expected the overflow to end with %s, got %+v", EventTasksChanged, last)
+	}
+}
+
+func Test_Events_UnsubscribeClosesChannel(t *testing.T) {
+	t.Parallel()
+	svc := New(&db.NoOpDB{})
+	ch, unsubscribe := svc.SubscribeEvents("")
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Error("channel should be closed after unsubscribing")
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	ICAL_PRODID      = "-//priotasks//priotasks//EN"
+	ICAL_UID_SUFFIX  = "@priotasks"
+	ICAL_TIME_FORMAT = "20060102T150405Z"
+	ICAL_LINE_LIMIT  = 75
+)
+
+// ICalOptions controls the generated calendar
+type ICalOptions struct {
+	Name string
+	// Events adds a VEVENT time block sized from the task cost next to every VTODO.
+	// Completed tasks end at their completion time, open tasks are placed one after
+	// another starting at EventsStart.
+	Events      bool
+	EventsStart time.Time
+}
+
+// ExportTasksToICal writes the tasks as an iCalendar (RFC 5545) document of VTODO entries
+func ExportTasksToICal(w io.Writer, tasks []models.Task, opts ICalOptions) error {
+	iw := newICalWriter(w)
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:" + ICAL_PRODID)
+	iw.line("CALSCALE:GREGORIAN")
+	if opts.Name != "" {
+		iw.line("X-WR-CALNAME:" + icalText(opts.Name))
+	}
+
+	nextStart := opts.EventsStart
+	for _, t := range tasks {
+		writeVTodo(iw, t)
+		if opts.Events {
+			duration := time.Duration(t.Cost.Minutes()) * time.Minute
+			var start time.Time
+			if t.IsCompleted() {
+				start = t.Completed.Add(-duration)
+			} else {
+				start = nextStart
+				nextStart = nextStart.Add(duration)
+			}
+			writeVEvent(iw, t, start, duration)
+		}
+	}
+
+	iw.line("END:VCALENDAR")
+	return iw.flush()
+}
+
+// icalPriority maps the priority to the iCalendar scale where 1 is the highest and 9 the lowest
+func icalPriority(p models.TaskPriority) int {
+	switch p {
+	case models.PriorityUrgent:
+		return 1
+	case models.PriorityHigh:
+		return 3
+	case models.PriorityMedium:
+		return 5
+	default:
+		return 9
+	}
+}
+
+func icalStatus(t models.Task) string {
+	switch {
+	case t.IsCompleted():
+		return "COMPLETED"
+	case t.Wip:
+		return "IN-PROCESS"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+func icalUID(taskId string) string {
+	return taskId + ICAL_UID_SUFFIX
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format(ICAL_TIME_FORMAT)
+}
+
+func writeVTodo(iw *icalWriter, t models.Task) {
+	iw.line("BEGIN:VTODO")
+	iw.line("UID:" + icalUID(t.Id))
+	iw.line("DTSTAMP:" + icalTime(taskStamp(t)))
+	iw.line("CREATED:" + icalTime(t.Created))
+	if !t.Updated.IsZero() {
+		iw.line("LAST-MODIFIED:" + icalTime(t.Updated))
+	}
+	iw.line("SUMMARY:" + icalText(t.Title))
+	if t.Content != "" {
+		iw.line("DESCRIPTION:" + icalText(t.Content))
+	}
+	iw.line(fmt.Sprintf("PRIORITY:%d", icalPriority(t.Priority)))
+	iw.line("STATUS:" + icalStatus(t))
+	if t.IsCompleted() {
+		iw.line("COMPLETED:" + icalTime(t.Completed))
+		iw.line("PERCENT-COMPLETE:100")
+	}
+	writeCategories(iw, t.Tags)
+	iw.line("END:VTODO")
+}
+
+func writeVEvent(iw *icalWriter, t models.Task, start time.Time, duration time.Duration) {
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + t.Id + "-block" + ICAL_UID_SUFFIX)
+	iw.line("DTSTAMP:" + icalTime(taskStamp(t)))
+	iw.line("DTSTART:" + icalTime(start))
+	iw.line("DTEND:" + icalTime(start.Add(duration)))
+	iw.line("SUMMARY:" + icalText(t.Title))
+	iw.line("RELATED-TO:" + icalUID(t.Id))
+	iw.line("TRANSP:OPAQUE")
+	writeCategories(iw, t.Tags)
+	iw.line("END:VEVENT")
+}
+
+func writeCategories(iw *icalWriter, tags []models.TaskTag) {
+	if len(tags) == 0 {
+		return
+	}
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = icalText(string(tag))
+	}
+	iw.line("CATEGORIES:" + strings.Join(names, ","))
+}
+
+// taskStamp is the last time the task changed
+func taskStamp(t models.Task) time.Time {
+	if t.Updated.After(t.Created) {
+		return t.Updated
+	}
+	return t.Created
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// icalText escapes a TEXT value
+func icalText(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// icalWriter writes content lines terminated by CRLF and folded at 75 octets
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newICalWriter(w io.Writer) *icalWriter {
+	return &icalWriter{w: bufio.NewWriter(w)}
+}
+
+func (iw *icalWriter) line(s string) {
+	if iw.err != nil {
+		return
+	}
+	limit := ICAL_LINE_LIMIT
+	for len(s) > limit {
+		// never split a multi-byte character
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, iw.err = iw.w.WriteString(s[:cut] + "\r\n "); iw.err != nil {
+			return
+		}
+		s = s[cut:]
+		limit = ICAL_LINE_LIMIT - 1 // continuation lines start with a space
+	}
+	_, iw.err = iw.w.WriteString(s + "\r\n")
+}
+
+func (iw *icalWriter) flush() error {
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+// ICalQuery builds the feed query for a selection (open, planned, wip or all) limited to the tags
+func ICalQuery(selection string, tags []models.TaskTag) (models.TasksQuery, error) {
+	query := models.TasksQuery{
+		SortColumn:    models.ColumnValue,
+		SortDirection: models.Desc,
+		Tags:          tags,
+	}
+	switch selection {
+	case "", consts.ICAL_SELECT_OPEN:
+		query.FilterCompleted = true
+	case consts.ICAL_SELECT_PLANNED:
+		query.FilterCompleted = true
+		query.Planned = true
+	case consts.ICAL_SELECT_WIP:
+		query.FilterCompleted = true
+		query.FilterWip = true
+	case consts.ICAL_SELECT_ALL:
+	default:
+		return query, fmt.Errorf("ICalQuery: unknown selection: %q", selection)
+	}
+	return query, nil
+}
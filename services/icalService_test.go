@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_ExportTasksToICal(t *testing.T) {
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{
+			Id:       "a1",
+			Title:    "Write report, part 1; draft",
+			Content:  "line one\nline two",
+			Created:  created,
+			Updated:  created.Add(time.Hour),
+			Priority: models.PriorityUrgent,
+			Cost:     models.CostM,
+			Wip:      true,
+			Tags:     []models.TaskTag{"work", "q1"},
+		},
+		{
+			Id:        "b2",
+			Title:     "Done",
+			Created:   created,
+			Updated:   created,
+			Completed: created.Add(48 * time.Hour),
+			Cost:      models.CostS,
+		},
+	}
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := ExportTasksToICal(&buf, tasks, ICalOptions{Name: "priotasks", Events: true, EventsStart: start}); err != nil {
+		t.Fatalf("ExportTasksToICal failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("unexpected calendar envelope:\n%s", out)
+	}
+	for _, expected := range []string{
+		"UID:a1@priotasks\r\n",
+		"SUMMARY:Write report\\, part 1\\; draft\r\n",
+		"DESCRIPTION:line one\\nline two\r\n",
+		"PRIORITY:1\r\n",
+		"STATUS:IN-PROCESS\r\n",
+		"CATEGORIES:work,q1\r\n",
+		"LAST-MODIFIED:20250301T110000Z\r\n",
+		"DTSTART:20250310T090000Z\r\nDTEND:20250310T100000Z\r\n",
+		"STATUS:COMPLETED\r\nCOMPLETED:20250303T100000Z\r\nPERCENT-COMPLETE:100\r\n",
+		"PRIORITY:9\r\n",
+		"DTSTART:20250303T093000Z\r\nDTEND:20250303T100000Z\r\n",
+		"RELATED-TO:b2@priotasks\r\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("iCalendar output missing %q:\n%s", expected, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VTODO") != 2 || strings.Count(out, "BEGIN:VEVENT") != 2 {
+		t.Errorf("expected 2 VTODO and 2 VEVENT components:\n%s", out)
+	}
+}
+
+func Test_ExportTasksToICal_FoldsLongLines(t *testing.T) {
+	title := strings.Repeat("ä", 100)
+	var buf bytes.Buffer
+	if err := ExportTasksToICal(&buf, []models.Task{{Id: "x", Title: title}}, ICalOptions{}); err != nil {
+		t.Fatalf("ExportTasksToICal failed: %v", err)
+	}
+
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > ICAL_LINE_LIMIT {
+			t.Errorf("line longer than %d octets: %q", ICAL_LINE_LIMIT, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	if !strings.Contains(unfolded.String(), "\nSUMMARY:"+title+"\n") {
+		t.Errorf("folded summary does not unfold to the title:\n%s", unfolded.String())
+	}
+}
+
+func Test_ICalQuery(t *testing.T) {
+	q, err := ICalQuery(consts.ICAL_SELECT_PLANNED, []models.TaskTag{"work"})
+	if err != nil {
+		t.Fatalf("ICalQuery failed: %v", err)
+	}
+	if !q.FilterCompleted || !q.Planned || len(q.Tags) != 1 {
+		t.Errorf("unexpected planned query: %+v", q)
+	}
+
+	q, _ = ICalQuery(consts.ICAL_SELECT_ALL, nil)
+	if q.FilterCompleted {
+		t.Errorf("all should include completed tasks: %+v", q)
+	}
+
+	if _, err := ICalQuery("bogus", nil); err == nil {
+		t.Error("expected an error for an unknown selection")
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportTasksFromYAML upserts tasks and their tags of the user from the YAML produced by ExportTasksToYAML
+func (svc *TaskService) ImportTasksFromYAML(ctx context.Context, owner string, data []byte, dryRun bool) (models.ImportReport, error) {
+	var tasks []models.Task
+	if err := yaml.Unmarshal(data, &tasks); err != nil {
+		return models.ImportReport{DryRun: dryRun}, fmt.Errorf("failed to unmarshal tasks from YAML: %w", err)
+	}
+	return svc.importTasks(ctx, owner, tasks, dryRun)
+}
+
+// importTasks upserts the tasks by id. Tasks without an id are created, tasks whose
+// stored copy was updated after the imported one are reported as conflicts and skipped.
+// The tasks are imported as the tasks of the owner, ids of other users' tasks are conflicts.
+// A failing import saves none of the tasks.
+func (svc *TaskService) importTasks(ctx context.Context, owner string, tasks []models.Task, dryRun bool) (models.ImportReport, error) {
+	pfx := "importTasks:"
+	report := models.ImportReport{DryRun: dryRun}
+	err := svc.store.WithTx(ctx, func(ctx context.Context) error {
+		allTags, err := svc.store.Tags(ctx, owner)
+		if err != nil {
+			return fmt.Errorf("%s failed to retrieve tags: %w", pfx, err)
+		}
+		knownTags := make(map[models.TaskTag]bool)
+		for _, tag := range allTags {
+			knownTags[tag] = true
+		}
+
+		seenIds := make(map[string]bool)
+		for _, task := range tasks {
+			conflict := func(reason string) {
+				report.Conflicts = append(report.Conflicts, models.ImportConflict{TaskId: task.Id, Title: task.Title, Reason: reason})
+			}
+
+			if task.Id != "" {
+				if seenIds[task.Id] {
+					conflict("duplicate id in the import")
+					continue
+				}
+				seenIds[task.Id] = true
+			}
+			if task.Title == "" && task.Content == "" {
+				conflict("task has neither title nor content")
+				continue
+			}
+
+			isNew := true
+			if task.Id != "" {
+				existing, err := svc.store.FindTask(ctx, task.Id)
+				if err == nil && existing.Owner != owner {
+					conflict("the id belongs to a task of another user")
+					continue
+				}
+				if err == nil {
+					isNew = false
+					if existing.Updated.After(task.Updated) {
+						conflict(fmt.Sprintf("stored task was updated at %v, after the imported version", existing.Updated))
+						continue
+					}
+					if existing.Updated.Equal(task.Updated) {
+						report.Unchanged = append(report.Unchanged, task)
+						continue
+					}
+				} else if !errors.Is(err, db.ErrNotFound) {
+					return fmt.Errorf("%s failed to find task %s: %w", pfx, task.Id, err)
+				}
+			}
+
+			task.Owner = owner
+			if err := svc.projects.validateAssignment(ctx, owner, task); errors.Is(err, ErrInvalidAssignment) {
+				conflict(err.Error())
+				continue
+			} else if err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+
+			var tags []models.TaskTag
+			for _, tag := range task.Tags {
+				if tag.IsEmpty() || slices.Contains(tags, tag) {
+					continue
+				}
+				tags = append(tags, tag)
+				if !knownTags[tag] {
+					knownTags[tag] = true
+					report.NewTags = append(report.NewTags, tag)
+					if !dryRun {
+						if err := svc.tags.SaveTag(ctx, owner, tag); err != nil {
+							return fmt.Errorf("%s %w", pfx, err)
+						}
+					}
+				}
+			}
+			task.Tags = tags
+
+			if isNew {
+				if task.Id == "" {
+					task.Id = uuid.NewString()
+				}
+				if task.Created.IsZero() {
+					task.Created = time.Now()
+				}
+				if task.Updated.IsZero() {
+					task.Updated = task.Created
+				}
+			}
+
+			if !dryRun {
+				if err := svc.SaveTask(ctx, task); err != nil {
+					return fmt.Errorf("%s %w", pfx, err)
+				}
+				if err := svc.updateTaskTags(ctx, task.Id, tags); err != nil {
+					return fmt.Errorf("%s %w", pfx, err)
+				}
+			}
+
+			if isNew {
+				report.Created = append(report.Created, task)
+			} else {
+				report.Updated = append(report.Updated, task)
+			}
+		}
+
+		if !dryRun {
+			svc.publishTasksSaved(ctx, owner, slices.Concat(report.Created, report.Updated))
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	slog.InfoContext(ctx, pfx, "dry_run", dryRun, "created", len(report.Created), "updated", len(report.Updated),
+		"unchanged", len(report.Unchanged), "conflicts", len(report.Conflicts))
+	return report, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func exportTestTasks(t *testing.T) []byte {
+	updated := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{
+			Id:       "task-1",
+			Title:    "First",
+			Content:  "First content",
+			Created:  updated.Add(-time.Hour),
+			Updated:  updated,
+			Priority: models.PriorityUrgent,
+			Impact:   models.ImpactConsiderable,
+			Cost:     models.CostXL,
+			Fun:      models.FunL,
+			Wip:      true,
+			Tags:     []models.TaskTag{"home", "garden"},
+		},
+		{
+			Id:        "task-2",
+			Title:     "Second",
+			Created:   updated.Add(-time.Hour),
+			Updated:   updated,
+			Completed: updated,
+			Priority:  models.PriorityLow,
+			Impact:    models.ImpactSlight,
+			Cost:      models.CostXS,
+			Fun:       models.FunS,
+		},
+	}
+	data, err := ExportTasksToYAML(tasks)
+	if err != nil {
+		t.Fatalf("ExportTasksToYAML failed: %v", err)
+	}
+	return data
+}
+
+func Test_ImportTasksFromYAML_RoundTrip(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+	data := exportTestTasks(t)
+
+	report, err := svc.Tasks.ImportTasksFromYAML(context.Background(), "", data, false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromYAML failed: %v", err)
+	}
+	if len(report.Created) != 2 || len(report.Updated) != 0 || len(report.Conflicts) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.NewTags) != 2 {
+		t.Errorf("expected 2 new tags, got %v", report.NewTags)
+	}
+
+	task := mockDB.tasks["task-1"]
+	if task.Priority != models.PriorityUrgent || task.Impact != models.ImpactConsiderable ||
+		task.Cost != models.CostXL || task.Fun != models.FunL || !task.Wip {
+		t.Errorf("enum values were not restored: %+v", task)
+	}
+	if len(mockDB.taskTags["task-1"]) != 2 {
+		t.Errorf("expected 2 tags on task-1, got %v", mockDB.taskTags["task-1"])
+	}
+	if !mockDB.tasks["task-2"].IsCompleted() {
+		t.Error("task-2 should be completed")
+	}
+
+	report, err = svc.Tasks.ImportTasksFromYAML(context.Background(), "", data, false)
+	if err != nil {
+		t.Fatalf("second ImportTasksFromYAML failed: %v", err)
+	}
+	if len(report.Unchanged) != 2 || len(report.Created) != 0 || len(report.NewTags) != 0 {
+		t.Errorf("second import should not change anything: %+v", report)
+	}
+}
+
+func Test_ImportTasksFromYAML_DryRun(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+
+	report, err := svc.Tasks.ImportTasksFromYAML(context.Background(), "", exportTestTasks(t), true)
+	if err != nil {
+		t.Fatalf("ImportTasksFromYAML failed: %v", err)
+	}
+	if len(report.Created) != 2 {
+		t.Errorf("expected 2 planned creates, got %d", len(report.Created))
+	}
+	if len(mockDB.tasks) != 0 || len(mockDB.tags) != 0 {
+		t.Errorf("dry run must not save anything: tasks=%v tags=%v", mockDB.tasks, mockDB.tags)
+	}
+}
+
+func Test_ImportTasksFromYAML_Conflicts(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+	data := exportTestTasks(t)
+
+	mockDB.SaveTask(context.Background(), models.Task{Id: "task-1", Title: "Changed later", Updated: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)})
+	mockDB.SaveTask(context.Background(), models.Task{Id: "task-2", Title: "Older", Updated: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)})
+
+	report, err := svc.Tasks.ImportTasksFromYAML(context.Background(), "", data, false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromYAML failed: %v", err)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].TaskId != "task-1" {
+		t.Errorf("expected a conflict for task-1, got %+v", report.Conflicts)
+	}
+	if len(report.Updated) != 1 || report.Updated[0].Id != "task-2" {
+		t.Errorf("expected task-2 to be updated, got %+v", report.Updated)
+	}
+	if mockDB.tasks["task-1"].Title != "Changed later" {
+		t.Error("conflicting task must not be overwritten")
+	}
+	if mockDB.tasks["task-2"].Title != "Second" {
+		t.Error("older task should be overwritten")
+	}
+}
+
+func Test_ImportTasksFromYAML_InvalidEnum(t *testing.T) {
+	t.Parallel()
+	svc, _ := setupTestDB()
+
+	_, err := svc.Tasks.ImportTasksFromYAML(context.Background(), "", []byte("- id: x\n  title: t\n  priority: Whenever\n"), true)
+	if err == nil {
+		t.Error("expected an error for unknown priority")
+	}
+}
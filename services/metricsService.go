@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/inaryzen/priotasks/common"
+)
+
+// RegisterMetrics adds the task counts and the age and size of the latest backup in appDir
+// to the metrics; the metrics are global, so it is called once per process
+func (svc *Services) RegisterMetrics(appDir string) {
+	backups := &BackupService{baseDir: appDir}
+	common.NewGaugeFunc("priotasks_tasks",
+		"Number of tasks of all users by state; wip and planned count open tasks.", svc.taskCountSamples, "state")
+	common.NewGaugeFunc("priotasks_backup_age_seconds",
+		"Seconds since the latest database backup was created.", backups.ageSamples)
+	common.NewGaugeFunc("priotasks_backup_size_bytes",
+		"Size of the latest database backup.", backups.sizeSamples)
+}
+
+// CheckDatabase returns an error when the database can't be queried
+func (svc *Services) CheckDatabase(ctx context.Context) error {
+	if err := svc.store.Ping(ctx); err != nil {
+		return fmt.Errorf("CheckDatabase: %w", err)
+	}
+	return nil
+}
+
+func (svc *Services) taskCountSamples(ctx context.Context) ([]common.Sample, error) {
+	counts, err := svc.store.TaskCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []common.Sample{
+		{LabelValues: []string{"open"}, Value: float64(counts.Open)},
+		{LabelValues: []string{"completed"}, Value: float64(counts.Completed)},
+		{LabelValues: []string{"wip"}, Value: float64(counts.Wip)},
+		{LabelValues: []string{"planned"}, Value: float64(counts.Planned)},
+	}, nil
+}
+
+func (s *BackupService) ageSamples(ctx context.Context) ([]common.Sample, error) {
+	latest, err := s.LatestBackup()
+	if err != nil || latest == nil {
+		return nil, err
+	}
+	return []common.Sample{{Value: time.Since(latest.ModTime()).Seconds()}}, nil
+}
+
+func (s *BackupService) sizeSamples(ctx context.Context) ([]common.Sample, error) {
+	latest, err := s.LatestBackup()
+	if err != nil || latest == nil {
+		return nil, err
+	}
+	return []common.Sample{{Value: float64(latest.Size())}}, nil
+}
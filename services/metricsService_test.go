@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func TestTaskCountSamples(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	tasks := []models.Task{
+		{Id: "open", Title: "Open"},
+		{Id: "wip", Title: "Wip", Wip: true, Planned: true},
+		{Id: "done", Title: "Done", Wip: true, Completed: time.Now()},
+	}
+	for _, task := range tasks {
+		if err := svc.Tasks.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+
+	samples, err := svc.taskCountSamples(context.Background())
+	if err != nil {
+		t.Fatalf("taskCountSamples failed: %v", err)
+	}
+	expected := map[string]float64{"open": 2, "completed": 1, "wip": 1, "planned": 1}
+	for _, s := range samples {
+		if s.Value != expected[s.LabelValues[0]] {
+			t.Errorf("expected %v %v tasks, got %v", expected[s.LabelValues[0]], s.LabelValues[0], s.Value)
+		}
+	}
+	if len(samples) != len(expected) {
+		t.Errorf("expected %d states, got %v", len(expected), samples)
+	}
+}
+
+func TestCheckDatabase(t *testing.T) {
+	t.Parallel()
+	svc := setupSQLiteDB(t)
+	if err := svc.CheckDatabase(context.Background()); err != nil {
+		t.Errorf("expected the database to be reachable, got %v", err)
+	}
+	svc.store.Close()
+	if err := svc.CheckDatabase(context.Background()); err == nil {
+		t.Errorf("expected a closed database to fail the check")
+	}
+}
+
+func TestBackupSamples(t *testing.T) {
+	s := &BackupService{baseDir: t.TempDir()}
+	if samples, err := s.sizeSamples(context.Background()); err != nil || samples != nil {
+		t.Fatalf("expected no samples without backups, got %v %v", samples, err)
+	}
+
+	older := filepath.Join(s.baseDir, "priotasks_db_backup_20250101_000000.db")
+	newer := filepath.Join(s.baseDir, "priotasks_db_backup_20250102_000000.db")
+	os.WriteFile(older, []byte("old backup"), 0600)
+	os.WriteFile(newer, []byte("new"), 0600)
+	hourAgo := time.Now().Add(-time.Hour)
+	os.Chtimes(older, hourAgo.Add(-time.Hour), hourAgo.Add(-time.Hour))
+	os.Chtimes(newer, hourAgo, hourAgo)
+
+	size, err := s.sizeSamples(context.Background())
+	if err != nil || len(size) != 1 || size[0].Value != 3 {
+		t.Errorf("expected the size of the latest backup, got %v %v", size, err)
+	}
+	age, err := s.ageSamples(context.Background())
+	if err != nil || len(age) != 1 || age[0].Value < 3600 || age[0].Value > 3660 {
+		t.Errorf("expected the latest backup to be an hour old, got %v %v", age, err)
+	}
+}
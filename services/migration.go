@@ -1,24 +1,50 @@
 package services
 
 import (
-	"github.com/inaryzen/priotasks/db"
+	"context"
 )
 
-func Init() {
-	migrationTaskValue()
+// Migrate updates the data stored by older versions, once per migration
+func (svc *Services) Migrate() {
+	svc.migrationTaskValue()
+	svc.migrationAdoptOwnerlessData()
 }
 
-func migrationTaskValue() {
-	d := db.DB()
+// migrationAdoptOwnerlessData hands the tasks created before the owners existed
+// to the first admin, if the users were created before
+func (svc *Services) migrationAdoptOwnerlessData() {
+	ctx := context.Background()
+	d := svc.store
+	id := "adopt_ownerless_data"
+	if !d.MigrationExists(ctx, id) {
+		users, err := d.Users(ctx)
+		if err != nil {
+			panic(err)
+		}
+		for _, u := range users {
+			if u.Admin {
+				if err := svc.users.transferData(ctx, "", u.Username); err != nil {
+					panic(err)
+				}
+				break
+			}
+		}
+		d.RecordMigration(ctx, id)
+	}
+}
+
+func (svc *Services) migrationTaskValue() {
+	ctx := context.Background()
+	d := svc.store
 	id := "update_task_value"
-	if !d.MigrationExists(id) {
-		tasks, err := d.Tasks()
+	if !d.MigrationExists(ctx, id) {
+		tasks, err := d.Tasks(ctx)
 		if err != nil {
 			panic(err)
 		}
 		for _, t := range tasks {
-			SaveTask(t)
+			svc.tasks.SaveTask(ctx, t)
 		}
-		d.RecordMigration(id)
+		d.RecordMigration(ctx, id)
 	}
 }
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const MAX_PROJECT_NAME_LENGTH = 64
+
+var (
+	ErrInvalidProject    = errors.New("invalid project")
+	ErrProjectOwnerOnly  = errors.New("only the owner can change the project")
+	ErrInvalidAssignment = errors.New("invalid project or assignee")
+)
+
+// Projects returns the projects the user is a member of
+func (svc *ProjectService) Projects(ctx context.Context, username string) ([]models.Project, error) {
+	projects, err := svc.store.Projects(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("Projects: %w", err)
+	}
+	return projects, nil
+}
+
+// FindProject returns the project if the user is a member; db.ErrNotFound otherwise
+func (svc *ProjectService) FindProject(ctx context.Context, username, projectId string) (models.Project, error) {
+	p, err := svc.store.FindProject(ctx, projectId)
+	if err != nil {
+		return p, err
+	}
+	if !p.HasMember(username) {
+		return models.Project{}, db.ErrNotFound
+	}
+	return p, nil
+}
+
+func (svc *ProjectService) CreateProject(ctx context.Context, owner, name string) (models.Project, error) {
+	if owner == "" {
+		return models.Project{}, fmt.Errorf("%w: projects need sign-in to be enabled", ErrInvalidProject)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MAX_PROJECT_NAME_LENGTH {
+		return models.Project{}, fmt.Errorf("%w: the name must have 1 to %d characters", ErrInvalidProject, MAX_PROJECT_NAME_LENGTH)
+	}
+	p := models.Project{
+		Id:      uuid.NewString(),
+		Name:    name,
+		Owner:   owner,
+		Created: time.Now(),
+		Members: []string{owner},
+	}
+	if err := svc.store.SaveProject(ctx, p); err != nil {
+		return p, fmt.Errorf("CreateProject: %w", err)
+	}
+	return p, nil
+}
+
+// findOwnProject returns the project if the user owns it
+func (svc *ProjectService) findOwnProject(ctx context.Context, username, projectId string) (models.Project, error) {
+	p, err := svc.FindProject(ctx, username, projectId)
+	if err != nil {
+		return p, err
+	}
+	if p.Owner != username {
+		return p, ErrProjectOwnerOnly
+	}
+	return p, nil
+}
+
+// AddProjectMember shares the tasks of the project with another user
+func (svc *ProjectService) AddProjectMember(ctx context.Context, actor, projectId, member string) error {
+	p, err := svc.findOwnProject(ctx, actor, projectId)
+	if err != nil {
+		return err
+	}
+	member = strings.TrimSpace(member)
+	if p.HasMember(member) {
+		return nil
+	}
+	if _, err := svc.store.FindUser(ctx, member); errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("%w: unknown user %q", ErrInvalidProject, member)
+	} else if err != nil {
+		return fmt.Errorf("AddProjectMember: %w", err)
+	}
+	p.Members = append(p.Members, member)
+	if err := svc.store.SaveProject(ctx, p); err != nil {
+		return fmt.Errorf("AddProjectMember: %w", err)
+	}
+	svc.publishTasksChanged(ctx, member)
+	return nil
+}
+
+// RemoveProjectMember is for the owner removing a member or for a member leaving;
+// the owner cannot leave their own project. The tasks of the project assigned to
+// the member are unassigned.
+func (svc *ProjectService) RemoveProjectMember(ctx context.Context, actor, projectId, member string) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		p, err := svc.FindProject(ctx, actor, projectId)
+		if err != nil {
+			return err
+		}
+		if p.Owner != actor && member != actor {
+			return ErrProjectOwnerOnly
+		}
+		if member == p.Owner {
+			return fmt.Errorf("%w: the owner cannot leave the project", ErrInvalidProject)
+		}
+		if !p.HasMember(member) {
+			return db.ErrNotFound
+		}
+		audience := p.Members
+		p.Members = slices.DeleteFunc(slices.Clone(p.Members), func(m string) bool { return m == member })
+		if err := svc.store.SaveProject(ctx, p); err != nil {
+			return fmt.Errorf("RemoveProjectMember: %w", err)
+		}
+		svc.clearProjectFilter(ctx, member, projectId)
+		for _, u := range audience {
+			svc.publishTasksChanged(ctx, u)
+		}
+		return nil
+	})
+}
+
+// DeleteProject deletes the project of the user, its tasks stay with their owners
+func (svc *ProjectService) DeleteProject(ctx context.Context, actor, projectId string) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		p, err := svc.findOwnProject(ctx, actor, projectId)
+		if err != nil {
+			return err
+		}
+		if err := svc.store.DeleteProject(ctx, projectId); err != nil {
+			return fmt.Errorf("DeleteProject: %w", err)
+		}
+		for _, u := range p.Members {
+			svc.clearProjectFilter(ctx, u, projectId)
+			svc.publishTasksChanged(ctx, u)
+		}
+		return nil
+	})
+}
+
+// clearProjectFilter drops the project from the filter of a user who lost access to it
+func (svc *ProjectService) clearProjectFilter(ctx context.Context, username, projectId string) {
+	s, err := svc.settings.FindUserSettings(ctx, username)
+	if err != nil || s.TasksQuery.Project != projectId {
+		return
+	}
+	s.TasksQuery.Project = ""
+	if err := svc.settings.UpdateUserSettings(ctx, s); err != nil {
+		slog.ErrorContext(ctx, "clearProjectFilter: failed to save the settings", "username", username, "error", err)
+	}
+}
+
+// canAccessTask reports whether the user owns the task or is a member of its project
+func (svc *ProjectService) canAccessTask(ctx context.Context, username string, task models.Task) (bool, error) {
+	if task.Owner == username {
+		return true, nil
+	}
+	if task.Project == "" {
+		return false, nil
+	}
+	p, err := svc.store.FindProject(ctx, task.Project)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.HasMember(username), nil
+}
+
+// validateAssignment checks the project and the assignee of a task saved by the user:
+// the user must be a member of the project and the assignee one as well; a private
+// task can only be assigned to its owner
+func (svc *ProjectService) validateAssignment(ctx context.Context, username string, task models.Task) error {
+	if task.Project == "" {
+		if task.Assignee != "" && task.Assignee != task.Owner {
+			return fmt.Errorf("%w: a private task can only be assigned to its owner", ErrInvalidAssignment)
+		}
+		return nil
+	}
+	p, err := svc.FindProject(ctx, username, task.Project)
+	if errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("%w: unknown project %q", ErrInvalidAssignment, task.Project)
+	}
+	if err != nil {
+		return fmt.Errorf("validateAssignment: %w", err)
+	}
+	if task.Assignee != "" && !p.HasMember(task.Assignee) {
+		return fmt.Errorf("%w: %q is not a member of %q", ErrInvalidAssignment, task.Assignee, p.Name)
+	}
+	return nil
+}
+
+// taskAudience returns the users who see the task: its owner and the members of its project
+func (svc *ProjectService) taskAudience(ctx context.Context, task models.Task) []string {
+	audience := []string{task.Owner}
+	if task.Project == "" {
+		return audience
+	}
+	p, err := svc.store.FindProject(ctx, task.Project)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			slog.ErrorContext(ctx, "taskAudience: failed to find the project", "project", task.Project, "error", err)
+		}
+		return audience
+	}
+	for _, m := range p.Members {
+		if !slices.Contains(audience, m) {
+			audience = append(audience, m)
+		}
+	}
+	return audience
+}
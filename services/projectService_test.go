@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+func setupProjectUsers(t *testing.T) (*Services, models.Project) {
+	t.Helper()
+	svc := setupMemDB(t)
+	for _, u := range []string{"alice", "bob", "carol"} {
+		if err := svc.Users.CreateUser(context.Background(), u, "correct horse", u == "alice"); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+	}
+	p, err := svc.Projects.CreateProject(context.Background(), "alice", "Launch")
+	if err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+	if err := svc.Projects.AddProjectMember(context.Background(), "alice", p.Id, "bob"); err != nil {
+		t.Fatalf("AddProjectMember failed: %v", err)
+	}
+	return svc, p
+}
+
+// receiveEventOfKind skips the other events, such as the refreshes of the table
+func receiveEventOfKind(t *testing.T, ch <-chan Event, kind EventKind) Event {
+	t.Helper()
+	for {
+		if e := receiveEvent(t, ch); e.Kind == kind {
+			return e
+		}
+	}
+}
+
+func TestProjects_Membership(t *testing.T) {
+	t.Parallel()
+	svc, p := setupProjectUsers(t)
+
+	if err := svc.Projects.AddProjectMember(context.Background(), "bob", p.Id, "carol"); !errors.Is(err, ErrProjectOwnerOnly) {
+		t.Errorf("expected ErrProjectOwnerOnly for a member adding members, got %v", err)
+	}
+	if err := svc.Projects.AddProjectMember(context.Background(), "alice", p.Id, "dave"); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("expected ErrInvalidProject for an unknown user, got %v", err)
+	}
+	if _, err := svc.Projects.FindProject(context.Background(), "carol", p.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected the project to be hidden from carol, got %v", err)
+	}
+	if err := svc.Projects.RemoveProjectMember(context.Background(), "alice", p.Id, "alice"); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("expected the owner not to be able to leave, got %v", err)
+	}
+	if _, err := svc.Projects.CreateProject(context.Background(), "", "Anonymous"); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("expected projects to need sign-in, got %v", err)
+	}
+}
+
+func TestProjects_AssignmentAndNotification(t *testing.T) {
+	t.Parallel()
+	svc, p := setupProjectUsers(t)
+	ch, unsubscribe := svc.SubscribeEvents("bob")
+	defer unsubscribe()
+
+	task := models.Task{Title: "Ship it", Owner: "alice", Project: p.Id, Assignee: "bob"}
+	if err := svc.Tasks.SaveNewTask(context.Background(), task, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	assigned := receiveEventOfKind(t, ch, EventTaskAssigned)
+
+	shared, err := svc.Tasks.FindTask(context.Background(), "bob", assigned.TaskId)
+	if err != nil || shared.Owner != "alice" || shared.Assignee != "bob" {
+		t.Fatalf("expected bob to see the task assigned to him, got %+v, %v", shared, err)
+	}
+	if _, err := svc.Tasks.FindTask(context.Background(), "carol", shared.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected the task to be hidden from carol, got %v", err)
+	}
+
+	changed := shared
+	changed.Owner = "bob"
+	changed.Assignee = "carol"
+	if err := svc.Tasks.UpdateTask(context.Background(), changed, nil); !errors.Is(err, ErrInvalidAssignment) {
+		t.Errorf("expected ErrInvalidAssignment for a non-member, got %v", err)
+	}
+	changed.Assignee = "alice"
+	if err := svc.Tasks.UpdateTask(context.Background(), changed, []models.TaskTag{"review"}); err != nil {
+		t.Fatalf("UpdateTask by a member failed: %v", err)
+	}
+	updated, err := svc.Tasks.FindTask(context.Background(), "alice", shared.Id)
+	if err != nil || updated.Owner != "alice" || updated.Assignee != "alice" {
+		t.Errorf("expected the task to stay with alice and be assigned to her, got %+v, %v", updated, err)
+	}
+	if tags, _ := svc.Tags.Tags(context.Background(), "alice"); len(tags) != 1 || tags[0] != "review" {
+		t.Errorf("expected the tag to be created for the owner, got %v", tags)
+	}
+
+	if err := svc.Projects.RemoveProjectMember(context.Background(), "bob", p.Id, "bob"); err != nil {
+		t.Fatalf("RemoveProjectMember failed: %v", err)
+	}
+	if _, err := svc.Tasks.FindTask(context.Background(), "bob", shared.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected bob to lose access after leaving, got %v", err)
+	}
+}
+
+func TestProjects_PrivateTaskAssignment(t *testing.T) {
+	t.Parallel()
+	svc, _ := setupProjectUsers(t)
+	task := models.Task{Title: "Mine", Owner: "alice", Assignee: "bob"}
+	if err := svc.Tasks.SaveNewTask(context.Background(), task, nil); !errors.Is(err, ErrInvalidAssignment) {
+		t.Errorf("expected a private task not to be assignable to others, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/models"
+)
+
+// ThisWeek returns the current week, Monday midnight to next Monday midnight in the time
+// zone loc
+func ThisWeek(loc *time.Location) (time.Time, time.Time) {
+	monday := thisMonday(time.Now().In(loc))
+	return monday, monday.AddDate(0, 0, 7)
+}
+
+// GenerateReport summarizes the period [from, to): completed tasks grouped by tag,
+// open high-priority tasks and work in progress of the user. The times of the tasks are in
+// the time zone of from.
+func (svc *TaskService) GenerateReport(ctx context.Context, owner string, from, to time.Time) (models.Report, error) {
+	pfx := "GenerateReport:"
+	report := models.Report{From: from, To: to}
+
+	completed, err := svc.FindTasks(ctx, models.TasksQuery{
+		FilterIncompleted: true,
+		CompletedFrom:     from,
+		CompletedTo:       to,
+		SortColumn:        models.Completed,
+		SortDirection:     models.Asc,
+		Owner:             owner,
+	})
+	if err != nil {
+		return report, fmt.Errorf("%s %w", pfx, err)
+	}
+	// CompletedTo is inclusive in FindTasks, the report end is not
+	completed = slices.DeleteFunc(completed, func(t models.Task) bool {
+		return !t.Completed.Before(to)
+	})
+	completed = models.TasksIn(completed, from.Location())
+	report.Completed = completed
+	report.TotalMinutes = models.CalculateTotalTime(completed)
+	report.CompletedByTag = groupByTag(completed)
+
+	open, err := svc.FindTasks(ctx, models.TasksQuery{
+		FilterCompleted: true,
+		SortColumn:      models.Priority,
+		SortDirection:   models.Desc,
+		Owner:           owner,
+	})
+	if err != nil {
+		return report, fmt.Errorf("%s %w", pfx, err)
+	}
+	for _, t := range models.TasksIn(open, from.Location()) {
+		if t.Priority >= models.PriorityHigh {
+			report.HighPriority = append(report.HighPriority, t)
+		}
+		if t.Wip {
+			report.Wip = append(report.Wip, t)
+		}
+	}
+
+	return report, nil
+}
+
+// groupByTag puts every task into the group of each of its tags; groups are sorted by tag
+// with the untagged group last
+func groupByTag(tasks []models.Task) []models.ReportGroup {
+	groups := make(map[models.TaskTag][]models.Task)
+	for _, t := range tasks {
+		if len(t.Tags) == 0 {
+			groups[models.UNTAGGED_GROUP] = append(groups[models.UNTAGGED_GROUP], t)
+		}
+		for _, tag := range t.Tags {
+			groups[tag] = append(groups[tag], t)
+		}
+	}
+
+	var result []models.ReportGroup
+	for tag, groupTasks := range groups {
+		result = append(result, models.ReportGroup{
+			Tag:          tag,
+			Tasks:        groupTasks,
+			TotalMinutes: models.CalculateTotalTime(groupTasks),
+		})
+	}
+	slices.SortFunc(result, func(a, b models.ReportGroup) int {
+		if a.Tag == models.UNTAGGED_GROUP {
+			return 1
+		}
+		if b.Tag == models.UNTAGGED_GROUP {
+			return -1
+		}
+		return strings.Compare(string(a.Tag), string(b.Tag))
+	})
+	return result
+}
+
+// ReportToMarkdown writes the report as a Markdown document
+func ReportToMarkdown(w io.Writer, r models.Report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Report %s – %s\n\n", r.From.Format(consts.DEFAULT_DATE_FORMAT), r.LastDay().Format(consts.DEFAULT_DATE_FORMAT))
+
+	fmt.Fprintf(&b, "## Completed (%d, %s)\n\n", len(r.Completed), models.FormatTotalTime(r.TotalMinutes))
+	if len(r.CompletedByTag) == 0 {
+		b.WriteString("Nothing was completed.\n\n")
+	}
+	for _, g := range r.CompletedByTag {
+		fmt.Fprintf(&b, "### %s (%d, %s)\n\n", markdownText(string(g.Tag)), len(g.Tasks), models.FormatTotalTime(g.TotalMinutes))
+		for _, t := range g.Tasks {
+			fmt.Fprintf(&b, "- [x] %s (%s, %s)\n", markdownText(t.Title), t.Priority.Name(), t.Cost.ToHumanString())
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "## Open high priority (%d)\n\n", len(r.HighPriority))
+	writeMarkdownTaskList(&b, r.HighPriority)
+
+	fmt.Fprintf(&b, "## In progress (%d)\n\n", len(r.Wip))
+	writeMarkdownTaskList(&b, r.Wip)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownTaskList(b *strings.Builder, tasks []models.Task) {
+	if len(tasks) == 0 {
+		b.WriteString("None.\n\n")
+		return
+	}
+	for _, t := range tasks {
+		fmt.Fprintf(b, "- [ ] %s (%s)", markdownText(t.Title), t.Priority.Name())
+		if len(t.Tags) > 0 {
+			fmt.Fprintf(b, " %s", markdownTags(t.Tags))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
+// ExportTasksToMarkdown writes the tasks as a Markdown table
+func ExportTasksToMarkdown(w io.Writer, tasks []models.Task) error {
+	var b strings.Builder
+	b.WriteString("| Done | Title | Priority | Impact | Cost | Fun | Tags | Completed |\n")
+	b.WriteString("|------|-------|----------|--------|------|-----|------|-----------|\n")
+	for _, t := range tasks {
+		done := "[ ]"
+		completed := ""
+		if t.IsCompleted() {
+			done = "[x]"
+			completed = t.Completed.Format(consts.DEFAULT_TIME_FORMAT)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			done,
+			markdownCell(t.Title),
+			t.Priority.Name(),
+			t.Impact.Name(),
+			t.Cost.Name(),
+			t.Fun.Name(),
+			markdownCell(joinTagNames(t.Tags)),
+			completed,
+		)
+	}
+	fmt.Fprintf(&b, "\nTotal: %d tasks, %s\n", len(tasks), models.FormatTotalTime(models.CalculateTotalTime(tasks)))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func joinTagNames(tags []models.TaskTag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = string(tag)
+	}
+	return strings.Join(names, ", ")
+}
+
+func markdownTags(tags []models.TaskTag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = "`" + strings.ReplaceAll(string(tag), "`", "'") + "`"
+	}
+	return strings.Join(names, " ")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "*", "\\*", "_", "\\_", "`", "\\`", "[", "\\[", "]", "\\]", "<", "&lt;", "#", "\\#",
+)
+
+// markdownText keeps user text on a single line and escapes Markdown markup
+func markdownText(s string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(s), " "))
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(markdownText(s), "|", "\\|")
+}
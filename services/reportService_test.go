@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_GenerateReport(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	from := time.Date(2025, 4, 7, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	save := func(task models.Task, tags ...models.TaskTag) {
+		task.Created = from.AddDate(0, -1, 0)
+		task.Updated = task.Created
+		if err := svc.Tasks.SaveTask(context.Background(), task); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+		for _, tag := range tags {
+			svc.Tags.SaveTag(context.Background(), "", tag)
+			if err := svc.Tags.AddTagToTask(context.Background(), task.Id, tag); err != nil {
+				t.Fatalf("AddTagToTask failed: %v", err)
+			}
+		}
+	}
+	save(models.Task{Id: "1", Title: "Fix *bug*", Completed: from.Add(time.Hour), Cost: models.CostM}, "work")
+	save(models.Task{Id: "2", Title: "Review", Completed: from.AddDate(0, 0, 3), Cost: models.CostS}, "work", "team")
+	save(models.Task{Id: "3", Title: "Groceries", Completed: from.AddDate(0, 0, 6), Cost: models.CostXS})
+	save(models.Task{Id: "4", Title: "Next week", Completed: to, Cost: models.CostXXL}, "work")
+	save(models.Task{Id: "5", Title: "Last week", Completed: from.Add(-time.Hour), Cost: models.CostXXL})
+	save(models.Task{Id: "6", Title: "Urgent open", Priority: models.PriorityUrgent, Wip: true}, "work")
+	save(models.Task{Id: "7", Title: "Low open", Priority: models.PriorityLow})
+
+	report, err := svc.Tasks.GenerateReport(context.Background(), "", from, to)
+	if err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+
+	if len(report.Completed) != 3 {
+		t.Errorf("expected 3 completed tasks, got %d", len(report.Completed))
+	}
+	if report.TotalMinutes != 60+30+10 {
+		t.Errorf("unexpected total time: %d", report.TotalMinutes)
+	}
+	if len(report.CompletedByTag) != 3 {
+		t.Fatalf("expected groups team, work, untagged; got %+v", report.CompletedByTag)
+	}
+	if g := report.CompletedByTag[1]; g.Tag != "work" || len(g.Tasks) != 2 || g.TotalMinutes != 90 {
+		t.Errorf("unexpected work group: %+v", g)
+	}
+	if g := report.CompletedByTag[2]; g.Tag != models.UNTAGGED_GROUP || len(g.Tasks) != 1 {
+		t.Errorf("untagged group should be last: %+v", g)
+	}
+	if len(report.HighPriority) != 1 || report.HighPriority[0].Id != "6" {
+		t.Errorf("unexpected high priority tasks: %+v", report.HighPriority)
+	}
+	if len(report.Wip) != 1 || report.Wip[0].Id != "6" {
+		t.Errorf("unexpected wip tasks: %+v", report.Wip)
+	}
+
+	var buf bytes.Buffer
+	if err := ReportToMarkdown(&buf, report); err != nil {
+		t.Fatalf("ReportToMarkdown failed: %v", err)
+	}
+	md := buf.String()
+	for _, expected := range []string{
+		"# Report 2025-04-07 – 2025-04-13",
+		"## Completed (3, 1h 40m)",
+		"### work (2, 1h 30m)",
+		`- [x] Fix \*bug\* (Low, ~1h)`,
+		"## Open high priority (1)",
+		"- [ ] Urgent open (Urgent) `work`",
+	} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("Markdown report missing %q:\n%s", expected, md)
+		}
+	}
+}
+
+func Test_ExportTasksToMarkdown(t *testing.T) {
+	tasks := []models.Task{
+		{Title: "Pipe | in title", Priority: models.PriorityHigh, Cost: models.CostM, Tags: []models.TaskTag{"a", "b"}},
+		{Title: "Done", Completed: time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC), Cost: models.CostS},
+	}
+	var buf bytes.Buffer
+	if err := ExportTasksToMarkdown(&buf, tasks); err != nil {
+		t.Fatalf("ExportTasksToMarkdown failed: %v", err)
+	}
+	md := buf.String()
+	for _, expected := range []string{
+		`| [ ] | Pipe \| in title | High | Slight | M (~1h) | S | a, b |  |`,
+		"| [x] | Done |",
+		"2025-01-01 08:00:00 |",
+		"Total: 2 tasks, 1h 30m",
+	} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("Markdown export missing %q:\n%s", expected, md)
+		}
+	}
+}
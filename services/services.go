@@ -0,0 +1,78 @@
+package services
+
+import (
+	"time"
+
+	"github.com/inaryzen/priotasks/db"
+)
+
+// Services are the operations of one priotasks instance on its store. Instances share no
+// state, so several of them can run in one process, e.g. in parallel tests.
+type Services struct {
+	Tasks    *TaskService
+	Tags     *TagService
+	Settings *SettingsService
+	Users    *UserService
+	Projects *ProjectService
+	Webhooks *WebhookService
+	*instance
+}
+
+type TaskService struct{ *instance }
+
+type TagService struct{ *instance }
+
+type SettingsService struct{ *instance }
+
+type ProjectService struct{ *instance }
+
+type UserService struct {
+	*instance
+	cleanup sessionCleanup
+}
+
+type WebhookService struct {
+	*instance
+	dispatcher *webhookDispatcher
+}
+
+// instance is what the services of one Services share: the store, the event bus and the
+// other services they call
+type instance struct {
+	store    db.Db
+	events   *eventBus
+	tasks    *TaskService
+	tags     *TagService
+	settings *SettingsService
+	users    *UserService
+	projects *ProjectService
+	webhooks *WebhookService
+	// timeZone is the time zone of the users who did not choose one
+	timeZone *time.Location
+}
+
+// New returns the services working on the store, which must be initialized
+func New(store db.Db) *Services {
+	in := &instance{store: store, events: newEventBus(), timeZone: time.Local}
+	in.tasks = &TaskService{in}
+	in.tags = &TagService{in}
+	in.settings = &SettingsService{in}
+	in.users = &UserService{instance: in}
+	in.projects = &ProjectService{in}
+	in.webhooks = &WebhookService{instance: in, dispatcher: newWebhookDispatcher(store)}
+	return &Services{
+		Tasks:    in.tasks,
+		Tags:     in.tags,
+		Settings: in.settings,
+		Users:    in.users,
+		Projects: in.projects,
+		Webhooks: in.webhooks,
+		instance: in,
+	}
+}
+
+// SetTimeZone sets the time zone of the users who did not choose one, the local time zone of
+// the server by default. It is set before the services are used.
+func (svc *Services) SetTimeZone(loc *time.Location) {
+	svc.timeZone = loc
+}
@@ -1,10 +1,10 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
-	"github.com/inaryzen/priotasks/db"
 	"github.com/inaryzen/priotasks/models"
 )
 
@@ -12,8 +12,9 @@ var (
 	ErrEmptyTag = errors.New("empty tag is not allowed")
 )
 
-func Tags() ([]models.TaskTag, error) {
-	tags, err := db.DB().Tags()
+// Tags returns the tags of the user
+func (svc *TagService) Tags(ctx context.Context, owner string) ([]models.TaskTag, error) {
+	tags, err := svc.store.Tags(ctx, owner)
 	if err != nil {
 		return nil, fmt.Errorf("Tags: %w", err)
 	} else {
@@ -21,23 +22,25 @@ func Tags() ([]models.TaskTag, error) {
 	}
 }
 
-func SaveTag(tag models.TaskTag) error {
+func (svc *TagService) SaveTag(ctx context.Context, owner string, tag models.TaskTag) error {
 	if tag.IsEmpty() {
 		return ErrEmptyTag
 	}
-	err := db.DB().SaveTag(string(tag))
+	err := svc.store.SaveTag(ctx, owner, string(tag))
 	if err != nil {
 		return fmt.Errorf("SaveTag: error tag=%v: %w", tag, err)
 	} else {
+		svc.publishTagsChanged(ctx, owner)
 		return nil
 	}
 }
 
-func AddTagToTask(taskId string, tag models.TaskTag) error {
+// AddTagToTask tags the task with one of the tags of its owner
+func (svc *TagService) AddTagToTask(ctx context.Context, taskId string, tag models.TaskTag) error {
 	if tag.IsEmpty() {
 		return ErrEmptyTag
 	}
-	err := db.DB().AddTagToTask(taskId, string(tag))
+	err := svc.store.AddTagToTask(ctx, taskId, string(tag))
 	if err != nil {
 		return fmt.Errorf("AddTagToTask: error tag=%v; taskId=%v: %w", tag, taskId, err)
 	} else {
@@ -45,45 +48,49 @@ func AddTagToTask(taskId string, tag models.TaskTag) error {
 	}
 }
 
-func DeleteTag(tag models.TaskTag) error {
-	if tag.IsEmpty() {
-		return ErrEmptyTag
-	}
+// DeleteTag removes the tag from the tasks of the owner and deletes it, both or neither
+func (svc *TagService) DeleteTag(ctx context.Context, owner string, tag models.TaskTag) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		if tag.IsEmpty() {
+			return ErrEmptyTag
+		}
 
-	// Start by removing it from all tasks
-	if err := db.DB().DeleteTagFromAllTasks(string(tag)); err != nil {
-		return fmt.Errorf("DeleteTag: failed to remove tag from tasks: %w", err)
-	}
+		// Start by removing it from all tasks
+		if err := svc.store.DeleteTagFromAllTasks(ctx, owner, string(tag)); err != nil {
+			return fmt.Errorf("DeleteTag: failed to remove tag from tasks: %w", err)
+		}
 
-	// Then delete the tag itself
-	if err := db.DB().DeleteTag(string(tag)); err != nil {
-		return fmt.Errorf("DeleteTag: failed to delete tag: %w", err)
-	}
+		// Then delete the tag itself
+		if err := svc.store.DeleteTag(ctx, owner, string(tag)); err != nil {
+			return fmt.Errorf("DeleteTag: failed to delete tag: %w", err)
+		}
 
-	return nil
+		svc.publishTagsChanged(ctx, owner)
+		return nil
+	})
 }
 
-func TaskTags(taskId string) ([]models.TaskTag, error) {
-	tags, err := db.DB().TaskTags(taskId)
+func (svc *TagService) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
+	tags, err := svc.store.TaskTags(ctx, taskId)
 	if err != nil {
 		return nil, fmt.Errorf("TaskTags: failed to get tags for task %s: %w", taskId, err)
 	}
 	return tags, nil
 }
 
-func RemoveTagFromTask(taskId string, tag models.TaskTag) error {
+func (svc *TagService) RemoveTagFromTask(ctx context.Context, taskId string, tag models.TaskTag) error {
 	if tag.IsEmpty() {
 		return ErrEmptyTag
 	}
-	err := db.DB().DeleteTagFromTask(taskId, string(tag))
+	err := svc.store.DeleteTagFromTask(ctx, taskId, string(tag))
 	if err != nil {
 		return fmt.Errorf("RemoveTagFromTask: taskId=%v, tag=%v: %w", taskId, tag, err)
 	}
 	return nil
 }
 
-func TasksTags(taskIds []string) (map[string][]models.TaskTag, error) {
-	tags, err := db.DB().TasksTags(taskIds)
+func (svc *TagService) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
+	tags, err := svc.store.TasksTags(ctx, owner, taskIds)
 	if err != nil {
 		return nil, fmt.Errorf("TasksTags: failed to get tags for tasks: %w", err)
 	}
@@ -1,16 +1,18 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
-	"github.com/inaryzen/priotasks/common"
 	"github.com/inaryzen/priotasks/db"
 	"github.com/inaryzen/priotasks/models"
 )
 
-func FindTasks(query models.TasksQuery) ([]models.Task, error) {
-	tasks, err := db.DB().FindTasks(query)
+func (svc *TaskService) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
+	tasks, err := svc.store.FindTasks(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve tasks: %w", err)
 	}
@@ -20,7 +22,7 @@ func FindTasks(query models.TasksQuery) ([]models.Task, error) {
 		taskIds[i] = task.Id
 	}
 
-	taskTags, err := db.DB().TasksTags(taskIds)
+	taskTags, err := svc.store.TasksTags(ctx, query.Owner, taskIds)
 	if err != nil {
 		return nil, fmt.Errorf("FindTasks: failed to retrieve task tags: %w", err)
 	}
@@ -32,47 +34,110 @@ func FindTasks(query models.TasksQuery) ([]models.Task, error) {
 	return tasks, nil
 }
 
-func DeleteTask(taskId string) error {
-	common.Debug("deleting task: %v", taskId)
-
-	err := db.DB().DeleteTask(taskId)
+// FindTask returns the task if the user owns it or is a member of its project;
+// db.ErrNotFound for the other tasks
+func (svc *TaskService) FindTask(ctx context.Context, owner, taskId string) (models.Task, error) {
+	task, err := svc.store.FindTask(ctx, taskId)
 	if err != nil {
-		log.Printf("failed to delete the task: %s: %s", taskId, err)
-		return err
+		return task, err
 	}
-	return nil
-}
-
-func DeleteAllTasks() error {
-	common.Debug("deleting all tasks")
-
-	err := db.DB().DeleteAllTasks()
+	ok, err := svc.projects.canAccessTask(ctx, owner, task)
 	if err != nil {
-		log.Printf("failed to delete all tasks: %s", err)
-		return err
+		return models.EMPTY_TASK, fmt.Errorf("FindTask: %w", err)
 	}
-	return nil
+	if !ok {
+		return models.EMPTY_TASK, db.ErrNotFound
+	}
+	return task, nil
 }
 
-func UpdateTask(changed models.Task, changedTags []models.TaskTag) error {
-	orig, err := db.DB().FindTask(changed.Id)
+func (svc *TaskService) DeleteTask(ctx context.Context, owner, taskId string) error {
+	task, err := svc.FindTask(ctx, owner, taskId)
 	if err != nil {
-		log.Printf("UpdateTask: failed to find the record: %s: %s", changed.Id, err)
-		return err
-	}
-	orig = orig.Update(changed)
-	if err = SaveTask(orig); err != nil {
 		return err
 	}
-	err = updateTaskTags(orig.Id, changedTags)
+	err = svc.store.DeleteTask(ctx, taskId)
 	if err != nil {
-		return err
+		return fmt.Errorf("DeleteTask: failed to delete the task: %v: %w", taskId, err)
 	}
+	svc.publishTaskDeleted(ctx, task)
 	return nil
 }
 
-func updateTaskTags(taskId string, changedTags []models.TaskTag) error {
-	origTags, err := TaskTags(taskId)
+var (
+	ErrConflict       = errors.New("task was changed in the meantime")
+	ErrMissingVersion = errors.New("the version the change is based on is missing")
+)
+
+// ConflictError is returned by UpdateTask for a stale write; Current is the stored task
+type ConflictError struct {
+	Current models.Task
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: %s was updated at %v", ErrConflict, e.Current.Id, e.Current.Updated)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// UpdateTask applies the change to the stored task. changed.Updated is the version the change
+// was based on: the update is rejected with ErrMissingVersion without it and with a
+// ConflictError if the stored task was updated since. changed.Owner is the user making the change, only their
+// own tasks and those of their projects are found; the task keeps its owner. The task and
+// its tags are saved in one transaction.
+func (svc *TaskService) UpdateTask(ctx context.Context, changed models.Task, changedTags []models.TaskTag) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		orig, err := svc.FindTask(ctx, changed.Owner, changed.Id)
+		if err != nil {
+			return err
+		}
+		if changed.Updated.IsZero() {
+			return ErrMissingVersion
+		}
+		if changed.Updated.Unix() != orig.Updated.Unix() {
+			current := orig
+			if current.Tags, err = svc.tags.TaskTags(ctx, orig.Id); err != nil {
+				return fmt.Errorf("UpdateTask: %w", err)
+			}
+			return &ConflictError{Current: current}
+		}
+		prev := orig
+		orig = orig.Update(changed)
+		if orig.Updated.Unix() <= prev.Updated.Unix() {
+			// keep versions unique when updates follow each other within a second
+			orig.Updated = prev.Updated.Truncate(time.Second).Add(time.Second)
+		}
+		if orig.Project != prev.Project || orig.Assignee != prev.Assignee {
+			if err = svc.projects.validateAssignment(ctx, changed.Owner, orig); err != nil {
+				return err
+			}
+		}
+		if orig.Owner != changed.Owner {
+			// a member tags a shared task with the tags of its owner
+			if err = svc.tags.ensureTags(ctx, orig.Owner, changedTags); err != nil {
+				return fmt.Errorf("UpdateTask: %w", err)
+			}
+		}
+		if err = svc.SaveTask(ctx, orig); err != nil {
+			return err
+		}
+		err = svc.updateTaskTags(ctx, orig.Id, changedTags)
+		if err != nil {
+			return err
+		}
+		svc.publishTaskSaved(ctx, orig)
+		if orig.Assignee != prev.Assignee && orig.Assignee != "" && orig.Assignee != changed.Owner {
+			svc.publishTaskAssigned(ctx, orig)
+		}
+		svc.webhooks.fireTaskWebhooks(ctx, &prev, orig, changedTags)
+		return nil
+	})
+}
+
+func (svc *TaskService) updateTaskTags(ctx context.Context, taskId string, changedTags []models.TaskTag) error {
+	origTags, err := svc.tags.TaskTags(ctx, taskId)
 	if err != nil {
 		return fmt.Errorf("updateTaskTags: %w", err)
 	}
@@ -92,14 +157,14 @@ func updateTaskTags(taskId string, changedTags []models.TaskTag) error {
 	}
 	newTags := findMissing(changedTags, origTags)
 	for _, t := range newTags {
-		err := AddTagToTask(taskId, t)
+		err := svc.tags.AddTagToTask(ctx, taskId, t)
 		if err != nil {
 			return fmt.Errorf("updateTaskTags: %w", err)
 		}
 	}
 	removeTags := findMissing(origTags, changedTags)
 	for _, t := range removeTags {
-		err := RemoveTagFromTask(taskId, t)
+		err := svc.tags.RemoveTagFromTask(ctx, taskId, t)
 		if err != nil {
 			return fmt.Errorf("updateTaskTags: %w", err)
 		}
@@ -107,104 +172,121 @@ func updateTaskTags(taskId string, changedTags []models.TaskTag) error {
 	return nil
 }
 
-func SaveNewTask(t models.Task, tags []models.TaskTag) error {
-	t = t.AsNewTask()
-	if err := SaveTask(t); err != nil {
-		return err
-	}
+// SaveNewTask saves the task of t.Owner, who must be a member of its project if it has one,
+// together with its tags
+func (svc *TaskService) SaveNewTask(ctx context.Context, t models.Task, tags []models.TaskTag) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		t = t.AsNewTask()
+		if err := svc.projects.validateAssignment(ctx, t.Owner, t); err != nil {
+			return err
+		}
+		if err := svc.SaveTask(ctx, t); err != nil {
+			return err
+		}
 
-	for _, tag := range tags {
-		err := AddTagToTask(t.Id, tag)
-		if err != nil {
-			return fmt.Errorf("SaveNewTask: %w", err)
+		for _, tag := range tags {
+			err := svc.tags.AddTagToTask(ctx, t.Id, tag)
+			if err != nil {
+				return fmt.Errorf("SaveNewTask: %w", err)
+			}
 		}
-	}
-	return nil
+		svc.publishTaskSaved(ctx, t)
+		if t.Assignee != "" && t.Assignee != t.Owner {
+			svc.publishTaskAssigned(ctx, t)
+		}
+		svc.webhooks.fireTaskWebhooks(ctx, nil, t, tags)
+		return nil
+	})
 }
 
-func SaveTask(c models.Task) error {
+func (svc *TaskService) SaveTask(ctx context.Context, c models.Task) error {
 	c = c.CalculateValue()
 
-	err := db.DB().SaveTask(c)
+	err := svc.store.SaveTask(ctx, c)
 	if err != nil {
-		log.Printf("failed to update the record: %s: %s", c.Id, err)
-		return err
+		return fmt.Errorf("SaveTask: %w", err)
 	}
 	return nil
 }
 
-func FlipTask(card models.Task) error {
-	if card.Completed == models.NOT_COMPLETED {
-		card = card.Complete()
-	} else {
-		card = card.Uncomplete()
-	}
-	err := db.DB().SaveTask(card)
-	if err != nil {
-		return fmt.Errorf("failed to flip the card: %v: %w", card.Id, err)
-	}
-	if common.IsDebug() {
-		log.Printf("Updated Completed status of card: %v", card)
-	}
-	return nil
-}
-
-func ReducePriorityForVisibleTasks(query models.TasksQuery) error {
-	tasks, err := FindTasks(query)
-	if err != nil {
-		return fmt.Errorf("ReducePriorityForVisibleTasks: failed to retrieve tasks: %w", err)
-	}
+// ReducePriorityForVisibleTasks lowers the priority of all the tasks the query finds, or of none
+func (svc *TaskService) ReducePriorityForVisibleTasks(ctx context.Context, query models.TasksQuery) error {
+	return svc.store.WithTx(ctx, func(ctx context.Context) error {
+		tasks, err := svc.FindTasks(ctx, query)
+		if err != nil {
+			return fmt.Errorf("ReducePriorityForVisibleTasks: failed to retrieve tasks: %w", err)
+		}
 
-	for _, task := range tasks {
-		newPriority := task.Priority.Reduce()
-		if newPriority != task.Priority {
-			task.Priority = newPriority
-			if err := SaveTask(task); err != nil {
-				return fmt.Errorf("ReducePriorityForVisibleTasks: failed to save task %s: %w", task.Id, err)
+		for _, task := range tasks {
+			newPriority := task.Priority.Reduce()
+			if newPriority != task.Priority {
+				prev := task
+				task.Priority = newPriority
+				if err := svc.SaveTask(ctx, task); err != nil {
+					return fmt.Errorf("ReducePriorityForVisibleTasks: failed to save task %s: %w", task.Id, err)
+				}
+				svc.webhooks.fireTaskWebhooks(ctx, &prev, task, nil)
 			}
 		}
-	}
-	return nil
+		svc.publishTasksChanged(ctx, query.Owner)
+		return nil
+	})
 }
 
-func CloneTask(taskId string) (models.Task, error) {
+// CloneTask copies the task with its tags for the owner
+func (svc *TaskService) CloneTask(ctx context.Context, owner, taskId string) (models.Task, error) {
 	pfx := "CloneTask:"
-	log.Printf("%s cloning task with ID: %s", pfx, taskId)
+	slog.DebugContext(ctx, pfx, "task_id", taskId)
 
-	// ai: Get the original task
-	originalTask, err := db.DB().FindTask(taskId)
-	if err != nil {
-		return models.Task{}, fmt.Errorf("%s failed to find original task: %w", pfx, err)
-	}
-
-	// ai: Get the original task's tags
-	originalTags, err := db.DB().TaskTags(taskId)
-	if err != nil {
-		return models.Task{}, fmt.Errorf("%s failed to get original task tags: %w", pfx, err)
-	}
+	var clonedTask models.Task
+	err := svc.store.WithTx(ctx, func(ctx context.Context) error {
+		// ai: Get the original task
+		originalTask, err := svc.FindTask(ctx, owner, taskId)
+		if err != nil {
+			return fmt.Errorf("%s failed to find original task: %w", pfx, err)
+		}
 
-	// ai: Create cloned task with modified properties
-	clonedTask := originalTask
-	clonedTask.Title = "Copy of " + originalTask.Title
-	clonedTask.Completed = models.NOT_COMPLETED // Reset completion status
+		// ai: Get the original task's tags
+		originalTags, err := svc.store.TaskTags(ctx, taskId)
+		if err != nil {
+			return fmt.Errorf("%s failed to get original task tags: %w", pfx, err)
+		}
 
-	// ai: Save the cloned task (AsNewTask will generate new ID and timestamps)
-	clonedTask = clonedTask.AsNewTask()
-	err = SaveTask(clonedTask)
-	if err != nil {
-		return models.Task{}, fmt.Errorf("%s failed to save cloned task: %w", pfx, err)
-	}
+		// ai: Create cloned task with modified properties
+		clonedTask = originalTask
+		clonedTask.Title = "Copy of " + originalTask.Title
+		clonedTask.Completed = models.NOT_COMPLETED // Reset completion status
+		if clonedTask.Owner != owner {
+			// the clone of a shared task belongs to the member cloning it
+			clonedTask.Owner = owner
+			if err := svc.tags.ensureTags(ctx, owner, originalTags); err != nil {
+				return fmt.Errorf("%s %w", pfx, err)
+			}
+		}
 
-	// ai: Add tags to the cloned task
-	for _, tag := range originalTags {
-		err := AddTagToTask(clonedTask.Id, tag)
+		// ai: Save the cloned task (AsNewTask will generate new ID and timestamps)
+		clonedTask = clonedTask.AsNewTask()
+		err = svc.SaveTask(ctx, clonedTask)
 		if err != nil {
-			return models.Task{}, fmt.Errorf("%s failed to add tag to cloned task: %w", pfx, err)
+			return fmt.Errorf("%s failed to save cloned task: %w", pfx, err)
 		}
-	}
 
-	// ai: Set the tags on the returned task object
-	clonedTask.Tags = originalTags
+		// ai: Add tags to the cloned task
+		for _, tag := range originalTags {
+			err := svc.tags.AddTagToTask(ctx, clonedTask.Id, tag)
+			if err != nil {
+				return fmt.Errorf("%s failed to add tag to cloned task: %w", pfx, err)
+			}
+		}
 
+		// ai: Set the tags on the returned task object
+		clonedTask.Tags = originalTags
+		svc.publishTaskSaved(ctx, clonedTask)
+		svc.webhooks.fireTaskWebhooks(ctx, nil, clonedTask, originalTags)
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
 	return clonedTask, nil
 }
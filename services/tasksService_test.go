@@ -1,9 +1,12 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"slices"
 
@@ -19,7 +22,7 @@ type MockDB struct {
 	taskTags   map[string][]models.TaskTag
 }
 
-func (m *MockDB) Tasks() ([]models.Task, error) {
+func (m *MockDB) Tasks(ctx context.Context) ([]models.Task, error) {
 	var result []models.Task
 	for _, value := range m.tasks {
 		result = append(result, value)
@@ -27,7 +30,7 @@ func (m *MockDB) Tasks() ([]models.Task, error) {
 	return result, nil
 }
 
-func (m *MockDB) SaveTag(tagId string) error {
+func (m *MockDB) SaveTag(ctx context.Context, owner, tagId string) error {
 	if m.tags == nil {
 		m.tags = make(map[string]bool)
 	}
@@ -35,14 +38,14 @@ func (m *MockDB) SaveTag(tagId string) error {
 	return nil
 }
 
-func (m *MockDB) TaskTags(taskId string) ([]models.TaskTag, error) {
+func (m *MockDB) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
 	if m.taskTags == nil {
 		return nil, nil
 	}
 	return m.taskTags[taskId], nil
 }
 
-func (m *MockDB) Tags() ([]models.TaskTag, error) {
+func (m *MockDB) Tags(ctx context.Context, owner string) ([]models.TaskTag, error) {
 	var tags []models.TaskTag
 	for tag := range m.tags {
 		tags = append(tags, models.TaskTag(tag))
@@ -50,7 +53,7 @@ func (m *MockDB) Tags() ([]models.TaskTag, error) {
 	return tags, nil
 }
 
-func (m *MockDB) AddTagToTask(taskId, tagId string) error {
+func (m *MockDB) AddTagToTask(ctx context.Context, taskId, tagId string) error {
 	if m.taskTags == nil {
 		m.taskTags = make(map[string][]models.TaskTag)
 	}
@@ -58,7 +61,7 @@ func (m *MockDB) AddTagToTask(taskId, tagId string) error {
 	return nil
 }
 
-func (m *MockDB) DeleteTagFromTask(taskId, tagId string) error {
+func (m *MockDB) DeleteTagFromTask(ctx context.Context, taskId, tagId string) error {
 	if m.taskTags == nil {
 		return db.ErrNotFound
 	}
@@ -78,14 +81,14 @@ func (m *MockDB) DeleteTagFromTask(taskId, tagId string) error {
 	return db.ErrNotFound
 }
 
-func (m *MockDB) FindTask(taskId string) (models.Task, error) {
+func (m *MockDB) FindTask(ctx context.Context, taskId string) (models.Task, error) {
 	if task, exists := m.tasks[taskId]; exists {
 		return task, nil
 	}
 	return models.Task{}, db.ErrNotFound
 }
 
-func (m *MockDB) SaveTask(task models.Task) error {
+func (m *MockDB) SaveTask(ctx context.Context, task models.Task) error {
 	if m.tasks == nil {
 		m.tasks = make(map[string]models.Task)
 	}
@@ -93,18 +96,18 @@ func (m *MockDB) SaveTask(task models.Task) error {
 	return nil
 }
 
-func (m *MockDB) MigrationExists(id string) bool {
+func (m *MockDB) MigrationExists(ctx context.Context, id string) bool {
 	return m.migrations[id]
 }
 
-func (m *MockDB) RecordMigration(id string) {
+func (m *MockDB) RecordMigration(ctx context.Context, id string) {
 	if m.migrations == nil {
 		m.migrations = make(map[string]bool)
 	}
 	m.migrations[id] = true
 }
 
-func (m *MockDB) TasksTags(taskIds []string) (map[string][]models.TaskTag, error) {
+func (m *MockDB) TasksTags(ctx context.Context, owner string, taskIds []string) (map[string][]models.TaskTag, error) {
 	result := make(map[string][]models.TaskTag)
 	if m.taskTags == nil {
 		return result, nil
@@ -118,19 +121,25 @@ func (m *MockDB) TasksTags(taskIds []string) (map[string][]models.TaskTag, error
 	return result, nil
 }
 
-func setupTestDB() *MockDB {
+// setupMemDB returns the services on an empty in-memory store, which behaves as DbSQLite does
+func setupMemDB(t *testing.T) *Services {
+	t.Helper()
+	return New(db.NewMemDB())
+}
+
+func setupTestDB() (*Services, *MockDB) {
 	mockDB := &MockDB{
 		tasks:      make(map[string]models.Task),
 		migrations: make(map[string]bool),
 		tags:       make(map[string]bool),
 		taskTags:   make(map[string][]models.TaskTag),
 	}
-	db.SetDB(mockDB)
-	return mockDB
+	return New(mockDB), mockDB
 }
 
 func TestSaveNewTask_DoNotTouchTitleIfItDefined(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	task := models.Task{
 		Title:     "ExistingTitle",
@@ -141,12 +150,12 @@ func TestSaveNewTask_DoNotTouchTitleIfItDefined(t *testing.T) {
 		Completed: models.NOT_COMPLETED,
 	}
 
-	err := SaveNewTask(task, nil)
+	err := svc.Tasks.SaveNewTask(context.Background(), task, nil)
 	if err != nil {
 		t.Errorf("SaveNewTask failed: %v", err)
 	}
 
-	actual, _ := mockDB.Tasks()
+	actual, _ := mockDB.Tasks(context.Background())
 	expectedTitle := "ExistingTitle"
 	if actual[0].Title != expectedTitle {
 		t.Errorf("error; actual=%v; expected=%v", actual[0].Title, expectedTitle)
@@ -154,7 +163,8 @@ func TestSaveNewTask_DoNotTouchTitleIfItDefined(t *testing.T) {
 }
 
 func TestSaveNewTask_TitleCorrectlyUpdated(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	task := models.Task{
 		Title:     "",
@@ -165,12 +175,12 @@ func TestSaveNewTask_TitleCorrectlyUpdated(t *testing.T) {
 		Completed: models.NOT_COMPLETED,
 	}
 
-	err := SaveNewTask(task, nil)
+	err := svc.Tasks.SaveNewTask(context.Background(), task, nil)
 	if err != nil {
 		t.Errorf("SaveNewTask failed: %v", err)
 	}
 
-	actual, _ := mockDB.Tasks()
+	actual, _ := mockDB.Tasks(context.Background())
 	expectedTitle := "LongTitleLongTitleLongTitleLongTitleLongTitleLongTitleLongTitleL"
 	if actual[0].Title != expectedTitle {
 		t.Errorf("error; actual=%v; expected=%v", actual[0].Title, expectedTitle)
@@ -178,7 +188,8 @@ func TestSaveNewTask_TitleCorrectlyUpdated(t *testing.T) {
 }
 
 func TestSaveNewTask_TitleDoesNotHaveLineBreak(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	task := models.Task{
 		Title:     "",
@@ -189,12 +200,12 @@ func TestSaveNewTask_TitleDoesNotHaveLineBreak(t *testing.T) {
 		Completed: models.NOT_COMPLETED,
 	}
 
-	err := SaveNewTask(task, nil)
+	err := svc.Tasks.SaveNewTask(context.Background(), task, nil)
 	if err != nil {
 		t.Errorf("SaveNewTask failed: %v", err)
 	}
 
-	actual, _ := mockDB.Tasks()
+	actual, _ := mockDB.Tasks(context.Background())
 	expectedTitle := "LongTitleLongTitleLong"
 	if actual[0].Title != expectedTitle {
 		t.Errorf("error; actual=%v; expected=%v", actual[0].Title, expectedTitle)
@@ -202,7 +213,8 @@ func TestSaveNewTask_TitleDoesNotHaveLineBreak(t *testing.T) {
 }
 
 func TestUpdateTask(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	taskId := "Id"
 	originalTask := models.Task{
@@ -210,20 +222,21 @@ func TestUpdateTask(t *testing.T) {
 		Title:    "Title",
 		Content:  "Content",
 		Priority: models.PriorityLow,
+		Updated:  time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
 	updatedTask := originalTask
 	updatedTask.Title = "NewTitle"
 	updatedTask.Content = "NewContent"
 	updatedTask.Priority = models.PriorityLow
 
-	err := UpdateTask(updatedTask, nil)
+	err := svc.Tasks.UpdateTask(context.Background(), updatedTask, nil)
 	if err != nil {
 		t.Errorf("UpdateTask failed: %v", err)
 	}
 
-	saved, err := mockDB.FindTask(taskId)
+	saved, err := mockDB.FindTask(context.Background(), taskId)
 	if err != nil {
 		t.Errorf("Failed to retrieve updated task: %v", err)
 	}
@@ -235,7 +248,8 @@ func TestUpdateTask(t *testing.T) {
 }
 
 func TestSaveTask(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	task := models.Task{
 		Id:       "test-id",
@@ -246,12 +260,12 @@ func TestSaveTask(t *testing.T) {
 		Cost:     models.CostL,
 	}
 
-	err := SaveTask(task)
+	err := svc.Tasks.SaveTask(context.Background(), task)
 	if err != nil {
 		t.Errorf("SaveTask failed: %v", err)
 	}
 
-	saved, err := mockDB.FindTask("test-id")
+	saved, err := mockDB.FindTask(context.Background(), "test-id")
 	if err != nil {
 		t.Errorf("Failed to retrieve saved task: %v", err)
 	}
@@ -270,14 +284,15 @@ func TestSaveTask(t *testing.T) {
 }
 
 func TestUpdateTaskTags(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 	taskId := "test-task"
 
 	// Initial tags
 	initialTags := []models.TaskTag{"tag1", "tag2"}
 	for _, tag := range initialTags {
-		mockDB.SaveTag(string(tag))
-		mockDB.AddTagToTask(taskId, string(tag))
+		mockDB.SaveTag(context.Background(), "", string(tag))
+		mockDB.AddTagToTask(context.Background(), taskId, string(tag))
 	}
 
 	// Test cases
@@ -305,14 +320,14 @@ func TestUpdateTaskTags(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := updateTaskTags(taskId, tt.changedTags)
+			err := svc.Tasks.updateTaskTags(context.Background(), taskId, tt.changedTags)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("updateTaskTags() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			// Verify tags were updated correctly
-			resultTags, err := TaskTags(taskId)
+			resultTags, err := svc.Tags.TaskTags(context.Background(), taskId)
 			if err != nil {
 				t.Errorf("Failed to get task tags: %v", err)
 				return
@@ -339,16 +354,17 @@ type reducePriorityMockDB struct {
 	savedTasks    []models.Task
 }
 
-func (m *reducePriorityMockDB) FindTasks(query models.TasksQuery) ([]models.Task, error) {
+func (m *reducePriorityMockDB) FindTasks(ctx context.Context, query models.TasksQuery) ([]models.Task, error) {
 	return m.expectedTasks, nil
 }
 
-func (m *reducePriorityMockDB) SaveTask(task models.Task) error {
+func (m *reducePriorityMockDB) SaveTask(ctx context.Context, task models.Task) error {
 	m.savedTasks = append(m.savedTasks, task)
 	return nil
 }
 
 func TestReducePriorityForVisibleTasks(t *testing.T) {
+	t.Parallel()
 	tests := []struct {
 		name          string
 		tasks         []models.Task
@@ -392,9 +408,9 @@ func TestReducePriorityForVisibleTasks(t *testing.T) {
 			mockDB := &reducePriorityMockDB{
 				expectedTasks: tt.tasks,
 			}
-			db.SetDB(mockDB)
+			svc := New(mockDB)
 
-			err := ReducePriorityForVisibleTasks(tt.query)
+			err := svc.Tasks.ReducePriorityForVisibleTasks(context.Background(), tt.query)
 			if err != nil {
 				t.Fatalf("ReducePriorityForVisibleTasks failed: %v", err)
 			}
@@ -444,7 +460,8 @@ func TestReducePriorityForVisibleTasks(t *testing.T) {
 
 // ai: Happy Path Tests for CloneTask
 func Test_CloneTask_Success(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:       "original-id",
@@ -457,9 +474,9 @@ func Test_CloneTask_Success(t *testing.T) {
 		Wip:      true,
 		Planned:  false,
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -494,22 +511,23 @@ func Test_CloneTask_Success(t *testing.T) {
 }
 
 func Test_CloneTask_WithTags(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:    "original-id",
 		Title: "Task with tags",
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
 	// ai: Add tags to original task
 	tags := []models.TaskTag{"urgent", "work", "project"}
 	for _, tag := range tags {
-		mockDB.SaveTag(string(tag))
-		mockDB.AddTagToTask("original-id", string(tag))
+		mockDB.SaveTag(context.Background(), "", string(tag))
+		mockDB.AddTagToTask(context.Background(), "original-id", string(tag))
 	}
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -533,15 +551,16 @@ func Test_CloneTask_WithTags(t *testing.T) {
 }
 
 func Test_CloneTask_WithoutTags(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:    "original-id",
 		Title: "Task without tags",
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -552,16 +571,17 @@ func Test_CloneTask_WithoutTags(t *testing.T) {
 }
 
 func Test_CloneTask_CompletedTask(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:        "original-id",
 		Title:     "Completed Task",
 		Completed: models.NOT_COMPLETED.Add(24 * 60 * 60 * 1000000000), // 1 day ago
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -573,7 +593,8 @@ func Test_CloneTask_CompletedTask(t *testing.T) {
 
 // ai: Property Validation Tests for CloneTask
 func Test_CloneTask_TitlePrefix(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	testCases := []struct {
 		name          string
@@ -592,9 +613,9 @@ func Test_CloneTask_TitlePrefix(t *testing.T) {
 				Id:    taskId,
 				Title: tc.originalTitle,
 			}
-			mockDB.SaveTask(originalTask)
+			mockDB.SaveTask(context.Background(), originalTask)
 
-			clonedTask, err := CloneTask(taskId)
+			clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", taskId)
 			if err != nil {
 				t.Fatalf("CloneTask failed: %v", err)
 			}
@@ -607,15 +628,16 @@ func Test_CloneTask_TitlePrefix(t *testing.T) {
 }
 
 func Test_CloneTask_NewIdGenerated(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:    "original-id",
 		Title: "Test Task",
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -629,7 +651,8 @@ func Test_CloneTask_NewIdGenerated(t *testing.T) {
 }
 
 func Test_CloneTask_NewTimestamps(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:      "original-id",
@@ -637,9 +660,9 @@ func Test_CloneTask_NewTimestamps(t *testing.T) {
 		Created: models.NOT_COMPLETED.Add(-24 * 60 * 60 * 1000000000), // 1 day ago
 		Updated: models.NOT_COMPLETED.Add(-12 * 60 * 60 * 1000000000), // 12 hours ago
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -651,16 +674,17 @@ func Test_CloneTask_NewTimestamps(t *testing.T) {
 }
 
 func Test_CloneTask_CompletionReset(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:        "original-id",
 		Title:     "Completed Task",
 		Completed: models.NOT_COMPLETED.Add(24 * 60 * 60 * 1000000000), // 1 day ago
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -671,7 +695,8 @@ func Test_CloneTask_CompletionReset(t *testing.T) {
 }
 
 func Test_CloneTask_OtherPropertiesCopied(t *testing.T) {
-	mockDB := setupTestDB()
+	t.Parallel()
+	svc, mockDB := setupTestDB()
 
 	originalTask := models.Task{
 		Id:       "original-id",
@@ -684,9 +709,9 @@ func Test_CloneTask_OtherPropertiesCopied(t *testing.T) {
 		Wip:      true,
 		Planned:  true,
 	}
-	mockDB.SaveTask(originalTask)
+	mockDB.SaveTask(context.Background(), originalTask)
 
-	clonedTask, err := CloneTask("original-id")
+	clonedTask, err := svc.Tasks.CloneTask(context.Background(), "", "original-id")
 	if err != nil {
 		t.Fatalf("CloneTask failed: %v", err)
 	}
@@ -716,9 +741,10 @@ func Test_CloneTask_OtherPropertiesCopied(t *testing.T) {
 
 // ai: Error Handling Tests for CloneTask
 func Test_CloneTask_TaskNotFound(t *testing.T) {
-	setupTestDB()
+	t.Parallel()
+	svc, _ := setupTestDB()
 
-	_, err := CloneTask("non-existent-id")
+	_, err := svc.Tasks.CloneTask(context.Background(), "", "non-existent-id")
 	if err == nil {
 		t.Error("Expected error when cloning non-existent task")
 	}
@@ -736,35 +762,36 @@ type errorMockDB struct {
 	addTagError   error
 }
 
-func (m *errorMockDB) FindTask(taskId string) (models.Task, error) {
+func (m *errorMockDB) FindTask(ctx context.Context, taskId string) (models.Task, error) {
 	if m.findTaskError != nil {
 		return models.Task{}, m.findTaskError
 	}
-	return m.MockDB.FindTask(taskId)
+	return m.MockDB.FindTask(ctx, taskId)
 }
 
-func (m *errorMockDB) TaskTags(taskId string) ([]models.TaskTag, error) {
+func (m *errorMockDB) TaskTags(ctx context.Context, taskId string) ([]models.TaskTag, error) {
 	if m.taskTagsError != nil {
 		return nil, m.taskTagsError
 	}
-	return m.MockDB.TaskTags(taskId)
+	return m.MockDB.TaskTags(ctx, taskId)
 }
 
-func (m *errorMockDB) SaveTask(task models.Task) error {
+func (m *errorMockDB) SaveTask(ctx context.Context, task models.Task) error {
 	if m.saveTaskError != nil {
 		return m.saveTaskError
 	}
-	return m.MockDB.SaveTask(task)
+	return m.MockDB.SaveTask(ctx, task)
 }
 
-func (m *errorMockDB) AddTagToTask(taskId, tagId string) error {
+func (m *errorMockDB) AddTagToTask(ctx context.Context, taskId, tagId string) error {
 	if m.addTagError != nil {
 		return m.addTagError
 	}
-	return m.MockDB.AddTagToTask(taskId, tagId)
+	return m.MockDB.AddTagToTask(ctx, taskId, tagId)
 }
 
 func Test_CloneTask_DatabaseError(t *testing.T) {
+	t.Parallel()
 	mockDB := &errorMockDB{
 		MockDB: MockDB{
 			tasks: map[string]models.Task{
@@ -773,9 +800,9 @@ func Test_CloneTask_DatabaseError(t *testing.T) {
 		},
 		taskTagsError: fmt.Errorf("database connection error"),
 	}
-	db.SetDB(mockDB)
+	svc := New(mockDB)
 
-	_, err := CloneTask("test-id")
+	_, err := svc.Tasks.CloneTask(context.Background(), "", "test-id")
 	if err == nil {
 		t.Error("Expected error when database operation fails")
 	}
@@ -785,6 +812,7 @@ func Test_CloneTask_DatabaseError(t *testing.T) {
 }
 
 func Test_CloneTask_SaveTaskFails(t *testing.T) {
+	t.Parallel()
 	mockDB := &errorMockDB{
 		MockDB: MockDB{
 			tasks: map[string]models.Task{
@@ -796,9 +824,9 @@ func Test_CloneTask_SaveTaskFails(t *testing.T) {
 		},
 		saveTaskError: fmt.Errorf("save operation failed"),
 	}
-	db.SetDB(mockDB)
+	svc := New(mockDB)
 
-	_, err := CloneTask("test-id")
+	_, err := svc.Tasks.CloneTask(context.Background(), "", "test-id")
 	if err == nil {
 		t.Error("Expected error when SaveTask fails")
 	}
@@ -808,6 +836,7 @@ func Test_CloneTask_SaveTaskFails(t *testing.T) {
 }
 
 func Test_CloneTask_AddTagFails(t *testing.T) {
+	t.Parallel()
 	mockDB := &errorMockDB{
 		MockDB: MockDB{
 			tasks: map[string]models.Task{
@@ -819,9 +848,9 @@ func Test_CloneTask_AddTagFails(t *testing.T) {
 		},
 		addTagError: fmt.Errorf("add tag operation failed"),
 	}
-	db.SetDB(mockDB)
+	svc := New(mockDB)
 
-	_, err := CloneTask("test-id")
+	_, err := svc.Tasks.CloneTask(context.Background(), "", "test-id")
 	if err == nil {
 		t.Error("Expected error when AddTagToTask fails")
 	}
@@ -831,6 +860,7 @@ func Test_CloneTask_AddTagFails(t *testing.T) {
 }
 
 func Test_CloneTask_GetTagsFails(t *testing.T) {
+	t.Parallel()
 	mockDB := &errorMockDB{
 		MockDB: MockDB{
 			tasks: map[string]models.Task{
@@ -839,9 +869,9 @@ func Test_CloneTask_GetTagsFails(t *testing.T) {
 		},
 		taskTagsError: fmt.Errorf("get tags operation failed"),
 	}
-	db.SetDB(mockDB)
+	svc := New(mockDB)
 
-	_, err := CloneTask("test-id")
+	_, err := svc.Tasks.CloneTask(context.Background(), "", "test-id")
 	if err == nil {
 		t.Error("Expected error when TaskTags fails")
 	}
@@ -849,3 +879,181 @@ func Test_CloneTask_GetTagsFails(t *testing.T) {
 		t.Errorf("Expected 'failed to get original task tags' error, got: %v", err)
 	}
 }
+
+func Test_UpdateTask_RejectsStaleVersion(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "Original"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	tasks, _ := svc.Tasks.FindTasks(context.Background(), models.TasksQuery{})
+	opened := tasks[0]
+
+	first := opened
+	first.Title = "First tab"
+	if err := svc.Tasks.UpdateTask(context.Background(), first, nil); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+
+	second := opened
+	second.Title = "Second tab"
+	err := svc.Tasks.UpdateTask(context.Background(), second, nil)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if conflict.Current.Title != "First tab" {
+		t.Errorf("conflict should carry the stored task: %+v", conflict.Current)
+	}
+
+	// a write based on the current version goes through, even within the same second
+	second.Updated = conflict.Current.Updated
+	if err := svc.Tasks.UpdateTask(context.Background(), second, nil); err != nil {
+		t.Fatalf("update based on the current version failed: %v", err)
+	}
+	stored, _ := svc.Tasks.FindCalDAVTask(context.Background(), "", opened.Id)
+	if stored.Title != "Second tab" || stored.Version() == conflict.Current.Version() {
+		t.Errorf("unexpected stored task: %+v", stored)
+	}
+
+	// a write without a version is rejected rather than overwriting blindly
+	second.Updated = time.Time{}
+	second.Title = "Forced"
+	if err := svc.Tasks.UpdateTask(context.Background(), second, nil); !errors.Is(err, ErrMissingVersion) {
+		t.Errorf("expected the missing version to be rejected, got %v", err)
+	}
+}
+
+func TestTasks_IsolatedPerUser(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	for _, owner := range []string{"alice", "bob"} {
+		if err := svc.Tags.SaveTag(context.Background(), owner, models.TaskTag(owner)); err != nil {
+			t.Fatalf("SaveTag failed: %v", err)
+		}
+		if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: owner, Owner: owner}, []models.TaskTag{models.TaskTag(owner)}); err != nil {
+			t.Fatalf("SaveNewTask failed: %v", err)
+		}
+	}
+	settings, err := svc.Settings.FindUserSettings(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("FindUserSettings failed: %v", err)
+	}
+	settings.TasksQuery.FilterCompleted = false
+	if err := svc.Settings.UpdateUserSettings(context.Background(), settings); err != nil {
+		t.Fatalf("UpdateUserSettings failed: %v", err)
+	}
+	if s, _ := svc.Settings.FindUserSettings(context.Background(), "bob"); !s.TasksQuery.FilterCompleted {
+		t.Error("the settings of alice should not change those of bob")
+	}
+
+	tasks, err := svc.Tasks.FindTasks(context.Background(), settings.TasksQuery)
+	if err != nil || len(tasks) != 1 || tasks[0].Title != "alice" || len(tasks[0].Tags) != 1 {
+		t.Fatalf("expected only the task of alice, got %+v, %v", tasks, err)
+	}
+	if tags, _ := svc.Tags.Tags(context.Background(), "alice"); len(tags) != 1 || tags[0] != "alice" {
+		t.Errorf("expected only the tag of alice, got %v", tags)
+	}
+
+	aliceTask := tasks[0]
+	if err := svc.Tags.AddTagToTask(context.Background(), aliceTask.Id, "bob"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("expected the tag of another user to be rejected, got %v", err)
+	}
+	if _, err := svc.Tasks.FindTask(context.Background(), "bob", aliceTask.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("bob should not find the task of alice, got %v", err)
+	}
+	if err := svc.Tasks.UpdateTask(context.Background(), models.Task{Id: aliceTask.Id, Title: "taken", Owner: "bob"}, nil); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("bob should not update the task of alice, got %v", err)
+	}
+	if _, err := svc.Tasks.CloneTask(context.Background(), "bob", aliceTask.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("bob should not clone the task of alice, got %v", err)
+	}
+	if err := svc.Tasks.DeleteTask(context.Background(), "bob", aliceTask.Id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("bob should not delete the task of alice, got %v", err)
+	}
+	if _, err := svc.Tasks.FindTask(context.Background(), "alice", aliceTask.Id); err != nil {
+		t.Errorf("the task of alice should be kept, got %v", err)
+	}
+}
+
+func Test_SaveNewTask_RollsBackOnTagFailure(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ctx := context.Background()
+	if err := svc.Tags.SaveTag(ctx, "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	events, unsubscribe := svc.SubscribeEvents("")
+	defer unsubscribe()
+
+	err := svc.Tasks.SaveNewTask(ctx, models.Task{Title: "Task"}, []models.TaskTag{"work", "unknown"})
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the unknown tag to fail, got %v", err)
+	}
+	tasks, err := svc.store.Tasks(ctx)
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("expected no task to be left behind, got %v %v", tasks, err)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("expected no event for a rolled back task, got %v", e)
+	default:
+	}
+}
+
+func Test_UpdateTask_RollsBackOnTagFailure(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ctx := context.Background()
+	for _, tag := range []models.TaskTag{"work", "home"} {
+		if err := svc.Tags.SaveTag(ctx, "", tag); err != nil {
+			t.Fatalf("SaveTag failed: %v", err)
+		}
+	}
+	if err := svc.Tasks.SaveNewTask(ctx, models.Task{Title: "Before"}, []models.TaskTag{"work"}); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	tasks, err := svc.store.Tasks(ctx)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("expected the saved task, got %v %v", tasks, err)
+	}
+	id := tasks[0].Id
+
+	err = svc.Tasks.UpdateTask(ctx, models.Task{Id: id, Title: "After", Updated: tasks[0].Updated}, []models.TaskTag{"home", "unknown"})
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the unknown tag to fail, got %v", err)
+	}
+	task, err := svc.Tasks.FindTask(ctx, "", id)
+	if err != nil || task.Title != "Before" {
+		t.Errorf("expected the title to be rolled back, got %q %v", task.Title, err)
+	}
+	if tags, _ := svc.Tags.TaskTags(ctx, id); !slices.Equal(tags, []models.TaskTag{"work"}) {
+		t.Errorf("expected the tags to be rolled back, got %v", tags)
+	}
+}
+
+func Test_DeleteTag_RemovesFromTasks(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	ctx := context.Background()
+	if err := svc.Tags.SaveTag(ctx, "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if err := svc.Tasks.SaveNewTask(ctx, models.Task{Title: "Task"}, []models.TaskTag{"work"}); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	tasks, err := svc.store.Tasks(ctx)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("expected the saved task, got %v %v", tasks, err)
+	}
+
+	if err := svc.Tags.DeleteTag(ctx, "", "work"); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	if tags, _ := svc.Tags.TaskTags(ctx, tasks[0].Id); len(tags) != 0 {
+		t.Errorf("expected the tag to be removed from the task, got %v", tags)
+	}
+	if tags, _ := svc.Tags.Tags(ctx, ""); len(tags) != 0 {
+		t.Errorf("expected the tag to be deleted, got %v", tags)
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+// Taskwarrior tasks carry the uuid, description, entry/modified/end timestamps, status,
+// priority (H, M, L), project, tags and annotations. The project becomes a "project:<name>"
+// tag, annotations become the lines of the content, an active task (start) is work in
+// progress. Fields Taskwarrior has no attribute for are written as user defined attributes,
+// which Taskwarrior keeps when it imports and exports the tasks again. The Taskwarrior
+// attributes priotasks has no field for (due, scheduled, wait, until, depends, recur) are
+// reported as warnings of the import.
+const (
+	TASKWARRIOR_TIME_FORMAT = "20060102T150405Z"
+	TASKWARRIOR_PROJECT_TAG = "project:"
+	// TASKWARRIOR_ANNOTATION_STAMP prefixes the content line of an annotation with the time it
+	// was made, unless it is the time the line is exported with, see annotationEntry
+	TASKWARRIOR_ANNOTATION_STAMP = "[" + TASKWARRIOR_TIME_FORMAT + "] "
+
+	TASKWARRIOR_STATUS_PENDING   = "pending"
+	TASKWARRIOR_STATUS_COMPLETED = "completed"
+	TASKWARRIOR_STATUS_DELETED   = "deleted"
+	TASKWARRIOR_STATUS_RECURRING = "recurring"
+)
+
+// TaskwarriorTime is a timestamp in Taskwarrior's compact UTC format
+type TaskwarriorTime struct {
+	time.Time
+}
+
+func (t TaskwarriorTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(TASKWARRIOR_TIME_FORMAT))
+}
+
+func (t *TaskwarriorTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(TASKWARRIOR_TIME_FORMAT, s)
+	if err != nil {
+		// Taskwarrior 3 may also write RFC 3339
+		parsed, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("invalid Taskwarrior time: %q", s)
+		}
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
+
+type TaskwarriorAnnotation struct {
+	Entry       TaskwarriorTime `json:"entry"`
+	Description string          `json:"description"`
+}
+
+type TaskwarriorTask struct {
+	UUID        string                  `json:"uuid"`
+	Description string                  `json:"description"`
+	Status      string                  `json:"status"`
+	Entry       *TaskwarriorTime        `json:"entry,omitempty"`
+	Modified    *TaskwarriorTime        `json:"modified,omitempty"`
+	End         *TaskwarriorTime        `json:"end,omitempty"`
+	Start       *TaskwarriorTime        `json:"start,omitempty"`
+	Priority    string                  `json:"priority,omitempty"`
+	Project     string                  `json:"project,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	Annotations []TaskwarriorAnnotation `json:"annotations,omitempty"`
+
+	PriotasksPriority string `json:"priotasks_priority,omitempty"`
+	PriotasksImpact   string `json:"priotasks_impact,omitempty"`
+	PriotasksCost     string `json:"priotasks_cost,omitempty"`
+	PriotasksFun      string `json:"priotasks_fun,omitempty"`
+	PriotasksPlanned  string `json:"priotasks_planned,omitempty"`
+
+	// attributes priotasks has no field for, only read to report them
+	Due       json.RawMessage `json:"due,omitempty"`
+	Scheduled json.RawMessage `json:"scheduled,omitempty"`
+	Wait      json.RawMessage `json:"wait,omitempty"`
+	Until     json.RawMessage `json:"until,omitempty"`
+	Depends   json.RawMessage `json:"depends,omitempty"`
+	Recur     json.RawMessage `json:"recur,omitempty"`
+}
+
+// unmappedAttributes are the names of the attributes of tw that are not imported
+func (tw TaskwarriorTask) unmappedAttributes() []string {
+	var names []string
+	for _, a := range []struct {
+		name  string
+		value json.RawMessage
+	}{
+		{"due", tw.Due}, {"scheduled", tw.Scheduled}, {"wait", tw.Wait},
+		{"until", tw.Until}, {"depends", tw.Depends}, {"recur", tw.Recur},
+	} {
+		if len(a.value) > 0 && string(a.value) != "null" {
+			names = append(names, a.name)
+		}
+	}
+	return names
+}
+
+func priorityToTaskwarrior(p models.TaskPriority) string {
+	switch p {
+	case models.PriorityUrgent, models.PriorityHigh:
+		return "H"
+	case models.PriorityMedium:
+		return "M"
+	default:
+		return "L"
+	}
+}
+
+func priorityFromTaskwarrior(p string) models.TaskPriority {
+	switch p {
+	case "H":
+		return models.PriorityHigh
+	case "M":
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+func twTime(t time.Time) *TaskwarriorTime {
+	return &TaskwarriorTime{t}
+}
+
+// annotationEntry is the time the i-th content line of a task created at created is exported
+// with: a second per line, as Taskwarrior keeps one annotation per second
+func annotationEntry(created time.Time, i int) time.Time {
+	return created.Add(time.Duration(i) * time.Second)
+}
+
+// annotationFromLine converts the i-th content line, taking the time of its stamp if it has one
+func annotationFromLine(line string, created time.Time, i int) TaskwarriorAnnotation {
+	stampLen := len(TASKWARRIOR_ANNOTATION_STAMP)
+	if len(line) >= stampLen && line[0] == '[' && line[stampLen-2:stampLen] == "] " {
+		if entry, err := time.Parse(TASKWARRIOR_TIME_FORMAT, line[1:stampLen-2]); err == nil {
+			return TaskwarriorAnnotation{Entry: TaskwarriorTime{entry}, Description: line[stampLen:]}
+		}
+	}
+	return TaskwarriorAnnotation{Entry: TaskwarriorTime{annotationEntry(created, i)}, Description: line}
+}
+
+// annotationLine is the content line of the i-th annotation, stamped unless it was made at
+// the time its line would be exported with
+func annotationLine(a TaskwarriorAnnotation, created time.Time, i int) string {
+	if a.Entry.Unix() == annotationEntry(created, i).Unix() {
+		return a.Description
+	}
+	return a.Entry.UTC().Format(TASKWARRIOR_ANNOTATION_STAMP) + a.Description
+}
+
+// TaskToTaskwarrior converts the task; each line of the content, blank ones included,
+// becomes an annotation
+func TaskToTaskwarrior(t models.Task) TaskwarriorTask {
+	tw := TaskwarriorTask{
+		UUID:              t.Id,
+		Description:       t.Title,
+		Status:            TASKWARRIOR_STATUS_PENDING,
+		Entry:             twTime(t.Created),
+		Modified:          twTime(taskStamp(t)),
+		Priority:          priorityToTaskwarrior(t.Priority),
+		PriotasksPriority: t.Priority.Name(),
+		PriotasksImpact:   t.Impact.Name(),
+		PriotasksCost:     t.Cost.Name(),
+		PriotasksFun:      t.Fun.Name(),
+	}
+	if t.IsCompleted() {
+		tw.Status = TASKWARRIOR_STATUS_COMPLETED
+		tw.End = twTime(t.Completed)
+	} else if t.Wip {
+		tw.Start = twTime(taskStamp(t))
+	}
+	if t.Planned {
+		tw.PriotasksPlanned = "true"
+	}
+
+	for _, tag := range t.Tags {
+		if project, ok := strings.CutPrefix(string(tag), TASKWARRIOR_PROJECT_TAG); ok && tw.Project == "" {
+			tw.Project = project
+		} else {
+			tw.Tags = append(tw.Tags, string(tag))
+		}
+	}
+
+	if t.Content != "" {
+		for i, line := range strings.Split(t.Content, "\n") {
+			tw.Annotations = append(tw.Annotations, annotationFromLine(strings.TrimRight(line, "\r"), t.Created, i))
+		}
+	}
+	return tw
+}
+
+// TaskFromTaskwarrior converts a Taskwarrior task; the priotasks attributes win over the
+// Taskwarrior priority when both are present
+func TaskFromTaskwarrior(tw TaskwarriorTask) (models.Task, error) {
+	task := models.EMPTY_TASK
+	task.Id = tw.UUID
+	task.Title = tw.Description
+	task.Priority = priorityFromTaskwarrior(tw.Priority)
+	if tw.Entry != nil {
+		task.Created = tw.Entry.Time
+	}
+	if tw.Modified != nil {
+		task.Updated = tw.Modified.Time
+	} else {
+		task.Updated = task.Created
+	}
+	if tw.Status == TASKWARRIOR_STATUS_COMPLETED {
+		task.Completed = task.Updated
+		if tw.End != nil {
+			task.Completed = tw.End.Time
+		}
+	}
+	task.Wip = tw.Start != nil && !task.IsCompleted()
+	task.Planned = tw.PriotasksPlanned == "true"
+
+	var err error
+	if tw.PriotasksPriority != "" {
+		if task.Priority, err = models.ParseTaskPriority(tw.PriotasksPriority); err != nil {
+			return task, err
+		}
+	}
+	if tw.PriotasksImpact != "" {
+		if task.Impact, err = models.ParseTaskImpact(tw.PriotasksImpact); err != nil {
+			return task, err
+		}
+	}
+	if tw.PriotasksCost != "" {
+		if task.Cost, err = models.ParseTaskCost(tw.PriotasksCost); err != nil {
+			return task, err
+		}
+	}
+	if tw.PriotasksFun != "" {
+		if task.Fun, err = models.ParseTaskFun(tw.PriotasksFun); err != nil {
+			return task, err
+		}
+	}
+
+	if tw.Project != "" {
+		task.Tags = append(task.Tags, models.TaskTag(TASKWARRIOR_PROJECT_TAG+tw.Project))
+	}
+	for _, tag := range tw.Tags {
+		task.Tags = append(task.Tags, models.TaskTag(tag))
+	}
+
+	lines := make([]string, len(tw.Annotations))
+	for i, a := range tw.Annotations {
+		lines[i] = annotationLine(a, task.Created, i)
+	}
+	task.Content = strings.Join(lines, "\n")
+	return task, nil
+}
+
+// ExportTasksToTaskwarrior writes the tasks as a JSON array, one task per line, the way
+// "task export" does
+func ExportTasksToTaskwarrior(w io.Writer, tasks []models.Task) error {
+	var b bytes.Buffer
+	b.WriteString("[\n")
+	for i, t := range tasks {
+		data, err := json.Marshal(TaskToTaskwarrior(t))
+		if err != nil {
+			return fmt.Errorf("ExportTasksToTaskwarrior: task %s: %w", t.Id, err)
+		}
+		b.Write(data)
+		if i < len(tasks)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ImportTasksFromTaskwarrior accepts a JSON array or one JSON object per line. Deleted tasks
+// and recurrence templates are reported as conflicts and skipped, the tasks imported without
+// some of their attributes as warnings.
+func (svc *TaskService) ImportTasksFromTaskwarrior(ctx context.Context, owner string, data []byte, dryRun bool) (models.ImportReport, error) {
+	twTasks, err := decodeTaskwarrior(data)
+	if err != nil {
+		return models.ImportReport{DryRun: dryRun}, fmt.Errorf("failed to read Taskwarrior JSON: %w", err)
+	}
+
+	var tasks []models.Task
+	var conflicts, warnings []models.ImportConflict
+	for i, tw := range twTasks {
+		conflict := func(reason string) {
+			conflicts = append(conflicts, models.ImportConflict{TaskId: tw.UUID, Title: tw.Description, Reason: fmt.Sprintf("task %d: %s", i+1, reason)})
+		}
+		switch tw.Status {
+		case TASKWARRIOR_STATUS_DELETED:
+			conflict("deleted tasks are not imported")
+			continue
+		case TASKWARRIOR_STATUS_RECURRING:
+			conflict("recurrence templates are not imported")
+			continue
+		}
+		task, err := TaskFromTaskwarrior(tw)
+		if err != nil {
+			conflict(err.Error())
+			continue
+		}
+		if names := tw.unmappedAttributes(); len(names) > 0 {
+			warnings = append(warnings, models.ImportConflict{TaskId: tw.UUID, Title: tw.Description,
+				Reason: fmt.Sprintf("task %d: imported without %s, priotasks has no field for it", i+1, strings.Join(names, ", "))})
+		}
+		tasks = append(tasks, task)
+	}
+
+	report, err := svc.importTasks(ctx, owner, tasks, dryRun)
+	report.Conflicts = append(conflicts, report.Conflicts...)
+	report.Warnings = warnings
+	return report, err
+}
+
+func decodeTaskwarrior(data []byte) ([]TaskwarriorTask, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var tasks []TaskwarriorTask
+		err := json.Unmarshal(trimmed, &tasks)
+		return tasks, err
+	}
+
+	var tasks []TaskwarriorTask
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var tw TaskwarriorTask
+		err := dec.Decode(&tw)
+		if errors.Is(err, io.EOF) {
+			return tasks, nil
+		}
+		if err != nil {
+			return tasks, err
+		}
+		tasks = append(tasks, tw)
+	}
+}
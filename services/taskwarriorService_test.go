@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_TaskwarriorRoundTrip(t *testing.T) {
+	created := time.Date(2025, 2, 1, 9, 30, 0, 0, time.Local)
+	tasks := []models.Task{
+		{
+			Id:        "0b5e9a7e-7d5b-4c1f-9d3e-1c2f3a4b5c6d",
+			Title:     "Plant tomatoes",
+			Content:   "buy seeds\n\n[20250203T101500Z] prepared soil\n",
+			Created:   created,
+			Updated:   created.Add(time.Hour),
+			Completed: models.NOT_COMPLETED,
+			Priority:  models.PriorityUrgent,
+			Impact:    models.ImpactHigh,
+			Cost:      models.CostL,
+			Fun:       models.FunXL,
+			Wip:       true,
+			Planned:   true,
+			Tags:      []models.TaskTag{"project:home.garden", "outdoor"},
+		},
+		{
+			Id:        "1c6f0b8f-8e6c-4d20-8e4f-2d3a4b5c6d7e",
+			Title:     "Done",
+			Created:   created,
+			Updated:   created.Add(2 * time.Hour),
+			Completed: created.Add(2 * time.Hour),
+			Priority:  models.PriorityLow,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportTasksToTaskwarrior(&buf, tasks); err != nil {
+		t.Fatalf("ExportTasksToTaskwarrior failed: %v", err)
+	}
+
+	var raw []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("export is not a JSON array: %v\n%s", err, buf.String())
+	}
+	first := raw[0]
+	if first["project"] != "home.garden" || first["priority"] != "H" || first["status"] != "pending" || first["start"] == nil {
+		t.Errorf("unexpected Taskwarrior task: %v", first)
+	}
+	if first["entry"] != created.UTC().Format(TASKWARRIOR_TIME_FORMAT) {
+		t.Errorf("unexpected entry: %v", first["entry"])
+	}
+	annotations, _ := first["annotations"].([]any)
+	if len(annotations) != 4 {
+		t.Fatalf("expected an annotation per line, blank ones included: %v", first["annotations"])
+	}
+	if stamped := annotations[2].(map[string]any); stamped["entry"] != "20250203T101500Z" || stamped["description"] != "prepared soil" {
+		t.Errorf("unexpected stamped annotation: %v", stamped)
+	}
+	if raw[1]["status"] != "completed" || raw[1]["end"] == nil {
+		t.Errorf("unexpected completed task: %v", raw[1])
+	}
+
+	twTasks, err := decodeTaskwarrior(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeTaskwarrior failed: %v", err)
+	}
+	for i, tw := range twTasks {
+		got, err := TaskFromTaskwarrior(tw)
+		if err != nil {
+			t.Fatalf("TaskFromTaskwarrior failed: %v", err)
+		}
+		want := tasks[i]
+		if got.Id != want.Id || got.Title != want.Title || got.Content != want.Content ||
+			!got.Created.Equal(want.Created) || !got.Updated.Equal(want.Updated) || !got.Completed.Equal(want.Completed) ||
+			got.Priority != want.Priority || got.Impact != want.Impact || got.Cost != want.Cost || got.Fun != want.Fun ||
+			got.Wip != want.Wip || got.Planned != want.Planned || !slices.Equal(got.Tags, want.Tags) {
+			t.Errorf("round trip changed the task:\nwant %+v\ngot  %+v", want, got)
+		}
+	}
+}
+
+func Test_TaskFromTaskwarrior_Native(t *testing.T) {
+	line := `{"id":0,"uuid":"abc","description":"Fix bike","status":"completed","entry":"20250101T080000Z",` +
+		`"modified":"20250102T080000Z","end":"20250102T070000Z","priority":"M","project":"errands","tags":["bike"],` +
+		`"annotations":[{"entry":"20250101T090000Z","description":"flat tyre"}],"urgency":4.2}`
+	twTasks, err := decodeTaskwarrior([]byte(line + "\n" + strings.Replace(line, `"abc"`, `"def"`, 1)))
+	if err != nil {
+		t.Fatalf("decodeTaskwarrior failed for line-delimited input: %v", err)
+	}
+	if len(twTasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(twTasks))
+	}
+
+	task, err := TaskFromTaskwarrior(twTasks[0])
+	if err != nil {
+		t.Fatalf("TaskFromTaskwarrior failed: %v", err)
+	}
+	// the annotation was made an hour after the task, its time is kept in the content
+	if task.Priority != models.PriorityMedium || task.Content != "[20250101T090000Z] flat tyre" ||
+		!slices.Equal(task.Tags, []models.TaskTag{"project:errands", "bike"}) {
+		t.Errorf("unexpected task: %+v", task)
+	}
+	if !task.Completed.Equal(time.Date(2025, 1, 2, 7, 0, 0, 0, time.UTC)) || task.Completed.Location() != time.UTC {
+		t.Errorf("unexpected completed time: %v", task.Completed)
+	}
+
+	back := TaskToTaskwarrior(task)
+	if !slices.Equal(back.Annotations, twTasks[0].Annotations) {
+		t.Errorf("annotations changed: want %+v, got %+v", twTasks[0].Annotations, back.Annotations)
+	}
+}
+
+func Test_ImportTasksFromTaskwarrior_SkipsDeleted(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	data := `[{"uuid":"a","description":"Keep","status":"pending","entry":"20250101T080000Z"},
+{"uuid":"b","description":"Gone","status":"deleted","entry":"20250101T080000Z"}]`
+
+	report, err := svc.Tasks.ImportTasksFromTaskwarrior(context.Background(), "", []byte(data), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromTaskwarrior failed: %v", err)
+	}
+	if len(report.Created) != 1 || report.Created[0].Id != "a" {
+		t.Errorf("unexpected created tasks: %+v", report.Created)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].TaskId != "b" {
+		t.Errorf("deleted task should be reported: %+v", report.Conflicts)
+	}
+}
+
+func Test_ImportTasksFromTaskwarrior_WarnsAboutUnmappedAttributes(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	data := `{"uuid":"a","description":"Pay rent","status":"pending","entry":"20250101T080000Z","due":"20250201T000000Z","wait":"20250125T000000Z"}
+{"uuid":"b","description":"Plain","status":"pending","entry":"20250101T080000Z"}`
+
+	report, err := svc.Tasks.ImportTasksFromTaskwarrior(context.Background(), "", []byte(data), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromTaskwarrior failed: %v", err)
+	}
+	if len(report.Created) != 2 || len(report.Conflicts) != 0 {
+		t.Errorf("expected both tasks to be imported: %+v", report)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].TaskId != "a" || !strings.Contains(report.Warnings[0].Reason, "due, wait") {
+		t.Errorf("expected a warning about due and wait: %+v", report.Warnings)
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	SELF_SIGNED_CERT_FILE = "tls-cert.pem"
+	SELF_SIGNED_KEY_FILE  = "tls-key.pem"
+
+	selfSignedValidity = 5 * 365 * 24 * time.Hour
+	// a certificate expiring sooner is replaced on start
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// EnsureSelfSignedCert returns the self-signed certificate kept in the app directory,
+// generating it on the first run or when it is about to expire. The certificate covers
+// localhost, the host name and the addresses of the network interfaces, so tablets on the
+// home network can reach the server by IP; delete the files to regenerate it after the
+// addresses change.
+func EnsureSelfSignedCert(appDir string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(appDir, SELF_SIGNED_CERT_FILE)
+	keyFile = filepath.Join(appDir, SELF_SIGNED_KEY_FILE)
+
+	notAfter, err := certificateNotAfter(certFile)
+	if err == nil && time.Now().Add(selfSignedRenewBefore).Before(notAfter) {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("EnsureSelfSignedCert: replacing unreadable certificate", "file", certFile, "error", err)
+	}
+
+	if err := writeSelfSignedCert(certFile, keyFile, time.Now(), selfSignedHosts()); err != nil {
+		return "", "", fmt.Errorf("EnsureSelfSignedCert: %w", err)
+	}
+	slog.Info("generated a self-signed certificate", "file", certFile)
+	return certFile, keyFile, nil
+}
+
+func certificateNotAfter(certFile string) (time.Time, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("no certificate in %v", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// selfSignedHosts are the names and addresses the server is reached by
+func selfSignedHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name, name+".local")
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		slog.Warn("selfSignedHosts: failed to list the network addresses", "error", err)
+		return hosts
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+	return hosts
+}
+
+func writeSelfSignedCert(certFile, keyFile string, now time.Time, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"priotasks"}, CommonName: "priotasks"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := writePemFile(keyFile, "PRIVATE KEY", keyDer, 0600); err != nil {
+		return err
+	}
+	return writePemFile(certFile, "CERTIFICATE", der, 0644)
+}
+
+func writePemFile(path, blockType string, der []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %v: %w", path, err)
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %v: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := EnsureSelfSignedCert(dir)
+	if err != nil {
+		t.Fatalf("EnsureSelfSignedCert failed: %v", err)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("expected a usable key pair, got %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	if !slices.Contains(cert.DNSNames, "localhost") || cert.VerifyHostname("127.0.0.1") != nil {
+		t.Errorf("expected the certificate to cover localhost, got %v %v", cert.DNSNames, cert.IPAddresses)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the key to be private, got %v, %v", info.Mode(), err)
+	}
+
+	first, _ := os.ReadFile(certFile)
+	if _, _, err := EnsureSelfSignedCert(dir); err != nil {
+		t.Fatalf("EnsureSelfSignedCert failed: %v", err)
+	}
+	if second, _ := os.ReadFile(certFile); !bytes.Equal(first, second) {
+		t.Errorf("expected the certificate to be reused on the next start")
+	}
+}
+
+func TestEnsureSelfSignedCert_RenewsExpiring(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, SELF_SIGNED_CERT_FILE)
+	keyFile := filepath.Join(dir, SELF_SIGNED_KEY_FILE)
+	issued := time.Now().Add(-selfSignedValidity + 24*time.Hour)
+	if err := writeSelfSignedCert(certFile, keyFile, issued, []string{"localhost"}); err != nil {
+		t.Fatalf("writeSelfSignedCert failed: %v", err)
+	}
+
+	if _, _, err := EnsureSelfSignedCert(dir); err != nil {
+		t.Fatalf("EnsureSelfSignedCert failed: %v", err)
+	}
+	notAfter, err := certificateNotAfter(certFile)
+	if err != nil || notAfter.Before(time.Now().Add(selfSignedValidity-time.Hour)) {
+		t.Errorf("expected a renewed certificate, got %v, %v", notAfter, err)
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inaryzen/priotasks/consts"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+// todo.txt lines look like "x 2025-01-03 2025-01-01 Title +project @context cost:S id:...".
+// Tags starting with "@" are written as contexts, all other tags as projects; on import
+// projects lose the "+" while contexts keep the "@", so both survive a round trip.
+const (
+	TODO_TXT_EXT_ID       = "id"
+	TODO_TXT_EXT_IMPACT   = "impact"
+	TODO_TXT_EXT_COST     = "cost"
+	TODO_TXT_EXT_FUN      = "fun"
+	TODO_TXT_EXT_PRIORITY = "pri"
+	TODO_TXT_EXT_WIP      = "wip"
+	TODO_TXT_EXT_PLANNED  = "planned"
+)
+
+var todoTxtPriorityRe = regexp.MustCompile(`^\([A-Z]\)$`)
+
+func priorityToTodoTxt(p models.TaskPriority) string {
+	switch p {
+	case models.PriorityUrgent:
+		return "A"
+	case models.PriorityHigh:
+		return "B"
+	case models.PriorityMedium:
+		return "C"
+	default:
+		return "D"
+	}
+}
+
+func priorityFromTodoTxt(letter string) models.TaskPriority {
+	switch letter {
+	case "A":
+		return models.PriorityUrgent
+	case "B":
+		return models.PriorityHigh
+	case "C":
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+// TaskToTodoTxt formats the task as a single todo.txt line
+func TaskToTodoTxt(t models.Task) string {
+	var parts []string
+	if t.IsCompleted() {
+		parts = append(parts, "x", t.Completed.Format(consts.DEFAULT_DATE_FORMAT))
+	} else {
+		parts = append(parts, "("+priorityToTodoTxt(t.Priority)+")")
+	}
+	if !t.Created.IsZero() {
+		parts = append(parts, t.Created.Format(consts.DEFAULT_DATE_FORMAT))
+	}
+
+	title := strings.Join(strings.Fields(t.Title), " ")
+	if title != "" {
+		parts = append(parts, title)
+	}
+
+	for _, tag := range t.Tags {
+		name := strings.Join(strings.Fields(string(tag)), "_")
+		if !strings.HasPrefix(name, "@") {
+			name = "+" + name
+		}
+		parts = append(parts, name)
+	}
+
+	cost, _, _ := strings.Cut(t.Cost.Name(), " ")
+	parts = append(parts,
+		TODO_TXT_EXT_IMPACT+":"+t.Impact.Name(),
+		TODO_TXT_EXT_COST+":"+cost,
+		TODO_TXT_EXT_FUN+":"+t.Fun.Name(),
+	)
+	if t.IsCompleted() {
+		parts = append(parts, TODO_TXT_EXT_PRIORITY+":"+priorityToTodoTxt(t.Priority))
+	}
+	if t.Wip {
+		parts = append(parts, TODO_TXT_EXT_WIP+":true")
+	}
+	if t.Planned {
+		parts = append(parts, TODO_TXT_EXT_PLANNED+":true")
+	}
+	if t.Id != "" {
+		parts = append(parts, TODO_TXT_EXT_ID+":"+t.Id)
+	}
+	return strings.Join(parts, " ")
+}
+
+// TaskFromTodoTxt parses a single todo.txt line, the dates starting at midnight in loc.
+// Unknown key:value pairs stay in the title.
+func TaskFromTodoTxt(line string, loc *time.Location) (models.Task, error) {
+	task := models.EMPTY_TASK
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		return task, errors.New("empty line")
+	}
+
+	parseDate := func() (time.Time, bool) {
+		if len(tokens) == 0 {
+			return time.Time{}, false
+		}
+		d, err := time.ParseInLocation(consts.DEFAULT_DATE_FORMAT, tokens[0], loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		tokens = tokens[1:]
+		return d, true
+	}
+
+	if tokens[0] == "x" {
+		tokens = tokens[1:]
+		task.Completed = time.Now()
+		if d, ok := parseDate(); ok {
+			task.Completed = d
+		}
+	} else if todoTxtPriorityRe.MatchString(tokens[0]) {
+		task.Priority = priorityFromTodoTxt(tokens[0][1:2])
+		tokens = tokens[1:]
+	}
+	if d, ok := parseDate(); ok {
+		task.Created = d
+	}
+
+	var errs []string
+	var title []string
+	for _, token := range tokens {
+		if len(token) > 1 && token[0] == '+' {
+			task.Tags = append(task.Tags, models.TaskTag(token[1:]))
+			continue
+		}
+		if len(token) > 1 && token[0] == '@' {
+			task.Tags = append(task.Tags, models.TaskTag(token))
+			continue
+		}
+
+		key, value, found := strings.Cut(token, ":")
+		if !found || value == "" {
+			title = append(title, token)
+			continue
+		}
+		var err error
+		switch key {
+		case TODO_TXT_EXT_ID:
+			task.Id = value
+		case TODO_TXT_EXT_IMPACT:
+			task.Impact, err = models.ParseTaskImpact(value)
+		case TODO_TXT_EXT_COST:
+			task.Cost, err = models.ParseTaskCost(value)
+		case TODO_TXT_EXT_FUN:
+			task.Fun, err = models.ParseTaskFun(value)
+		case TODO_TXT_EXT_PRIORITY:
+			task.Priority = priorityFromTodoTxt(strings.ToUpper(value))
+		case TODO_TXT_EXT_WIP:
+			task.Wip, err = strconv.ParseBool(value)
+		case TODO_TXT_EXT_PLANNED:
+			task.Planned, err = strconv.ParseBool(value)
+		default:
+			title = append(title, token)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %q", key, value))
+		}
+	}
+	task.Title = strings.Join(title, " ")
+
+	if len(errs) > 0 {
+		return task, errors.New(strings.Join(errs, "; "))
+	}
+	return task, nil
+}
+
+// ExportTasksToTodoTxt writes one todo.txt line per task
+func ExportTasksToTodoTxt(w io.Writer, tasks []models.Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		if _, err := bw.WriteString(TaskToTodoTxt(t) + "\n"); err != nil {
+			return fmt.Errorf("ExportTasksToTodoTxt: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportTasksFromTodoTxt upserts tasks from a todo.txt file, de-duplicating them by the id extension.
+// todo.txt has no modification time, so the file wins over stored tasks: a line with a known
+// id updates that task unless nothing it carries has changed. Content is kept from the stored task.
+// The dates start at midnight in the time zone of the user.
+func (svc *TaskService) ImportTasksFromTodoTxt(ctx context.Context, owner string, data []byte, dryRun bool) (models.ImportReport, error) {
+	loc, err := svc.settings.Location(ctx, owner)
+	if err != nil {
+		return models.ImportReport{DryRun: dryRun}, fmt.Errorf("failed to find the time zone: %w", err)
+	}
+	var tasks []models.Task
+	var lineErrors []models.ImportConflict
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		task, err := TaskFromTodoTxt(line, loc)
+		if err == nil {
+			task, err = svc.mergeTodoTxtTask(ctx, owner, task, loc)
+		}
+		if err != nil {
+			lineErrors = append(lineErrors, models.ImportConflict{
+				TaskId: task.Id,
+				Title:  task.Title,
+				Reason: fmt.Sprintf("line %d: %v", lineNo, err),
+			})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return models.ImportReport{DryRun: dryRun}, fmt.Errorf("failed to read the todo.txt file: %w", err)
+	}
+
+	report, err := svc.importTasks(ctx, owner, tasks, dryRun)
+	report.Conflicts = append(lineErrors, report.Conflicts...)
+	return report, err
+}
+
+// mergeTodoTxtTask fills in what todo.txt does not carry from the stored task of the user with the same id;
+// the dates are compared as days in loc, the time zone the file was read in
+func (svc *TaskService) mergeTodoTxtTask(ctx context.Context, owner string, task models.Task, loc *time.Location) (models.Task, error) {
+	if task.Id == "" {
+		return task, nil
+	}
+	existing, err := svc.FindTask(ctx, owner, task.Id)
+	if errors.Is(err, db.ErrNotFound) {
+		return task, nil
+	}
+	if err != nil {
+		return task, err
+	}
+	existingTags, err := svc.store.TaskTags(ctx, task.Id)
+	if err != nil {
+		return task, err
+	}
+
+	sameDay := func(a, b time.Time) bool {
+		return a.In(loc).Format(consts.DEFAULT_DATE_FORMAT) == b.In(loc).Format(consts.DEFAULT_DATE_FORMAT)
+	}
+	task.Content = existing.Content
+	if task.Created.IsZero() || sameDay(task.Created, existing.Created) {
+		task.Created = existing.Created
+	}
+	if task.IsCompleted() && existing.IsCompleted() && (sameDay(task.Completed, existing.Completed) || sameDay(task.Completed, time.Now())) {
+		task.Completed = existing.Completed
+	}
+
+	sortedTags := func(tags []models.TaskTag) []models.TaskTag {
+		return slices.Sorted(slices.Values(tags))
+	}
+	unchanged := task.Title == existing.Title &&
+		task.Completed.Equal(existing.Completed) &&
+		task.Priority == existing.Priority &&
+		task.Impact == existing.Impact &&
+		task.Cost == existing.Cost &&
+		task.Fun == existing.Fun &&
+		task.Wip == existing.Wip &&
+		task.Planned == existing.Planned &&
+		slices.Equal(sortedTags(task.Tags), sortedTags(existingTags))
+	if unchanged {
+		task.Updated = existing.Updated
+	} else {
+		task.Updated = time.Now()
+	}
+	return task, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+func Test_TaskToTodoTxt(t *testing.T) {
+	tests := []struct {
+		name string
+		task models.Task
+		want string
+	}{
+		{
+			name: "open task",
+			task: models.Task{
+				Id:       "abc",
+				Title:    "Call  the\nplumber",
+				Created:  time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC),
+				Priority: models.PriorityUrgent,
+				Impact:   models.ImpactHigh,
+				Cost:     models.CostS,
+				Fun:      models.FunM,
+				Wip:      true,
+				Tags:     []models.TaskTag{"home repair", "@phone"},
+			},
+			want: "(A) 2025-04-01 Call the plumber +home_repair @phone impact:High cost:S fun:M wip:true id:abc",
+		},
+		{
+			name: "completed task",
+			task: models.Task{
+				Id:        "def",
+				Title:     "Done",
+				Created:   time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC),
+				Completed: time.Date(2025, 4, 3, 18, 0, 0, 0, time.UTC),
+				Priority:  models.PriorityLow,
+				Impact:    models.ImpactSlight,
+				Cost:      models.CostXXL,
+				Fun:       models.FunS,
+			},
+			want: "x 2025-04-03 2025-04-01 Done impact:Slight cost:XXL fun:S pri:D id:def",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaskToTodoTxt(tt.task); got != tt.want {
+				t.Errorf("TaskToTodoTxt() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TaskFromTodoTxt(t *testing.T) {
+	task, err := TaskFromTodoTxt("x 2025-04-03 2025-04-01 Pay rent due:2025-05-01 +home @bank cost:M fun:XL pri:B id:xyz", time.UTC)
+	if err != nil {
+		t.Fatalf("TaskFromTodoTxt failed: %v", err)
+	}
+	if task.Id != "xyz" || task.Title != "Pay rent due:2025-05-01" {
+		t.Errorf("unexpected id/title: %q %q", task.Id, task.Title)
+	}
+	if !task.Completed.Equal(time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC)) ||
+		!task.Created.Equal(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected dates: completed=%v created=%v", task.Completed, task.Created)
+	}
+	if task.Priority != models.PriorityHigh || task.Cost != models.CostM || task.Fun != models.FunXL || task.Impact != models.ImpactModerate {
+		t.Errorf("unexpected enums: %+v", task)
+	}
+	if len(task.Tags) != 2 || task.Tags[0] != "home" || task.Tags[1] != "@bank" {
+		t.Errorf("unexpected tags: %v", task.Tags)
+	}
+
+	task, err = TaskFromTodoTxt("(E) Someday", time.UTC)
+	if err != nil || task.Priority != models.PriorityLow || task.IsCompleted() {
+		t.Errorf("unexpected result for low priority line: %+v, %v", task, err)
+	}
+
+	if _, err := TaskFromTodoTxt("(A) Broken impact:Huge", time.UTC); err == nil {
+		t.Error("expected an error for unknown impact")
+	}
+}
+
+func Test_ImportTasksFromTodoTxt_DeduplicatesById(t *testing.T) {
+	t.Parallel()
+	svc, mockDB := setupTestDB()
+	updated := time.Date(2025, 4, 2, 10, 0, 0, 0, time.UTC)
+	mockDB.SaveTask(context.Background(), models.Task{
+		Id:       "keep",
+		Title:    "Unchanged",
+		Content:  "Long description",
+		Created:  time.Date(2025, 4, 1, 9, 30, 0, 0, time.UTC),
+		Updated:  updated,
+		Priority: models.PriorityHigh,
+		Impact:   models.ImpactModerate,
+		Cost:     models.CostM,
+		Fun:      models.FunM,
+	})
+	mockDB.SaveTask(context.Background(), models.Task{
+		Id:       "edit",
+		Title:    "Before",
+		Content:  "Kept content",
+		Created:  time.Date(2025, 4, 1, 9, 30, 0, 0, time.UTC),
+		Updated:  updated,
+		Priority: models.PriorityHigh,
+	})
+
+	data := strings.Join([]string{
+		"(B) 2025-04-01 Unchanged impact:Moderate cost:M fun:M id:keep",
+		"x 2025-04-05 2025-04-01 After +phone impact:Moderate cost:M fun:M pri:B id:edit",
+		"(C) New task without id",
+		"",
+		"(A) Broken fun:Huge",
+	}, "\n")
+
+	report, err := svc.Tasks.ImportTasksFromTodoTxt(context.Background(), "", []byte(data), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromTodoTxt failed: %v", err)
+	}
+	if len(report.Unchanged) != 1 || len(report.Updated) != 1 || len(report.Created) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(report.Conflicts) != 1 || !strings.HasPrefix(report.Conflicts[0].Reason, "line 5:") {
+		t.Errorf("expected an error for line 5, got %+v", report.Conflicts)
+	}
+
+	edited := mockDB.tasks["edit"]
+	if edited.Title != "After" || edited.Content != "Kept content" || !edited.IsCompleted() {
+		t.Errorf("unexpected edited task: %+v", edited)
+	}
+	if !edited.Created.Equal(time.Date(2025, 4, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("created time should be kept, got %v", edited.Created)
+	}
+	if len(mockDB.taskTags["edit"]) != 1 || mockDB.taskTags["edit"][0] != "phone" {
+		t.Errorf("unexpected tags: %v", mockDB.taskTags["edit"])
+	}
+}
+
+func Test_ImportTasksFromTodoTxt_RoundTripAfterLocalMidnight(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("LoadLocation failed: %v", err)
+	}
+	svc.SetTimeZone(tokyo)
+	ctx := context.Background()
+
+	// just after midnight in Tokyo, still the day before in UTC
+	created := time.Date(2025, 4, 1, 15, 30, 0, 0, time.UTC)
+	completed := time.Date(2025, 4, 4, 15, 10, 0, 0, time.UTC)
+	if err := svc.Tasks.SaveTask(ctx, models.Task{Id: "late", Title: "Late", Created: created, Updated: completed, Completed: completed}); err != nil {
+		t.Fatalf("SaveTask failed: %v", err)
+	}
+	task, err := svc.Tasks.FindTask(ctx, "", "late")
+	if err != nil {
+		t.Fatalf("FindTask failed: %v", err)
+	}
+
+	var buf strings.Builder
+	if err := ExportTasksToTodoTxt(&buf, models.TasksIn([]models.Task{task}, tokyo)); err != nil {
+		t.Fatalf("ExportTasksToTodoTxt failed: %v", err)
+	}
+	report, err := svc.Tasks.ImportTasksFromTodoTxt(ctx, "", []byte(buf.String()), false)
+	if err != nil {
+		t.Fatalf("ImportTasksFromTodoTxt failed: %v", err)
+	}
+	if len(report.Unchanged) != 1 || len(report.Updated) != 0 {
+		t.Errorf("expected the exported task to be unchanged, got %+v for %q", report, buf.String())
+	}
+	stored, _ := svc.Tasks.FindTask(ctx, "", "late")
+	if !stored.Created.Equal(created) || !stored.Completed.Equal(completed) {
+		t.Errorf("expected the times to be kept, got created %v completed %v", stored.Created, stored.Completed)
+	}
+}
@@ -1,11 +1,12 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
-	"github.com/inaryzen/priotasks/common"
 	"github.com/inaryzen/priotasks/consts"
 	"github.com/inaryzen/priotasks/db"
 	"github.com/inaryzen/priotasks/models"
@@ -13,78 +14,153 @@ import (
 
 const SETTINGS_ID = "UserSettings"
 
-func SetCompletedFilter(val bool) error {
-	s, err := FindUserSettings()
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+// SettingsId is the id of the settings of the user; SETTINGS_ID while sign-in is disabled
+func SettingsId(owner string) string {
+	if owner == "" {
+		return SETTINGS_ID
+	}
+	return SETTINGS_ID + ":" + owner
+}
+
+func (svc *SettingsService) SetCompletedFilter(ctx context.Context, owner string, val bool) error {
+	s, err := svc.FindUserSettings(ctx, owner)
 	if err != nil {
 		return err
 	}
 	s.TasksQuery.FilterCompleted = val
-	err = UpdateUserSettings(s)
+	err = svc.UpdateUserSettings(ctx, s)
 	return err
 }
 
-func RemoveTagFromSettings(tag models.TaskTag) error {
-	common.Debug("RemoveTagFromSettings: %v", tag)
-	s, err := FindUserSettings()
+func (svc *SettingsService) RemoveTagFromSettings(ctx context.Context, owner string, tag models.TaskTag) error {
+	slog.DebugContext(ctx, "RemoveTagFromSettings", "tag", tag)
+	s, err := svc.FindUserSettings(ctx, owner)
 	if err != nil {
 		return fmt.Errorf("RemoveTagFromfilter: not found: %w", err)
 	}
 	t := s.TasksQuery
 	s.TasksQuery = t.RemoveTag(tag)
-	err = UpdateUserSettings(s)
+	err = svc.UpdateUserSettings(ctx, s)
 	if err != nil {
 		return fmt.Errorf("RemoveTagFromfilter: update: %w", err)
 	}
 	return nil
 }
 
-func FindUserSettings() (models.Settings, error) {
+// FindUserSettings returns the settings of the user, their query is limited to the tasks of the user
+func (svc *SettingsService) FindUserSettings(ctx context.Context, owner string) (models.Settings, error) {
 	var s models.Settings
 	var err error
 
-	s, err = db.DB().FindSettings(SETTINGS_ID)
+	s, err = svc.store.FindSettings(ctx, SettingsId(owner))
 	if errors.Is(err, db.ErrNotFound) {
-		err = UpdateUserSettings(models.Settings{
-			Id: SETTINGS_ID,
+		s = models.Settings{
+			Id: SettingsId(owner),
 			TasksQuery: models.TasksQuery{
 				FilterCompleted: true,
 			},
-		})
+		}
+		err = svc.UpdateUserSettings(ctx, s)
 	}
 	if err != nil {
 		err = fmt.Errorf("failed to retrieve settings: %w", err)
 	}
+	s.TasksQuery.Owner = owner
 
 	return s, err
 }
 
-func UpdateUserSettings(s models.Settings) error {
-	return db.DB().SaveSettings(s)
+func (svc *SettingsService) UpdateUserSettings(ctx context.Context, s models.Settings) error {
+	return svc.store.SaveSettings(ctx, s)
 }
 
-func ToggleSorting(s models.Settings, newColumn models.SortColumn, actDir models.SortDirection) error {
+func (svc *SettingsService) ToggleSorting(ctx context.Context, s models.Settings, newColumn models.SortColumn, actDir models.SortDirection) error {
 	if s.TasksQuery.SortColumn == newColumn {
 		s.TasksQuery.SortDirection = actDir.Flip()
 	} else {
 		s.TasksQuery.SortDirection = models.Desc // default
 	}
 	s.TasksQuery.SortColumn = newColumn
-	return UpdateUserSettings(s)
+	return svc.UpdateUserSettings(ctx, s)
+}
+
+// Location returns the time zone of the user, the default time zone of the services unless
+// they chose one
+func (svc *SettingsService) Location(ctx context.Context, owner string) (*time.Location, error) {
+	s, err := svc.FindUserSettings(ctx, owner)
+	if err != nil {
+		return svc.timeZone, fmt.Errorf("Location: %w", err)
+	}
+	return svc.location(s), nil
+}
+
+// location is the time zone of the settings; a time zone the server no longer knows falls
+// back to the default one
+func (svc *SettingsService) location(s models.Settings) *time.Location {
+	if s.TimeZone == "" {
+		return svc.timeZone
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		slog.Warn("location: unknown time zone", "settings", s.Id, "timeZone", s.TimeZone, "error", err)
+		return svc.timeZone
+	}
+	return loc
+}
+
+// DefaultLocation is the time zone of the users who did not choose one
+func (svc *SettingsService) DefaultLocation() *time.Location {
+	return svc.timeZone
+}
+
+// SetTimeZone sets the time zone of the user by its IANA name, empty for the default one
+func (svc *SettingsService) SetTimeZone(ctx context.Context, owner, name string) error {
+	if name != "" {
+		if _, err := time.LoadLocation(name); err != nil {
+			return fmt.Errorf("SetTimeZone: %q: %w", name, ErrInvalidTimeZone)
+		}
+	}
+	s, err := svc.FindUserSettings(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("SetTimeZone: %w", err)
+	}
+	s.TimeZone = name
+	if err := svc.UpdateUserSettings(ctx, s); err != nil {
+		return fmt.Errorf("SetTimeZone: %w", err)
+	}
+	return nil
 }
 
-func ApplyPreparedQuery(preparedQueryName string) error {
-	s, err := FindUserSettings()
+func (svc *SettingsService) ApplyPreparedQuery(ctx context.Context, owner, preparedQueryName string) error {
+	s, err := svc.FindUserSettings(ctx, owner)
 	if err != nil {
 		return err
 	}
-	q := s.TasksQuery
-	q = q.Reset()
 
-	common.Debug("ApplyPreparedQuery: %v", preparedQueryName)
+	q := PreparedQuery(preparedQueryName, s.TasksQuery, svc.location(s))
+
+	slog.DebugContext(ctx, "ApplyPreparedQuery", "name", preparedQueryName, "query", q)
+	s.TasksQuery = q
+	svc.UpdateUserSettings(ctx, s)
+
+	return nil
+}
+
+// PreparedQuery resets the base query and applies the named prepared query to it, the days
+// starting at midnight in the time zone loc of the user
+func PreparedQuery(preparedQueryName string, base models.TasksQuery, loc *time.Location) models.TasksQuery {
+	return preparedQuery(preparedQueryName, base, time.Now().In(loc))
+}
+
+// preparedQuery applies the prepared query at now, in the time zone of now. The days are
+// 23 or 25 hours long when daylight saving time starts or ends.
+func preparedQuery(preparedQueryName string, base models.TasksQuery, now time.Time) models.TasksQuery {
+	q := base.Reset()
 
 	switch preparedQueryName {
 	case consts.PREPARED_QUERY_COMPLETED_YESTERDAY:
-		now := time.Now()
 		midnightYesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, now.Location())
 		midnightToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 		q.CompletedFrom = midnightYesterday
@@ -93,7 +169,6 @@ func ApplyPreparedQuery(preparedQueryName string) error {
 		q.FilterCompleted = false
 		q.SortColumn = models.Completed
 	case consts.PREPARED_QUERY_COMPLETED_TODAY:
-		now := time.Now()
 		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 		nextMidnight := midnight.AddDate(0, 0, 1)
 		q.CompletedFrom = midnight
@@ -102,7 +177,7 @@ func ApplyPreparedQuery(preparedQueryName string) error {
 		q.FilterCompleted = false
 		q.SortColumn = models.Completed
 	case consts.PREPARED_QUERY_COMPLETED_THIS_WEEK:
-		mondayMidnight := thisMonday()
+		mondayMidnight := thisMonday(now)
 		nextMonday := mondayMidnight.AddDate(0, 0, 7)
 		q.CompletedFrom = mondayMidnight
 		q.CompletedTo = nextMonday
@@ -110,7 +185,6 @@ func ApplyPreparedQuery(preparedQueryName string) error {
 		q.FilterCompleted = false
 		q.SortColumn = models.Completed
 	case consts.PREPARED_QUERY_COMPLETED_LAST_TWO_WEEKS:
-		now := time.Now()
 		twoWeeksAgo := time.Date(now.Year(), now.Month(), now.Day()-14, 0, 0, 0, 0, now.Location())
 		q.CompletedFrom = twoWeeksAgo
 		q.CompletedTo = now
@@ -118,9 +192,9 @@ func ApplyPreparedQuery(preparedQueryName string) error {
 		q.FilterCompleted = false
 		q.SortColumn = models.Completed
 	case consts.PREPARED_QUERY_COMPLETED_LAST_WEEK:
-		previousMonday := thisMonday().AddDate(0, 0, -7)
+		previousMonday := thisMonday(now).AddDate(0, 0, -7)
 		q.CompletedFrom = previousMonday
-		q.CompletedTo = time.Now()
+		q.CompletedTo = now
 		q.FilterIncompleted = true
 		q.FilterCompleted = false
 		q.SortColumn = models.Completed
@@ -130,15 +204,11 @@ func ApplyPreparedQuery(preparedQueryName string) error {
 		// nop
 	}
 
-	common.Debug("ApplyPreparedQuery: %v", q)
-	s.TasksQuery = q
-	UpdateUserSettings(s)
-
-	return nil
+	return q
 }
 
-func thisMonday() time.Time {
-	now := time.Now()
+// thisMonday is the midnight starting the week of now, in the time zone of now
+func thisMonday(now time.Time) time.Time {
 	offset := int(time.Monday - now.Weekday())
 	if offset > 0 { // time.Sunday == 0
 		offset -= 7 // 1 - 0 - 7 == -6
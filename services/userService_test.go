@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -16,18 +19,18 @@ type userSettingsTestDB struct {
 	findCallCount int
 }
 
-func (m *userSettingsTestDB) FindSettings(settingsId string) (models.Settings, error) {
+func (m *userSettingsTestDB) FindSettings(ctx context.Context, settingsId string) (models.Settings, error) {
 	m.findCallCount++
 	return m.settings, nil
 }
 
-func (m *userSettingsTestDB) SaveSettings(s models.Settings) error {
+func (m *userSettingsTestDB) SaveSettings(ctx context.Context, s models.Settings) error {
 	m.saveCallCount++
 	m.settings = s
 	return nil
 }
 
-func setupUserSettingsTestDB() *userSettingsTestDB {
+func setupUserSettingsTestDB() (*Services, *userSettingsTestDB) {
 	mockDB := &userSettingsTestDB{
 		settings: models.Settings{
 			Id: SETTINGS_ID,
@@ -36,14 +39,14 @@ func setupUserSettingsTestDB() *userSettingsTestDB {
 			},
 		},
 	}
-	db.SetDB(mockDB)
-	return mockDB
+	return New(mockDB), mockDB
 }
 
 func Test_SetCompletedFilter_Success(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := SetCompletedFilter(false)
+	err := svc.Settings.SetCompletedFilter(context.Background(), "", false)
 	if err != nil {
 		t.Errorf("SetCompletedFilter failed: %v", err)
 	}
@@ -62,10 +65,11 @@ func Test_SetCompletedFilter_Success(t *testing.T) {
 }
 
 func Test_RemoveTagFromSettings_Success(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	mockDB.settings.TasksQuery.Tags = []models.TaskTag{"tag1", "tag2", "tag3"}
 
-	err := RemoveTagFromSettings("tag2")
+	err := svc.Settings.RemoveTagFromSettings(context.Background(), "", "tag2")
 	if err != nil {
 		t.Errorf("RemoveTagFromSettings failed: %v", err)
 	}
@@ -82,10 +86,11 @@ func Test_RemoveTagFromSettings_Success(t *testing.T) {
 }
 
 func Test_ToggleSorting_NewColumn(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	settings := mockDB.settings
 
-	err := ToggleSorting(settings, models.Priority, models.Asc)
+	err := svc.Settings.ToggleSorting(context.Background(), settings, models.Priority, models.Asc)
 	if err != nil {
 		t.Errorf("ToggleSorting failed: %v", err)
 	}
@@ -100,12 +105,13 @@ func Test_ToggleSorting_NewColumn(t *testing.T) {
 }
 
 func Test_ToggleSorting_SameColumn(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	settings := mockDB.settings
 	settings.TasksQuery.SortColumn = models.Priority
 	settings.TasksQuery.SortDirection = models.Desc
 
-	err := ToggleSorting(settings, models.Priority, models.Asc)
+	err := svc.Settings.ToggleSorting(context.Background(), settings, models.Priority, models.Asc)
 	if err != nil {
 		t.Errorf("ToggleSorting failed: %v", err)
 	}
@@ -120,9 +126,10 @@ func Test_ToggleSorting_SameColumn(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_CompletedToday(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_COMPLETED_TODAY)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_COMPLETED_TODAY)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
@@ -150,9 +157,10 @@ func Test_ApplyPreparedQuery_CompletedToday(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_CompletedYesterday(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_COMPLETED_YESTERDAY)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_COMPLETED_YESTERDAY)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
@@ -180,14 +188,15 @@ func Test_ApplyPreparedQuery_CompletedYesterday(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_Reset(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	mockDB.settings.TasksQuery.Tags = []models.TaskTag{"tag1", "tag2"}
 	mockDB.settings.TasksQuery.FilterCompleted = false
 	mockDB.settings.TasksQuery.FilterIncompleted = true
 	mockDB.settings.TasksQuery.FilterWip = true
 	mockDB.settings.TasksQuery.SortColumn = models.Priority
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_RESET)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_RESET)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
@@ -214,15 +223,16 @@ func Test_ApplyPreparedQuery_Reset(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_CompletedThisWeek(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_COMPLETED_THIS_WEEK)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_COMPLETED_THIS_WEEK)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
 
 	q := mockDB.settings.TasksQuery
-	mondayMidnight := thisMonday()
+	mondayMidnight := thisMonday(time.Now())
 	nextMonday := mondayMidnight.AddDate(0, 0, 7)
 
 	if !q.CompletedFrom.Equal(mondayMidnight) {
@@ -243,15 +253,16 @@ func Test_ApplyPreparedQuery_CompletedThisWeek(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_CompletedLastWeek(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_COMPLETED_LAST_WEEK)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_COMPLETED_LAST_WEEK)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
 
 	q := mockDB.settings.TasksQuery
-	previousMonday := thisMonday().AddDate(0, 0, -7)
+	previousMonday := thisMonday(time.Now()).AddDate(0, 0, -7)
 
 	if !q.CompletedFrom.Equal(previousMonday) {
 		t.Errorf("CompletedFrom not set correctly, got %v, want %v", q.CompletedFrom, previousMonday)
@@ -267,9 +278,10 @@ func Test_ApplyPreparedQuery_CompletedLastWeek(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_CompletedLastTwoWeeks(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 
-	err := ApplyPreparedQuery(consts.PREPARED_QUERY_COMPLETED_LAST_TWO_WEEKS)
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", consts.PREPARED_QUERY_COMPLETED_LAST_TWO_WEEKS)
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery failed: %v", err)
 	}
@@ -292,10 +304,11 @@ func Test_ApplyPreparedQuery_CompletedLastTwoWeeks(t *testing.T) {
 }
 
 func Test_ApplyPreparedQuery_InvalidQueryName(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	originalSettings := mockDB.settings
 
-	err := ApplyPreparedQuery("invalid-query-name")
+	err := svc.Settings.ApplyPreparedQuery(context.Background(), "", "invalid-query-name")
 	if err != nil {
 		t.Errorf("ApplyPreparedQuery should not return error for invalid query name, got: %v", err)
 	}
@@ -307,7 +320,8 @@ func Test_ApplyPreparedQuery_InvalidQueryName(t *testing.T) {
 }
 
 func Test_UpdateUserSettings_Success(t *testing.T) {
-	mockDB := setupUserSettingsTestDB()
+	t.Parallel()
+	svc, mockDB := setupUserSettingsTestDB()
 	newSettings := models.Settings{
 		Id: SETTINGS_ID,
 		TasksQuery: models.TasksQuery{
@@ -317,7 +331,7 @@ func Test_UpdateUserSettings_Success(t *testing.T) {
 		},
 	}
 
-	err := UpdateUserSettings(newSettings)
+	err := svc.Settings.UpdateUserSettings(context.Background(), newSettings)
 	if err != nil {
 		t.Errorf("UpdateUserSettings failed: %v", err)
 	}
@@ -337,3 +351,120 @@ func Test_UpdateUserSettings_Success(t *testing.T) {
 		t.Error("Tags were not updated correctly")
 	}
 }
+
+func Test_PreparedQuery_DaylightSavingTime(t *testing.T) {
+	t.Parallel()
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		now    time.Time
+		from   time.Time
+		length time.Duration
+	}{
+		{"today, clocks forward", consts.PREPARED_QUERY_COMPLETED_TODAY, time.Date(2025, 3, 9, 23, 30, 0, 0, newYork), time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), 23 * time.Hour},
+		{"today, clocks back", consts.PREPARED_QUERY_COMPLETED_TODAY, time.Date(2025, 11, 2, 0, 30, 0, 0, newYork), time.Date(2025, 11, 2, 0, 0, 0, 0, newYork), 25 * time.Hour},
+		{"yesterday, clocks forward", consts.PREPARED_QUERY_COMPLETED_YESTERDAY, time.Date(2025, 3, 31, 0, 15, 0, 0, berlin), time.Date(2025, 3, 30, 0, 0, 0, 0, berlin), 23 * time.Hour},
+		{"yesterday, clocks back", consts.PREPARED_QUERY_COMPLETED_YESTERDAY, time.Date(2025, 10, 27, 8, 0, 0, 0, berlin), time.Date(2025, 10, 26, 0, 0, 0, 0, berlin), 25 * time.Hour},
+		{"this week, clocks forward on Sunday", consts.PREPARED_QUERY_COMPLETED_THIS_WEEK, time.Date(2025, 3, 9, 12, 0, 0, 0, newYork), time.Date(2025, 3, 3, 0, 0, 0, 0, newYork), 7*24*time.Hour - time.Hour},
+		{"this week, clocks back on Sunday", consts.PREPARED_QUERY_COMPLETED_THIS_WEEK, time.Date(2025, 10, 26, 23, 0, 0, 0, berlin), time.Date(2025, 10, 20, 0, 0, 0, 0, berlin), 7*24*time.Hour + time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := preparedQuery(tt.query, models.TasksQuery{}, tt.now)
+			if !q.CompletedFrom.Equal(tt.from) {
+				t.Errorf("expected CompletedFrom %v, got %v", tt.from, q.CompletedFrom)
+			}
+			if got := q.CompletedTo.Sub(q.CompletedFrom); got != tt.length {
+				t.Errorf("expected the period to last %v, got %v", tt.length, got)
+			}
+			if q.CompletedTo.In(tt.now.Location()).Hour() != 0 {
+				t.Errorf("expected the period to end at midnight, got %v", q.CompletedTo)
+			}
+		})
+	}
+}
+
+func Test_PreparedQuery_CompletedTodayAroundMidnight(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := setupMemDB(t)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the times are given in UTC, where the evening in New York is the next day
+	for id, completed := range map[string]time.Time{
+		"evening":      time.Date(2025, 3, 10, 3, 30, 0, 0, time.UTC),
+		"next-day":     time.Date(2025, 3, 10, 4, 30, 0, 0, time.UTC),
+		"day-before":   time.Date(2025, 3, 9, 4, 30, 0, 0, time.UTC),
+		"after-dawn":   time.Date(2025, 3, 9, 5, 30, 0, 0, time.UTC),
+		"not-done-yet": models.NOT_COMPLETED,
+	} {
+		if err := svc.store.SaveTask(ctx, models.Task{Id: id, Completed: completed}); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+
+	q := preparedQuery(consts.PREPARED_QUERY_COMPLETED_TODAY, models.TasksQuery{}, time.Date(2025, 3, 9, 23, 45, 0, 0, newYork))
+	q.SortDirection = models.Asc
+	tasks, err := svc.Tasks.FindTasks(ctx, q)
+	if err != nil {
+		t.Fatalf("FindTasks failed: %v", err)
+	}
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.Id)
+	}
+	if want := []string{"after-dawn", "evening"}; !slices.Equal(got, want) {
+		t.Errorf("expected the tasks completed on 2025-03-09 in New York %v, got %v", want, got)
+	}
+}
+
+func Test_Location_TimeZoneOfTheUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := setupMemDB(t)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetTimeZone(tokyo)
+
+	if loc, err := svc.Settings.Location(ctx, "alice"); err != nil || loc != tokyo {
+		t.Errorf("expected the default time zone, got %v, %v", loc, err)
+	}
+	if err := svc.Settings.SetTimeZone(ctx, "alice", "Mars/Olympus_Mons"); !errors.Is(err, ErrInvalidTimeZone) {
+		t.Errorf("SetTimeZone(%q): expected ErrInvalidTimeZone, got %v", "Mars/Olympus_Mons", err)
+	}
+	if err := svc.Settings.SetTimeZone(ctx, "alice", "Europe/Berlin"); err != nil {
+		t.Fatalf("SetTimeZone failed: %v", err)
+	}
+	if loc, err := svc.Settings.Location(ctx, "alice"); err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("expected the time zone of alice, got %v, %v", loc, err)
+	}
+	if loc, err := svc.Settings.Location(ctx, "bob"); err != nil || loc != tokyo {
+		t.Errorf("expected bob to keep the default time zone, got %v, %v", loc, err)
+	}
+
+	// the days of the prepared queries start at midnight in the time zone of the user
+	if err := svc.Settings.ApplyPreparedQuery(ctx, "alice", consts.PREPARED_QUERY_COMPLETED_TODAY); err != nil {
+		t.Fatalf("ApplyPreparedQuery failed: %v", err)
+	}
+	s, err := svc.Settings.FindUserSettings(ctx, "alice")
+	if err != nil {
+		t.Fatalf("FindUserSettings failed: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if from := s.TasksQuery.CompletedFrom.In(berlin); from.Hour() != 0 || from.Minute() != 0 {
+		t.Errorf("expected the day to start at midnight in Berlin, got %v", from)
+	}
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inaryzen/priotasks/db"
+	"github.com/inaryzen/priotasks/models"
+)
+
+const (
+	WEBHOOK_HEADER_EVENT     = "X-Priotasks-Event"
+	WEBHOOK_HEADER_DELIVERY  = "X-Priotasks-Delivery"
+	WEBHOOK_HEADER_SIGNATURE = "X-Priotasks-Signature"
+	WEBHOOK_SIGNATURE_PREFIX = "sha256="
+	WEBHOOK_USER_AGENT       = "priotasks-webhook"
+
+	WEBHOOK_MAX_ATTEMPTS      = 5
+	WEBHOOK_DELIVERY_LOG_SIZE = 500
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// webhookDispatcher sends the requests in the background so that saving a task never waits
+// for a receiver. Failed attempts are retried with exponential backoff.
+type webhookDispatcher struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	pending     sync.WaitGroup
+	store       db.Db // where the deliveries are logged
+}
+
+func newWebhookDispatcher(store db.Db) *webhookDispatcher {
+	return &webhookDispatcher{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: WEBHOOK_MAX_ATTEMPTS,
+		backoff:     webhookBackoff,
+		store:       store,
+	}
+}
+
+// webhookBackoff is the delay after the failed attempt: 1s, 2s, 4s, ...
+func webhookBackoff(attempt int) time.Duration {
+	return time.Second << (attempt - 1)
+}
+
+// WebhookPayload is the JSON body of every webhook request
+type WebhookPayload struct {
+	Event            models.WebhookEvent `json:"event"`
+	Timestamp        time.Time           `json:"timestamp"`
+	Task             WebhookTask         `json:"task"`
+	PreviousPriority string              `json:"previousPriority,omitempty"`
+}
+
+type WebhookTask struct {
+	Id        string     `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Priority  string     `json:"priority"`
+	Impact    string     `json:"impact"`
+	Cost      string     `json:"cost"`
+	Fun       string     `json:"fun"`
+	Wip       bool       `json:"wip"`
+	Planned   bool       `json:"planned"`
+	Created   time.Time  `json:"created"`
+	Updated   time.Time  `json:"updated"`
+	Completed *time.Time `json:"completed,omitempty"`
+	Tags      []string   `json:"tags"`
+	Owner     string     `json:"owner,omitempty"`
+	Project   string     `json:"project,omitempty"`
+	Assignee  string     `json:"assignee,omitempty"`
+}
+
+func newWebhookTask(t models.Task, tags []models.TaskTag) WebhookTask {
+	result := WebhookTask{
+		Id:       t.Id,
+		Title:    t.Title,
+		Content:  t.Content,
+		Priority: t.Priority.ToStr(),
+		Impact:   t.Impact.ToHumanString(),
+		Cost:     t.Cost.ToHumanString(),
+		Fun:      t.Fun.ToHumanString(),
+		Wip:      t.Wip,
+		Planned:  t.Planned,
+		Created:  t.Created,
+		Updated:  t.Updated,
+		Tags:     []string{},
+		Owner:    t.Owner,
+		Project:  t.Project,
+		Assignee: t.Assignee,
+	}
+	if t.IsCompleted() {
+		completed := t.Completed
+		result.Completed = &completed
+	}
+	for _, tag := range tags {
+		result.Tags = append(result.Tags, string(tag))
+	}
+	return result
+}
+
+// SignWebhookPayload returns the value of the signature header: the hex HMAC-SHA256 of the
+// body keyed with the secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return WEBHOOK_SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (svc *WebhookService) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	return svc.store.Webhooks(ctx)
+}
+
+// CreateWebhook validates and saves a new subscription
+func (svc *WebhookService) CreateWebhook(ctx context.Context, rawUrl string, events []models.WebhookEvent, tags []models.TaskTag, secret string) (models.Webhook, error) {
+	u, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.Webhook{}, fmt.Errorf("%w: the URL must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(events) == 0 {
+		return models.Webhook{}, fmt.Errorf("%w: select at least one event", ErrInvalidWebhook)
+	}
+	for _, e := range events {
+		if !slices.Contains(models.WEBHOOK_EVENTS, e) {
+			return models.Webhook{}, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+
+	h := models.Webhook{
+		Id:      uuid.New().String(),
+		Url:     u.String(),
+		Events:  events,
+		Tags:    tags,
+		Secret:  secret,
+		Created: time.Now(),
+	}
+	if err := svc.store.SaveWebhook(ctx, h); err != nil {
+		return h, fmt.Errorf("CreateWebhook: %w", err)
+	}
+	return h, nil
+}
+
+func (svc *WebhookService) DeleteWebhook(ctx context.Context, webhookId string) error {
+	return svc.store.DeleteWebhook(ctx, webhookId)
+}
+
+func (svc *WebhookService) WebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	return svc.store.WebhookDeliveries(ctx, limit)
+}
+
+// taskWebhookEvents compares the task with its previous state, nil for a new task
+func taskWebhookEvents(prev *models.Task, task models.Task) []models.WebhookEvent {
+	if prev == nil {
+		return []models.WebhookEvent{models.WebhookTaskCreated}
+	}
+	var result []models.WebhookEvent
+	if !prev.IsCompleted() && task.IsCompleted() {
+		result = append(result, models.WebhookTaskCompleted)
+	}
+	if prev.Priority != task.Priority {
+		result = append(result, models.WebhookTaskReprioritized)
+	}
+	return result
+}
+
+// fireTaskWebhooks sends the lifecycle events of the saved task to the matching webhooks.
+// The tags of the task are loaded when nil. Failures are logged, they never fail the save.
+func (svc *WebhookService) fireTaskWebhooks(ctx context.Context, prev *models.Task, task models.Task, tags []models.TaskTag) {
+	pfx := "fireTaskWebhooks:"
+	events := taskWebhookEvents(prev, task)
+	if len(events) == 0 {
+		return
+	}
+	hooks, err := svc.store.Webhooks(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, pfx, "task_id", task.Id, "error", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	if tags == nil {
+		if tags, err = svc.store.TaskTags(ctx, task.Id); err != nil {
+			slog.ErrorContext(ctx, pfx, "task_id", task.Id, "error", err)
+			return
+		}
+	}
+
+	for _, event := range events {
+		payload := WebhookPayload{
+			Event:     event,
+			Timestamp: time.Now().UTC(),
+			Task:      newWebhookTask(task, tags),
+		}
+		if event == models.WebhookTaskReprioritized {
+			payload.PreviousPriority = prev.Priority.ToStr()
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			slog.ErrorContext(ctx, pfx, "task_id", task.Id, "error", err)
+			continue
+		}
+		for _, h := range hooks {
+			if h.Matches(event, tags) {
+				db.AfterCommit(ctx, func(ctx context.Context) {
+					svc.dispatcher.send(ctx, h, event, task.Id, body)
+				})
+			}
+		}
+	}
+}
+
+// send delivers the body in the background; the delivery outlives the request, its log
+// lines keep the request id
+func (d *webhookDispatcher) send(ctx context.Context, h models.Webhook, event models.WebhookEvent, taskId string, body []byte) {
+	ctx = context.WithoutCancel(ctx)
+	d.pending.Add(1)
+	go func() {
+		defer d.pending.Done()
+		d.deliver(ctx, h, event, taskId, body)
+	}()
+}
+
+// wait blocks until the pending deliveries are done, including their retries, or until
+// ctx is done
+func (d *webhookDispatcher) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitWebhooks waits for the deliveries still sending or retrying, which log their attempts
+// to the store, so that the store is closed after them. It gives up when ctx is done.
+func (svc *Services) WaitWebhooks(ctx context.Context) error {
+	if err := svc.webhooks.dispatcher.wait(ctx); err != nil {
+		return fmt.Errorf("WaitWebhooks: %w", err)
+	}
+	return nil
+}
+
+func (d *webhookDispatcher) deliver(ctx context.Context, h models.Webhook, event models.WebhookEvent, taskId string, body []byte) {
+	deliveryId := uuid.New().String()
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		delivery := models.WebhookDelivery{
+			DeliveryId: deliveryId,
+			WebhookId:  h.Id,
+			Url:        h.Url,
+			Event:      event,
+			TaskId:     taskId,
+			Attempt:    attempt,
+			Created:    time.Now(),
+		}
+		retry := d.attempt(h, &delivery, body)
+		delivery.Duration = time.Since(delivery.Created)
+		d.logDelivery(ctx, delivery)
+		if !retry {
+			return
+		}
+		if attempt < d.maxAttempts {
+			time.Sleep(d.backoff(attempt))
+		}
+	}
+	slog.WarnContext(ctx, "webhook: giving up on delivery", "delivery_id", deliveryId, "event", event, "url", h.Url, "attempts", d.maxAttempts)
+}
+
+// attempt makes one request and reports whether it should be retried: on network errors,
+// 429 and 5xx responses
+func (d *webhookDispatcher) attempt(h models.Webhook, delivery *models.WebhookDelivery, body []byte) bool {
+	req, err := http.NewRequest(http.MethodPost, h.Url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", WEBHOOK_USER_AGENT)
+	req.Header.Set(WEBHOOK_HEADER_EVENT, string(delivery.Event))
+	req.Header.Set(WEBHOOK_HEADER_DELIVERY, delivery.DeliveryId)
+	if h.Secret != "" {
+		req.Header.Set(WEBHOOK_HEADER_SIGNATURE, SignWebhookPayload(h.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return true
+	}
+	resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+	if delivery.Succeeded() {
+		return false
+	}
+	delivery.Error = resp.Status
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func (d *webhookDispatcher) logDelivery(ctx context.Context, delivery models.WebhookDelivery) {
+	slog.DebugContext(ctx, "webhook: delivery", "delivery_id", delivery.DeliveryId, "attempt", delivery.Attempt, "status", delivery.StatusCode, "error", delivery.Error)
+	if err := d.store.SaveWebhookDelivery(ctx, delivery); err != nil {
+		slog.ErrorContext(ctx, "webhook: failed to record the delivery", "error", err)
+		return
+	}
+	if err := d.store.PruneWebhookDeliveries(ctx, WEBHOOK_DELIVERY_LOG_SIZE); err != nil {
+		slog.ErrorContext(ctx, "webhook: failed to record the delivery", "error", err)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inaryzen/priotasks/models"
+)
+
+type receivedWebhook struct {
+	header  http.Header
+	body    []byte
+	payload WebhookPayload
+}
+
+// webhookReceiver records the requests and answers with the statuses in order, then 200
+func webhookReceiver(t *testing.T, svc *Services, statuses ...int) (*httptest.Server, func() []receivedWebhook) {
+	var mu sync.Mutex
+	var received []receivedWebhook
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload %s: %v", body, err)
+		}
+		mu.Lock()
+		status := http.StatusOK
+		if len(received) < len(statuses) {
+			status = statuses[len(received)]
+		}
+		received = append(received, receivedWebhook{r.Header.Clone(), body, payload})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []receivedWebhook {
+		svc.Webhooks.dispatcher.wait(context.Background())
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(received)
+	}
+}
+
+func fastWebhookRetries(t *testing.T, svc *Services) {
+	orig := svc.Webhooks.dispatcher.backoff
+	svc.Webhooks.dispatcher.backoff = func(int) time.Duration { return time.Millisecond }
+	t.Cleanup(func() { svc.Webhooks.dispatcher.backoff = orig })
+}
+
+func findTaskByTitle(t *testing.T, svc *Services, title string) models.Task {
+	t.Helper()
+	tasks, err := svc.store.Tasks(context.Background())
+	if err != nil {
+		t.Fatalf("Tasks failed: %v", err)
+	}
+	for _, task := range tasks {
+		if task.Title == title {
+			return task
+		}
+	}
+	t.Fatalf("task %q not found", title)
+	return models.Task{}
+}
+
+func Test_Webhooks_SignedAndFilteredByTag(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	srv, received := webhookReceiver(t, svc)
+	if err := svc.Tags.SaveTag(context.Background(), "", "work"); err != nil {
+		t.Fatalf("SaveTag failed: %v", err)
+	}
+	if _, err := svc.Webhooks.CreateWebhook(context.Background(), srv.URL, models.WEBHOOK_EVENTS, []models.TaskTag{"work"}, "s3cret"); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "Tagged"}, []models.TaskTag{"work"}); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "Untagged"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+
+	got := received()
+	if len(got) != 1 {
+		t.Fatalf("expected only the tagged task to be sent, got %d requests", len(got))
+	}
+	r := got[0]
+	if r.payload.Event != models.WebhookTaskCreated || r.payload.Task.Title != "Tagged" {
+		t.Errorf("unexpected payload: %+v", r.payload)
+	}
+	if r.header.Get(WEBHOOK_HEADER_EVENT) != string(models.WebhookTaskCreated) {
+		t.Errorf("unexpected event header: %q", r.header.Get(WEBHOOK_HEADER_EVENT))
+	}
+	if sig := r.header.Get(WEBHOOK_HEADER_SIGNATURE); sig != SignWebhookPayload("s3cret", r.body) {
+		t.Errorf("signature %q does not match the body", sig)
+	}
+
+	tagged := findTaskByTitle(t, svc, "Tagged")
+	tagged.Priority = models.PriorityUrgent
+	tagged.Completed = time.Now()
+	if err := svc.Tasks.UpdateTask(context.Background(), tagged, []models.TaskTag{"work"}); err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	got = received()[1:]
+	var events []models.WebhookEvent
+	for _, r := range got {
+		events = append(events, r.payload.Event)
+	}
+	slices.Sort(events)
+	if !slices.Equal(events, []models.WebhookEvent{models.WebhookTaskCompleted, models.WebhookTaskReprioritized}) {
+		t.Errorf("unexpected events after the update: %v", events)
+	}
+	for _, r := range got {
+		if r.payload.Event == models.WebhookTaskReprioritized && r.payload.PreviousPriority != models.PriorityLow.ToStr() {
+			t.Errorf("unexpected previous priority: %q", r.payload.PreviousPriority)
+		}
+	}
+}
+
+func Test_Webhooks_RetriedAndLogged(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	fastWebhookRetries(t, svc)
+	srv, received := webhookReceiver(t, svc, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	if _, err := svc.Webhooks.CreateWebhook(context.Background(), srv.URL, []models.WebhookEvent{models.WebhookTaskCreated}, nil, ""); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "New"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+
+	got := received()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(got))
+	}
+	if got[0].header.Get(WEBHOOK_HEADER_SIGNATURE) != "" {
+		t.Error("an unsigned webhook should not send a signature")
+	}
+	if got[0].header.Get(WEBHOOK_HEADER_DELIVERY) != got[2].header.Get(WEBHOOK_HEADER_DELIVERY) {
+		t.Error("retries should keep the delivery id")
+	}
+
+	log, err := svc.Webhooks.WebhookDeliveries(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("WebhookDeliveries failed: %v", err)
+	}
+	if len(log) != 3 {
+		t.Fatalf("expected 3 logged attempts, got %d", len(log))
+	}
+	if !log[0].Succeeded() || log[0].Attempt != 3 {
+		t.Errorf("the latest attempt should be the successful third: %+v", log[0])
+	}
+	if log[2].Succeeded() || log[2].StatusCode != http.StatusInternalServerError {
+		t.Errorf("the first attempt should have failed with 500: %+v", log[2])
+	}
+}
+
+func Test_Webhooks_ClientErrorIsNotRetried(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	fastWebhookRetries(t, svc)
+	srv, received := webhookReceiver(t, svc, http.StatusBadRequest)
+	if _, err := svc.Webhooks.CreateWebhook(context.Background(), srv.URL, []models.WebhookEvent{models.WebhookTaskCreated}, nil, ""); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "New"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+	if got := received(); len(got) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(got))
+	}
+}
+
+func Test_WaitWebhooks_WaitsForRetries(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	svc.Webhooks.dispatcher.backoff = func(int) time.Duration { return 100 * time.Millisecond }
+	srv, _ := webhookReceiver(t, svc, http.StatusServiceUnavailable)
+	if _, err := svc.Webhooks.CreateWebhook(context.Background(), srv.URL, []models.WebhookEvent{models.WebhookTaskCreated}, nil, ""); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if err := svc.Tasks.SaveNewTask(context.Background(), models.Task{Title: "New"}, nil); err != nil {
+		t.Fatalf("SaveNewTask failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := svc.WaitWebhooks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to stop waiting for the retry at the deadline, got %v", err)
+	}
+
+	if err := svc.WaitWebhooks(context.Background()); err != nil {
+		t.Fatalf("WaitWebhooks failed: %v", err)
+	}
+	log, err := svc.Webhooks.WebhookDeliveries(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("WebhookDeliveries failed: %v", err)
+	}
+	if len(log) != 2 || !log[0].Succeeded() {
+		t.Errorf("expected the retry to be logged before WaitWebhooks returns, got %+v", log)
+	}
+}
+
+func Test_CreateWebhook_Validation(t *testing.T) {
+	t.Parallel()
+	svc := setupMemDB(t)
+	tests := []struct {
+		url    string
+		events []models.WebhookEvent
+	}{
+		{"ftp://example.com", models.WEBHOOK_EVENTS},
+		{"/relative", models.WEBHOOK_EVENTS},
+		{"https://example.com", nil},
+		{"https://example.com", []models.WebhookEvent{"task.unknown"}},
+	}
+	for _, tt := range tests {
+		if _, err := svc.Webhooks.CreateWebhook(context.Background(), tt.url, tt.events, nil, ""); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("CreateWebhook(%q, %v): expected ErrInvalidWebhook, got %v", tt.url, tt.events, err)
+		}
+	}
+}